@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"go1090/internal/app"
+	"go1090/internal/rtlsdr"
 )
 
 func main() {
@@ -37,11 +38,106 @@ Example usage:
 	rootCmd.Flags().Uint32VarP(&config.Frequency, "frequency", "f", app.DefaultFrequency, "Frequency to tune to (Hz)")
 	rootCmd.Flags().Uint32VarP(&config.SampleRate, "sample-rate", "s", app.DefaultSampleRate, "Sample rate (Hz)")
 	rootCmd.Flags().IntVarP(&config.Gain, "gain", "g", app.DefaultGain, "Gain setting (0 for auto)")
+	rootCmd.Flags().Float64Var(&config.Latitude, "lat", 0, "Receiver latitude (decimal degrees)")
+	rootCmd.Flags().Float64Var(&config.Longitude, "lon", 0, "Receiver longitude (decimal degrees)")
+	rootCmd.Flags().Float64Var(&config.LocalQNH, "local-qnh", 0, "Local QNH (mb); flag Comm-B pressure settings far from it")
 	rootCmd.Flags().IntVarP(&config.DeviceIndex, "device", "d", 0, "RTL-SDR device index")
+	rootCmd.Flags().IntVar(&config.PPM, "ppm", 0, "Frequency correction of the dongle's oscillator (ppm)")
+	rootCmd.Flags().BoolVar(&config.BiasTee, "bias-tee", false, "Power an external LNA through the antenna input")
+	rootCmd.Flags().BoolVar(&config.OffsetTuning, "offset-tuning", false, "Enable offset tuning (E4000 tuners)")
+	rootCmd.Flags().BoolVar(&config.ScanOffset, "scan-offset", false, "At startup, tune to the frequency within ±50 kHz that decodes best")
 	rootCmd.Flags().StringVarP(&config.LogDir, "log-dir", "l", "./logs", "Log directory")
 	rootCmd.Flags().BoolVarP(&config.LogRotateUTC, "utc", "u", true, "Use UTC for log rotation")
+	rootCmd.Flags().BoolVar(&config.CompressLive, "compress-live", false, "Gzip the active log file as it is written instead of on rotation")
+	rootCmd.Flags().StringVar(&config.SplitBy, "split-by", app.SplitByNone, "Split the log into daily files per aircraft or downlink format (none, icao, type)")
 	rootCmd.Flags().BoolVarP(&config.Verbose, "verbose", "v", false, "Verbose logging")
 	rootCmd.Flags().BoolVar(&config.ShowVersion, "version", false, "Show version information")
+	rootCmd.Flags().BoolVar(&config.DumpCPR, "dump-cpr", false, "Log per-aircraft CPR decoder diagnostics with the statistics")
+	rootCmd.Flags().StringVar(&config.OutputFormat, "output-format", app.OutputFormatSBS, "Output format (sbs, json, csv, geojson)")
+	rootCmd.Flags().BoolVar(&config.EmitRaw, "emit-raw-comment", false, "Trace output to raw frames: an AVR line before each SBS record, a raw field in JSON")
+	rootCmd.Flags().BoolVar(&config.PayloadHex, "payload-hex", false, "Add the raw 56-bit ME (DF17/18) and MB (DF20/21) fields to JSON output as me_hex and mb_hex")
+	rootCmd.Flags().IntVar(&config.CoordDigits, "coord-precision", app.DefaultCoordPrecision, "Decimal places for latitude/longitude in SBS and JSON output (3-8)")
+	rootCmd.Flags().StringVar(&config.TimestampSource, "timestamp-source", app.TimestampMonotonic, "Output timestamp source (monotonic, wall); wall follows system clock steps")
+	rootCmd.Flags().BoolVar(&config.PositionOnly, "positions-only", false, "Output only messages that yield a position")
+	rootCmd.Flags().BoolVar(&config.MergeFrames, "merge-position-frames", false, "Emit position messages only once the position resolves, dropping the first frame of an even/odd pair")
+	rootCmd.Flags().BoolVar(&config.NoMilitary, "exclude-military", false, "Output no messages from addresses in military blocks")
+	rootCmd.Flags().BoolVar(&config.AnonOnly, "anon-only", false, "Output only messages from addresses allocated to no state (e.g. TIS-B/ADS-R track files)")
+	rootCmd.Flags().Float64Var(&config.SNRThreshold, "snr-threshold", app.DefaultSNRThreshold, "Minimum preamble SNR (dB)")
+	rootCmd.Flags().BoolVar(&config.StrictCRC, "no-error-correction", false, "Accept only frames whose CRC validates without bit-error correction")
+	rootCmd.Flags().StringVar(&config.DemodPhases, "demod-phases", app.DemodPhasesBest, "Demodulator phase search (best, single); single uses less CPU for slightly lower yield")
+	rootCmd.Flags().BoolVar(&config.NoOverlap, "no-demod-overlap", false, "Demodulate each sample buffer on its own instead of carrying its end into the next")
+	rootCmd.Flags().BoolVar(&config.StrictDF11, "strict-df11", false, "Accept DF11 all-call replies only with interrogator code 0 or one listed in --df11-iid")
+	rootCmd.Flags().UintSliceVar(&config.DF11IIDs, "df11-iid", nil, "Additional DF11 interrogator codes accepted with --strict-df11 (e.g. 1,2)")
+	rootCmd.Flags().BoolVar(&config.Plausibility, "plausibility-filter", false, "Drop updates inconsistent with the aircraft's previous state (e.g. faster than Mach 2)")
+	rootCmd.Flags().StringVar(&config.FilterFile, "icao-filter", "", "ICAO allow/deny list file (reloaded on SIGHUP)")
+	rootCmd.Flags().StringVar(&config.TraceICAO, "trace-icao", "", "Log detailed debug output for a single aircraft (hex ICAO)")
+	rootCmd.Flags().IntVar(&config.MaxTracked, "max-tracked-aircraft", app.DefaultMaxTrackedAircraft, "Maximum aircraft kept for CPR decoding before evicting the least recently seen")
+	rootCmd.Flags().BoolVar(&config.CPRPairOnly, "require-cpr-pair", false, "Suppress positions for an aircraft until an even/odd CPR pair has been decoded")
+	rootCmd.Flags().DurationVar(&config.PosMaxAge, "position-max-age", app.DefaultPositionMaxAge, "Keep reporting the last known position this long when a frame cannot be decoded (0 to disable)")
+	rootCmd.Flags().IntVar(&config.ZoneRetryFrames, "cpr-retry-frames", app.DefaultZoneRetryFrames, "Earlier CPR frames kept per parity to pair with when an even/odd pair crosses a latitude zone (0 to disable)")
+	rootCmd.Flags().DurationVar(&config.Duration, "duration", 0, "Shut down cleanly after this long, e.g. 30s (0 to run until interrupted)")
+	rootCmd.Flags().BoolVar(&config.Stdin, "stdin", false, "Read raw 8-bit I/Q samples from standard input instead of an RTL-SDR")
+	rootCmd.Flags().StringVar(&config.IQFormat, "iq-format", app.IQFormatU8, "Sample format of --stdin input (u8, cs16, cf32)")
+	rootCmd.Flags().StringVar(&config.RawSave, "raw-save", "", "Record the raw I/Q input to this file while decoding, for replay with --stdin")
+	rootCmd.Flags().IntVar(&config.RawSaveMaxMB, "raw-save-max-mb", 0, "Stop recording --raw-save after this many MiB (0 for no limit)")
+	rootCmd.Flags().StringVar(&config.Bind, "bind", app.DefaultBindAddress, "Address network servers listen on (e.g. 127.0.0.1 for local access only)")
+	rootCmd.Flags().StringVar(&config.SBSSocket, "sbs-socket", "", "Unix domain socket path to serve the output stream on")
+	rootCmd.Flags().StringVar(&config.SBSUDP, "sbs-udp", "", "Send SBS lines as UDP datagrams to this host:port, e.g. a multicast group")
+	rootCmd.Flags().StringVar(&config.JSONUDP, "json-udp", "", "Send JSON records as UDP datagrams to this host:port, e.g. a multicast group")
+	rootCmd.Flags().IntVar(&config.HTTPPort, "http-port", 0, "HTTP port for health and data endpoints (0 to disable)")
+	rootCmd.Flags().IntVar(&config.TrackHistory, "track-history", app.DefaultTrackHistory, "Positions kept per aircraft for /data/track/<icao>.json (0 to disable)")
+	rootCmd.Flags().IntVar(&config.MLATPort, "mlat-port", 0, "TCP port serving Beast frames timestamped with the receiver sample clock for MLAT clients (0 to disable)")
+	rootCmd.Flags().IntVar(&config.ReplayBuffer, "replay-buffer", 0, "Recent lines or frames sent to each client of --sbs-socket and --mlat-port as it connects (0 to disable)")
+	rootCmd.Flags().DurationVar(&config.ReadyTimeout, "ready-timeout", app.DefaultReadyTimeout, "Report not ready after this long without preambles or messages")
+	rootCmd.Flags().DurationVar(&config.StatsPeriod, "stats-interval", app.DefaultStatsInterval, "Log processing statistics this often (0 to log only the final summary)")
+	rootCmd.Flags().StringVar(&config.StatsCSV, "stats-csv", "", "Append one row of run statistics and settings to this CSV file at shutdown")
+	rootCmd.Flags().IntVar(&config.MaxProcs, "max-procs", 0, "Most CPUs to run Go code on at once, i.e. GOMAXPROCS (0 for all)")
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "selftest",
+		Short: "Decode embedded known-good signals and report PASS/FAIL per case",
+		Long: `Synthesizes I/Q samples for embedded reference frames, runs them through
+the demodulator and decoder, and checks the ICAO address, callsign,
+altitude and position that come out. Exits non-zero if any case fails.
+No RTL-SDR is needed.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.RunSelfTest(cmd.OutOrStdout())
+		},
+	})
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "decode <hex frame>",
+		Short: "Decode a single hex Mode S frame and print its fields",
+		Long: `Checks the CRC of one frame given in hex (optionally in AVR "*...;"
+form), correcting bit errors as live decoding does, and prints the fields it
+decodes to. A position needs its even/odd CPR partner and is not resolved.
+No RTL-SDR is needed.
+
+Example usage:
+  go1090 decode 8D4840D6202CC371C32CE0576098`,
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.DecodeFrame(cmd.OutOrStdout(), args[0])
+		},
+	})
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "devices",
+		Short: "List attached RTL-SDR devices with their index and serial",
+		Long: `Lists every RTL-SDR dongle librtlsdr can see. The index is what
+--device takes.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rtlsdr.WriteDeviceTable(cmd.OutOrStdout(), rtlsdr.ListDevices())
+		},
+	})
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
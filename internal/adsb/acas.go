@@ -0,0 +1,55 @@
+package adsb
+
+// ACASRA is the TCAS/ACAS resolution advisory broadcast in an aircraft status
+// message (TC28 subtype 2)
+type ACASRA struct {
+	Active          bool     `json:"active"`           // An RA is in force against one or more threats
+	Terminated      bool     `json:"terminated"`       // The RA has just ended
+	MultipleThreats bool     `json:"multiple_threats"` // More than one threat is being resolved
+	ARA             uint16   `json:"ara"`              // Active resolution advisories, 14 bits
+	RAC             []string `json:"rac,omitempty"`    // Complements received from other aircraft
+
+	// Threat identity, either an address or an altitude/range/bearing estimate
+	ThreatHex      string   `json:"threat_hex,omitempty"`
+	ThreatAltitude *int     `json:"threat_alt,omitempty"`     // Feet
+	ThreatRange    *float64 `json:"threat_range,omitempty"`   // Nautical miles
+	ThreatBearing  *int     `json:"threat_bearing,omitempty"` // Degrees relative to own heading
+}
+
+// Resolution advisory transitions, reported on the message that shows them
+const (
+	RAEventStart = "start" // The aircraft's first RA since none was in force
+	RAEventEnd   = "end"   // The RA in force has terminated
+)
+
+// racNames are the resolution advisory complements, most significant bit first
+var racNames = [4]string{"not below", "not above", "not left", "not right"}
+
+// RACNames lists the complements set in a 4-bit RAC field
+func RACNames(rac uint8) []string {
+	var names []string
+	for i, name := range racNames {
+		if rac&(8>>i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ThreatRangeFromCode converts a 7-bit threat range code to nautical miles,
+// returning false for code 0 (no estimate). Code 127 means beyond 12.55 NM.
+func ThreatRangeFromCode(code uint8) (float64, bool) {
+	if code == 0 || code > 127 {
+		return 0, false
+	}
+	return float64(code-1) / 10, true
+}
+
+// ThreatBearingFromCode converts a 6-bit threat bearing code to degrees,
+// returning false for code 0 (no estimate) and the reserved codes 61-63
+func ThreatBearingFromCode(code uint8) (int, bool) {
+	if code == 0 || code > 60 {
+		return 0, false
+	}
+	return int(code-1) * 6, true
+}
@@ -0,0 +1,69 @@
+package adsb
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultAddressTTL is how long an address sent in the clear verifies frames
+// that overlay it on their parity, as dump1090's ICAO filter
+const DefaultAddressTTL = 60 * time.Second
+
+// AddressTable remembers the aircraft addresses recently received in the
+// clear, in DF11 all-call replies and DF17/18 extended squitters. Frames that
+// overlay the address on their parity field (AP) cannot be checked on their
+// own: their CRC residual is an address, and on noise a random one. Only a
+// residual matching a known aircraft verifies them.
+type AddressTable struct {
+	mu   sync.RWMutex
+	seen map[uint32]time.Time
+	ttl  time.Duration
+}
+
+// NewAddressTable creates a table whose addresses expire ttl after they were
+// last seen
+func NewAddressTable(ttl time.Duration) *AddressTable {
+	return &AddressTable{seen: make(map[uint32]time.Time), ttl: ttl}
+}
+
+// Add records icao as seen at the given time
+func (t *AddressTable) Add(icao uint32, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if at.After(t.seen[icao]) {
+		t.seen[icao] = at
+	}
+}
+
+// Known reports whether icao was seen within the TTL before at. A nil table
+// knows no address.
+func (t *AddressTable) Known(icao uint32, at time.Time) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	seen, ok := t.seen[icao]
+	return ok && at.Sub(seen) <= t.ttl
+}
+
+// Prune forgets the addresses that have expired by now
+func (t *AddressTable) Prune(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for icao, seen := range t.seen {
+		if now.Sub(seen) > t.ttl {
+			delete(t.seen, icao)
+		}
+	}
+}
+
+// Len returns the number of addresses held
+func (t *AddressTable) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.seen)
+}
@@ -0,0 +1,32 @@
+package adsb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAddressTable tests that addresses are known for the TTL after they
+// were last seen
+func TestAddressTable(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	table := NewAddressTable(time.Minute)
+
+	table.Add(0x4840D6, now)
+	assert.True(t, table.Known(0x4840D6, now.Add(time.Minute)))
+	assert.False(t, table.Known(0x4840D6, now.Add(time.Minute+time.Second)))
+	assert.False(t, table.Known(0x40621D, now))
+
+	// An older sighting does not shorten a newer one
+	table.Add(0x4840D6, now.Add(30*time.Second))
+	table.Add(0x4840D6, now)
+	assert.True(t, table.Known(0x4840D6, now.Add(90*time.Second)))
+
+	table.Add(0x40621D, now)
+	table.Prune(now.Add(80 * time.Second))
+	assert.Equal(t, 1, table.Len())
+
+	var none *AddressTable
+	assert.False(t, none.Known(0x4840D6, now))
+}
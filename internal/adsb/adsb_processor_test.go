@@ -1,10 +1,17 @@
 package adsb
 
 import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestNewADSBProcessor tests the NewADSBProcessor function
@@ -17,7 +24,7 @@ func TestNewADSBProcessor(t *testing.T) {
 	assert.NotNil(t, processor)
 	assert.Equal(t, sampleRate, processor.sampleRate)
 	assert.Equal(t, logger, processor.logger)
-	assert.NotNil(t, processor.aircraft)
+	assert.NotNil(t, processor.typeCounts)
 	assert.Equal(t, uint64(0), processor.messageCount)
 	assert.Equal(t, uint64(0), processor.preambleCount)
 	assert.Equal(t, uint64(0), processor.validMessages)
@@ -25,6 +32,32 @@ func TestNewADSBProcessor(t *testing.T) {
 	assert.Equal(t, uint64(0), processor.rejectedUnknown)
 }
 
+// TestNewADSBProcessor_SampleRate tests that a sample rate other than 2.4 MHz
+// is warned about, or rejected by the checked constructor
+func TestNewADSBProcessor_SampleRate(t *testing.T) {
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+
+	processor := NewADSBProcessor(2400000, logger)
+	assert.NotNil(t, processor)
+	assert.Empty(t, logs.String())
+
+	processor = NewADSBProcessor(2000000, logger)
+	assert.NotNil(t, processor, "an unsupported rate still gets a processor")
+	assert.Contains(t, logs.String(), "level=warning")
+	assert.Contains(t, logs.String(), "unsupported sample rate 2000000 Hz")
+
+	processor, err := NewADSBProcessorChecked(2400000, logger)
+	require.NoError(t, err)
+	assert.NotNil(t, processor)
+
+	processor, err = NewADSBProcessorChecked(2000000, logger)
+	require.Error(t, err)
+	assert.Nil(t, processor)
+	assert.Contains(t, err.Error(), "requires 2400000 Hz")
+}
+
 // TestCalculateMagnitude tests the calculateMagnitude function
 func TestCalculateMagnitude(t *testing.T) {
 	processor := NewADSBProcessor(2400000, logrus.New())
@@ -221,27 +254,65 @@ func TestScoreMessage(t *testing.T) {
 		{
 			name: "Valid DF 17 message",
 			message: &ADSBMessage{
-				Valid: true,
-				Data:  [14]byte{0x8D, 0x48, 0x44, 0x12}, // DF=17
+				Valid:   true,
+				CRCType: "valid",
+				Data:    [14]byte{0x8D, 0x48, 0x44, 0x12, 0x58}, // DF=17, TC=11
 			},
-			expected: 1500, // 1000 + 500 for valid DF
+			expected: 1600, // 1000 + 500 for valid DF + 100 for valid type code
+		},
+		{
+			name: "DF 17 message without type code",
+			message: &ADSBMessage{
+				Valid:   true,
+				CRCType: "valid",
+				Data:    [14]byte{0x8D, 0x48, 0x44, 0x12}, // DF=17, TC=0
+			},
+			expected: 1450, // 1000 + 500 for valid DF - 50 for invalid type code
 		},
 		{
 			name: "Valid DF 4 message",
 			message: &ADSBMessage{
-				Valid: true,
-				Data:  [14]byte{0x20, 0x48, 0x44, 0x12}, // DF=4
+				Valid:   true,
+				CRCType: "valid",
+				Data:    [14]byte{0x20, 0x48, 0x44, 0x12}, // DF=4
 			},
 			expected: 1500, // 1000 + 500 for valid DF
 		},
 		{
-			name: "Invalid DF",
+			name: "Single bit corrected",
+			message: &ADSBMessage{
+				Valid:   true,
+				CRCType: "corrected-1",
+				Data:    [14]byte{0x20, 0x48, 0x44, 0x12}, // DF=4
+			},
+			expected: 1250, // 750 + 500 for valid DF
+		},
+		{
+			name: "Two bits corrected",
+			message: &ADSBMessage{
+				Valid:   true,
+				CRCType: "corrected-2",
+				Data:    [14]byte{0x20, 0x48, 0x44, 0x12}, // DF=4
+			},
+			expected: 1000, // 500 + 500 for valid DF
+		},
+		{
+			name: "Unknown CRC type",
 			message: &ADSBMessage{
 				Valid: true,
-				Data:  [14]byte{0x78, 0x48, 0x44, 0x12}, // DF=15 (invalid)
+				Data:  [14]byte{0x20, 0x48, 0x44, 0x12},
 			},
 			expected: -1,
 		},
+		{
+			name: "Invalid DF",
+			message: &ADSBMessage{
+				Valid:   true,
+				CRCType: "valid",
+				Data:    [14]byte{0x78, 0x48, 0x44, 0x12}, // DF=15 (invalid)
+			},
+			expected: 800, // 1000 - 200 penalty for unknown DF
+		},
 	}
 
 	for _, tt := range tests {
@@ -310,6 +381,13 @@ func TestADSBMessage_GetICAO(t *testing.T) {
 			},
 			expected: 0x484400, // Will read partial data
 		},
+		{
+			name: "DF11 all-call reply",
+			message: &ADSBMessage{
+				Data: [14]byte{0x5D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3},
+			},
+			expected: 0x4840D6, // In the clear, parity carries the interrogator
+		},
 	}
 
 	for _, tt := range tests {
@@ -320,6 +398,95 @@ func TestADSBMessage_GetICAO(t *testing.T) {
 	}
 }
 
+// TestADSBMessage_GetICAO_AddressParity tests address recovery from the
+// address/parity field of surveillance and Comm-B replies
+func TestADSBMessage_GetICAO_AddressParity(t *testing.T) {
+	tests := []struct {
+		name     string
+		frame    string
+		expected uint32
+	}{
+		{name: "DF20 BDS 5,0", frame: "A000139381951536E024D4CCF6B5", expected: 0x3C4DD2},
+		{name: "DF20 BDS 4,0", frame: "A00004128F39F91A7E27C46ADC21", expected: 0x48507F},
+		{name: "DF20 BDS 2,0", frame: "A000029C85E42F313000007047D3", expected: 0x4243D0},
+		{name: "DF21 BDS 6,0", frame: "A8001EBCFFFB23286004A73F6A5B", expected: 0x48548E},
+		{name: "DF4 on the ground", frame: "2140621DC08AD3", expected: 0x40621D},
+		{name: "DF5 squawk 7700", frame: "28000AAA0AC6D4", expected: 0x40621D},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.frame)
+			require.NoError(t, err)
+			message := &ADSBMessage{}
+			copy(message.Data[:], data)
+
+			result := message.GetICAO()
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+// TestValidateMessage_AddressParity tests that surveillance and Comm-B
+// replies validate only when their overlaid address is a known aircraft
+func TestValidateMessage_AddressParity(t *testing.T) {
+	now := time.Now()
+	known := NewAddressTable(DefaultAddressTTL)
+	known.Add(0x40621D, now)
+	known.Add(0x3C4DD2, now)
+
+	for _, frame := range []string{"28000AAA0AC6D4", "2140621DC08AD3", "A000139381951536E024D4CCF6B5"} {
+		data, err := hex.DecodeString(frame)
+		require.NoError(t, err)
+		msg := &ADSBMessage{Timestamp: now}
+		copy(msg.Data[:], data)
+
+		ValidateMessage(msg, true)
+		assert.False(t, msg.Valid, "%s without known addresses", frame)
+
+		ValidateMessageKnown(msg, true, known)
+		assert.True(t, msg.Valid, frame)
+		assert.Equal(t, "valid", msg.CRCType, frame)
+
+		// Once the address has expired
+		msg.Timestamp = now.Add(DefaultAddressTTL + time.Second)
+		ValidateMessageKnown(msg, true, known)
+		assert.False(t, msg.Valid, "%s after the TTL", frame)
+	}
+}
+
+// TestProcessIQSamples_AddressParity tests that a surveillance reply is
+// decoded once its aircraft has been heard in the clear
+func TestProcessIQSamples_AddressParity(t *testing.T) {
+	position := []byte{0x8D, 0x40, 0x62, 0x1D, 0x58, 0xC3, 0x82, 0xD6, 0x90, 0xC8, 0xAC, 0x28, 0x63, 0xA7}
+	squawk := []byte{0x28, 0x00, 0x0A, 0xAA, 0x0A, 0xC6, 0xD4}
+
+	decode := func(frames ...[]byte) []*ADSBMessage {
+		signal := modulateFrames(frames...)
+		iq := make([]complex128, len(signal))
+		for i, v := range signal {
+			iq[i] = complex(float64(v)/1000, 0)
+		}
+		processor := NewADSBProcessor(2400000, logrus.New())
+
+		var valid []*ADSBMessage
+		for _, msg := range processor.ProcessIQSamples(iq, time.Now()) {
+			if msg.Valid {
+				valid = append(valid, msg)
+			}
+		}
+		return valid
+	}
+
+	assert.Empty(t, decode(squawk), "unknown address")
+
+	messages := decode(position, squawk)
+	require.Len(t, messages, 2)
+	assert.Equal(t, uint8(5), messages[1].GetDF())
+	assert.Equal(t, uint32(0x40621D), messages[1].GetICAO())
+	assert.Equal(t, squawk, messages[1].Frame())
+}
+
 // TestADSBMessage_GetDF tests the GetDF method
 func TestADSBMessage_GetDF(t *testing.T) {
 	tests := []struct {
@@ -355,6 +522,20 @@ func TestADSBMessage_GetDF(t *testing.T) {
 			},
 			expected: 11,
 		},
+		{
+			name: "DF 24",
+			message: &ADSBMessage{
+				Data: [14]byte{0xC0, 0x48, 0x44, 0x12}, // 0xC0 = 11000000, DF = 24
+			},
+			expected: 24,
+		},
+		{
+			name: "DF 24 with control bits set",
+			message: &ADSBMessage{
+				Data: [14]byte{0xF5, 0x48, 0x44, 0x12}, // 0xF5 = 11110101, only the top two bits select DF 24
+			},
+			expected: 24,
+		},
 	}
 
 	for _, tt := range tests {
@@ -410,6 +591,79 @@ func TestADSBMessage_GetTypeCode(t *testing.T) {
 	}
 }
 
+// TestADSBMessage_IsSpontaneous tests telling squitters from interrogation
+// replies by downlink format
+func TestADSBMessage_IsSpontaneous(t *testing.T) {
+	// DF11 from 4840D6 whose parity carries the interrogator code
+	df11 := func(iid uint32) *ADSBMessage {
+		msg := &ADSBMessage{}
+		copy(msg.Data[:], []byte{0x5D, 0x48, 0x40, 0xD6})
+		parity := CalculateCRC(msg.Data[:4]) ^ iid
+		msg.Data[4], msg.Data[5], msg.Data[6] = byte(parity>>16), byte(parity>>8), byte(parity)
+		return msg
+	}
+	withDF := func(df uint8) *ADSBMessage {
+		return &ADSBMessage{Data: [14]byte{df << 3, 0x48, 0x40, 0xD6}}
+	}
+
+	tests := []struct {
+		name     string
+		message  *ADSBMessage
+		expected bool
+	}{
+		{name: "DF0 short air-air surveillance", message: withDF(0), expected: false},
+		{name: "DF4 altitude reply", message: withDF(4), expected: false},
+		{name: "DF5 identity reply", message: withDF(5), expected: false},
+		{name: "DF11 acquisition squitter", message: df11(0), expected: true},
+		{name: "DF11 all-call reply", message: df11(5), expected: false},
+		{name: "DF16 long air-air surveillance", message: withDF(16), expected: false},
+		{name: "DF17 extended squitter", message: withDF(17), expected: true},
+		{name: "DF18 non-transponder extended squitter", message: withDF(18), expected: true},
+		{name: "DF19 military extended squitter", message: withDF(19), expected: true},
+		{name: "DF20 Comm-B altitude reply", message: withDF(20), expected: false},
+		{name: "DF21 Comm-B identity reply", message: withDF(21), expected: false},
+		{name: "DF24 Comm-D ELM", message: &ADSBMessage{Data: [14]byte{0xC0, 0x48, 0x40, 0xD6}}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.message.IsSpontaneous())
+		})
+	}
+}
+
+// TestADSBMessage_Source tests attributing extended squitters by DF and the
+// DF18 control field
+func TestADSBMessage_Source(t *testing.T) {
+	tests := []struct {
+		name       string
+		first      byte
+		cf         uint8
+		source     string
+		typeFormat bool
+	}{
+		{name: "DF17 transponder", first: 0x8D, cf: 0, source: SourceADSB, typeFormat: true},
+		{name: "DF18 CF0 non-transponder", first: 0x90, cf: 0, source: SourceADSBNT, typeFormat: true},
+		{name: "DF18 CF1 non-ICAO address", first: 0x91, cf: 1, source: SourceADSBOther, typeFormat: true},
+		{name: "DF18 CF2 fine TIS-B", first: 0x92, cf: 2, source: SourceTISB, typeFormat: true},
+		{name: "DF18 CF3 coarse TIS-B", first: 0x93, cf: 3, source: SourceTISB, typeFormat: false},
+		{name: "DF18 CF4 management", first: 0x94, cf: 4, source: "", typeFormat: false},
+		{name: "DF18 CF5 fine TIS-B, non-ICAO", first: 0x95, cf: 5, source: SourceTISB, typeFormat: true},
+		{name: "DF18 CF6 ADS-R", first: 0x96, cf: 6, source: SourceADSR, typeFormat: true},
+		{name: "DF18 CF7 reserved", first: 0x97, cf: 7, source: "", typeFormat: false},
+		{name: "DF11 all-call", first: 0x5D, cf: 0, source: "", typeFormat: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &ADSBMessage{Data: [14]byte{tt.first, 0x48, 0x40, 0xD6, 0x20}}
+			assert.Equal(t, tt.cf, msg.GetCF())
+			assert.Equal(t, tt.source, msg.Source())
+			assert.Equal(t, tt.typeFormat, msg.HasTypeCodeFormat())
+		})
+	}
+}
+
 // TestProcessIQSamples tests the main ProcessIQSamples function
 func TestProcessIQSamples(t *testing.T) {
 	processor := NewADSBProcessor(2400000, logrus.New())
@@ -443,7 +697,7 @@ func TestProcessIQSamples(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := processor.ProcessIQSamples(tt.input)
+			result := processor.ProcessIQSamples(tt.input, time.Now())
 			// Function may return nil slice when no messages found
 			if result != nil {
 				assert.IsType(t, []*ADSBMessage{}, result)
@@ -535,8 +789,677 @@ func TestConcurrentProcessing(t *testing.T) {
 	assert.True(t, true)
 }
 
+// TestSNRThreshold tests that the SNR threshold changes preamble acceptance
+func TestSNRThreshold(t *testing.T) {
+	// Phase 3 preamble with an 8 dB signal/noise ratio (peaks 1000, floor 400)
+	magnitude := make([]uint16, 300)
+	for i := range magnitude {
+		magnitude[i] = 400
+	}
+	for _, peak := range []int{1, 3, 9, 11, 12} {
+		magnitude[peak] = 1000
+	}
+
+	tests := []struct {
+		name           string
+		thresholdDB    float64
+		expectAccepted bool
+	}{
+		{
+			name:           "Default threshold accepts",
+			thresholdDB:    DefaultSNRThreshold,
+			expectAccepted: true,
+		},
+		{
+			name:           "Strict threshold rejects",
+			thresholdDB:    10.0,
+			expectAccepted: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewADSBProcessor(2400000, logrus.New())
+			processor.SetSNRThreshold(tt.thresholdDB)
+
+			processor.demodulate2400(magnitude, time.Now())
+			snrRejects, quietRejects, floorRejects := processor.GetPreambleRejects()
+			assert.Equal(t, uint64(0), quietRejects)
+			assert.Equal(t, uint64(0), floorRejects)
+
+			if tt.expectAccepted {
+				assert.Equal(t, uint64(1), processor.preambleCount)
+				assert.Equal(t, uint64(0), snrRejects)
+			} else {
+				assert.Equal(t, uint64(0), processor.preambleCount)
+				assert.Equal(t, uint64(1), snrRejects)
+			}
+		})
+	}
+}
+
+// TestNoiseFloor tests that the noise floor estimate follows the channel noise
+// level up and down, and that noise-level preambles are held to it
+func TestNoiseFloor(t *testing.T) {
+	processor := NewADSBProcessor(2400000, logrus.New())
+	assert.True(t, math.IsInf(processor.GetNoiseFloor(), -1))
+
+	feed := func(scale float64, seed int64) float64 {
+		for i := int64(0); i < 24; i++ {
+			m := noiseMagnitude(64*1024, seed+i)
+			for k := range m {
+				m[k] = uint16(float64(m[k]) * scale)
+			}
+			processor.demodulate2400(m, time.Now())
+		}
+		return processor.GetNoiseFloor()
+	}
+
+	quiet := feed(1, 0)
+	// Fed by every candidate, not just those passing the gates, the floor
+	// sits at the mean noise magnitude: Rayleigh, sigma*sqrt(pi/2)
+	assert.InDelta(t, 20*math.Log10(300*math.Sqrt(math.Pi/2)/math.MaxUint16), quiet, 1.0)
+	loud := feed(4, 100)
+	assert.InDelta(t, 12.0, loud-quiet, 1.0) // 4x the amplitude is 12 dB
+	assert.InDelta(t, quiet, feed(1, 200), 1.0)
+
+	// Noise preambles passing on a quiet gap are counted apart from the
+	// local SNR rejects
+	snrRejects, _, floorRejects := processor.GetPreambleRejects()
+	assert.NotZero(t, snrRejects)
+	assert.NotZero(t, floorRejects)
+
+	// A frame well above the floor still decodes
+	es := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+	messages := processor.demodulate2400(modulateFrames(es), time.Now())
+	require.Len(t, messages, 1)
+	assert.True(t, messages[0].Valid)
+}
+
+// TestSinglePhase tests that a clean frame decodes with both the full phase
+// search and the single-phase fast path
+func TestSinglePhase(t *testing.T) {
+	es := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+
+	for _, single := range []bool{false, true} {
+		t.Run(fmt.Sprintf("single=%v", single), func(t *testing.T) {
+			processor := NewADSBProcessor(2400000, logrus.New())
+			processor.SetSinglePhase(single)
+
+			messages := processor.demodulate2400(modulateFrames(es), time.Now())
+			require.NotEmpty(t, messages)
+			assert.True(t, messages[0].Valid)
+			assert.Equal(t, es, messages[0].Data[:])
+			assert.Equal(t, "valid", messages[0].CRCType)
+		})
+	}
+}
+
+// TestPhaseStats tests that valid messages are counted under the phase they
+// were decoded at, for frames sampled at known phase offsets
+func TestPhaseStats(t *testing.T) {
+	es := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+
+	// Single phase decoding picks the phase from the preamble, so each
+	// sampling offset lands on a known phase
+	processor := NewADSBProcessor(2400000, logrus.New())
+	processor.SetSinglePhase(true)
+	assert.Equal(t, map[int]uint64{4: 0, 5: 0, 6: 0, 7: 0, 8: 0}, processor.GetPhaseStats())
+
+	now := time.Now()
+	offsets := []float64{0.1, 0.1, 0.1, 0.4, 0.4}
+	for i, offset := range offsets {
+		// A second apart, so the repeats are not suppressed as duplicates
+		messages := processor.demodulate2400(modulateFramesAt(offset, es), now.Add(time.Duration(i)*time.Second))
+		require.Len(t, messages, 1)
+		require.True(t, messages[0].Valid)
+	}
+	assert.Equal(t, map[int]uint64{4: 2, 5: 3, 6: 0, 7: 0, 8: 0}, processor.GetPhaseStats())
+
+	// The map is a copy
+	processor.GetPhaseStats()[4] = 100
+	assert.Equal(t, uint64(2), processor.GetPhaseStats()[4])
+
+	// Invalid messages are not counted
+	corrupted := append([]byte(nil), es...)
+	corrupted[6] ^= 0x0F
+	processor.demodulate2400(modulateFramesAt(0.4, corrupted), now.Add(time.Minute))
+	assert.Equal(t, map[int]uint64{4: 2, 5: 3, 6: 0, 7: 0, 8: 0}, processor.GetPhaseStats())
+}
+
+// TestDuplicateSuppression tests that repeats of the same frame are dropped
+func TestDuplicateSuppression(t *testing.T) {
+	processor := NewADSBProcessor(2400000, logrus.New())
+
+	now := time.Now()
+	frame := [14]byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+
+	first := &ADSBMessage{Data: frame, Timestamp: now, Valid: true}
+	assert.False(t, processor.isDuplicate(first))
+
+	// Same frame decoded again from an adjacent phase
+	repeat := &ADSBMessage{Data: frame, Timestamp: now.Add(100 * time.Microsecond), Valid: true}
+	assert.True(t, processor.isDuplicate(repeat))
+	assert.Equal(t, uint64(1), processor.GetDuplicateCount())
+
+	// A different frame from the same aircraft is not a duplicate
+	other := frame
+	other[10] ^= 0x01
+	assert.False(t, processor.isDuplicate(&ADSBMessage{Data: other, Timestamp: now, Valid: true}))
+
+	// The same frame outside the window is a new transmission
+	later := &ADSBMessage{Data: frame, Timestamp: now.Add(time.Second), Valid: true}
+	assert.False(t, processor.isDuplicate(later))
+	assert.Equal(t, uint64(1), processor.GetDuplicateCount())
+
+	// Expired frames are pruned
+	processor.pruneRecentFrames(now.Add(time.Hour))
+	assert.Empty(t, processor.recentFrames)
+}
+
+// TestMultipathBestCopy tests that of a valid and a corrected copy of the same
+// message only the valid one is emitted, whichever arrives first
+func TestMultipathBestCopy(t *testing.T) {
+	valid := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+	corrupted := append([]byte(nil), valid...)
+	corrupted[6] ^= 0x04
+
+	tests := []struct {
+		name   string
+		frames [][]byte
+	}{
+		{name: "Corrected copy first", frames: [][]byte{corrupted, valid}},
+		{name: "Valid copy first", frames: [][]byte{valid, corrupted}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewADSBProcessor(2400000, logrus.New())
+
+			messages := processor.demodulate2400(modulateFrames(tt.frames...), time.Now())
+
+			require.Len(t, messages, 1)
+			assert.Equal(t, "valid", messages[0].CRCType)
+			assert.Equal(t, valid, messages[0].Data[:])
+			assert.Equal(t, uint64(1), processor.GetDuplicateCount())
+
+			_, _, validCount, corrected, singleBit, _ := processor.GetStats()
+			assert.Equal(t, uint64(1), validCount)
+			assert.Zero(t, corrected)
+			assert.Zero(t, singleBit)
+		})
+	}
+}
+
+// TestReflectionSelection tests picking the best of differing copies of one
+// logical message within the multipath window
+func TestReflectionSelection(t *testing.T) {
+	processor := NewADSBProcessor(2400000, logrus.New())
+
+	now := time.Now()
+	frame := [14]byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+	miscorrected := frame
+	miscorrected[7] ^= 0x01
+
+	first := &ADSBMessage{Data: miscorrected, Timestamp: now, Valid: true, CRCType: "corrected-2", Signal: -6}
+	assert.False(t, processor.isReflection(first))
+	batch := []*ADSBMessage{first}
+
+	// A weaker copy with a pristine CRC outranks a two-bit repair
+	weaker := &ADSBMessage{Data: frame, Timestamp: now.Add(time.Millisecond), Valid: true, CRCType: "valid", Signal: -12}
+	assert.True(t, processor.isReflection(weaker))
+	processor.keepBetterCopy(batch, weaker)
+	assert.Same(t, weaker, batch[0])
+
+	// An equally ranked copy wins only with a stronger signal
+	stronger := &ADSBMessage{Data: frame, Timestamp: now.Add(2 * time.Millisecond), Valid: true, CRCType: "valid", Signal: -3}
+	processor.keepBetterCopy(batch, stronger)
+	assert.Same(t, stronger, batch[0])
+	processor.keepBetterCopy(batch, &ADSBMessage{Data: frame, Timestamp: now.Add(2 * time.Millisecond), Valid: true, CRCType: "valid", Signal: -9})
+	assert.Same(t, stronger, batch[0])
+
+	// Another message type from the same aircraft is not a reflection
+	velocity := &ADSBMessage{Data: [14]byte{0x8D, 0x48, 0x40, 0xD6, 0x99}, Timestamp: now, Valid: true}
+	assert.False(t, processor.isReflection(velocity))
+
+	// Outside the window a copy is a new message
+	assert.False(t, processor.isReflection(&ADSBMessage{Data: frame, Timestamp: now.Add(time.Second), Valid: true}))
+	assert.Equal(t, uint64(1), processor.GetReflectionCount())
+
+	processor.pruneRecentLogical(now.Add(time.Hour))
+	assert.Empty(t, processor.recentLogical)
+}
+
+// TestDF24LengthHandling tests that a Comm-D ELM frame from a known aircraft
+// is passed on whole and the frame after it still decodes, while one from an
+// unknown address is a failed decode
+func TestDF24LengthHandling(t *testing.T) {
+	processor := NewADSBProcessor(2400000, logrus.New())
+
+	es := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+	position := []byte{0x8D, 0x40, 0x62, 0x1D, 0x58, 0xC3, 0x82, 0xD6, 0x90, 0xC8, 0xAC, 0x28, 0x63, 0xA7}
+	commD := elmReply(0x4840D6, 3, "SEGMENT 03", time.Time{}).Data[:]
+	stranger := elmReply(0x123456, 3, "SEGMENT 03", time.Time{}).Data[:]
+
+	msg := &ADSBMessage{}
+	copy(msg.Data[:], commD)
+	ValidateAndCorrectMessage(msg)
+	assert.Equal(t, "invalid", msg.CRCType, "unverifiable without known addresses")
+	assert.False(t, msg.Valid)
+	assert.Equal(t, LongMessageBytes, MessageLength(msg.GetDF()))
+
+	messages := processor.demodulate2400(modulateFrames(es, commD, position, stranger), time.Now())
+
+	// The stranger scores as a failed CRC and yields nothing
+	require.Len(t, messages, 3)
+	assert.True(t, messages[0].Valid)
+	assert.Equal(t, "comm-d", messages[1].CRCType)
+	assert.False(t, messages[1].Valid)
+	assert.Equal(t, commD, messages[1].Data[:])
+	assert.True(t, messages[2].Valid)
+	assert.Equal(t, position, messages[2].Data[:])
+	assert.Equal(t, uint64(1), processor.GetCommDCount())
+	assert.Equal(t, uint64(1), processor.rejectedUnknown)
+}
+
+// TestDF24Noise tests that noise, half of which starts with the Comm-D bits,
+// yields no Comm-D segments
+func TestDF24Noise(t *testing.T) {
+	processor := NewADSBProcessor(2400000, logrus.New())
+	processor.demodulate2400(noiseMagnitude(SupportedSampleRate/4, 1), time.Now())
+
+	_, preambles, valid, _, _, _ := processor.GetStats()
+	require.NotZero(t, preambles)
+	assert.Zero(t, valid)
+	assert.Zero(t, processor.GetCommDCount())
+}
+
+// TestMessageLength tests the length of every 5-bit DF, both as given and as
+// decoded from a first byte
+func TestMessageLength(t *testing.T) {
+	const S, L = ShortMessageBytes, LongMessageBytes
+	expected := [32]int{
+		S, S, S, S, S, S, S, S, // DF0-7: DF0, DF4, DF5 assigned
+		S, S, S, S, S, S, S, S, // DF8-15: DF11 assigned
+		L, L, L, L, L, L, L, L, // DF16-23: DF16-21 assigned
+		L, L, L, L, L, L, L, L, // DF24-31: Comm-D ELM
+	}
+
+	for df := uint8(0); df < 32; df++ {
+		t.Run(fmt.Sprintf("DF%d", df), func(t *testing.T) {
+			assert.Equal(t, expected[df], MessageLength(df))
+
+			// DF24-31 share the two-bit Comm-D format
+			decoded := DecodeDF(df << 3)
+			if df >= 24 {
+				assert.Equal(t, uint8(24), decoded)
+			} else {
+				assert.Equal(t, df, decoded)
+			}
+			assert.Equal(t, expected[df], MessageLength(decoded))
+		})
+	}
+}
+
+// TestTypeBreakdown tests valid message counts by downlink format and type code
+func TestTypeBreakdown(t *testing.T) {
+	processor := NewADSBProcessor(2400000, logrus.New())
+
+	// DF11 all-call reply from 4840D6 with interrogator code 0
+	allCall := []byte{0x5D, 0x48, 0x40, 0xD6, 0, 0, 0}
+	parity := CalculateCRC(allCall[:4])
+	allCall[4], allCall[5], allCall[6] = byte(parity>>16), byte(parity>>8), byte(parity)
+
+	frames := [][]byte{
+		{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}, // TC4 identification
+		{0x8D, 0x40, 0x62, 0x1D, 0x58, 0xC3, 0x82, 0xD6, 0x90, 0xC8, 0xAC, 0x28, 0x63, 0xA7}, // TC11 position
+		{0x8D, 0x40, 0x62, 0x1D, 0xA0, 0xC3, 0x82, 0xD6, 0x90, 0xC8, 0xAC, 0x5C, 0x84, 0xCA}, // TC20 position
+		{0x8D, 0x48, 0x50, 0x20, 0x99, 0x44, 0x09, 0x94, 0x08, 0x38, 0x17, 0x5B, 0x28, 0x4F}, // TC19 velocity
+		allCall,
+	}
+
+	messages := processor.demodulate2400(modulateFrames(frames...), time.Now())
+	require.Len(t, messages, len(frames))
+
+	assert.Equal(t, map[string]uint64{
+		"DF11":      1,
+		"DF17":      4,
+		"DF17/TC4":  1,
+		"DF17/TC11": 1,
+		"DF17/TC19": 1,
+		"DF17/TC20": 1,
+	}, processor.GetTypeBreakdown())
+
+	// The returned map is a copy
+	processor.GetTypeBreakdown()["DF11"] = 100
+	assert.Equal(t, uint64(1), processor.GetTypeBreakdown()["DF11"])
+}
+
+// TestStrictDF11 tests lenient and strict acceptance of DF11 replies by
+// interrogator code
+func TestStrictDF11(t *testing.T) {
+	// DF11 all-call reply from 4840D6 whose CRC residual carries the code
+	allCall := func(iid uint32) *ADSBMessage {
+		msg := &ADSBMessage{}
+		copy(msg.Data[:], []byte{0x5D, 0x48, 0x40, 0xD6})
+		parity := CalculateCRC(msg.Data[:4]) ^ iid
+		msg.Data[4], msg.Data[5], msg.Data[6] = byte(parity>>16), byte(parity>>8), byte(parity)
+		return msg
+	}
+	iids := []uint32{0, 5, 0x3F}
+
+	tests := []struct {
+		name     string
+		strict   bool
+		extra    []uint8
+		accepted []uint32
+	}{
+		{name: "Lenient", accepted: []uint32{0, 5, 0x3F}},
+		{name: "Strict", strict: true, accepted: []uint32{0}},
+		{name: "Strict with extra code", strict: true, extra: []uint8{5}, accepted: []uint32{0, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewADSBProcessor(2400000, logrus.New())
+			processor.SetDF11Interrogators(tt.strict, tt.extra)
+
+			var accepted []uint32
+			for _, iid := range iids {
+				msg := allCall(iid)
+				ValidateMessage(msg, true)
+				processor.checkDF11Interrogator(msg)
+				if msg.Valid {
+					assert.Equal(t, "valid", msg.CRCType)
+					accepted = append(accepted, iid)
+				} else {
+					assert.Equal(t, "invalid-iid", msg.CRCType)
+				}
+			}
+			assert.Equal(t, tt.accepted, accepted)
+		})
+	}
+
+	// A corrected reply is not rejected by the strict check
+	processor := NewADSBProcessor(2400000, logrus.New())
+	processor.SetDF11Interrogators(true, nil)
+	msg := allCall(0)
+	msg.Data[1] ^= 0x10
+	ValidateMessage(msg, true)
+	processor.checkDF11Interrogator(msg)
+	assert.True(t, msg.Valid)
+	assert.Equal(t, "corrected-1", msg.CRCType)
+}
+
+// TestMessageTimestamps tests that timestamps follow the sample clock, not processing time
+func TestMessageTimestamps(t *testing.T) {
+	processor := NewADSBProcessor(2400000, logrus.New())
+	// The buffers are far shorter than the multipath window
+	processor.multipathWindow = 0
+
+	first := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+	second := []byte{0x8D, 0x40, 0x62, 0x1D, 0x58, 0xC3, 0x82, 0xD6, 0x90, 0xC8, 0xAC, 0x28, 0x63, 0xA7}
+
+	// Two buffers, each holding both frames 130us apart (260 chips per frame)
+	m := modulateFrames(first, second)
+	iq := make([]complex128, len(m))
+	for i, v := range m {
+		iq[i] = complex(float64(v)/1000, 0)
+	}
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	bufferDuration := SampleDuration(uint64(len(iq)), 2400000)
+
+	var timestamps []time.Time
+	for buffer := 0; buffer < 2; buffer++ {
+		messages := processor.ProcessIQSamples(iq, base.Add(time.Duration(buffer)*bufferDuration))
+		require.Len(t, messages, 2)
+		for _, msg := range messages {
+			timestamps = append(timestamps, msg.Timestamp)
+		}
+	}
+
+	// One sample is 1/2.4 MHz, about 417ns
+	sample := SampleDuration(1, 2400000)
+	for i := 1; i < len(timestamps); i++ {
+		assert.True(t, timestamps[i].After(timestamps[i-1]), "timestamps must be monotonic")
+	}
+	assert.InDelta(t, 130*time.Microsecond, timestamps[1].Sub(timestamps[0]), float64(sample))
+	assert.Equal(t, bufferDuration, timestamps[2].Sub(timestamps[0]))
+	assert.Equal(t, bufferDuration, timestamps[3].Sub(timestamps[1]))
+	assert.WithinDuration(t, base, timestamps[0], 20*time.Microsecond)
+}
+
+// TestMLATTicks tests sample count to 12 MHz tick conversion
+func TestMLATTicks(t *testing.T) {
+	assert.Equal(t, uint64(5), MLATTicks(1, 2400000))
+	assert.Equal(t, uint64(6), MLATTicks(1, 2000000))
+	assert.Equal(t, uint64(MLATClockRate), MLATTicks(2400000, 2400000))
+	// Days of samples must not overflow
+	assert.Equal(t, uint64(72*3600*MLATClockRate), MLATTicks(72*3600*2400000, 2400000))
+	assert.Equal(t, uint64(0), MLATTicks(100, 0))
+}
+
+// TestNextPreambleCandidate tests that stepping over indices finds exactly the
+// candidates an exhaustive scan with the quick check finds
+func TestNextPreambleCandidate(t *testing.T) {
+	m := noiseMagnitude(64*1024, 2)
+	end := len(m) - 240
+
+	var exhaustive []int
+	for j := 0; j < end; j++ {
+		if m[j] < m[j+1] && m[j+1] > m[j+2] && m[j+12] > m[j+13] {
+			exhaustive = append(exhaustive, j)
+		}
+	}
+
+	var stepped []int
+	for j := nextPreambleCandidate(m, 0, end); j < end; j = nextPreambleCandidate(m, j+1, end) {
+		stepped = append(stepped, j)
+	}
+
+	require.NotEmpty(t, exhaustive)
+	assert.Equal(t, exhaustive, stepped)
+}
+
+// TestProcessIQSamples_ShortBuffers tests that empty and tiny buffers are
+// skipped without a scan while still advancing the sample clock
+func TestProcessIQSamples_ShortBuffers(t *testing.T) {
+	processor := NewADSBProcessor(2400000, logrus.New())
+
+	var consumed uint64
+	for _, n := range []int{0, 1, 2, minDemodSamples - 1, minDemodSamples} {
+		iq := make([]complex128, n)
+		for i := range iq {
+			iq[i] = complex(float64(i%3), 0)
+		}
+		assert.Empty(t, processor.ProcessIQSamples(iq, time.Now()), "%d samples", n)
+		consumed += uint64(n)
+		assert.Equal(t, consumed, processor.sampleClock, "%d samples", n)
+	}
+	assert.Nil(t, processor.demodulate2400(nil, time.Now()))
+
+	_, preambles, valid, _, _, _ := processor.GetStats()
+	assert.Zero(t, preambles)
+	assert.Zero(t, valid)
+}
+
+// TestProcessIQSamples_BufferOverlap tests that a frame split across two
+// buffers anywhere around its span is decoded exactly once, at the sample
+// clock it has in one unsplit buffer, and lost without the overlap
+func TestProcessIQSamples_BufferOverlap(t *testing.T) {
+	frame := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+	const lead = 500
+	signal := modulateFrames(frame)
+	iq := make([]complex128, lead+len(signal))
+	for i, v := range signal {
+		iq[lead+i] = complex(float64(v)/1000, 0)
+	}
+
+	decode := func(overlap bool, split int) []*ADSBMessage {
+		processor := NewADSBProcessor(2400000, logrus.New())
+		processor.dedupWindow = 0
+		processor.multipathWindow = 0
+		processor.SetBufferOverlap(overlap)
+
+		now := time.Now()
+		var valid []*ADSBMessage
+		for _, msg := range append(processor.ProcessIQSamples(iq[:split], now), processor.ProcessIQSamples(iq[split:], now)...) {
+			if msg.Valid {
+				valid = append(valid, msg)
+			}
+		}
+		return valid
+	}
+
+	whole := decode(true, 0)
+	require.Len(t, whole, 1)
+	clock := whole[0].SampleClock
+
+	for split := lead - minDemodSamples; split <= lead+len(signal); split++ {
+		messages := decode(true, split)
+		require.Len(t, messages, 1, "split at %d", split)
+		assert.Equal(t, frame, messages[0].Data[:], "split at %d", split)
+		assert.Equal(t, clock, messages[0].SampleClock, "split at %d", split)
+	}
+
+	// The frame's preamble lies in the first buffer's last unscanned samples
+	assert.Empty(t, decode(false, lead+100))
+	assert.Len(t, decode(true, lead+100), 1)
+}
+
+// TestDemodulate2400_EveryOffset tests that frames over a noise floor are
+// found wherever they start relative to the sample grid
+func TestDemodulate2400_EveryOffset(t *testing.T) {
+	frame := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+	signal := modulateFrames(frame, frame)
+
+	for offset := 0; offset < 12; offset++ {
+		processor := NewADSBProcessor(2400000, logrus.New())
+		processor.dedupWindow = 0
+		processor.multipathWindow = 0
+
+		m := noiseMagnitude(offset+len(signal), int64(offset))
+		for i := range m {
+			m[i] /= 10
+		}
+		for i, v := range signal {
+			m[offset+i] += v
+		}
+
+		messages := processor.demodulate2400(m, time.Now())
+		valid := 0
+		for _, msg := range messages {
+			if msg.Valid {
+				assert.Equal(t, frame, msg.Data[:], "offset %d", offset)
+				valid++
+			}
+		}
+		assert.Equal(t, 2, valid, "offset %d", offset)
+	}
+}
+
+// TestSampleDuration tests sample count to duration conversion
+func TestSampleDuration(t *testing.T) {
+	assert.Equal(t, time.Second, SampleDuration(2400000, 2400000))
+	assert.Equal(t, 500*time.Nanosecond, SampleDuration(1, 2000000))
+	// Days of samples must not overflow
+	assert.Equal(t, 72*time.Hour, SampleDuration(72*3600*2400000, 2400000))
+	assert.Equal(t, time.Duration(0), SampleDuration(100, 0))
+}
+
+// TestStrictCRC tests that a single-bit-corrupted frame is only accepted with error correction
+func TestStrictCRC(t *testing.T) {
+	frame := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+	corrupted := append([]byte(nil), frame...)
+	corrupted[6] ^= 0x10
+
+	tests := []struct {
+		name            string
+		errorCorrection bool
+		expectedValid   int
+	}{
+		{name: "Correction enabled", errorCorrection: true, expectedValid: 1},
+		{name: "Strict CRC", errorCorrection: false, expectedValid: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewADSBProcessor(2400000, logrus.New())
+			processor.SetErrorCorrection(tt.errorCorrection)
+
+			messages := processor.demodulate2400(modulateFrames(corrupted), time.Now())
+
+			valid := 0
+			for _, msg := range messages {
+				if msg.Valid {
+					valid++
+					assert.Equal(t, frame, msg.Data[:])
+				}
+			}
+			assert.Equal(t, tt.expectedValid, valid)
+
+			_, _, _, corrected, singleBit, twoBit := processor.GetStats()
+			assert.Equal(t, uint64(tt.expectedValid), corrected)
+			assert.Equal(t, uint64(tt.expectedValid), singleBit)
+			assert.Zero(t, twoBit)
+		})
+	}
+}
+
 // Helper functions for test data generation
 
+// modulateFrames renders Mode S frames as ideal 2.4 MHz magnitude samples,
+// each preceded by a 10us gap and the preamble
+func modulateFrames(frames ...[]byte) []uint16 {
+	return modulateFramesAt(0.25, frames...)
+}
+
+// modulateFramesAt is modulateFrames with the first sample starting offset
+// samples into the signal, to shift the frames' sampling phase
+func modulateFramesAt(offset float64, frames ...[]byte) []uint16 {
+	preamble := []bool{true, false, true, false, false, false, false, true, false, true, false, false, false, false, false, false}
+
+	// Half-microsecond chips; each bit is a high/low or low/high pair
+	var chips []bool
+	for _, frame := range frames {
+		chips = append(chips, make([]bool, 20)...)
+		chips = append(chips, preamble...)
+		for _, b := range frame {
+			for i := 7; i >= 0; i-- {
+				bit := b&(1<<i) != 0
+				chips = append(chips, bit, !bit)
+			}
+		}
+	}
+	chips = append(chips, make([]bool, 600)...)
+
+	// Each sample integrates 1/1.2 of a chip
+	m := make([]uint16, len(chips)*6/5-2)
+	for k := range m {
+		start := (float64(k) + offset) / 1.2
+		end := start + 1/1.2
+		var high float64
+		for c := int(start); float64(c) < end; c++ {
+			if chips[c] {
+				high += math.Min(end, float64(c+1)) - math.Max(start, float64(c))
+			}
+		}
+		m[k] = uint16(high * 1.2 * 1000)
+	}
+	return m
+}
+
+// noiseMagnitude returns n magnitude samples of Rayleigh-like receiver noise
+func noiseMagnitude(n int, seed int64) []uint16 {
+	rng := rand.New(rand.NewSource(seed))
+	m := make([]uint16, n)
+	for i := range m {
+		m[i] = uint16(math.Hypot(rng.NormFloat64(), rng.NormFloat64()) * 300)
+	}
+	return m
+}
+
 func generateRandomIQData(length int) []complex128 {
 	data := make([]complex128, length)
 	for i := range data {
@@ -573,19 +1496,76 @@ func BenchmarkCalculateMagnitude(b *testing.B) {
 	processor := NewADSBProcessor(2400000, logrus.New())
 	data := generateRandomIQData(1000)
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		processor.calculateMagnitude(data)
 	}
 }
 
+// BenchmarkCalculateMagnitude_Unbuffered measures the per-call allocation the
+// reused buffer avoids, for comparison with BenchmarkCalculateMagnitude
+func BenchmarkCalculateMagnitude_Unbuffered(b *testing.B) {
+	processor := NewADSBProcessor(2400000, logrus.New())
+	data := generateRandomIQData(1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		processor.magnitude = nil
+		processor.calculateMagnitude(data)
+	}
+}
+
 func BenchmarkProcessIQSamples(b *testing.B) {
 	processor := NewADSBProcessor(2400000, logrus.New())
 	data := generateRandomIQData(1000)
 
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		processor.ProcessIQSamples(data, time.Now())
+	}
+}
+
+// BenchmarkDemodulate2400_Noise measures the preamble scan over a buffer of
+// receiver noise with no transmissions, the common case on a quiet channel
+func BenchmarkDemodulate2400_Noise(b *testing.B) {
+	processor := NewADSBProcessor(2400000, logrus.New())
+	m := noiseMagnitude(256*1024, 1)
+
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		processor.ProcessIQSamples(data)
+		processor.demodulate2400(m, time.Now())
+	}
+}
+
+// BenchmarkDemodulate2400_Phases compares the full phase search with the
+// single-phase fast path on a buffer of frames in noise
+func BenchmarkDemodulate2400_Phases(b *testing.B) {
+	es := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+	var frames [][]byte
+	for i := 0; i < 200; i++ {
+		frames = append(frames, es)
+	}
+	signal := modulateFrames(frames...)
+	m := noiseMagnitude(len(signal), 1)
+	for i, v := range signal {
+		m[i] = m[i]/4 + v
+	}
+
+	for _, single := range []bool{false, true} {
+		b.Run(fmt.Sprintf("single=%v", single), func(b *testing.B) {
+			processor := NewADSBProcessor(2400000, logrus.New())
+			processor.SetSinglePhase(single)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				processor.demodulate2400(m, time.Now())
+			}
+		})
 	}
 }
 
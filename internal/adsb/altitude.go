@@ -0,0 +1,90 @@
+package adsb
+
+// Bits of a 13-bit altitude code (C1 A1 C2 A2 C4 A4 M B1 Q B2 D2 B4 D4). In
+// Gillham coded altitudes the Q position carries D1, which is never used.
+const (
+	ac13MBit = 0x0040 // Metric units
+	ac13QBit = 0x0010 // 25-foot increments
+)
+
+// DecodeAC13 converts a 13-bit altitude code, as carried by surveillance
+// replies (DF0/4/16/20), to feet. Codes with the Q bit set are 25-foot
+// increments; otherwise they are 100-foot Gillham (Mode C) codes. ok is false
+// for code 0 (no altitude), metric codes and invalid Gillham codes.
+func DecodeAC13(code uint16) (feet int, ok bool) {
+	code &= 0x1FFF
+	if code == 0 || code&ac13MBit != 0 {
+		return 0, false
+	}
+
+	if code&ac13QBit != 0 {
+		// Remove M and Q to get the 11-bit count of 25 ft above -1000 ft
+		n := (code&0x1F80)>>2 | (code&0x0020)>>1 | code&0x000F
+		return int(n)*25 - 1000, true
+	}
+	return decodeGillham(code)
+}
+
+// DecodeAC12 converts a 12-bit altitude code, as carried by airborne position
+// squitters, to feet. It is the 13-bit code without the M bit.
+func DecodeAC12(code uint16) (feet int, ok bool) {
+	code &= 0x0FFF
+	if code == 0 {
+		return 0, false
+	}
+	return DecodeAC13((code&0x0FC0)<<1 | code&0x003F)
+}
+
+// decodeGillham converts a 13-bit Gillham code to feet (dump1090's
+// ModeAToModeC). The D2..B4 bits are a Gray code of 500 ft steps; C1 C2 C4 are
+// a 5-state Gray code of 100 ft steps that runs backwards in odd 500 ft steps.
+func decodeGillham(code uint16) (int, bool) {
+	bit := func(mask uint16) bool { return code&mask != 0 }
+
+	// D1 is unused and at least one C bit is always set
+	if bit(ac13QBit) || code&0x1500 == 0 {
+		return 0, false
+	}
+
+	var oneHundreds int
+	if bit(0x1000) { // C1
+		oneHundreds ^= 0x007
+	}
+	if bit(0x0400) { // C2
+		oneHundreds ^= 0x003
+	}
+	if bit(0x0100) { // C4
+		oneHundreds ^= 0x001
+	}
+	// 7 stands for 5; 5 is itself invalid
+	if oneHundreds&5 == 5 {
+		oneHundreds ^= 2
+	}
+	if oneHundreds > 5 {
+		return 0, false
+	}
+
+	var fiveHundreds int
+	for _, g := range []struct {
+		mask uint16
+		flip int
+	}{
+		{0x0004, 0x0FF}, // D2
+		{0x0001, 0x07F}, // D4
+		{0x0800, 0x03F}, // A1
+		{0x0200, 0x01F}, // A2
+		{0x0080, 0x00F}, // A4
+		{0x0020, 0x007}, // B1
+		{0x0008, 0x003}, // B2
+		{0x0002, 0x001}, // B4
+	} {
+		if bit(g.mask) {
+			fiveHundreds ^= g.flip
+		}
+	}
+
+	if fiveHundreds&1 != 0 {
+		oneHundreds = 6 - oneHundreds
+	}
+	return (fiveHundreds*5 + oneHundreds - 13) * 100, true
+}
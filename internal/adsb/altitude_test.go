@@ -0,0 +1,102 @@
+package adsb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDecodeAC13 tests 13-bit altitude decoding in both encodings
+func TestDecodeAC13(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     uint16
+		expected int
+		ok       bool
+	}{
+		{name: "DF20 frame A02014B4...", code: 0x14B4, expected: 32300, ok: true},
+		{name: "Q bit, lowest", code: 0x0010, expected: -1000, ok: true},
+		{name: "Q bit, highest", code: 0x1FBF, expected: 50175, ok: true},
+		{name: "Gillham -1200", code: 0x0100, expected: -1200, ok: true},
+		{name: "Gillham -1000", code: 0x0400, expected: -1000, ok: true},
+		{name: "Gillham 0", code: 0x040A, expected: 0, ok: true},
+		{name: "Gillham 100", code: 0x140A, expected: 100, ok: true},
+		{name: "Gillham 1000", code: 0x0428, expected: 1000, ok: true},
+		{name: "Gillham 12300", code: 0x1228, expected: 12300, ok: true},
+		{name: "Gillham 38000", code: 0x0C83, expected: 38000, ok: true},
+		{name: "Gillham 62700", code: 0x0101, expected: 62700, ok: true},
+		{name: "Gillham 126700", code: 0x0104, expected: 126700, ok: true},
+		{name: "No altitude", code: 0x0000, ok: false},
+		{name: "Metric", code: 0x0050, ok: false},
+		{name: "Gillham without C bits", code: 0x0A0A, ok: false},
+		{name: "Gillham C1 C2 C4", code: 0x1500, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feet, ok := DecodeAC13(tt.code)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, feet)
+		})
+	}
+}
+
+// TestDecodeAC13_AllCodes tests that every 25 ft and Gillham code decodes to a
+// distinct altitude in range and on the encoding's step
+func TestDecodeAC13_AllCodes(t *testing.T) {
+	quarter := make(map[int]bool)
+	gillham := make(map[int]bool)
+
+	for code := uint16(0); code < 1<<13; code++ {
+		feet, ok := DecodeAC13(code)
+		switch {
+		case code == 0 || code&ac13MBit != 0:
+			assert.False(t, ok, "code %04X", code)
+		case code&ac13QBit != 0:
+			assert.True(t, ok, "code %04X", code)
+			assert.Zero(t, feet%25, "code %04X", code)
+			assert.False(t, quarter[feet], "code %04X duplicates %d ft", code, feet)
+			quarter[feet] = true
+		case ok:
+			assert.Zero(t, feet%100, "code %04X", code)
+			assert.GreaterOrEqual(t, feet, -1200, "code %04X", code)
+			assert.LessOrEqual(t, feet, 126700, "code %04X", code)
+			assert.False(t, gillham[feet], "code %04X duplicates %d ft", code, feet)
+			gillham[feet] = true
+		}
+	}
+
+	assert.Len(t, quarter, 2048)
+	assert.Len(t, gillham, 1280) // -1200 to 126700 ft
+}
+
+// TestDecodeAC12 tests 12-bit altitude decoding against the 13-bit decoder
+func TestDecodeAC12(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     uint16
+		expected int
+		ok       bool
+	}{
+		{name: "DF17 frame 8D40621D58C382D6...", code: 0xC38, expected: 38000, ok: true},
+		{name: "Gillham 5000", code: 0x248, expected: 5000, ok: true},
+		{name: "Gillham 35000", code: 0x661, expected: 35000, ok: true},
+		{name: "No altitude", code: 0x000, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feet, ok := DecodeAC12(tt.code)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, feet)
+		})
+	}
+
+	// Every code matches its 13-bit form with M=0 inserted
+	for code := uint16(0); code < 1<<12; code++ {
+		feet12, ok12 := DecodeAC12(code)
+		feet13, ok13 := DecodeAC13((code&0x0FC0)<<1 | code&0x003F)
+		assert.Equal(t, ok13, ok12, "code %03X", code)
+		assert.Equal(t, feet13, feet12, "code %03X", code)
+	}
+}
@@ -0,0 +1,287 @@
+package adsb
+
+import "math"
+
+// BDS40 holds the selected vertical intention register (Comm-B BDS 4,0).
+// Subfields whose status bit is clear are left nil.
+type BDS40 struct {
+	MCPAltitude *int     // MCP/FCU selected altitude (ft)
+	FMSAltitude *int     // FMS selected altitude (ft)
+	BaroSetting *float64 // Barometric pressure setting (mb)
+}
+
+// BDS 4,0 plausibility limits
+const (
+	bds40MaxAltitude = 50000  // Highest selected altitude accepted (ft)
+	bds40MinQNH      = 800.0  // QNH encoding offset (mb)
+	bds40MaxQNH      = 1100.0 // Highest pressure setting accepted (mb)
+)
+
+// Pressure setting checks against a local QNH
+const (
+	StandardQNH  = 1013.25 // Standard pressure setting above the transition altitude (mb)
+	QNHTolerance = 10.0    // Largest plausible difference from the local QNH (mb)
+)
+
+// QNHOutlier reports whether a reported pressure setting is neither standard
+// pressure nor within QNHTolerance of the local QNH, as with a transponder
+// stuck on an old setting
+func QNHOutlier(setting, localQNH float64) bool {
+	// Standard pressure is encoded as 1013.2 or 1013.3 mb
+	if math.Abs(setting-StandardQNH) < 0.1 {
+		return false
+	}
+	return math.Abs(setting-localQNH) > QNHTolerance
+}
+
+// mbBits extracts bits firstBit..lastBit (1-based, inclusive) of a 56-bit MB field
+func mbBits(mb []byte, firstBit, lastBit int) uint32 {
+	var value uint32
+	for bit := firstBit; bit <= lastBit; bit++ {
+		byteIndex := (bit - 1) / 8
+		bitIndex := 7 - ((bit - 1) % 8)
+		value = value<<1 | uint32((mb[byteIndex]>>bitIndex)&1)
+	}
+	return value
+}
+
+// mbStatusConsistent reports whether a status-gated MB subfield is well formed:
+// a clear status bit must be followed by an all-zero value
+func mbStatusConsistent(mb []byte, statusBit, firstBit, lastBit int) bool {
+	return mbBits(mb, statusBit, statusBit) == 1 || mbBits(mb, firstBit, lastBit) == 0
+}
+
+// DecodeBDS40 decodes a 56-bit Comm-B MB field as BDS 4,0 (selected vertical
+// intention). It returns false when the field is not consistent with BDS 4,0,
+// since Comm-B replies do not identify the register they carry.
+func DecodeBDS40(mb []byte) (*BDS40, bool) {
+	if len(mb) != 7 {
+		return nil, false
+	}
+
+	// Every status bit must agree with its subfield
+	if !mbStatusConsistent(mb, 1, 2, 13) ||
+		!mbStatusConsistent(mb, 14, 15, 26) ||
+		!mbStatusConsistent(mb, 27, 28, 39) ||
+		!mbStatusConsistent(mb, 48, 49, 51) ||
+		!mbStatusConsistent(mb, 54, 55, 56) {
+		return nil, false
+	}
+
+	// Reserved bits 40-47 and 52-53 are always zero
+	if mbBits(mb, 40, 47) != 0 || mbBits(mb, 52, 53) != 0 {
+		return nil, false
+	}
+
+	result := &BDS40{}
+
+	if mbBits(mb, 1, 1) == 1 {
+		alt := int(mbBits(mb, 2, 13)) * 16
+		if alt > bds40MaxAltitude {
+			return nil, false
+		}
+		result.MCPAltitude = &alt
+	}
+
+	if mbBits(mb, 14, 14) == 1 {
+		alt := int(mbBits(mb, 15, 26)) * 16
+		if alt > bds40MaxAltitude {
+			return nil, false
+		}
+		result.FMSAltitude = &alt
+	}
+
+	if mbBits(mb, 27, 27) == 1 {
+		qnh := float64(mbBits(mb, 28, 39))*0.1 + bds40MinQNH
+		if qnh > bds40MaxQNH {
+			return nil, false
+		}
+		result.BaroSetting = &qnh
+	}
+
+	// A register with nothing reported cannot be told apart from other BDS codes
+	if result.MCPAltitude == nil && result.FMSAltitude == nil && result.BaroSetting == nil {
+		return nil, false
+	}
+
+	return result, true
+}
+
+// mbSigned extracts a two's complement subfield of a 56-bit MB field: the
+// sign at signBit followed by the value bits firstBit..lastBit
+func mbSigned(mb []byte, signBit, firstBit, lastBit int) int {
+	value := int(mbBits(mb, firstBit, lastBit))
+	if mbBits(mb, signBit, signBit) == 1 {
+		value -= 1 << (lastBit - firstBit + 1)
+	}
+	return value
+}
+
+// angle360 maps an angle in degrees from -180..180 to 0..360
+func angle360(deg float64) float64 {
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+// BDS50 holds the track and turn report (Comm-B BDS 5,0). Subfields whose
+// status bit is clear are left nil.
+type BDS50 struct {
+	Roll        *float64 // Roll angle (degrees, positive right wing down)
+	TrueTrack   *float64 // True track angle (degrees, 0-360)
+	GroundSpeed *int     // Ground speed (kt)
+	TrackRate   *float64 // Track angle rate (degrees/s)
+	TAS         *int     // True airspeed (kt)
+}
+
+// BDS 5,0 plausibility limits
+const (
+	bds50MaxRoll        = 50.0 // Steepest roll angle accepted (degrees)
+	bds50MaxGroundSpeed = 600  // Highest ground speed accepted (kt)
+	bds50MaxTAS         = 500  // Highest true airspeed accepted (kt)
+	bds50MaxSpeedDiff   = 200  // Largest ground speed/airspeed difference accepted (kt)
+)
+
+// DecodeBDS50 decodes a 56-bit Comm-B MB field as BDS 5,0 (track and turn
+// report). It returns false when the field is not consistent with BDS 5,0.
+func DecodeBDS50(mb []byte) (*BDS50, bool) {
+	if len(mb) != 7 {
+		return nil, false
+	}
+
+	if !mbStatusConsistent(mb, 1, 2, 11) ||
+		!mbStatusConsistent(mb, 12, 13, 23) ||
+		!mbStatusConsistent(mb, 24, 25, 34) ||
+		!mbStatusConsistent(mb, 35, 36, 45) ||
+		!mbStatusConsistent(mb, 46, 47, 56) {
+		return nil, false
+	}
+
+	result := &BDS50{}
+
+	if mbBits(mb, 1, 1) == 1 {
+		roll := float64(mbSigned(mb, 2, 3, 11)) * 45 / 256
+		if math.Abs(roll) > bds50MaxRoll {
+			return nil, false
+		}
+		result.Roll = &roll
+	}
+
+	if mbBits(mb, 12, 12) == 1 {
+		track := angle360(float64(mbSigned(mb, 13, 14, 23)) * 90 / 512)
+		result.TrueTrack = &track
+	}
+
+	if mbBits(mb, 24, 24) == 1 {
+		gs := int(mbBits(mb, 25, 34)) * 2
+		if gs > bds50MaxGroundSpeed {
+			return nil, false
+		}
+		result.GroundSpeed = &gs
+	}
+
+	if mbBits(mb, 35, 35) == 1 {
+		rate := float64(mbSigned(mb, 36, 37, 45)) * 8 / 256
+		result.TrackRate = &rate
+	}
+
+	if mbBits(mb, 46, 46) == 1 {
+		tas := int(mbBits(mb, 47, 56)) * 2
+		if tas > bds50MaxTAS {
+			return nil, false
+		}
+		result.TAS = &tas
+	}
+
+	if result.GroundSpeed != nil && result.TAS != nil &&
+		math.Abs(float64(*result.GroundSpeed-*result.TAS)) > bds50MaxSpeedDiff {
+		return nil, false
+	}
+
+	if result.Roll == nil && result.TrueTrack == nil && result.GroundSpeed == nil &&
+		result.TrackRate == nil && result.TAS == nil {
+		return nil, false
+	}
+
+	return result, true
+}
+
+// BDS60 holds the heading and speed report (Comm-B BDS 6,0). Subfields whose
+// status bit is clear are left nil.
+type BDS60 struct {
+	MagHeading   *float64 // Magnetic heading (degrees, 0-360)
+	IAS          *int     // Indicated airspeed (kt)
+	Mach         *float64
+	BaroRate     *int // Barometric altitude rate (ft/min)
+	InertialRate *int // Inertial vertical velocity (ft/min)
+}
+
+// BDS 6,0 plausibility limits
+const (
+	bds60MaxIAS  = 500  // Highest indicated airspeed accepted (kt)
+	bds60MaxMach = 1.0  // Highest Mach number accepted
+	bds60MaxRate = 6000 // Steepest climb or descent accepted (ft/min)
+)
+
+// DecodeBDS60 decodes a 56-bit Comm-B MB field as BDS 6,0 (heading and speed
+// report). It returns false when the field is not consistent with BDS 6,0.
+func DecodeBDS60(mb []byte) (*BDS60, bool) {
+	if len(mb) != 7 {
+		return nil, false
+	}
+
+	if !mbStatusConsistent(mb, 1, 2, 12) ||
+		!mbStatusConsistent(mb, 13, 14, 23) ||
+		!mbStatusConsistent(mb, 24, 25, 34) ||
+		!mbStatusConsistent(mb, 35, 36, 45) ||
+		!mbStatusConsistent(mb, 46, 47, 56) {
+		return nil, false
+	}
+
+	result := &BDS60{}
+
+	if mbBits(mb, 1, 1) == 1 {
+		heading := angle360(float64(mbSigned(mb, 2, 3, 12)) * 90 / 512)
+		result.MagHeading = &heading
+	}
+
+	if mbBits(mb, 13, 13) == 1 {
+		ias := int(mbBits(mb, 14, 23))
+		if ias > bds60MaxIAS {
+			return nil, false
+		}
+		result.IAS = &ias
+	}
+
+	if mbBits(mb, 24, 24) == 1 {
+		mach := float64(mbBits(mb, 25, 34)) * 2.048 / 512
+		if mach > bds60MaxMach {
+			return nil, false
+		}
+		result.Mach = &mach
+	}
+
+	if mbBits(mb, 35, 35) == 1 {
+		rate := mbSigned(mb, 36, 37, 45) * 32
+		if rate > bds60MaxRate || rate < -bds60MaxRate {
+			return nil, false
+		}
+		result.BaroRate = &rate
+	}
+
+	if mbBits(mb, 46, 46) == 1 {
+		rate := mbSigned(mb, 47, 48, 56) * 32
+		if rate > bds60MaxRate || rate < -bds60MaxRate {
+			return nil, false
+		}
+		result.InertialRate = &rate
+	}
+
+	if result.MagHeading == nil && result.IAS == nil && result.Mach == nil &&
+		result.BaroRate == nil && result.InertialRate == nil {
+		return nil, false
+	}
+
+	return result, true
+}
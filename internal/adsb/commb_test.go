@@ -0,0 +1,297 @@
+package adsb
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecodeBDS40 tests selected vertical intention decoding and status-bit gating
+func TestDecodeBDS40(t *testing.T) {
+	tests := []struct {
+		name        string
+		mb          string
+		expectValid bool
+		expectMCP   *int
+		expectFMS   *int
+		expectQNH   *float64
+	}{
+		{
+			name:        "All subfields set",
+			mb:          "85E42F31300000",
+			expectValid: true,
+			expectMCP:   intPtr(3008),
+			expectFMS:   intPtr(3008),
+			expectQNH:   floatPtr(1020.0),
+		},
+		{
+			name:        "MCP altitude only",
+			mb:          "85E00000000000",
+			expectValid: true,
+			expectMCP:   intPtr(3008),
+		},
+		{
+			name:        "Value present with status bit clear",
+			mb:          "05E42F31300000",
+			expectValid: false,
+		},
+		{
+			name:        "Reserved bits set",
+			mb:          "85E42F31301000",
+			expectValid: false,
+		},
+		{
+			name:        "No subfields reported",
+			mb:          "00000000000000",
+			expectValid: false,
+		},
+		{
+			name:        "Standard pressure",
+			mb:          "00000030A80000",
+			expectValid: true,
+			expectQNH:   floatPtr(1013.2),
+		},
+		{
+			name:        "Lowest pressure setting",
+			mb:          "00000020000000",
+			expectValid: true,
+			expectQNH:   floatPtr(800.0),
+		},
+		{
+			name:        "Highest pressure setting",
+			mb:          "00000037700000",
+			expectValid: true,
+			expectQNH:   floatPtr(1100.0),
+		},
+		{
+			name:        "Implausible pressure setting",
+			mb:          "00000037720000",
+			expectValid: false,
+		},
+		{
+			name:        "Implausible selected altitude",
+			mb:          "FFE00000000000",
+			expectValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb, err := hex.DecodeString(tt.mb)
+			require.NoError(t, err)
+
+			result, ok := DecodeBDS40(mb)
+			assert.Equal(t, tt.expectValid, ok)
+			if !tt.expectValid {
+				assert.Nil(t, result)
+				return
+			}
+
+			require.NotNil(t, result)
+			assert.Equal(t, tt.expectMCP, result.MCPAltitude)
+			assert.Equal(t, tt.expectFMS, result.FMSAltitude)
+			if tt.expectQNH == nil {
+				assert.Nil(t, result.BaroSetting)
+			} else {
+				require.NotNil(t, result.BaroSetting)
+				assert.Equal(t, *tt.expectQNH, *result.BaroSetting)
+			}
+		})
+	}
+}
+
+// TestDecodeBDS50 tests track and turn report decoding, signs and plausibility
+func TestDecodeBDS50(t *testing.T) {
+	tests := []struct {
+		name        string
+		mb          string
+		expectValid bool
+		expectRoll  *float64
+		expectTrack *float64
+		expectGS    *int
+		expectTAS   *int
+	}{
+		{
+			name:        "Roll, track, speeds and track rate",
+			mb:          "81951536E024D4",
+			expectValid: true,
+			expectRoll:  floatPtr(2.109375),
+			expectTrack: floatPtr(114.2578125),
+			expectGS:    intPtr(438),
+			expectTAS:   intPtr(424),
+		},
+		{
+			name:        "Left roll",
+			mb:          "F8E00000000000",
+			expectValid: true,
+			expectRoll:  floatPtr(-10.01953125),
+		},
+		{
+			name:        "Negative track",
+			mb:          "001C0000000000",
+			expectValid: true,
+			expectTrack: floatPtr(270),
+		},
+		{
+			name:        "Implausible roll",
+			mb:          "AAA00000000000",
+			expectValid: false,
+		},
+		{
+			name:        "Ground speed and airspeed too far apart",
+			mb:          "0000013E800432",
+			expectValid: false,
+		},
+		{
+			name:        "Value present with status bit clear",
+			mb:          "01951536E024D4",
+			expectValid: false,
+		},
+		{
+			name:        "No subfields reported",
+			mb:          "00000000000000",
+			expectValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb, err := hex.DecodeString(tt.mb)
+			require.NoError(t, err)
+
+			result, ok := DecodeBDS50(mb)
+			assert.Equal(t, tt.expectValid, ok)
+			if !tt.expectValid {
+				assert.Nil(t, result)
+				return
+			}
+
+			require.NotNil(t, result)
+			assert.Equal(t, tt.expectRoll, result.Roll)
+			assert.Equal(t, tt.expectTrack, result.TrueTrack)
+			assert.Equal(t, tt.expectGS, result.GroundSpeed)
+			assert.Equal(t, tt.expectTAS, result.TAS)
+		})
+	}
+}
+
+// TestDecodeBDS60 tests heading and speed report decoding, signs and plausibility
+func TestDecodeBDS60(t *testing.T) {
+	tests := []struct {
+		name          string
+		mb            string
+		expectValid   bool
+		expectHeading *float64
+		expectIAS     *int
+		expectMach    *float64
+		expectRate    *int
+	}{
+		{
+			name:          "Heading, speeds and vertical rates",
+			mb:            "8F39F91A7E27C4",
+			expectValid:   true,
+			expectHeading: floatPtr(42.71484375),
+			expectIAS:     intPtr(252),
+			expectMach:    floatPtr(0.42),
+			expectRate:    intPtr(-1920),
+		},
+		{
+			name:          "Negative heading",
+			mb:            "F8E00000000000",
+			expectValid:   true,
+			expectHeading: floatPtr(339.9609375),
+		},
+		{
+			name:        "Implausible airspeed",
+			mb:          "000CB000000000",
+			expectValid: false,
+		},
+		{
+			name:        "Implausible vertical rate",
+			mb:          "0000000039C000",
+			expectValid: false,
+		},
+		{
+			name:        "Value present with status bit clear",
+			mb:          "0F39F91A7E27C4",
+			expectValid: false,
+		},
+		{
+			name:        "No subfields reported",
+			mb:          "00000000000000",
+			expectValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb, err := hex.DecodeString(tt.mb)
+			require.NoError(t, err)
+
+			result, ok := DecodeBDS60(mb)
+			assert.Equal(t, tt.expectValid, ok)
+			if !tt.expectValid {
+				assert.Nil(t, result)
+				return
+			}
+
+			require.NotNil(t, result)
+			assert.Equal(t, tt.expectHeading, result.MagHeading)
+			assert.Equal(t, tt.expectIAS, result.IAS)
+			if tt.expectMach == nil {
+				assert.Nil(t, result.Mach)
+			} else {
+				require.NotNil(t, result.Mach)
+				assert.InDelta(t, *tt.expectMach, *result.Mach, 1e-9)
+			}
+			assert.Equal(t, tt.expectRate, result.BaroRate)
+		})
+	}
+}
+
+// TestGetMB tests MB field extraction from Comm-B replies
+func TestGetMB(t *testing.T) {
+	data, err := hex.DecodeString("A000029C85E42F313000007047D3")
+	require.NoError(t, err)
+
+	msg := &ADSBMessage{}
+	copy(msg.Data[:], data)
+	assert.Equal(t, data[4:11], msg.GetMB())
+
+	msg.Data[0] = 0x8D // DF17
+	assert.Nil(t, msg.GetMB())
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// TestQNHOutlier tests flagging pressure settings far from the local QNH
+func TestQNHOutlier(t *testing.T) {
+	tests := []struct {
+		name     string
+		setting  float64
+		local    float64
+		expected bool
+	}{
+		{name: "Local setting", setting: 1020.0, local: 1021.0, expected: false},
+		{name: "At the tolerance", setting: 1011.0, local: 1021.0, expected: false},
+		{name: "Past the tolerance", setting: 1010.9, local: 1021.0, expected: true},
+		{name: "Standard pressure rounded down", setting: 1013.2, local: 990.0, expected: false},
+		{name: "Standard pressure rounded up", setting: 1013.3, local: 990.0, expected: false},
+		{name: "Near but not standard", setting: 1013.1, local: 990.0, expected: true},
+		{name: "Stuck on a high setting", setting: 1040.0, local: 1002.0, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, QNHOutlier(tt.setting, tt.local))
+		})
+	}
+}
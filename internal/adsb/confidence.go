@@ -0,0 +1,62 @@
+package adsb
+
+import "math"
+
+// Decode confidence weighting: the points each part contributes to the
+// 0-100 confidence of a message
+const (
+	confidenceCRCPoints    = 55 // How the frame passed the CRC check
+	confidenceSignalPoints = 30 // Preamble pulse level
+	confidenceScorePoints  = 15 // Demodulator score
+)
+
+// Signal levels in dBFS at and below which the signal part counts nothing,
+// and at and above which it counts in full; levels between scale linearly
+const (
+	ConfidenceWeakSignal   = -36.0
+	ConfidenceStrongSignal = -6.0
+)
+
+// maxMessageScore is the best score scoreMessage gives: a pristine CRC, a
+// known DF and a valid extended squitter type code
+const maxMessageScore = 1600
+
+// Confidence combines how a message passed the CRC check, its signal level
+// and its demodulator score into one 0-100 value to threshold on:
+//
+//   - CRC, 55 points: valid in full, one corrected bit half, two corrected
+//     bits a fifth
+//   - Signal, 30 points: linear from ConfidenceWeakSignal to
+//     ConfidenceStrongSignal
+//   - Score, 15 points: the share of the best possible score
+//
+// Messages that failed the CRC check, and unverifiable Comm-D segments,
+// have confidence 0.
+func Confidence(msg *ADSBMessage) int {
+	var crc float64
+	switch msg.CRCType {
+	case "valid":
+		crc = 1
+	case "corrected-1":
+		crc = 0.5
+	case "corrected-2":
+		crc = 0.2
+	default:
+		return 0
+	}
+
+	signal := (msg.Signal - ConfidenceWeakSignal) / (ConfidenceStrongSignal - ConfidenceWeakSignal)
+	score := float64(msg.Score) / maxMessageScore
+
+	return int(math.Round(confidenceCRCPoints*crc +
+		confidenceSignalPoints*clamp01(signal) +
+		confidenceScorePoints*clamp01(score)))
+}
+
+// clamp01 limits v to the range 0 to 1; NaN counts as 0
+func clamp01(v float64) float64 {
+	if !(v > 0) {
+		return 0
+	}
+	return math.Min(v, 1)
+}
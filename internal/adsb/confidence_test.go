@@ -0,0 +1,38 @@
+package adsb
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConfidence tests the decode confidence of messages ordered from most
+// to least trustworthy
+func TestConfidence(t *testing.T) {
+	ordered := []struct {
+		name     string
+		msg      ADSBMessage
+		expected int
+	}{
+		{name: "Valid, strong", msg: ADSBMessage{CRCType: "valid", Signal: -3, Score: 1600}, expected: 100},
+		{name: "Valid, medium", msg: ADSBMessage{CRCType: "valid", Signal: -21, Score: 1600}, expected: 85},
+		{name: "Corrected-1, strong", msg: ADSBMessage{CRCType: "corrected-1", Signal: -6, Score: 1350}, expected: 70},
+		{name: "Valid, weak", msg: ADSBMessage{CRCType: "valid", Signal: -40, Score: 1500}, expected: 69},
+		{name: "Corrected-1, weak", msg: ADSBMessage{CRCType: "corrected-1", Signal: -36, Score: 1350}, expected: 40},
+		{name: "Corrected-2, weak", msg: ADSBMessage{CRCType: "corrected-2", Signal: -45, Score: 1100}, expected: 21},
+		{name: "Corrected-2, no signal", msg: ADSBMessage{CRCType: "corrected-2", Signal: math.Inf(-1), Score: 300}, expected: 14},
+		{name: "Invalid", msg: ADSBMessage{CRCType: "invalid", Signal: -3, Score: -1}, expected: 0},
+		{name: "Comm-D", msg: ADSBMessage{CRCType: "comm-d", Signal: -3}, expected: 0},
+	}
+
+	previous := 101
+	for _, tt := range ordered {
+		t.Run(tt.name, func(t *testing.T) {
+			confidence := Confidence(&tt.msg)
+			assert.Equal(t, tt.expected, confidence)
+			assert.LessOrEqual(t, confidence, previous)
+			previous = confidence
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package adsb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCountryForICAO tests address-block country lookup
+func TestCountryForICAO(t *testing.T) {
+	tests := []struct {
+		name     string
+		icao     uint32
+		expected string
+	}{
+		{name: "United States", icao: 0xA835AF, expected: "United States"},
+		{name: "United Kingdom", icao: 0x406A0B, expected: "United Kingdom"},
+		{name: "Brazil", icao: 0xE48D6F, expected: "Brazil"},
+		{name: "Germany", icao: 0x3C6444, expected: "Germany"},
+		{name: "Ireland", icao: 0x4CA2D1, expected: "Ireland"},
+		{name: "First address of a block", icao: 0x3C0000, expected: "Germany"},
+		{name: "Last address of a block", icao: 0x3FFFFF, expected: "Germany"},
+		{name: "Small block", icao: 0x4D2001, expected: "Malta"},
+		{name: "Gap between blocks", icao: 0x4D0400, expected: ""},
+		{name: "Unallocated", icao: 0x000000, expected: ""},
+		{name: "Above table", icao: 0xFFFFFF, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CountryForICAO(tt.icao))
+		})
+	}
+}
+
+// TestICAOAllocationsSorted tests that the allocation table supports binary search
+func TestICAOAllocationsSorted(t *testing.T) {
+	for i, block := range icaoAllocations {
+		assert.LessOrEqual(t, block.start, block.end, block.country)
+		if i > 0 {
+			assert.Greater(t, block.start, icaoAllocations[i-1].end, block.country)
+		}
+	}
+}
+
+// TestClassifyICAO tests military and anonymous address classification
+func TestClassifyICAO(t *testing.T) {
+	tests := []struct {
+		name     string
+		icao     uint32
+		expected AddressClass
+	}{
+		{name: "US civil", icao: 0xA835AF, expected: AddressCivil},
+		{name: "US military", icao: 0xAE1234, expected: AddressMilitary},
+		{name: "US military, first address", icao: 0xADF7C8, expected: AddressMilitary},
+		{name: "US civil, below military block", icao: 0xADF7C7, expected: AddressCivil},
+		{name: "UK military", icao: 0x43C6F1, expected: AddressMilitary},
+		{name: "UK civil", icao: 0x406A0B, expected: AddressCivil},
+		{name: "Germany military", icao: 0x3F6E42, expected: AddressMilitary},
+		{name: "Unallocated gap", icao: 0x4D0400, expected: AddressAnonymous},
+		{name: "ICAO reserved", icao: 0xF00001, expected: AddressAnonymous},
+		{name: "Zero", icao: 0x000000, expected: AddressAnonymous},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ClassifyICAO(tt.icao))
+		})
+	}
+}
+
+// TestSpecialBlocks tests that the special-use table is sorted and that each
+// block lies within a single state allocation
+func TestSpecialBlocks(t *testing.T) {
+	for i, block := range specialBlocks {
+		assert.LessOrEqual(t, block.start, block.end, "block %06X", block.start)
+		if i > 0 {
+			assert.Greater(t, block.start, specialBlocks[i-1].end, "block %06X", block.start)
+		}
+
+		country := CountryForICAO(block.start)
+		assert.NotEmpty(t, country, "block %06X", block.start)
+		assert.Equal(t, country, CountryForICAO(block.end), "block %06X", block.start)
+	}
+}
@@ -1,19 +1,100 @@
 package adsb
 
 import (
+	"container/list"
 	"math"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"go1090/internal/clock"
 )
 
+// DefaultMaxTrackedAircraft caps how many aircraft the CPR decoder keeps frames for
+const DefaultMaxTrackedAircraft = 10000
+
+// DefaultPositionMaxAge is how long the last known position is reported when
+// a frame cannot be decoded
+const DefaultPositionMaxAge = 30 * time.Second
+
+// DefaultZoneRetryFrames is how many earlier frames of each parity are kept to
+// pair with when the latest even/odd pair straddles a latitude zone boundary
+const DefaultZoneRetryFrames = 2
+
+// maxCPRPairAge is the maximum time between even and odd frames for a global decode
+const maxCPRPairAge = 10 * time.Second
+
+// maxGlobalCheckAge is how long a global fix is trusted to catch single-frame
+// decodes resolved into the wrong zone. At 600 kt an aircraft covers well under
+// half a latitude zone (3 degrees) in that time.
+const maxGlobalCheckAge = 10 * time.Minute
+
+// CPR decode methods recorded in the diagnostics
+const (
+	CPRMethodBothFrames   = "both frames"
+	CPRMethodSingleFrame  = "single frame"
+	CPRMethodLastPosition = "last position"
+)
+
+// CPR decode rejection reasons recorded in the diagnostics
+const (
+	CPRRejectLatitudeRange = "latitude out of range"
+	CPRRejectZoneCrossing  = "latitude zone crossing"
+	CPRRejectStalePair     = "stale pairing"
+	CPRRejectAwaitingPair  = "awaiting even/odd pair"
+	CPRRejectGlobalFix     = "disagrees with global fix"
+)
+
+// cprPairResult holds the intermediate values of a two-frame CPR decode
+type cprPairResult struct {
+	J      int
+	EvenNL int
+	OddNL  int
+	Reject string
+}
+
+// CPRDiagnostics describes the CPR decoder state for a single aircraft
+type CPRDiagnostics struct {
+	ICAO         uint32
+	EvenFrame    *CPRFrame
+	OddFrame     *CPRFrame
+	EvenAge      time.Duration
+	OddAge       time.Duration
+	J            int
+	EvenNL       int
+	OddNL        int
+	Method       string
+	RejectReason string
+	LastPos      *Position
+}
+
 // CPRDecoder handles CPR position decoding
 type CPRDecoder struct {
 	aircraftPositions map[uint32]*AircraftPosition
 	positionMutex     sync.RWMutex
 	logger            *logrus.Logger
-	verbose           bool
+	tracer            *Tracer
+
+	// Least-recently-updated eviction, front is most recent
+	lru         *list.List
+	lruElements map[uint32]*list.Element
+	maxTracked  int
+
+	// Reference position for single-frame decoding
+	refLat, refLon float64
+
+	// Suppress single-frame positions until a global decode succeeded
+	requirePair bool
+
+	// Age limit of the last-position fallback, 0 disables it
+	positionMaxAge time.Duration
+
+	// Earlier frames kept per parity for zone crossing retries, 0 disables them
+	zoneRetryFrames int
+
+	clock clock.Clock
 }
 
 // NewCPRDecoder creates a new CPR decoder
@@ -21,16 +102,111 @@ func NewCPRDecoder(logger *logrus.Logger, verbose bool) *CPRDecoder {
 	return &CPRDecoder{
 		aircraftPositions: make(map[uint32]*AircraftPosition),
 		logger:            logger,
-		verbose:           verbose,
+		tracer:            NewTracer(logger, verbose),
+		lru:               list.New(),
+		lruElements:       make(map[uint32]*list.Element),
+		maxTracked:        DefaultMaxTrackedAircraft,
+		refLat:            -23.5505, // São Paulo
+		refLon:            -46.6333,
+		positionMaxAge:    DefaultPositionMaxAge,
+		zoneRetryFrames:   DefaultZoneRetryFrames,
+		clock:             clock.Real{},
+	}
+}
+
+// SetReferencePosition sets the receiver position used as the single-frame
+// decoding reference when no recent aircraft position is available
+func (c *CPRDecoder) SetReferencePosition(lat, lon float64) {
+	c.positionMutex.Lock()
+	defer c.positionMutex.Unlock()
+
+	c.refLat, c.refLon = lat, lon
+}
+
+// SetRequirePair suppresses single-frame and last-position output for an
+// aircraft until an even/odd pair has been decoded globally for it. Afterwards
+// single frames are decoded locally against its own position.
+func (c *CPRDecoder) SetRequirePair(require bool) {
+	c.positionMutex.Lock()
+	defer c.positionMutex.Unlock()
+
+	c.requirePair = require
+}
+
+// SetClock sets the clock frames are timestamped and aged by
+func (c *CPRDecoder) SetClock(clk clock.Clock) {
+	c.positionMutex.Lock()
+	defer c.positionMutex.Unlock()
+
+	c.clock = clk
+}
+
+// SetPositionMaxAge sets how long the last known position is reported for an
+// aircraft whose latest frame could not be decoded. Zero disables the fallback.
+func (c *CPRDecoder) SetPositionMaxAge(age time.Duration) {
+	c.positionMutex.Lock()
+	defer c.positionMutex.Unlock()
+
+	c.positionMaxAge = age
+}
+
+// SetZoneRetryFrames sets how many earlier frames of each parity are kept per
+// aircraft. When the latest even/odd pair falls into different latitude zones,
+// the new frame is paired with these instead. Zero disables the retry.
+func (c *CPRDecoder) SetZoneRetryFrames(n int) {
+	c.positionMutex.Lock()
+	defer c.positionMutex.Unlock()
+
+	c.zoneRetryFrames = n
+}
+
+// SetMaxTracked sets how many aircraft are tracked before the least recently
+// updated ones are evicted
+func (c *CPRDecoder) SetMaxTracked(max int) {
+	c.positionMutex.Lock()
+	defer c.positionMutex.Unlock()
+
+	c.maxTracked = max
+	c.evictLocked()
+}
+
+// touchLocked marks an aircraft as most recently updated. The caller must hold positionMutex.
+func (c *CPRDecoder) touchLocked(icao uint32) {
+	if elem, ok := c.lruElements[icao]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.lruElements[icao] = c.lru.PushFront(icao)
+	c.evictLocked()
+}
+
+// evictLocked drops the least recently updated aircraft until the map is within
+// the cap. The caller must hold positionMutex.
+func (c *CPRDecoder) evictLocked() {
+	if c.maxTracked <= 0 {
+		return
+	}
+	for c.lru.Len() > c.maxTracked {
+		oldest := c.lru.Back()
+		icao := c.lru.Remove(oldest).(uint32)
+		delete(c.lruElements, icao)
+		delete(c.aircraftPositions, icao)
 	}
 }
 
+// SetTracer shares a per-aircraft debug tracer with the decoder
+func (c *CPRDecoder) SetTracer(tracer *Tracer) {
+	c.tracer = tracer
+}
+
 // DecodeCPRPosition decodes CPR coordinates to actual lat/lon using proper CPR algorithm
 func (c *CPRDecoder) DecodeCPRPosition(icao uint32, fFlag uint8, latCPR, lonCPR uint32) (float64, float64) {
-	now := time.Now()
-
 	// Get or create aircraft position tracking
 	c.positionMutex.Lock()
+	defer c.positionMutex.Unlock()
+
+	now := c.clock.Now()
+
 	aircraft, exists := c.aircraftPositions[icao]
 	if !exists {
 		aircraft = &AircraftPosition{
@@ -39,7 +215,9 @@ func (c *CPRDecoder) DecodeCPRPosition(icao uint32, fFlag uint8, latCPR, lonCPR
 		}
 		c.aircraftPositions[icao] = aircraft
 	}
-	c.positionMutex.Unlock()
+	c.touchLocked(icao)
+	aircraft.Method = ""
+	aircraft.RejectReason = ""
 
 	// Store the new frame
 	newFrame := &CPRFrame{
@@ -50,15 +228,24 @@ func (c *CPRDecoder) DecodeCPRPosition(icao uint32, fFlag uint8, latCPR, lonCPR
 	}
 
 	if fFlag == 0 {
+		aircraft.EvenHistory = c.retainFrameLocked(aircraft.EvenHistory, aircraft.EvenFrame)
 		aircraft.EvenFrame = newFrame
 	} else {
+		aircraft.OddHistory = c.retainFrameLocked(aircraft.OddHistory, aircraft.OddFrame)
 		aircraft.OddFrame = newFrame
 	}
 
 	// Try to decode using both frames if available
 	if aircraft.EvenFrame != nil && aircraft.OddFrame != nil {
 		// Both frames available - use proper CPR decoding
-		lat, lon := c.decodeCPRBothFrames(aircraft.EvenFrame, aircraft.OddFrame)
+		lat, lon, result := c.decodeCPRBothFrames(icao, aircraft.EvenFrame, aircraft.OddFrame)
+		if result.Reject == CPRRejectZoneCrossing {
+			lat, lon, result = c.retryZoneCrossingLocked(icao, aircraft, newFrame, result)
+		}
+		aircraft.LastJ = result.J
+		aircraft.EvenNL = result.EvenNL
+		aircraft.OddNL = result.OddNL
+		aircraft.RejectReason = result.Reject
 		if lat != 0 || lon != 0 {
 			aircraft.LastPos = &Position{
 				Latitude:  lat,
@@ -66,16 +253,30 @@ func (c *CPRDecoder) DecodeCPRPosition(icao uint32, fFlag uint8, latCPR, lonCPR
 				Timestamp: now,
 			}
 			aircraft.LastUpdate = now
-
-			if c.verbose {
-				c.logger.Debugf("CPR decode: ICAO=%06X, both frames, lat=%.6f, lon=%.6f", icao, lat, lon)
+			aircraft.Method = CPRMethodBothFrames
+			aircraft.GlobalDecoded = true
+			aircraft.GlobalPos = &Position{
+				Latitude:  lat,
+				Longitude: lon,
+				Timestamp: now,
 			}
+
+			c.tracer.Debugf(icao, "CPR decode: ICAO=%06X, both frames, lat=%.6f, lon=%.6f", icao, lat, lon)
 			return lat, lon
 		}
 	}
 
+	// Without a global decode a lone frame may resolve to the wrong zone
+	if c.requirePair && !aircraft.GlobalDecoded {
+		if aircraft.RejectReason == "" {
+			aircraft.RejectReason = CPRRejectAwaitingPair
+		}
+		c.tracer.Debugf(icao, "CPR decode: ICAO=%06X, awaiting even/odd pair", icao)
+		return 0, 0
+	}
+
 	// Single frame decoding (less accurate)
-	lat, lon := c.decodeCPRSingleFrame(newFrame)
+	lat, lon := c.decodeCPRSingleFrame(icao, newFrame)
 	if lat != 0 || lon != 0 {
 		aircraft.LastPos = &Position{
 			Latitude:  lat,
@@ -83,24 +284,103 @@ func (c *CPRDecoder) DecodeCPRPosition(icao uint32, fFlag uint8, latCPR, lonCPR
 			Timestamp: now,
 		}
 		aircraft.LastUpdate = now
+		aircraft.Method = CPRMethodSingleFrame
 
-		if c.verbose {
-			c.logger.Debugf("CPR decode: ICAO=%06X, single frame, lat=%.6f, lon=%.6f", icao, lat, lon)
-		}
+		c.tracer.Debugf(icao, "CPR decode: ICAO=%06X, single frame, lat=%.6f, lon=%.6f", icao, lat, lon)
 		return lat, lon
 	}
 
 	// Use last known position if available and recent
-	if aircraft.LastPos != nil && now.Sub(aircraft.LastPos.Timestamp) < 30*time.Second {
-		if c.verbose {
-			c.logger.Debugf("CPR decode: ICAO=%06X, using last position, lat=%.6f, lon=%.6f", icao, aircraft.LastPos.Latitude, aircraft.LastPos.Longitude)
-		}
+	if c.positionMaxAge > 0 && aircraft.LastPos != nil && now.Sub(aircraft.LastPos.Timestamp) < c.positionMaxAge {
+		aircraft.Method = CPRMethodLastPosition
+		c.tracer.Debugf(icao, "CPR decode: ICAO=%06X, using last position, lat=%.6f, lon=%.6f", icao, aircraft.LastPos.Latitude, aircraft.LastPos.Longitude)
 		return aircraft.LastPos.Latitude, aircraft.LastPos.Longitude
 	}
 
 	return 0, 0
 }
 
+// retainFrameLocked pushes a frame about to be replaced onto the front of its
+// parity's history, keeping at most zoneRetryFrames. The caller must hold
+// positionMutex.
+func (c *CPRDecoder) retainFrameLocked(history []*CPRFrame, frame *CPRFrame) []*CPRFrame {
+	if frame == nil || c.zoneRetryFrames <= 0 {
+		return nil
+	}
+	history = append([]*CPRFrame{frame}, history...)
+	if len(history) > c.zoneRetryFrames {
+		history = history[:c.zoneRetryFrames]
+	}
+	return history
+}
+
+// retryZoneCrossingLocked pairs a new frame with the earlier frames of the
+// other parity, newest first, once its pairing with the latest one straddled
+// a latitude zone boundary. A frame with a corrupt latitude, or one sent just
+// before the aircraft crossed the boundary, then no longer blocks the decode
+// until the next frame of its parity. The first pair within one zone wins;
+// otherwise the original rejection stands. The caller must hold positionMutex.
+func (c *CPRDecoder) retryZoneCrossingLocked(icao uint32, aircraft *AircraftPosition, frame *CPRFrame, rejected cprPairResult) (float64, float64, cprPairResult) {
+	history := aircraft.OddHistory
+	if frame.FFlag != 0 {
+		history = aircraft.EvenHistory
+	}
+
+	for _, earlier := range history {
+		evenFrame, oddFrame := frame, earlier
+		if frame.FFlag != 0 {
+			evenFrame, oddFrame = earlier, frame
+		}
+		lat, lon, result := c.decodeCPRBothFrames(icao, evenFrame, oddFrame)
+		if result.Reject == "" {
+			c.tracer.Debugf(icao, "CPR: zone crossing resolved with a frame from %v earlier", frame.Timestamp.Sub(earlier.Timestamp))
+			return lat, lon, result
+		}
+	}
+	return 0, 0, rejected
+}
+
+// GetDiagnostics returns a snapshot of the CPR decoder state for every tracked aircraft, sorted by ICAO
+func (c *CPRDecoder) GetDiagnostics() []CPRDiagnostics {
+	c.positionMutex.RLock()
+	defer c.positionMutex.RUnlock()
+
+	now := c.clock.Now()
+
+	diagnostics := make([]CPRDiagnostics, 0, len(c.aircraftPositions))
+	for icao, aircraft := range c.aircraftPositions {
+		diag := CPRDiagnostics{
+			ICAO:         icao,
+			J:            aircraft.LastJ,
+			EvenNL:       aircraft.EvenNL,
+			OddNL:        aircraft.OddNL,
+			Method:       aircraft.Method,
+			RejectReason: aircraft.RejectReason,
+		}
+		if aircraft.EvenFrame != nil {
+			frame := *aircraft.EvenFrame
+			diag.EvenFrame = &frame
+			diag.EvenAge = now.Sub(frame.Timestamp)
+		}
+		if aircraft.OddFrame != nil {
+			frame := *aircraft.OddFrame
+			diag.OddFrame = &frame
+			diag.OddAge = now.Sub(frame.Timestamp)
+		}
+		if aircraft.LastPos != nil {
+			pos := *aircraft.LastPos
+			diag.LastPos = &pos
+		}
+		diagnostics = append(diagnostics, diag)
+	}
+
+	sort.Slice(diagnostics, func(i, j int) bool {
+		return diagnostics[i].ICAO < diagnostics[j].ICAO
+	})
+
+	return diagnostics
+}
+
 // cprModInt performs always positive MOD operation (dump1090 style)
 func cprModInt(a, b int) int {
 	res := a % b
@@ -111,10 +391,23 @@ func cprModInt(a, b int) int {
 }
 
 // decodeCPRBothFrames decodes position using both even and odd frames (dump1090 algorithm)
-func (c *CPRDecoder) decodeCPRBothFrames(evenFrame, oddFrame *CPRFrame) (float64, float64) {
+func (c *CPRDecoder) decodeCPRBothFrames(icao uint32, evenFrame, oddFrame *CPRFrame) (float64, float64, cprPairResult) {
 	// Use dump1090's exact CPR algorithm
 	const CPR_MAX = 131072.0 // 2^17
 
+	var result cprPairResult
+
+	// Frames too far apart in time may belong to different positions
+	pairAge := oddFrame.Timestamp.Sub(evenFrame.Timestamp)
+	if pairAge < 0 {
+		pairAge = -pairAge
+	}
+	if pairAge > maxCPRPairAge {
+		c.tracer.Debugf(icao, "CPR: stale frame pair, age=%v", pairAge)
+		result.Reject = CPRRejectStalePair
+		return 0, 0, result
+	}
+
 	AirDlat0 := 360.0 / 60.0 // 6.0 degrees for even frame
 	AirDlat1 := 360.0 / 59.0 // ~6.101 degrees for odd frame
 
@@ -125,6 +418,7 @@ func (c *CPRDecoder) decodeCPRBothFrames(evenFrame, oddFrame *CPRFrame) (float64
 
 	// Compute the Latitude Index "j" (dump1090 method)
 	j := int(math.Floor(((59*lat0 - 60*lat1) / CPR_MAX) + 0.5))
+	result.J = j
 
 	rlat0 := AirDlat0 * (float64(cprModInt(j, 60)) + lat0/CPR_MAX)
 	rlat1 := AirDlat1 * (float64(cprModInt(j, 59)) + lat1/CPR_MAX)
@@ -139,18 +433,19 @@ func (c *CPRDecoder) decodeCPRBothFrames(evenFrame, oddFrame *CPRFrame) (float64
 
 	// Check to see that the latitude is in range: -90 .. +90
 	if rlat0 < -90 || rlat0 > 90 || rlat1 < -90 || rlat1 > 90 {
-		if c.verbose {
-			c.logger.Debugf("CPR: bad latitude data, rlat0=%.6f, rlat1=%.6f", rlat0, rlat1)
-		}
-		return 0, 0 // bad data
+		c.tracer.Debugf(icao, "CPR: bad latitude data, rlat0=%.6f, rlat1=%.6f", rlat0, rlat1)
+		result.Reject = CPRRejectLatitudeRange
+		return 0, 0, result // bad data
 	}
 
+	result.EvenNL = c.cprNLTable(rlat0)
+	result.OddNL = c.cprNLTable(rlat1)
+
 	// Check that both are in the same latitude zone, or abort
-	if c.cprNLTable(rlat0) != c.cprNLTable(rlat1) {
-		if c.verbose {
-			c.logger.Debugf("CPR: positions crossed latitude zone, nl0=%d, nl1=%d", c.cprNLTable(rlat0), c.cprNLTable(rlat1))
-		}
-		return 0, 0 // positions crossed a latitude zone, try again later
+	if result.EvenNL != result.OddNL {
+		c.tracer.Debugf(icao, "CPR: positions crossed latitude zone, nl0=%d, nl1=%d", result.EvenNL, result.OddNL)
+		result.Reject = CPRRejectZoneCrossing
+		return 0, 0, result // positions crossed a latitude zone, try again later
 	}
 
 	// Determine which frame to use (use most recent)
@@ -175,11 +470,9 @@ func (c *CPRDecoder) decodeCPRBothFrames(evenFrame, oddFrame *CPRFrame) (float64
 	// Renormalize longitude to -180 .. +180 (dump1090 method)
 	rlon -= math.Floor((rlon+180)/360) * 360
 
-	if c.verbose {
-		c.logger.Debugf("Both frames CPR: lat=%.6f, lon=%.6f, j=%d", rlat, rlon, j)
-	}
+	c.tracer.Debugf(icao, "Both frames CPR: lat=%.6f, lon=%.6f, j=%d", rlat, rlon, j)
 
-	return rlat, rlon
+	return rlat, rlon, result
 }
 
 // cprNFunction returns the number of longitude zones (dump1090 style)
@@ -196,23 +489,28 @@ func (c *CPRDecoder) cprDlonFunction(lat float64, fflag int) float64 {
 	return 360.0 / float64(c.cprNFunction(lat, fflag))
 }
 
-// decodeCPRSingleFrame decodes position using a single frame (less accurate, requires reference position)
-func (c *CPRDecoder) decodeCPRSingleFrame(frame *CPRFrame) (float64, float64) {
-	// For single frame decoding, we need a reference position
-	// Use a reasonable default for Brazil region: São Paulo area
-	refLat := -23.5505 // São Paulo latitude
-	refLon := -46.6333 // São Paulo longitude
-
-	// Try to use a more recent known position if available
-	c.positionMutex.Lock()
-	for _, aircraft := range c.aircraftPositions {
-		if aircraft.LastPos != nil && time.Since(aircraft.LastPos.Timestamp) < 5*time.Minute {
-			refLat = aircraft.LastPos.Latitude
-			refLon = aircraft.LastPos.Longitude
-			break
+// decodeCPRSingleFrame decodes position using a single frame (less accurate, requires reference position).
+// The caller must hold positionMutex.
+func (c *CPRDecoder) decodeCPRSingleFrame(icao uint32, frame *CPRFrame) (float64, float64) {
+	// For single frame decoding, we need a reference position: the
+	// receiver's when configured, otherwise the São Paulo area default
+	refLat, refLon := c.refLat, c.refLon
+
+	if own, ok := c.aircraftPositions[icao]; ok && own.GlobalDecoded && own.LastPos != nil &&
+		c.clock.Now().Sub(own.LastPos.Timestamp) < 5*time.Minute {
+		// The aircraft's own globally decoded position is the best reference
+		refLat = own.LastPos.Latitude
+		refLon = own.LastPos.Longitude
+	} else {
+		// Try to use a more recent known position if available
+		for _, aircraft := range c.aircraftPositions {
+			if aircraft.LastPos != nil && c.clock.Now().Sub(aircraft.LastPos.Timestamp) < 5*time.Minute {
+				refLat = aircraft.LastPos.Latitude
+				refLon = aircraft.LastPos.Longitude
+				break
+			}
 		}
 	}
-	c.positionMutex.Unlock()
 
 	const CPR_MAX = 131072.0 // 2^17
 
@@ -259,16 +557,27 @@ func (c *CPRDecoder) decodeCPRSingleFrame(frame *CPRFrame) (float64, float64) {
 
 	// Validate the result
 	if rlat < -90 || rlat > 90 {
-		if c.verbose {
-			c.logger.Debugf("Single frame CPR: invalid latitude %.6f", rlat)
-		}
+		c.tracer.Debugf(icao, "Single frame CPR: invalid latitude %.6f", rlat)
 		return 0, 0
 	}
 
-	if c.verbose {
-		c.logger.Debugf("Single frame CPR: lat=%.6f, lon=%.6f (ref: %.6f, %.6f)", rlat, rlon, refLat, refLon)
+	// A reference near half a zone away resolves the frame into the
+	// neighbouring zone, a whole zone off; the aircraft's last global fix
+	// cannot be more than half a zone from the true position
+	if own, ok := c.aircraftPositions[icao]; ok && own.GlobalPos != nil && c.clock.Now().Sub(own.GlobalPos.Timestamp) < maxGlobalCheckAge {
+		dlat := math.Abs(rlat - own.GlobalPos.Latitude)
+		dlonFix := math.Abs(rlon - own.GlobalPos.Longitude)
+		dlonFix = math.Min(dlonFix, 360-dlonFix)
+		if dlat > AirDlat/2 || dlonFix > dlon/2 {
+			own.RejectReason = CPRRejectGlobalFix
+			c.tracer.Debugf(icao, "Single frame CPR: lat=%.6f, lon=%.6f (ref: %.6f, %.6f) is a zone off the global fix %.6f, %.6f",
+				rlat, rlon, refLat, refLon, own.GlobalPos.Latitude, own.GlobalPos.Longitude)
+			return 0, 0
+		}
 	}
 
+	c.tracer.Debugf(icao, "Single frame CPR: lat=%.6f, lon=%.6f (ref: %.6f, %.6f)", rlat, rlon, refLat, refLon)
+
 	return rlat, rlon
 }
 
@@ -2,9 +2,13 @@ package adsb
 
 import (
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go1090/internal/clock"
 )
 
 // TestNewCPRDecoder tests the CPR decoder constructor
@@ -208,3 +212,274 @@ func TestCPRConstants(t *testing.T) {
 		})
 	}
 }
+
+// TestCPRDiagnostics tests that decode attempts are recorded per aircraft
+func TestCPRDiagnostics(t *testing.T) {
+	logger := logrus.New()
+	mock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	decoder := NewCPRDecoder(logger, false)
+	decoder.SetClock(mock)
+
+	// Valid pair: even then odd frame for 52.257N 3.919E
+	decoder.DecodeCPRPosition(0x40621D, 0, 93000, 51372)
+	mock.Advance(time.Second)
+	lat, lon := decoder.DecodeCPRPosition(0x40621D, 1, 74158, 50194)
+	assert.InDelta(t, 52.2657, lat, 0.001)
+	assert.InDelta(t, 3.9389, lon, 0.001)
+
+	// Pair whose latitudes fall into different NL zones
+	decoder.DecodeCPRPosition(0x123456, 0, 0, 0)
+	decoder.DecodeCPRPosition(0x123456, 1, 31279, 0)
+
+	// Pair whose even frame is too old to be combined
+	decoder.DecodeCPRPosition(0xABCDEF, 0, 93000, 51372)
+	mock.Advance(time.Minute)
+	decoder.DecodeCPRPosition(0xABCDEF, 1, 74158, 50194)
+
+	diagnostics := decoder.GetDiagnostics()
+	require.Len(t, diagnostics, 3)
+
+	crossing := diagnostics[0]
+	assert.Equal(t, uint32(0x123456), crossing.ICAO)
+	assert.Equal(t, CPRRejectZoneCrossing, crossing.RejectReason)
+	assert.Equal(t, 5, crossing.EvenNL)
+	assert.Equal(t, 6, crossing.OddNL)
+
+	valid := diagnostics[1]
+	assert.Equal(t, uint32(0x40621D), valid.ICAO)
+	assert.Equal(t, CPRMethodBothFrames, valid.Method)
+	assert.Empty(t, valid.RejectReason)
+	assert.Equal(t, 8, valid.J)
+	assert.Equal(t, 36, valid.EvenNL)
+	assert.Equal(t, 36, valid.OddNL)
+	require.NotNil(t, valid.EvenFrame)
+	require.NotNil(t, valid.OddFrame)
+	assert.Equal(t, uint32(93000), valid.EvenFrame.LatCPR)
+	assert.Equal(t, uint32(74158), valid.OddFrame.LatCPR)
+	require.NotNil(t, valid.LastPos)
+
+	stale := diagnostics[2]
+	assert.Equal(t, uint32(0xABCDEF), stale.ICAO)
+	assert.Equal(t, CPRRejectStalePair, stale.RejectReason)
+	assert.GreaterOrEqual(t, stale.EvenAge, time.Minute)
+}
+
+// TestCPRStalePair tests that a global decode only pairs frames received
+// within maxCPRPairAge of each other, in either order
+func TestCPRStalePair(t *testing.T) {
+	decoder := NewCPRDecoder(logrus.New(), false)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		oddAt  time.Duration // After the even frame
+		reject string
+	}{
+		{name: "9 s apart", oddAt: 9 * time.Second},
+		{name: "odd 9 s first", oddAt: -9 * time.Second},
+		{name: "11 s apart", oddAt: 11 * time.Second, reject: CPRRejectStalePair},
+		{name: "odd 11 s first", oddAt: -11 * time.Second, reject: CPRRejectStalePair},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			even := &CPRFrame{LatCPR: 93000, LonCPR: 51372, FFlag: 0, Timestamp: start}
+			odd := &CPRFrame{LatCPR: 74158, LonCPR: 50194, FFlag: 1, Timestamp: start.Add(tt.oddAt)}
+
+			lat, lon, result := decoder.decodeCPRBothFrames(0x40621D, even, odd)
+			assert.Equal(t, tt.reject, result.Reject)
+			if tt.reject != "" {
+				assert.Zero(t, lat)
+				assert.Zero(t, lon)
+				return
+			}
+			// Longitude is taken from the newer frame
+			assert.InDelta(t, 52.26, lat, 0.01)
+			assert.InDelta(t, 3.93, lon, 0.02)
+		})
+	}
+}
+
+// TestCPRMaxTracked tests that the least recently updated aircraft are evicted
+func TestCPRMaxTracked(t *testing.T) {
+	logger := logrus.New()
+	decoder := NewCPRDecoder(logger, false)
+	decoder.SetMaxTracked(3)
+
+	for icao := uint32(0x100001); icao <= 0x100005; icao++ {
+		decoder.DecodeCPRPosition(icao, 0, 93000, 51372)
+	}
+
+	// Oldest two evicted, newest three retained
+	assert.Len(t, decoder.aircraftPositions, 3)
+	assert.NotContains(t, decoder.aircraftPositions, uint32(0x100001))
+	assert.NotContains(t, decoder.aircraftPositions, uint32(0x100002))
+	for icao := uint32(0x100003); icao <= 0x100005; icao++ {
+		assert.Contains(t, decoder.aircraftPositions, icao)
+	}
+
+	// A fresh update protects an old entry from the next eviction
+	decoder.DecodeCPRPosition(0x100003, 1, 74158, 50194)
+	decoder.DecodeCPRPosition(0x100006, 0, 93000, 51372)
+	assert.Contains(t, decoder.aircraftPositions, uint32(0x100003))
+	assert.NotContains(t, decoder.aircraftPositions, uint32(0x100004))
+	assert.Equal(t, len(decoder.aircraftPositions), decoder.lru.Len())
+
+	// Lowering the cap evicts immediately
+	decoder.SetMaxTracked(1)
+	assert.Len(t, decoder.aircraftPositions, 1)
+	assert.Contains(t, decoder.aircraftPositions, uint32(0x100006))
+}
+
+// TestCPRRequirePair tests that a lone frame yields no position until an
+// even/odd pair has been decoded for the aircraft
+func TestCPRRequirePair(t *testing.T) {
+	logger := logrus.New()
+	decoder := NewCPRDecoder(logger, false)
+	decoder.SetRequirePair(true)
+
+	// Another aircraft's position would otherwise serve as the reference
+	decoder.DecodeCPRPosition(0x484412, 0, 93000, 51372)
+	decoder.DecodeCPRPosition(0x484412, 1, 74158, 50194)
+
+	lat, lon := decoder.DecodeCPRPosition(0x40621D, 0, 93000, 51372)
+	assert.Zero(t, lat)
+	assert.Zero(t, lon)
+	assert.Equal(t, CPRRejectAwaitingPair, decoder.aircraftPositions[0x40621D].RejectReason)
+
+	// The odd frame completes the pair
+	lat, lon = decoder.DecodeCPRPosition(0x40621D, 1, 74158, 50194)
+	assert.InDelta(t, 52.2657, lat, 0.001)
+	assert.InDelta(t, 3.9389, lon, 0.001)
+
+	// Once decoded globally, a lone frame is decoded locally
+	decoder.aircraftPositions[0x40621D].OddFrame = nil
+	lat, lon = decoder.DecodeCPRPosition(0x40621D, 0, 93000, 51372)
+	assert.Equal(t, CPRMethodSingleFrame, decoder.aircraftPositions[0x40621D].Method)
+	assert.InDelta(t, 52.2572, lat, 0.001)
+	assert.InDelta(t, 3.9194, lon, 0.001)
+}
+
+// TestCPRPositionMaxAge tests that the last known position stands in for an
+// undecodable frame only until it is older than the configured limit
+func TestCPRPositionMaxAge(t *testing.T) {
+	const icao = 0x40621D
+	mock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	newDecoder := func(maxAge time.Duration) *CPRDecoder {
+		decoder := NewCPRDecoder(logrus.New(), false)
+		decoder.SetClock(mock)
+		decoder.SetPositionMaxAge(maxAge)
+		// Against a reference this close to the pole the frame below
+		// decodes beyond 90 degrees, so only the fallback can answer
+		decoder.aircraftPositions[icao] = &AircraftPosition{
+			ICAO:    icao,
+			LastPos: &Position{Latitude: 89.9, Longitude: 10, Timestamp: mock.Now()},
+		}
+		return decoder
+	}
+
+	t.Run("Within the limit", func(t *testing.T) {
+		decoder := newDecoder(DefaultPositionMaxAge)
+		mock.Advance(DefaultPositionMaxAge - time.Second)
+		lat, lon := decoder.DecodeCPRPosition(icao, 0, 39321, 0)
+		assert.Equal(t, 89.9, lat)
+		assert.Equal(t, 10.0, lon)
+		assert.Equal(t, CPRMethodLastPosition, decoder.aircraftPositions[icao].Method)
+	})
+
+	t.Run("Past the limit", func(t *testing.T) {
+		decoder := newDecoder(DefaultPositionMaxAge)
+		mock.Advance(DefaultPositionMaxAge + time.Second)
+		lat, lon := decoder.DecodeCPRPosition(icao, 0, 39321, 0)
+		assert.Zero(t, lat)
+		assert.Zero(t, lon)
+		assert.Empty(t, decoder.aircraftPositions[icao].Method)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		decoder := newDecoder(0)
+		lat, lon := decoder.DecodeCPRPosition(icao, 0, 39321, 0)
+		assert.Zero(t, lat)
+		assert.Zero(t, lon)
+	})
+}
+
+// TestCPRGlobalFixCheck tests that a single frame resolved into the wrong
+// latitude zone by a distant reference is rejected against the aircraft's
+// last global fix
+func TestCPRGlobalFixCheck(t *testing.T) {
+	const icao = 0x40621D
+	mock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	decoder := NewCPRDecoder(logrus.New(), false)
+	decoder.SetClock(mock)
+	decoder.SetPositionMaxAge(0)
+	// 3.3 degrees south of the aircraft, just past half an even zone
+	decoder.SetReferencePosition(48.95, 3.9)
+
+	decoder.DecodeCPRPosition(icao, 0, 93000, 51372)
+	lat, _ := decoder.DecodeCPRPosition(icao, 1, 74158, 50194)
+	require.InDelta(t, 52.2572, lat, 0.001)
+
+	// Once the pair and own position are too old to serve, the receiver is
+	// the reference and the even frame lands a zone south
+	mock.Advance(6 * time.Minute)
+	lat, lon := decoder.DecodeCPRPosition(icao, 0, 93000, 51372)
+	assert.Zero(t, lat)
+	assert.Zero(t, lon)
+	assert.Equal(t, CPRRejectGlobalFix, decoder.aircraftPositions[icao].RejectReason)
+
+	// Without a global fix the same decode goes through
+	other := NewCPRDecoder(logrus.New(), false)
+	other.SetReferencePosition(48.95, 3.9)
+	lat, _ = other.DecodeCPRPosition(icao, 0, 93000, 51372)
+	assert.InDelta(t, 52.2572-6, lat, 0.001)
+
+	// Past the check age the old fix no longer vetoes
+	mock.Advance(maxGlobalCheckAge)
+	lat, _ = decoder.DecodeCPRPosition(icao, 0, 93000, 51372)
+	assert.InDelta(t, 52.2572-6, lat, 0.001)
+}
+
+// TestCPRZoneRetry tests that a frame whose pairing with the latest frame of
+// the other parity crosses a latitude zone is paired with an earlier one
+func TestCPRZoneRetry(t *testing.T) {
+	const icao = 0x40621D
+
+	decode := func(retryFrames int) (float64, float64, *AircraftPosition) {
+		mock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+		decoder := NewCPRDecoder(logrus.New(), false)
+		decoder.SetClock(mock)
+		decoder.SetRequirePair(true)
+		decoder.SetZoneRetryFrames(retryFrames)
+
+		// A good odd frame, then one whose latitude lands a zone south of
+		// the even frame that follows
+		decoder.DecodeCPRPosition(icao, 1, 74158, 50194)
+		mock.Advance(time.Second)
+		decoder.DecodeCPRPosition(icao, 1, 70000, 50194)
+		mock.Advance(time.Second)
+		lat, lon := decoder.DecodeCPRPosition(icao, 0, 93000, 51372)
+		return lat, lon, decoder.aircraftPositions[icao]
+	}
+
+	t.Run("Resolved on the third frame", func(t *testing.T) {
+		lat, lon, aircraft := decode(DefaultZoneRetryFrames)
+		assert.InDelta(t, 52.2572, lat, 0.001)
+		assert.InDelta(t, 3.9194, lon, 0.001)
+		assert.Equal(t, CPRMethodBothFrames, aircraft.Method)
+		assert.Empty(t, aircraft.RejectReason)
+		assert.Equal(t, 36, aircraft.OddNL)
+		require.Len(t, aircraft.OddHistory, 1)
+		assert.Equal(t, uint32(74158), aircraft.OddHistory[0].LatCPR)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		lat, lon, aircraft := decode(0)
+		assert.Zero(t, lat)
+		assert.Zero(t, lon)
+		assert.Equal(t, CPRRejectZoneCrossing, aircraft.RejectReason)
+		assert.Empty(t, aircraft.OddHistory)
+	})
+}
@@ -31,7 +31,8 @@ func init() {
 
 // initErrorCorrectionTables initializes tables for single and two-bit error correction
 func initErrorCorrectionTables() {
-	// Single bit error table
+	// Single bit error table. Syndromes are taken over the whole 112-bit
+	// frame, matching how received messages are checked.
 	for i := 0; i < 112; i++ {
 		msg := make([]byte, 14)
 		// Set the bit at position i
@@ -40,7 +41,7 @@ func initErrorCorrectionTables() {
 		if bytePos < 14 {
 			msg[bytePos] = 1 << bitPos
 		}
-		crcErrorSingleBitTable[i] = calculateCRCRaw(msg[:11])
+		crcErrorSingleBitTable[i] = calculateCRCRaw(msg)
 	}
 
 	// Two bit error table (simplified version)
@@ -57,7 +58,7 @@ func initErrorCorrectionTables() {
 				if bytePos2 < 14 {
 					msg[bytePos2] |= 1 << bitPos2
 				}
-				crcErrorTwoBitTable[i*112+j] = calculateCRCRaw(msg[:11])
+				crcErrorTwoBitTable[i*112+j] = calculateCRCRaw(msg)
 			}
 		}
 	}
@@ -77,6 +78,22 @@ func calculateCRCRaw(data []byte) uint32 {
 	return rem
 }
 
+// overlaidParity returns the parity field of a frame XOR the CRC of its other
+// bits: what the transponder overlaid on the parity, such as the address of
+// an address/parity (AP) reply
+func overlaidParity(frame []byte) uint32 {
+	n := len(frame)
+	parity := uint32(frame[n-3])<<16 | uint32(frame[n-2])<<8 | uint32(frame[n-1])
+	return calculateCRCRaw(frame[:n-3]) ^ parity
+}
+
+// df11Interrogator returns the parity field of a DF11 reply XOR the CRC of
+// its other bits. Only the low 7 bits (the CL/IC interrogator code) may be set
+// in a genuine reply.
+func df11Interrogator(msg *ADSBMessage) uint32 {
+	return overlaidParity(msg.Data[:MessageLength(11)])
+}
+
 // CalculateCRC calculates the ADS-B CRC-24 checksum using Mode S standard (from dump1090)
 func CalculateCRC(data []byte) uint32 {
 	return calculateCRCRaw(data)
@@ -84,10 +101,25 @@ func CalculateCRC(data []byte) uint32 {
 
 // ValidateAndCorrectMessage performs CRC validation and error correction (dump1090-style)
 func ValidateAndCorrectMessage(msg *ADSBMessage) (uint64, uint64, uint64) {
+	return ValidateMessage(msg, true)
+}
+
+// ValidateMessage performs CRC validation, repairing one- and two-bit errors
+// only when correct is set. Without correction only messages whose raw CRC
+// already validates are accepted. With no known addresses, frames that
+// overlay the address on their parity are only accepted for address 0.
+func ValidateMessage(msg *ADSBMessage, correct bool) (uint64, uint64, uint64) {
+	return ValidateMessageKnown(msg, correct, nil)
+}
+
+// ValidateMessageKnown is ValidateMessage, verifying frames that overlay the
+// address on their parity, surveillance and Comm-B replies and Comm-D
+// segments, against the addresses in known as of the message's timestamp
+func ValidateMessageKnown(msg *ADSBMessage, correct bool, known *AddressTable) (uint64, uint64, uint64) {
 	var singleBitErrors, twoBitErrors, correctedMessages uint64
 
 	// Get DF (Downlink Format) to determine message validity
-	df := msg.Data[0] >> 3
+	df := DecodeDF(msg.Data[0])
 
 	// Pre-filter invalid DF codes (dump1090 style)
 	validDF := false
@@ -105,18 +137,30 @@ func ValidateAndCorrectMessage(msg *ADSBMessage) (uint64, uint64, uint64) {
 		return singleBitErrors, twoBitErrors, correctedMessages
 	}
 
-	// Determine message length
-	msgLen := 14 // Long message
-	if df == 0 || df == 4 || df == 5 || df == 11 {
-		msgLen = 7 // Short message
+	// Comm-D ELM segments are any frame starting with bits 11, so half of
+	// all noise decodes as one. Only a segment whose overlaid address is a
+	// known aircraft is marked for the demodulator to skip the full 112 bits
+	// and hand on for reassembly; it is still not valid, as the interrogator
+	// context in its parity is not checked.
+	if df == 24 {
+		msg.Valid = false
+		msg.CRCType = "invalid"
+		if known.Known(elmAddress(msg), msg.Timestamp) {
+			msg.CRCType = "comm-d"
+		}
+		msg.ErrorsCorrected = 0
+		return singleBitErrors, twoBitErrors, correctedMessages
 	}
 
+	// Determine message length
+	msgLen := MessageLength(df)
+
 	// Calculate CRC using dump1090 method
 	crc := calculateCRCRaw(msg.Data[:msgLen])
 	msg.CRC = crc
 
 	// For DF17/18, CRC should be 0
-	// For DF11, CRC should have low 7 bits as 0 (IID field)
+	// For DF11, the parity field may carry an interrogator code in its low 7 bits
 	if df == 17 || df == 18 {
 		if crc == 0 {
 			msg.Valid = true
@@ -125,15 +169,17 @@ func ValidateAndCorrectMessage(msg *ADSBMessage) (uint64, uint64, uint64) {
 			return singleBitErrors, twoBitErrors, correctedMessages
 		}
 	} else if df == 11 {
-		if (crc & 0xFFFF80) == 0 {
+		if df11Interrogator(msg)&0xFFFF80 == 0 {
 			msg.Valid = true
 			msg.CRCType = "valid"
 			msg.ErrorsCorrected = 0
 			return singleBitErrors, twoBitErrors, correctedMessages
 		}
 	} else {
-		// For other DF types, accept if CRC is 0
-		if crc == 0 {
+		// The other formats overlay the address on the parity (AP), so the
+		// residual is the address: accept it when it is a known aircraft,
+		// or 0 as before
+		if crc == 0 || known.Known(overlaidParity(msg.Data[:msgLen]), msg.Timestamp) {
 			msg.Valid = true
 			msg.CRCType = "valid"
 			msg.ErrorsCorrected = 0
@@ -142,12 +188,14 @@ func ValidateAndCorrectMessage(msg *ADSBMessage) (uint64, uint64, uint64) {
 	}
 
 	// Only try error correction for DF11/17/18
-	if df == 11 || df == 17 || df == 18 {
-		// Try single-bit error correction
-		for i := 0; i < len(crcErrorSingleBitTable); i++ {
+	if correct && (df == 11 || df == 17 || df == 18) {
+		// Try single-bit error correction. A short message has the same
+		// syndromes as the last 56 bits of a long one.
+		offset := (LongMessageBytes - msgLen) * 8
+		for i := offset; i < len(crcErrorSingleBitTable); i++ {
 			if crcErrorSingleBitTable[i] == crc {
 				// Found single bit error
-				bytePos := i / 8
+				bytePos := (i - offset) / 8
 				bitPos := 7 - (i % 8)
 				if bytePos < msgLen {
 					msg.Data[bytePos] ^= 1 << bitPos
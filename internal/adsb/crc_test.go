@@ -0,0 +1,40 @@
+package adsb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSingleBitCorrection tests that a frame with any one bit flipped is
+// repaired at that bit, for long and short messages
+func TestSingleBitCorrection(t *testing.T) {
+	es := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98}
+
+	// DF11 all-call reply from 4840D6 with interrogator code 0
+	allCall := []byte{0x5D, 0x48, 0x40, 0xD6, 0, 0, 0}
+	parity := CalculateCRC(allCall[:4])
+	allCall[4], allCall[5], allCall[6] = byte(parity>>16), byte(parity>>8), byte(parity)
+
+	for _, frame := range [][]byte{es, allCall} {
+		// A flip in the low 7 parity bits of a DF11 reply reads as a
+		// non-zero interrogator code rather than an error
+		last := len(frame) * 8
+		if DecodeDF(frame[0]) == 11 {
+			last -= 7
+		}
+		for bit := 8; bit < last; bit++ {
+			msg := &ADSBMessage{}
+			copy(msg.Data[:], frame)
+			msg.Data[bit/8] ^= 1 << (7 - bit%8)
+
+			singleBit, _, corrected := ValidateAndCorrectMessage(msg)
+			require.True(t, msg.Valid, "DF%d bit %d", DecodeDF(frame[0]), bit)
+			assert.Equal(t, "corrected-1", msg.CRCType, "DF%d bit %d", DecodeDF(frame[0]), bit)
+			assert.Equal(t, frame, msg.Data[:len(frame)], "DF%d bit %d", DecodeDF(frame[0]), bit)
+			assert.Equal(t, uint64(1), singleBit)
+			assert.Equal(t, uint64(1), corrected)
+		}
+	}
+}
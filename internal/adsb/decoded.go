@@ -0,0 +1,125 @@
+package adsb
+
+import (
+	"time"
+)
+
+// DecodedMessage holds the fields extracted from a single message, ready to be
+// formatted for output. Optional fields are nil (or empty) when not present.
+type DecodedMessage struct {
+	Timestamp        time.Time `json:"timestamp"`
+	ICAO             uint32    `json:"-"`
+	Hex              string    `json:"hex"`
+	Country          string    `json:"country,omitempty"` // State of registration from the address block
+	Signal           float64   `json:"rssi,omitempty"`    // Preamble pulse level in dBFS
+	DF               uint8     `json:"df"`
+	TypeCode         uint8     `json:"tc,omitempty"`
+	Source           string    `json:"source,omitempty"` // Extended squitter sender, e.g. "tisb" for ground-derived tracks
+	TransmissionType int       `json:"-"`                // SBS MSG transmission type (1-8)
+	CRCType          string    `json:"-"`                // "valid", "corrected-1" or "corrected-2"
+	Confidence       int       `json:"confidence"`       // 0-100 from CRC, signal and score, see adsb.Confidence
+	Raw              string    `json:"raw,omitempty"`    // Frame in hex, with --emit-raw-comment only
+
+	Callsign     string   `json:"flight,omitempty"`
+	Altitude     *int     `json:"alt_baro,omitempty"`
+	AltitudeGeom *int     `json:"alt_geom,omitempty"` // GNSS height (HAE), TC20-22
+	GroundSpeed  *int     `json:"gs,omitempty"`
+	Track        *float64 `json:"track,omitempty"`
+	VerticalRate *int     `json:"baro_rate,omitempty"`
+	IntentChange *bool    `json:"intent_change,omitempty"` // Velocity header: manoeuvre intent is changing
+	IFRCapable   *bool    `json:"ifr_capable,omitempty"`   // Velocity header: IFR-capable equipage
+	ADSBVersion  *int     `json:"adsb_version,omitempty"`  // Operational status (TC31): 0, 1 or 2
+	Latitude     *float64 `json:"lat,omitempty"`
+	Longitude    *float64 `json:"lon,omitempty"`
+	Squawk       string   `json:"squawk,omitempty"`
+	Alert        bool     `json:"alert,omitempty"`
+	Emergency    string   `json:"emergency,omitempty"` // "general", "nordo", "unlawful"
+	SPI          bool     `json:"spi,omitempty"`
+	OnGround     bool     `json:"on_ground"`
+	GroundKnown  bool     `json:"-"`           // OnGround is stated by the message rather than carried over or assumed
+	Spontaneous  bool     `json:"spontaneous"` // Squitter rather than a reply to an interrogation
+
+	// Airborne position header: transmitting from a single antenna (NIC
+	// supplement-B from ADS-B version 2)
+	SingleAntenna *bool `json:"single_antenna,omitempty"`
+
+	// Position integrity: NIC (version 1+) and horizontal containment radius
+	// in metres, from the type code refined by the aircraft's ADS-B version
+	// and NIC supplements
+	NIC *int     `json:"nic,omitempty"`
+	Rc  *float64 `json:"rc,omitempty"`
+
+	// NIC supplements from operational status: A (version 1+) and, on the
+	// surface, C (version 2)
+	NICSuppA *bool `json:"nic_a,omitempty"`
+	NICSuppC *bool `json:"nic_c,omitempty"`
+
+	// Length/width from surface operational status (TC31 subtype 1, version 1+)
+	Size *AircraftSize `json:"aircraft_size,omitempty"`
+
+	// Equipage from operational status (TC31, version 1+): capability class
+	// and operational mode flags
+	TCAS     *bool `json:"tcas,omitempty"`           // TCAS/ACAS operational
+	ES1090In *bool `json:"1090es_in,omitempty"`      // Receives 1090ES (version 2)
+	UATIn    *bool `json:"uat_in,omitempty"`         // Receives UAT (version 2)
+	RAActive *bool `json:"tcas_ra_active,omitempty"` // A resolution advisory is active
+
+	// Barometric altitude integrity (NICbaro) from airborne operational
+	// status (TC31 subtype 0, version 1+): the pressure altitude has been
+	// cross-checked against a second source
+	NICBaro *bool `json:"nic_baro,omitempty"`
+
+	// 56-bit ME (DF17/18) or MB (DF20/21) field, hex encoded, with
+	// --payload-hex only
+	MEHex string `json:"me_hex,omitempty"`
+	MBHex string `json:"mb_hex,omitempty"`
+
+	// Reassembled Comm-D extended length message (DF24), hex encoded
+	ELM         string `json:"elm,omitempty"`
+	ELMSegments int    `json:"elm_segments,omitempty"`
+
+	// TCAS resolution advisory (TC28 subtype 2)
+	ACASRA *ACASRA `json:"acas_ra,omitempty"`
+
+	// RAEventStart or RAEventEnd when this advisory starts or ends the
+	// aircraft's RA, as tracked by the registry
+	RAEvent string `json:"ra_event,omitempty"`
+
+	// Selected vertical intention (Comm-B BDS 4,0)
+	NavAltitudeMCP *int     `json:"nav_altitude_mcp,omitempty"`
+	NavAltitudeFMS *int     `json:"nav_altitude_fms,omitempty"`
+	NavQNH         *float64 `json:"nav_qnh,omitempty"`
+	NavQNHOutlier  bool     `json:"nav_qnh_outlier,omitempty"` // NavQNH far from --local-qnh
+
+	// Track and turn (Comm-B BDS 5,0) and heading and speed (BDS 6,0), in
+	// degrees: roll positive right wing down, tracks and headings 0-360
+	Roll       *float64 `json:"roll,omitempty"`
+	TrueTrack  *float64 `json:"true_track,omitempty"`
+	MagHeading *float64 `json:"mag_heading,omitempty"`
+}
+
+// IsPosition reports whether the message is an extended squitter airborne or
+// surface position, whose latitude and longitude are set only once the CPR
+// position could be resolved
+func (m *DecodedMessage) IsPosition() bool {
+	if m.DF != 17 && m.DF != 18 {
+		return false
+	}
+	return (m.TypeCode >= 5 && m.TypeCode <= 18) || (m.TypeCode >= 20 && m.TypeCode <= 22)
+}
+
+// MessageHandler receives every decoded message, for programs that embed the
+// decoder rather than parse its output. Messages are delivered in order from
+// a single goroutine and are shared with the other outputs, so they must not
+// be modified.
+type MessageHandler interface {
+	HandleMessage(msg *DecodedMessage) error
+}
+
+// MessageHandlerFunc adapts an ordinary function to a MessageHandler
+type MessageHandlerFunc func(msg *DecodedMessage) error
+
+// HandleMessage calls f(msg)
+func (f MessageHandlerFunc) HandleMessage(msg *DecodedMessage) error {
+	return f(msg)
+}
@@ -0,0 +1,49 @@
+package adsb
+
+import (
+	"time"
+)
+
+// DefaultDedupWindow is how long an emitted frame suppresses identical repeats.
+// A real retransmission is always much further apart than this.
+const DefaultDedupWindow = 500 * time.Microsecond
+
+// dedupKey identifies a physical transmission
+type dedupKey struct {
+	icao uint32
+	df   uint8
+	data [14]byte
+}
+
+// isDuplicate reports (and counts) whether an identical frame was emitted
+// within the dedup window, and records the frame otherwise
+func (p *ADSBProcessor) isDuplicate(msg *ADSBMessage) bool {
+	key := dedupKey{
+		icao: msg.GetICAO(),
+		df:   msg.GetDF(),
+		data: msg.Data,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if seen, ok := p.recentFrames[key]; ok && msg.Timestamp.Sub(seen) <= p.dedupWindow {
+		p.duplicates++
+		return true
+	}
+
+	p.recentFrames[key] = msg.Timestamp
+	return false
+}
+
+// pruneRecentFrames drops frames that can no longer suppress a repeat
+func (p *ADSBProcessor) pruneRecentFrames(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, seen := range p.recentFrames {
+		if now.Sub(seen) > p.dedupWindow {
+			delete(p.recentFrames, key)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package adsb
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Comm-D extended length message (DF24) limits
+const (
+	ELMSegmentBytes = 10 // MD field of one segment
+	MaxELMSegments  = 16 // ND is 4 bits
+)
+
+// DefaultELMTimeout is how long a transfer may go without a new segment before
+// it is considered finished
+const DefaultELMTimeout = 2 * time.Second
+
+// elmAnnounceTTL is how long after a reply announced a downlink ELM a
+// single-segment transfer is accepted as its delivery. Interrogators extract
+// it within the same beam dwell or on the next antenna scan.
+const elmAnnounceTTL = 30 * time.Second
+
+// ELMSegment is one downlink ELM segment of a DF24 reply
+type ELMSegment struct {
+	ICAO      uint32
+	Number    int // ND field
+	Payload   [ELMSegmentBytes]byte
+	Timestamp time.Time
+}
+
+// ELMMessage is a reassembled downlink ELM transfer
+type ELMMessage struct {
+	ICAO      uint32
+	Segments  int
+	Payload   []byte
+	Timestamp time.Time // Reception of the first segment
+}
+
+// ParseELMSegment extracts the downlink ELM segment from a DF24 reply. The
+// address is recovered from the address/parity field, so bit errors yield a
+// wrong address rather than a rejected segment. Replies with KE set
+// acknowledge an uplink ELM and carry no segment.
+func ParseELMSegment(msg *ADSBMessage) (ELMSegment, bool) {
+	if msg.GetDF() != 24 || msg.Data[0]&0x10 != 0 {
+		return ELMSegment{}, false
+	}
+
+	seg := ELMSegment{
+		ICAO:      elmAddress(msg),
+		Number:    int(msg.Data[0] & 0x0F),
+		Timestamp: msg.Timestamp,
+	}
+	copy(seg.Payload[:], msg.Data[1:1+ELMSegmentBytes])
+	return seg, true
+}
+
+// ELMAnnounced returns the number of segments of the downlink ELM that a
+// surveillance or Comm-B reply announces in its DR field (16-31 for 1-16
+// segments), or 0
+func ELMAnnounced(msg *ADSBMessage) int {
+	switch msg.GetDF() {
+	case 4, 5, 20, 21:
+	default:
+		return 0
+	}
+	dr := int(msg.Data[1] >> 3)
+	if dr < 16 {
+		return 0
+	}
+	return dr - 15
+}
+
+// elmAddress returns the address/parity field of a DF24 reply XOR the CRC of
+// its other bits, which is the aircraft address of an error-free reply
+func elmAddress(msg *ADSBMessage) uint32 {
+	return overlaidParity(msg.Data[:LongMessageBytes])
+}
+
+// elmTransfer collects the segments received from one aircraft
+type elmTransfer struct {
+	segments [MaxELMSegments]*[ELMSegmentBytes]byte
+	started  time.Time
+	updated  time.Time
+}
+
+// ELMReassembler reassembles downlink ELM transfers per aircraft. Segments
+// may arrive in any order and repeated segments replace earlier copies.
+// Segments carry no length, which is only announced by an earlier Comm-B
+// reply, so a transfer is finished once no segment has arrived for the
+// timeout: it is emitted when segments 0 to the highest received are all
+// present and dropped as incomplete otherwise.
+//
+// Segments are only accepted from addresses in the known table, as a DF24
+// address is recovered from the parity and noise yields random ones. A lone
+// segment 0 is still what most noise that slips through looks like, so it is
+// only emitted when a reply announced an ELM for the aircraft.
+type ELMReassembler struct {
+	mu          sync.Mutex
+	transfers   map[uint32]*elmTransfer
+	announced   map[uint32]time.Time
+	known       *AddressTable
+	timeout     time.Duration
+	incomplete  uint64
+	unannounced uint64
+}
+
+// NewELMReassembler creates a reassembler finishing transfers after timeout
+// without a new segment
+func NewELMReassembler(timeout time.Duration) *ELMReassembler {
+	return &ELMReassembler{
+		transfers: make(map[uint32]*elmTransfer),
+		announced: make(map[uint32]time.Time),
+		timeout:   timeout,
+	}
+}
+
+// SetAddresses sets the addresses segments are accepted from; with none set
+// every segment is dropped
+func (r *ELMReassembler) SetAddresses(known *AddressTable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.known = known
+}
+
+// Announce records that a reply from icao announced a downlink ELM
+func (r *ELMReassembler) Announce(icao uint32, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.announced[icao] = at
+}
+
+// Add stores a segment. A segment arriving after its aircraft's transfer timed
+// out, or differing from a segment of the same number already received,
+// starts a new transfer; the previous one is finished and returned when it was
+// complete. Segments from unknown addresses are dropped.
+func (r *ELMReassembler) Add(seg ELMSegment) *ELMMessage {
+	if seg.Number < 0 || seg.Number >= MaxELMSegments {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.known.Known(seg.ICAO, seg.Timestamp) {
+		return nil
+	}
+
+	var finished *ELMMessage
+	transfer, ok := r.transfers[seg.ICAO]
+	if ok {
+		stored := transfer.segments[seg.Number]
+		if seg.Timestamp.Sub(transfer.updated) >= r.timeout || (stored != nil && *stored != seg.Payload) {
+			finished = r.finishLocked(seg.ICAO, transfer)
+			ok = false
+		}
+	}
+	if !ok {
+		transfer = &elmTransfer{started: seg.Timestamp}
+		r.transfers[seg.ICAO] = transfer
+	}
+
+	payload := seg.Payload
+	transfer.segments[seg.Number] = &payload
+	transfer.updated = seg.Timestamp
+	return finished
+}
+
+// Expire finishes every transfer without a new segment for the timeout,
+// returning the complete ones ordered by address
+func (r *ELMReassembler) Expire(now time.Time) []*ELMMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var finished []*ELMMessage
+	for icao, transfer := range r.transfers {
+		if now.Sub(transfer.updated) < r.timeout {
+			continue
+		}
+		if msg := r.finishLocked(icao, transfer); msg != nil {
+			finished = append(finished, msg)
+		}
+	}
+
+	// Announcements are checked against the transfers above first
+	for icao, at := range r.announced {
+		if now.Sub(at) > elmAnnounceTTL {
+			delete(r.announced, icao)
+		}
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].ICAO < finished[j].ICAO
+	})
+	return finished
+}
+
+// finishLocked removes a transfer and assembles its payload, counting it as
+// incomplete when a segment below the highest received is missing, and as
+// unannounced when it is a lone segment no reply announced. The caller must
+// hold mu.
+func (r *ELMReassembler) finishLocked(icao uint32, transfer *elmTransfer) *ELMMessage {
+	delete(r.transfers, icao)
+	// An announcement is used up by the transfer it preceded; a later one
+	// waits for the next transfer
+	announced, ok := r.announced[icao]
+	ok = ok && !announced.After(transfer.started)
+	if ok {
+		delete(r.announced, icao)
+		ok = transfer.started.Sub(announced) <= elmAnnounceTTL
+	}
+
+	highest := -1
+	for i, segment := range transfer.segments {
+		if segment != nil {
+			highest = i
+		}
+	}
+
+	payload := make([]byte, 0, (highest+1)*ELMSegmentBytes)
+	for _, segment := range transfer.segments[:highest+1] {
+		if segment == nil {
+			r.incomplete++
+			return nil
+		}
+		payload = append(payload, segment[:]...)
+	}
+	if highest == 0 && !ok {
+		r.unannounced++
+		return nil
+	}
+
+	return &ELMMessage{
+		ICAO:      icao,
+		Segments:  highest + 1,
+		Payload:   payload,
+		Timestamp: transfer.started,
+	}
+}
+
+// GetIncompleteCount returns the number of transfers dropped with missing segments
+func (r *ELMReassembler) GetIncompleteCount() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.incomplete
+}
+
+// GetUnannouncedCount returns the number of single-segment transfers dropped
+// because no reply announced them
+func (r *ELMReassembler) GetUnannouncedCount() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.unannounced
+}
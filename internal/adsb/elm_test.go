@@ -0,0 +1,187 @@
+package adsb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// elmReply builds a DF24 downlink ELM reply from icao with the given segment
+// number and payload, its address/parity field overlaid with the address
+func elmReply(icao uint32, number int, payload string, timestamp time.Time) *ADSBMessage {
+	msg := &ADSBMessage{Timestamp: timestamp, CRCType: "comm-d"}
+	msg.Data[0] = 0xC0 | byte(number)
+	copy(msg.Data[1:1+ELMSegmentBytes], payload)
+	ap := CalculateCRC(msg.Data[:11]) ^ icao
+	msg.Data[11], msg.Data[12], msg.Data[13] = byte(ap>>16), byte(ap>>8), byte(ap)
+	return msg
+}
+
+// knownReassembler creates a reassembler accepting segments from the given
+// addresses, seen at now
+func knownReassembler(now time.Time, icaos ...uint32) *ELMReassembler {
+	known := NewAddressTable(DefaultAddressTTL)
+	for _, icao := range icaos {
+		known.Add(icao, now)
+	}
+	reassembler := NewELMReassembler(DefaultELMTimeout)
+	reassembler.SetAddresses(known)
+	return reassembler
+}
+
+// TestParseELMSegment tests address recovery and segment fields of DF24 replies
+func TestParseELMSegment(t *testing.T) {
+	now := time.Now()
+	seg, ok := ParseELMSegment(elmReply(0x4840D6, 5, "SEGMENT 05", now))
+	require.True(t, ok)
+	assert.Equal(t, uint32(0x4840D6), seg.ICAO)
+	assert.Equal(t, 5, seg.Number)
+	assert.Equal(t, "SEGMENT 05", string(seg.Payload[:]))
+	assert.Equal(t, now, seg.Timestamp)
+
+	// KE set: an acknowledgement of an uplink ELM
+	ack := elmReply(0x4840D6, 0, "", now)
+	ack.Data[0] |= 0x10
+	_, ok = ParseELMSegment(ack)
+	assert.False(t, ok)
+
+	// Not DF24
+	_, ok = ParseELMSegment(&ADSBMessage{Data: [14]byte{0x8D, 0x48, 0x40, 0xD6}})
+	assert.False(t, ok)
+}
+
+// TestELMReassembler tests reassembly of a transfer received out of order
+// with a retransmitted segment
+func TestELMReassembler(t *testing.T) {
+	start := time.Now()
+	reassembler := knownReassembler(start, 0x40621D)
+
+	for i, reply := range []struct {
+		number  int
+		payload string
+	}{
+		{2, "WORLD, AND"},
+		{0, "HELLO TO T"},
+		{2, "WORLD, AND"},
+		{3, " GOODBYE  "},
+		{1, "HE WHOLE  "},
+	} {
+		seg, ok := ParseELMSegment(elmReply(0x40621D, reply.number, reply.payload, start.Add(time.Duration(i)*100*time.Millisecond)))
+		require.True(t, ok)
+		assert.Nil(t, reassembler.Add(seg))
+	}
+
+	// Not finished while segments may still arrive
+	last := start.Add(400 * time.Millisecond)
+	assert.Empty(t, reassembler.Expire(last.Add(DefaultELMTimeout-time.Millisecond)))
+
+	finished := reassembler.Expire(last.Add(DefaultELMTimeout))
+	require.Len(t, finished, 1)
+	assert.Equal(t, uint32(0x40621D), finished[0].ICAO)
+	assert.Equal(t, 4, finished[0].Segments)
+	assert.Equal(t, "HELLO TO THE WHOLE  WORLD, AND GOODBYE  ", string(finished[0].Payload))
+	assert.Equal(t, start, finished[0].Timestamp)
+	assert.Zero(t, reassembler.GetIncompleteCount())
+
+	// Finished transfers are forgotten
+	assert.Empty(t, reassembler.Expire(last.Add(time.Hour)))
+}
+
+// TestELMReassembler_MissingSegment tests that a transfer with a gap is
+// dropped once it times out
+func TestELMReassembler_MissingSegment(t *testing.T) {
+	now := time.Now()
+	reassembler := knownReassembler(now, 0x40621D)
+
+	for _, number := range []int{0, 2} {
+		seg, _ := ParseELMSegment(elmReply(0x40621D, number, "0123456789", now))
+		reassembler.Add(seg)
+	}
+
+	assert.Empty(t, reassembler.Expire(now.Add(DefaultELMTimeout)))
+	assert.Equal(t, uint64(1), reassembler.GetIncompleteCount())
+}
+
+// TestELMReassembler_NextTransfer tests that a new transfer from the same
+// aircraft finishes the previous one
+func TestELMReassembler_NextTransfer(t *testing.T) {
+	now := time.Now()
+	reassembler := knownReassembler(now, 0x4840D6, 0x40621D)
+
+	add := func(number int, payload string, at time.Time) *ELMMessage {
+		seg, ok := ParseELMSegment(elmReply(0x4840D6, number, payload, at))
+		require.True(t, ok)
+		return reassembler.Add(seg)
+	}
+
+	assert.Nil(t, add(0, "FIRST 0000", now))
+	assert.Nil(t, add(1, "FIRST 1111", now))
+
+	// A different segment 0 starts over. The announcement of the second
+	// transfer, arriving before the first is finished, is kept for it.
+	reassembler.Announce(0x4840D6, now.Add(time.Second))
+	finished := add(0, "SECOND 000", now.Add(time.Second))
+	require.NotNil(t, finished)
+	assert.Equal(t, "FIRST 0000FIRST 1111", string(finished.Payload))
+
+	// So does any segment after the timeout
+	finished = add(0, "THIRD 0000", now.Add(time.Second+DefaultELMTimeout))
+	require.NotNil(t, finished)
+	assert.Equal(t, "SECOND 000", string(finished.Payload))
+
+	// Other aircraft are independent. The third lone segment was not
+	// announced, so only the other aircraft's announced one is emitted.
+	reassembler.Announce(0x40621D, now)
+	other, _ := ParseELMSegment(elmReply(0x40621D, 0, "OTHER 0000", now))
+	assert.Nil(t, reassembler.Add(other))
+	finishedAll := reassembler.Expire(now.Add(time.Hour))
+	require.Len(t, finishedAll, 1)
+	assert.Equal(t, "OTHER 0000", string(finishedAll[0].Payload))
+	assert.Equal(t, uint64(1), reassembler.GetUnannouncedCount())
+}
+
+// TestELMReassembler_Unverified tests that segments from unknown addresses
+// and lone unannounced segments, as noise yields, produce no transfer
+func TestELMReassembler_Unverified(t *testing.T) {
+	now := time.Now()
+	reassembler := knownReassembler(now, 0x4840D6)
+
+	stranger, _ := ParseELMSegment(elmReply(0x123456, 0, "NOISE 0000", now))
+	reassembler.Add(stranger)
+	lone, _ := ParseELMSegment(elmReply(0x4840D6, 0, "NOISE 0000", now))
+	reassembler.Add(lone)
+
+	assert.Empty(t, reassembler.Expire(now.Add(DefaultELMTimeout)))
+	assert.Equal(t, uint64(1), reassembler.GetUnannouncedCount())
+	assert.Zero(t, reassembler.GetIncompleteCount())
+
+	// An announcement from before the TTL is stale
+	reassembler.Announce(0x4840D6, now)
+	later := now.Add(elmAnnounceTTL + time.Second)
+	reassembler.known.Add(0x4840D6, later)
+	lone, _ = ParseELMSegment(elmReply(0x4840D6, 0, "LATE 00000", later))
+	reassembler.Add(lone)
+	assert.Empty(t, reassembler.Expire(later.Add(DefaultELMTimeout)))
+
+	// Without a table nothing is accepted
+	open := NewELMReassembler(DefaultELMTimeout)
+	seg, _ := ParseELMSegment(elmReply(0x4840D6, 0, "SEGMENT 00", now))
+	open.Add(seg)
+	assert.Empty(t, open.Expire(now.Add(time.Hour)))
+	assert.Zero(t, open.GetUnannouncedCount())
+}
+
+// TestELMAnnounced tests the segment count announced in the DR field
+func TestELMAnnounced(t *testing.T) {
+	msg := &ADSBMessage{Data: [14]byte{0xA0, 0x80}} // DF20, DR 16
+	assert.Equal(t, 1, ELMAnnounced(msg))
+	msg.Data[1] = 0xF8 // DR 31
+	assert.Equal(t, 16, ELMAnnounced(msg))
+	msg.Data[1] = 0x08 // DR 1: Comm-B broadcast
+	assert.Zero(t, ELMAnnounced(msg))
+	msg.Data[0] = 0x8D // DF17 has no DR field
+	msg.Data[1] = 0x80
+	assert.Zero(t, ELMAnnounced(msg))
+}
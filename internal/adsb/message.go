@@ -8,13 +8,75 @@ import (
 type ADSBMessage struct {
 	Data            [14]byte // 112 bits = 14 bytes
 	Timestamp       time.Time
-	Signal          float64
+	Signal          float64 // Preamble pulse level in dBFS
 	CRC             uint32
 	Valid           bool
 	Score           int
 	Phase           int
 	ErrorsCorrected int    // Number of bit errors corrected
-	CRCType         string // "valid", "corrected-1", "corrected-2", "invalid"
+	CRCType         string // "valid", "corrected-1", "corrected-2", "comm-d", "invalid"
+	SampleClock     uint64 // Receiver sample count at the preamble
+}
+
+// Mode S message lengths in bytes
+const (
+	ShortMessageBytes = 7
+	LongMessageBytes  = 14
+)
+
+// DecodeDF extracts the Downlink Format from the first message byte. DF24
+// (Comm-D ELM) is signalled by the top two bits alone, so every first byte
+// starting with 0b11 maps to 24.
+func DecodeDF(b byte) uint8 {
+	if b&0xC0 == 0xC0 {
+		return 24
+	}
+	return b >> 3
+}
+
+// messageLengths is the length in bytes of every 5-bit DF. Assigned formats
+// follow ICAO Annex 10; unassigned ones follow the first bit, which the
+// assigned formats also obey: 0 for short, 1 for long. DF24-31 are all the
+// two-bit Comm-D ELM format.
+var messageLengths = [32]int{
+	0:  ShortMessageBytes, // Short air-air surveillance (ACAS)
+	1:  ShortMessageBytes,
+	2:  ShortMessageBytes,
+	3:  ShortMessageBytes,
+	4:  ShortMessageBytes, // Surveillance, altitude reply
+	5:  ShortMessageBytes, // Surveillance, identity reply
+	6:  ShortMessageBytes,
+	7:  ShortMessageBytes,
+	8:  ShortMessageBytes,
+	9:  ShortMessageBytes,
+	10: ShortMessageBytes,
+	11: ShortMessageBytes, // All-call reply / acquisition squitter
+	12: ShortMessageBytes,
+	13: ShortMessageBytes,
+	14: ShortMessageBytes,
+	15: ShortMessageBytes,
+	16: LongMessageBytes, // Long air-air surveillance (ACAS)
+	17: LongMessageBytes, // Extended squitter
+	18: LongMessageBytes, // Extended squitter, non-transponder
+	19: LongMessageBytes, // Military extended squitter
+	20: LongMessageBytes, // Comm-B, altitude reply
+	21: LongMessageBytes, // Comm-B, identity reply
+	22: LongMessageBytes, // Military
+	23: LongMessageBytes,
+	24: LongMessageBytes, // Comm-D extended length message
+	25: LongMessageBytes,
+	26: LongMessageBytes,
+	27: LongMessageBytes,
+	28: LongMessageBytes,
+	29: LongMessageBytes,
+	30: LongMessageBytes,
+	31: LongMessageBytes,
+}
+
+// MessageLength returns the length in bytes of a message with the given DF,
+// as returned by DecodeDF or taken from the top five bits of the first byte
+func MessageLength(df uint8) int {
+	return messageLengths[df&0x1F]
 }
 
 // AircraftPosition tracks CPR position data for an aircraft
@@ -24,15 +86,23 @@ type AircraftPosition struct {
 	OddFrame   *CPRFrame
 	LastPos    *Position
 	LastUpdate time.Time
-}
 
-// AircraftState tracks position data for CPR decoding
-type AircraftState struct {
-	ICAO    uint32
-	EvenCPR *CPRFrame
-	OddCPR  *CPRFrame
-	LastPos *Position
-	Updated time.Time
+	// Earlier frames of each parity, newest first, to pair with when the
+	// latest pair straddles a latitude zone boundary
+	EvenHistory []*CPRFrame
+	OddHistory  []*CPRFrame
+
+	// Set once an even/odd pair has been decoded globally, with the latest
+	// such position
+	GlobalDecoded bool
+	GlobalPos     *Position
+
+	// Diagnostics from the most recent decode attempt
+	LastJ        int
+	EvenNL       int
+	OddNL        int
+	Method       string
+	RejectReason string
 }
 
 // CPRFrame represents a CPR encoded position frame
@@ -50,17 +120,45 @@ type Position struct {
 	Timestamp time.Time
 }
 
-// GetICAO extracts ICAO address from ADS-B message
+// GetICAO extracts the ICAO address of the message, as FrameICAO
 func (msg *ADSBMessage) GetICAO() uint32 {
-	if len(msg.Data) < 4 {
+	return FrameICAO(msg.Data[:])
+}
+
+// FrameICAO extracts the ICAO address of a Mode S frame. DF11, DF17 and DF18
+// carry it in bytes 1-3; replies to interrogations (DF0, 4, 5, 16, 20, 21)
+// overlay it on the parity field instead, so it is recovered from the CRC.
+// An AP reply with bit errors yields a wrong address rather than a failure.
+func FrameICAO(frame []byte) uint32 {
+	if len(frame) < 4 {
 		return 0
 	}
-	return uint32(msg.Data[1])<<16 | uint32(msg.Data[2])<<8 | uint32(msg.Data[3])
+	if df := DecodeDF(frame[0]); hasAddressParity(df) && len(frame) >= MessageLength(df) {
+		return overlaidParity(frame[:MessageLength(df)])
+	}
+	return uint32(frame[1])<<16 | uint32(frame[2])<<8 | uint32(frame[3])
+}
+
+// hasAddressParity reports whether a downlink format overlays the aircraft
+// address on its parity field (AP) rather than sending it in the clear
+func hasAddressParity(df uint8) bool {
+	switch df {
+	case 0, 4, 5, 16, 20, 21:
+		return true
+	default:
+		return false
+	}
 }
 
 // GetDF extracts Downlink Format from ADS-B message
 func (msg *ADSBMessage) GetDF() uint8 {
-	return (msg.Data[0] >> 3) & 0x1F
+	return DecodeDF(msg.Data[0])
+}
+
+// Frame returns the bytes of the message, without the zero padding after a
+// short frame
+func (msg *ADSBMessage) Frame() []byte {
+	return msg.Data[:MessageLength(msg.GetDF())]
 }
 
 // GetTypeCode extracts Type Code for DF17/18 messages
@@ -73,3 +171,81 @@ func (msg *ADSBMessage) GetTypeCode() uint8 {
 	}
 	return (msg.Data[4] >> 3) & 0x1F
 }
+
+// Sources of extended squitters, told apart by the DF and the DF18 control field
+const (
+	SourceADSB      = "adsb"       // DF17: the aircraft's own transponder
+	SourceADSBNT    = "adsb_nt"    // DF18 CF0: a non-transponder device
+	SourceADSBOther = "adsb_other" // DF18 CF1: a device with a non-ICAO address
+	SourceTISB      = "tisb"       // DF18 CF2, CF3, CF5: ground-derived traffic information
+	SourceADSR      = "adsr"       // DF18 CF6: ADS-B rebroadcast by a ground station
+)
+
+// GetCF extracts the control field of a DF18 message, or 0 for other formats
+func (msg *ADSBMessage) GetCF() uint8 {
+	if msg.GetDF() != 18 {
+		return 0
+	}
+	return msg.Data[0] & 0x07
+}
+
+// Source returns what sent an extended squitter, or "" for other formats and
+// for DF18 control fields that describe no aircraft (TIS-B/ADS-R management,
+// reserved)
+func (msg *ADSBMessage) Source() string {
+	switch msg.GetDF() {
+	case 17:
+		return SourceADSB
+	case 18:
+		switch msg.GetCF() {
+		case 0:
+			return SourceADSBNT
+		case 1:
+			return SourceADSBOther
+		case 2, 3, 5:
+			return SourceTISB
+		case 6:
+			return SourceADSR
+		}
+	}
+	return ""
+}
+
+// HasTypeCodeFormat reports whether the ME field uses the extended squitter
+// type-code formats. Coarse TIS-B (CF3), TIS-B/ADS-R management (CF4) and
+// reserved (CF7) DF18 messages do not.
+func (msg *ADSBMessage) HasTypeCodeFormat() bool {
+	switch msg.GetDF() {
+	case 17:
+		return true
+	case 18:
+		cf := msg.GetCF()
+		return cf != 3 && cf != 4 && cf != 7
+	default:
+		return false
+	}
+}
+
+// IsSpontaneous reports whether the message is a squitter, transmitted
+// unprompted, rather than a reply to an interrogation. Extended squitters
+// (DF17/18/19) always are; a DF11 is an acquisition squitter only with
+// interrogator code 0, which an all-call reply to a Mode S-only interrogator
+// with code 0 shares. Surveillance, Comm-B and Comm-D replies never are.
+func (msg *ADSBMessage) IsSpontaneous() bool {
+	switch msg.GetDF() {
+	case 17, 18, 19:
+		return true
+	case 11:
+		return df11Interrogator(msg) == 0
+	default:
+		return false
+	}
+}
+
+// GetMB extracts the 56-bit MB field from DF20/21 Comm-B replies
+func (msg *ADSBMessage) GetMB() []byte {
+	if msg.GetDF() != 20 && msg.GetDF() != 21 {
+		return nil
+	}
+	return msg.Data[4:11]
+}
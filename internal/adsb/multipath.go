@@ -0,0 +1,106 @@
+package adsb
+
+import (
+	"time"
+)
+
+// DefaultMultipathWindow is how long after a message other copies of the same
+// logical message are taken for reflections of it. Squitters of one type are
+// at least a few hundred milliseconds apart, so a real repeat never falls
+// inside it.
+const DefaultMultipathWindow = 3 * time.Millisecond
+
+// logicalKey identifies a logical message: one aircraft sending one kind of
+// message, whatever bits a reflection or a CRC repair left in its copies
+type logicalKey struct {
+	icao uint32
+	df   uint8
+	tc   uint8
+	odd  bool // CPR format of position squitters, sent as even/odd pairs
+}
+
+// logicalKeyOf returns the logical message a frame is a copy of
+func logicalKeyOf(msg *ADSBMessage) logicalKey {
+	key := logicalKey{icao: msg.GetICAO(), df: msg.GetDF()}
+	if key.df == 17 || key.df == 18 {
+		key.tc = msg.GetTypeCode()
+		if (key.tc >= 5 && key.tc <= 18) || (key.tc >= 20 && key.tc <= 22) {
+			key.odd = msg.Data[6]&0x04 != 0
+		}
+	}
+	return key
+}
+
+// copyRank orders copies by how they passed the CRC check
+func copyRank(msg *ADSBMessage) int {
+	switch msg.CRCType {
+	case "valid":
+		return 2
+	case "corrected-1":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// betterCopy reports whether a outranks b: a pristine CRC beats one repaired
+// bit, which beats two, and the stronger signal breaks ties
+func betterCopy(a, b *ADSBMessage) bool {
+	if ra, rb := copyRank(a), copyRank(b); ra != rb {
+		return ra > rb
+	}
+	return a.Signal > b.Signal
+}
+
+// isReflection reports (and counts) whether another copy of the same logical
+// message was emitted within the multipath window, and records the message
+// otherwise. Copies identical to one in the dedup window are caught first by
+// isDuplicate.
+func (p *ADSBProcessor) isReflection(msg *ADSBMessage) bool {
+	key := logicalKeyOf(msg)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if seen, ok := p.recentLogical[key]; ok && msg.Timestamp.Sub(seen) <= p.multipathWindow {
+		p.reflections++
+		return true
+	}
+
+	p.recentLogical[key] = msg.Timestamp
+	return false
+}
+
+// keepBetterCopy replaces the kept copy of msg's logical message in batch when
+// msg outranks it. Copies already returned by an earlier ProcessIQSamples call
+// cannot be replaced, so there the first copy stands.
+func (p *ADSBProcessor) keepBetterCopy(batch []*ADSBMessage, msg *ADSBMessage) {
+	key := logicalKeyOf(msg)
+	for i := len(batch) - 1; i >= 0; i-- {
+		kept := batch[i]
+		if msg.Timestamp.Sub(kept.Timestamp) > p.multipathWindow {
+			return
+		}
+		if !kept.Valid || logicalKeyOf(kept) != key {
+			continue
+		}
+		if betterCopy(msg, kept) {
+			p.uncountCorrection(kept)
+			p.countCorrection(msg)
+			batch[i] = msg
+		}
+		return
+	}
+}
+
+// pruneRecentLogical drops messages that can no longer suppress a reflection
+func (p *ADSBProcessor) pruneRecentLogical(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, seen := range p.recentLogical {
+		if now.Sub(seen) > p.multipathWindow {
+			delete(p.recentLogical, key)
+		}
+	}
+}
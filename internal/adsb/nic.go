@@ -0,0 +1,117 @@
+package adsb
+
+// Horizontal containment radii in metres, as nautical mile fractions where the
+// standards define them so
+const (
+	rc7m5  = 7.5
+	rc25m  = 25
+	rc75m  = 75
+	rc0NM1 = 185.2
+	rc0NM2 = 370.4
+	rc0NM3 = 555.6
+	rc0NM5 = 926
+	rc0NM6 = 1111.2
+	rc1NM  = 1852
+	rc2NM  = 3704
+	rc4NM  = 7408
+	rc8NM  = 14816
+	rc10NM = 18520
+	rc20NM = 37040
+)
+
+// NICSupplements are the supplement bits that refine the NIC a position type
+// code implies: A from operational status (version 1+), B from the airborne
+// position header (version 2) and C from surface operational status (version 2)
+type NICSupplements struct {
+	A, B, C bool
+}
+
+// nucpHPL maps version 0 position type codes to the horizontal protection
+// limit of their NUCp, which stands in for the containment radius
+var nucpHPL = map[uint8]float64{
+	5: rc7m5, 6: rc25m, 7: rc0NM1, 8: rc0NM2, // Surface, NUCp 9-6
+	9: rc7m5, 10: rc25m, 11: rc0NM1, 12: rc0NM2, 13: rc0NM5, // Airborne, NUCp 9-5
+	14: rc1NM, 15: rc2NM, 16: rc10NM, 17: rc20NM, // Airborne, NUCp 4-1
+	20: rc7m5, 21: rc25m, // GNSS height, NUCp 9-8
+}
+
+// PositionIntegrity returns the navigation integrity category and horizontal
+// containment radius (Rc, metres) of a position message with the given type
+// code, from an aircraft with the given ADS-B version and NIC supplements.
+// Version 0 has no NIC: its type code gives a NUCp, whose protection limit is
+// returned as the radius with nic -1. ok is false when the radius is unknown.
+func PositionIntegrity(typeCode uint8, version int, supp NICSupplements) (nic int, rc float64, ok bool) {
+	if version == 0 {
+		hpl, ok := nucpHPL[typeCode]
+		return -1, hpl, ok
+	}
+
+	nic, rc = positionNIC(typeCode, version, supp)
+	return nic, rc, rc > 0
+}
+
+// positionNIC looks up the NIC and Rc of a version 1 or 2 position. Version 1
+// refines with NIC supplement-A only; version 2 also uses B (airborne) or C
+// (surface).
+func positionNIC(typeCode uint8, version int, supp NICSupplements) (int, float64) {
+	v2 := version >= 2
+	switch typeCode {
+	case 5, 9, 20:
+		return 11, rc7m5
+	case 6, 10, 21:
+		return 10, rc25m
+	case 7: // Surface
+		if supp.A && !(v2 && supp.C) {
+			return 9, rc75m
+		}
+		return 8, rc0NM1
+	case 8: // Surface
+		switch {
+		case !v2:
+			return 0, 0
+		case supp.A && supp.C:
+			return 7, rc0NM2
+		case supp.A:
+			return 6, rc0NM3
+		case supp.C:
+			return 6, rc0NM5
+		default:
+			return 0, 0
+		}
+	case 11:
+		if supp.A && (!v2 || supp.B) {
+			return 9, rc75m
+		}
+		return 8, rc0NM1
+	case 12:
+		return 7, rc0NM2
+	case 13:
+		switch {
+		case !v2 && supp.A:
+			return 6, rc0NM6
+		case !v2:
+			return 6, rc0NM5
+		case !supp.A && supp.B:
+			return 6, rc0NM3
+		case !supp.A && !supp.B:
+			return 6, rc0NM5
+		case supp.A && supp.B:
+			return 6, rc0NM6
+		default:
+			return 0, 0 // Reserved combination
+		}
+	case 14:
+		return 5, rc1NM
+	case 15:
+		return 4, rc2NM
+	case 16:
+		if supp.A && (!v2 || supp.B) {
+			return 3, rc4NM
+		}
+		return 2, rc8NM
+	case 17:
+		return 1, rc20NM
+	default:
+		return 0, 0
+	}
+}
@@ -0,0 +1,77 @@
+package adsb
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPositionIntegrity tests the NIC and containment radius of the same type
+// codes from version 0, 1 and 2 aircraft with different NIC supplements
+func TestPositionIntegrity(t *testing.T) {
+	tests := []struct {
+		typeCode uint8
+		version  int
+		supp     NICSupplements
+		nic      int
+		rc       float64
+		ok       bool
+	}{
+		// Version 0 reports the NUCp protection limit and no NIC
+		{typeCode: 9, version: 0, nic: -1, rc: 7.5, ok: true},
+		{typeCode: 11, version: 0, nic: -1, rc: 185.2, ok: true},
+		{typeCode: 11, version: 0, supp: NICSupplements{A: true, B: true}, nic: -1, rc: 185.2, ok: true},
+		{typeCode: 13, version: 0, nic: -1, rc: 926, ok: true},
+		{typeCode: 16, version: 0, nic: -1, rc: 18520, ok: true},
+		{typeCode: 18, version: 0, nic: -1},
+		{typeCode: 8, version: 0, nic: -1, rc: 370.4, ok: true},
+
+		// Versions 1 and 2 share the unrefined type codes
+		{typeCode: 9, version: 2, nic: 11, rc: 7.5, ok: true},
+		{typeCode: 12, version: 1, nic: 7, rc: 370.4, ok: true},
+		{typeCode: 17, version: 2, nic: 1, rc: 37040, ok: true},
+		{typeCode: 18, version: 2},
+		{typeCode: 22, version: 2},
+
+		// TC11: version 1 needs A, version 2 needs A and B
+		{typeCode: 11, version: 1, nic: 8, rc: 185.2, ok: true},
+		{typeCode: 11, version: 1, supp: NICSupplements{A: true}, nic: 9, rc: 75, ok: true},
+		{typeCode: 11, version: 2, supp: NICSupplements{A: true}, nic: 8, rc: 185.2, ok: true},
+		{typeCode: 11, version: 2, supp: NICSupplements{A: true, B: true}, nic: 9, rc: 75, ok: true},
+
+		// TC13: version 2 uses B to pick among three radii
+		{typeCode: 13, version: 1, nic: 6, rc: 926, ok: true},
+		{typeCode: 13, version: 1, supp: NICSupplements{A: true}, nic: 6, rc: 1111.2, ok: true},
+		{typeCode: 13, version: 2, nic: 6, rc: 926, ok: true},
+		{typeCode: 13, version: 2, supp: NICSupplements{B: true}, nic: 6, rc: 555.6, ok: true},
+		{typeCode: 13, version: 2, supp: NICSupplements{A: true, B: true}, nic: 6, rc: 1111.2, ok: true},
+		{typeCode: 13, version: 2, supp: NICSupplements{A: true}},
+
+		// TC16
+		{typeCode: 16, version: 1, supp: NICSupplements{A: true}, nic: 3, rc: 7408, ok: true},
+		{typeCode: 16, version: 2, supp: NICSupplements{A: true}, nic: 2, rc: 14816, ok: true},
+		{typeCode: 16, version: 2, supp: NICSupplements{A: true, B: true}, nic: 3, rc: 7408, ok: true},
+
+		// Surface: version 2 uses C
+		{typeCode: 7, version: 1, supp: NICSupplements{A: true}, nic: 9, rc: 75, ok: true},
+		{typeCode: 7, version: 2, supp: NICSupplements{A: true, C: true}, nic: 8, rc: 185.2, ok: true},
+		{typeCode: 8, version: 1},
+		{typeCode: 8, version: 2},
+		{typeCode: 8, version: 2, supp: NICSupplements{A: true}, nic: 6, rc: 555.6, ok: true},
+		{typeCode: 8, version: 2, supp: NICSupplements{C: true}, nic: 6, rc: 926, ok: true},
+		{typeCode: 8, version: 2, supp: NICSupplements{A: true, C: true}, nic: 7, rc: 370.4, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("TC%d v%d %+v", tt.typeCode, tt.version, tt.supp), func(t *testing.T) {
+			nic, rc, ok := PositionIntegrity(tt.typeCode, tt.version, tt.supp)
+			assert.Equal(t, tt.ok, ok)
+			if !tt.ok {
+				return
+			}
+			assert.Equal(t, tt.nic, nic)
+			assert.Equal(t, tt.rc, rc)
+		})
+	}
+}
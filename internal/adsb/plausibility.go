@@ -0,0 +1,170 @@
+package adsb
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Plausibility limits. Anything beyond these between two updates from the same
+// aircraft is treated as a decoding error rather than real movement.
+const (
+	MaxPlausibleSpeed        = 1400   // knots, a little over Mach 2 at altitude
+	MaxPlausibleVerticalRate = 20000  // ft/min
+	MinPlausibleAltitude     = -1500  // ft
+	MaxPlausibleAltitude     = 100000 // ft
+	MaxGeomBaroDifference    = 5000   // ft between GNSS height and baro altitude
+
+	// Slack for CPR and altitude quantisation on closely spaced updates
+	positionSlackNM   = 0.5
+	altitudeSlackFt   = 500
+	earthRadiusNM     = 3440.065
+	plausibilityTTL   = 5 * time.Minute // Forget aircraft not heard for this long
+	maxImplausibleRun = 3               // Consecutive rejections before re-seeding
+)
+
+// plausibilityState is the last accepted state for an aircraft
+type plausibilityState struct {
+	lat, lon    float64
+	posTime     time.Time
+	alt         int
+	altTime     time.Time
+	geomAlt     int
+	geomTime    time.Time
+	lastSeen    time.Time
+	rejectedRun int
+}
+
+// PlausibilityFilter flags updates that are physically inconsistent with the
+// previous state of the same aircraft, e.g. a position implying supersonic
+// travel since the last fix
+type PlausibilityFilter struct {
+	mu       sync.Mutex
+	state    map[uint32]*plausibilityState
+	rejected uint64
+}
+
+// NewPlausibilityFilter creates an empty plausibility filter
+func NewPlausibilityFilter() *PlausibilityFilter {
+	return &PlausibilityFilter{
+		state: make(map[uint32]*plausibilityState),
+	}
+}
+
+// Check reports whether msg is consistent with the aircraft's previous state,
+// recording it as the new state when it is, and otherwise why not. Implausible
+// updates are counted and leave the state untouched, unless they persist, in
+// which case the earlier state is assumed to be the bad one and the filter
+// re-seeds from msg.
+func (f *PlausibilityFilter) Check(msg *DecodedMessage) (bool, string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	st, ok := f.state[msg.ICAO]
+	if !ok {
+		st = &plausibilityState{}
+		f.state[msg.ICAO] = st
+	}
+	st.lastSeen = msg.Timestamp
+
+	if reason := st.implausible(msg); reason != "" {
+		st.rejectedRun++
+		if st.rejectedRun < maxImplausibleRun {
+			f.rejected++
+			return false, reason
+		}
+		*st = plausibilityState{lastSeen: msg.Timestamp}
+	}
+
+	st.rejectedRun = 0
+	st.update(msg)
+	return true, ""
+}
+
+// implausible returns why msg conflicts with the stored state, or "" if it does not
+func (st *plausibilityState) implausible(msg *DecodedMessage) string {
+	if msg.GroundSpeed != nil && *msg.GroundSpeed > MaxPlausibleSpeed {
+		return "ground speed"
+	}
+
+	if alt := msg.Altitude; alt != nil {
+		if *alt < MinPlausibleAltitude || *alt > MaxPlausibleAltitude {
+			return "altitude out of range"
+		}
+		if !st.altTime.IsZero() {
+			minutes := msg.Timestamp.Sub(st.altTime).Minutes()
+			change := math.Abs(float64(*alt - st.alt))
+			if change > altitudeSlackFt+MaxPlausibleVerticalRate*math.Max(minutes, 0) {
+				return "altitude change"
+			}
+		}
+	}
+
+	// GNSS height and baro altitude arrive in different messages but should
+	// stay within a few thousand feet of each other
+	if msg.AltitudeGeom != nil && !st.altTime.IsZero() &&
+		msg.Timestamp.Sub(st.altTime) < time.Minute &&
+		math.Abs(float64(*msg.AltitudeGeom-st.alt)) > MaxGeomBaroDifference {
+		return "GNSS/baro altitude difference"
+	}
+	if msg.Altitude != nil && !st.geomTime.IsZero() &&
+		msg.Timestamp.Sub(st.geomTime) < time.Minute &&
+		math.Abs(float64(*msg.Altitude-st.geomAlt)) > MaxGeomBaroDifference {
+		return "GNSS/baro altitude difference"
+	}
+
+	if msg.Latitude != nil && msg.Longitude != nil && !st.posTime.IsZero() {
+		hours := msg.Timestamp.Sub(st.posTime).Hours()
+		distance := distanceNM(st.lat, st.lon, *msg.Latitude, *msg.Longitude)
+		if distance > positionSlackNM+MaxPlausibleSpeed*math.Max(hours, 0) {
+			return "position jump"
+		}
+	}
+
+	return ""
+}
+
+// update records the fields carried by an accepted message
+func (st *plausibilityState) update(msg *DecodedMessage) {
+	if msg.Altitude != nil {
+		st.alt = *msg.Altitude
+		st.altTime = msg.Timestamp
+	}
+	if msg.AltitudeGeom != nil {
+		st.geomAlt = *msg.AltitudeGeom
+		st.geomTime = msg.Timestamp
+	}
+	if msg.Latitude != nil && msg.Longitude != nil {
+		st.lat, st.lon = *msg.Latitude, *msg.Longitude
+		st.posTime = msg.Timestamp
+	}
+}
+
+// Prune forgets aircraft not heard since plausibilityTTL before now
+func (f *PlausibilityFilter) Prune(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for icao, st := range f.state {
+		if now.Sub(st.lastSeen) > plausibilityTTL {
+			delete(f.state, icao)
+		}
+	}
+}
+
+// GetRejectedCount returns the number of updates rejected as implausible
+func (f *PlausibilityFilter) GetRejectedCount() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rejected
+}
+
+// distanceNM returns the great-circle distance between two points in nautical miles
+func distanceNM(lat1, lon1, lat2, lon2 float64) float64 {
+	const rad = math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusNM * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,125 @@
+package adsb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// positionUpdate builds a position message for the plausibility tests
+func positionUpdate(ts time.Time, lat, lon float64, alt int) *DecodedMessage {
+	return &DecodedMessage{
+		Timestamp: ts,
+		ICAO:      0x4840D6,
+		Altitude:  &alt,
+		Latitude:  &lat,
+		Longitude: &lon,
+	}
+}
+
+// TestPlausibilityFilter tests a consistent track with an injected teleport
+func TestPlausibilityFilter(t *testing.T) {
+	filter := NewPlausibilityFilter()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// 450 kt due north is 0.125 NM (about 0.00208 degrees) per second
+	for i := 0; i < 10; i++ {
+		ok, reason := filter.Check(positionUpdate(start.Add(time.Duration(i)*time.Second), 52.0+float64(i)*0.00208, 4.0, 35000))
+		assert.True(t, ok, "update %d: %s", i, reason)
+	}
+
+	// Five degrees of latitude in one second
+	ok, reason := filter.Check(positionUpdate(start.Add(10*time.Second), 57.0, 4.0, 35000))
+	assert.False(t, ok)
+	assert.Equal(t, "position jump", reason)
+
+	// The track continues from the last good fix
+	ok, _ = filter.Check(positionUpdate(start.Add(11*time.Second), 52.0+11*0.00208, 4.0, 35000))
+	assert.True(t, ok)
+
+	// A corrupted altitude with the right position
+	ok, reason = filter.Check(positionUpdate(start.Add(12*time.Second), 52.0+12*0.00208, 4.0, -1000))
+	assert.False(t, ok)
+	assert.Equal(t, "altitude change", reason)
+
+	assert.Equal(t, uint64(2), filter.GetRejectedCount())
+}
+
+// TestPlausibilityFilter_Fields tests the individual plausibility rules
+func TestPlausibilityFilter_Fields(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		previous *DecodedMessage
+		update   *DecodedMessage
+		expected string
+	}{
+		{
+			name:     "Supersonic ground speed",
+			update:   &DecodedMessage{Timestamp: start, GroundSpeed: intPtr(2000)},
+			expected: "ground speed",
+		},
+		{
+			name:     "Altitude out of range",
+			update:   &DecodedMessage{Timestamp: start, Altitude: intPtr(150000)},
+			expected: "altitude out of range",
+		},
+		{
+			name:     "Plausible climb",
+			previous: &DecodedMessage{Timestamp: start, Altitude: intPtr(10000)},
+			update:   &DecodedMessage{Timestamp: start.Add(time.Minute), Altitude: intPtr(13000)},
+		},
+		{
+			name:     "GNSS height far from baro altitude",
+			previous: &DecodedMessage{Timestamp: start, Altitude: intPtr(35000)},
+			update:   &DecodedMessage{Timestamp: start.Add(time.Second), AltitudeGeom: intPtr(20000)},
+			expected: "GNSS/baro altitude difference",
+		},
+		{
+			name:     "GNSS height close to baro altitude",
+			previous: &DecodedMessage{Timestamp: start, Altitude: intPtr(35000)},
+			update:   &DecodedMessage{Timestamp: start.Add(time.Second), AltitudeGeom: intPtr(35600)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewPlausibilityFilter()
+			if tt.previous != nil {
+				ok, _ := filter.Check(tt.previous)
+				assert.True(t, ok)
+			}
+
+			ok, reason := filter.Check(tt.update)
+			assert.Equal(t, tt.expected == "", ok)
+			assert.Equal(t, tt.expected, reason)
+		})
+	}
+}
+
+// TestPlausibilityFilter_Reseed tests that a persistent disagreement replaces a bad earlier fix
+func TestPlausibilityFilter_Reseed(t *testing.T) {
+	filter := NewPlausibilityFilter()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// A bogus first fix, then the real track far away
+	ok, _ := filter.Check(positionUpdate(start, 10.0, 10.0, 35000))
+	assert.True(t, ok)
+
+	for i := 1; i < maxImplausibleRun; i++ {
+		ok, _ = filter.Check(positionUpdate(start.Add(time.Duration(i)*time.Second), 52.0, 4.0, 35000))
+		assert.False(t, ok)
+	}
+
+	ok, _ = filter.Check(positionUpdate(start.Add(maxImplausibleRun*time.Second), 52.0, 4.0, 35000))
+	assert.True(t, ok, "filter should re-seed from the persistent track")
+
+	ok, _ = filter.Check(positionUpdate(start.Add((maxImplausibleRun+1)*time.Second), 52.002, 4.0, 35000))
+	assert.True(t, ok)
+
+	// Stale aircraft are forgotten
+	filter.Prune(start.Add(time.Hour))
+	assert.Empty(t, filter.state)
+}
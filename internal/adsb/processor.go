@@ -1,6 +1,8 @@
 package adsb
 
 import (
+	"fmt"
+	"math"
 	"math/cmplx"
 	"sync"
 	"time"
@@ -15,28 +17,201 @@ type ADSBProcessor struct {
 	messageCount uint64
 
 	// Statistics
-	preambleCount     uint64
-	validMessages     uint64
-	rejectedBad       uint64
-	rejectedUnknown   uint64
-	correctedMessages uint64
-	singleBitErrors   uint64
-	twoBitErrors      uint64
-
-	// Aircraft tracking for CPR decoding
-	aircraft map[uint32]*AircraftState
-	mu       sync.RWMutex
+	preambleCount      uint64
+	validMessages      uint64
+	rejectedBad        uint64
+	rejectedUnknown    uint64
+	rejectedSNR        uint64
+	rejectedQuiet      uint64
+	rejectedNoiseFloor uint64
+	correctedMessages  uint64
+	singleBitErrors    uint64
+	twoBitErrors       uint64
+	duplicates         uint64
+	reflections        uint64
+	commDFrames        uint64
+
+	// Valid messages by downlink format and extended squitter type code,
+	// and by the phase they were decoded at, guarded by mu as they are read
+	// from other goroutines
+	typeCounts  map[string]uint64
+	phaseCounts [lastTryPhase - firstTryPhase + 1]uint64
+	mu          sync.RWMutex
+
+	// Per-aircraft debug tracing
+	tracer *Tracer
+
+	// Repair one- and two-bit CRC errors
+	errorCorrection bool
+
+	// Decode only the phase the preamble indicates instead of all five
+	singlePhase bool
+
+	// Interrogator codes accepted in DF11 replies; nil accepts any
+	df11IIDs map[uint8]bool
+
+	// Addresses recently received in the clear, which verify Comm-D
+	// segments
+	addresses *AddressTable
+
+	// Preamble acceptance: signal/noise amplitude ratio scaled by snrScale
+	snrRatio uint64
+
+	// Channel noise floor: a slow exponential average of the magnitude of
+	// the quiet samples of preambles that pass the local checks, 0 until the
+	// first one
+	noiseFloor float64
+
+	// Recently emitted frames, used to suppress multi-phase duplicates
+	recentFrames map[dedupKey]time.Time
+	dedupWindow  time.Duration
+
+	// Recent logical messages, used to pick the best of multipath copies
+	recentLogical   map[logicalKey]time.Time
+	multipathWindow time.Duration
+
+	// Samples consumed by earlier ProcessIQSamples calls: the receiver clock
+	// that MLAT timestamps count in
+	sampleClock uint64
+
+	// Magnitude buffer reused across ProcessIQSamples calls, grown to the
+	// largest input seen
+	magnitude []uint16
+
+	// Carry the samples the demodulator could not scan at the end of one
+	// buffer into the next, so messages straddling the boundary are decoded
+	bufferOverlap bool
+	carry         []uint16
 }
 
-// NewADSBProcessor creates a new ADS-B processor
+// SupportedSampleRate is the only sample rate the demodulator handles: its
+// preamble and bit timings are fixed for 2.4 MHz and input is not resampled
+const SupportedSampleRate = 2400000
+
+// NewADSBProcessor creates a new ADS-B processor. Any sample rate other than
+// SupportedSampleRate is accepted with a warning, as it decodes few or no
+// messages; use NewADSBProcessorChecked to reject it instead.
 func NewADSBProcessor(sampleRate uint32, logger *logrus.Logger) *ADSBProcessor {
+	if err := checkSampleRate(sampleRate); err != nil {
+		logger.WithField("sample_rate", sampleRate).Warnf("%v; expect few or no decoded messages", err)
+	}
+	return newADSBProcessor(sampleRate, logger)
+}
+
+// NewADSBProcessorChecked creates a new ADS-B processor, failing when the
+// sample rate is not SupportedSampleRate
+func NewADSBProcessorChecked(sampleRate uint32, logger *logrus.Logger) (*ADSBProcessor, error) {
+	if err := checkSampleRate(sampleRate); err != nil {
+		return nil, err
+	}
+	return newADSBProcessor(sampleRate, logger), nil
+}
+
+// checkSampleRate reports an error for a sample rate the demodulator does
+// not support
+func checkSampleRate(sampleRate uint32) error {
+	if sampleRate != SupportedSampleRate {
+		return fmt.Errorf("unsupported sample rate %d Hz: the demodulator requires %d Hz and does not resample", sampleRate, SupportedSampleRate)
+	}
+	return nil
+}
+
+// newADSBProcessor creates a processor with the default settings
+func newADSBProcessor(sampleRate uint32, logger *logrus.Logger) *ADSBProcessor {
 	return &ADSBProcessor{
 		logger:     logger,
 		sampleRate: sampleRate,
-		aircraft:   make(map[uint32]*AircraftState),
+		typeCounts: make(map[string]uint64),
+
+		snrRatio:        snrRatioFromDB(DefaultSNRThreshold),
+		errorCorrection: true,
+		bufferOverlap:   true,
+		recentFrames:    make(map[dedupKey]time.Time),
+		dedupWindow:     DefaultDedupWindow,
+		recentLogical:   make(map[logicalKey]time.Time),
+		multipathWindow: DefaultMultipathWindow,
+		addresses:       NewAddressTable(DefaultAddressTTL),
 	}
 }
 
+// DefaultSNRThreshold is the preamble SNR gate in dB, equivalent to dump1090's
+// signal*2 >= noise*3 check
+const DefaultSNRThreshold = 3.52
+
+// snrScale is the fixed-point scale used for the preamble SNR comparison
+const snrScale = 1000
+
+// snrRatioFromDB converts an SNR threshold in dB to a scaled amplitude ratio
+func snrRatioFromDB(db float64) uint64 {
+	return uint64(math.Round(math.Pow(10, db/20) * snrScale))
+}
+
+// noiseFloorWeight is the weight of each preamble candidate in the noise floor
+// average. Every sample run matching a preamble's peak pattern counts, before
+// the SNR and quiet-bit gates: tens of thousands a second on noise alone at
+// 2.4 MHz, so the estimate follows a change in the channel within a fraction
+// of a second while a single strong frame barely moves it.
+const noiseFloorWeight = 1.0 / 1024
+
+// updateNoiseFloor folds the mean quiet-sample magnitude of a preamble
+// candidate, passed or not, into the noise floor
+func (p *ADSBProcessor) updateNoiseFloor(quiet float64) {
+	if p.noiseFloor == 0 {
+		p.noiseFloor = quiet
+		return
+	}
+	p.noiseFloor += (quiet - p.noiseFloor) * noiseFloorWeight
+}
+
+// SetTracer sets the per-aircraft debug tracer
+func (p *ADSBProcessor) SetTracer(tracer *Tracer) {
+	p.tracer = tracer
+}
+
+// SetErrorCorrection enables or disables CRC error correction; when disabled
+// only frames with a pristine CRC are accepted
+func (p *ADSBProcessor) SetErrorCorrection(enabled bool) {
+	p.errorCorrection = enabled
+}
+
+// SetSinglePhase decodes each preamble only at the phase its shape
+// indicates, about a fifth of the bit decoding and CRC work, instead of
+// trying phases 4-8 and keeping the best. Weak or distorted frames whose
+// best phase differs are lost.
+func (p *ADSBProcessor) SetSinglePhase(enabled bool) {
+	p.singlePhase = enabled
+}
+
+// SetBufferOverlap enables or disables carrying the unscanned end of each
+// buffer into the next ProcessIQSamples call; it is enabled by default.
+// Disabling it drops any samples already carried.
+func (p *ADSBProcessor) SetBufferOverlap(enabled bool) {
+	p.bufferOverlap = enabled
+	if !enabled {
+		p.carry = p.carry[:0]
+	}
+}
+
+// SetDF11Interrogators restricts DF11 all-call replies to the given
+// interrogator codes, the low 7 bits of the parity field XOR the CRC (II codes
+// 0-15 map to themselves). Code 0, used by spontaneous acquisition squitters, is always
+// accepted. Passing strict as false accepts any code, as before.
+func (p *ADSBProcessor) SetDF11Interrogators(strict bool, iids []uint8) {
+	if !strict {
+		p.df11IIDs = nil
+		return
+	}
+	p.df11IIDs = map[uint8]bool{0: true}
+	for _, iid := range iids {
+		p.df11IIDs[iid&0x7F] = true
+	}
+}
+
+// SetSNRThreshold sets the minimum preamble signal-to-noise ratio in dB
+func (p *ADSBProcessor) SetSNRThreshold(db float64) {
+	p.snrRatio = snrRatioFromDB(db)
+}
+
 // Correlation functions from dump1090 - these correlate a 1-0 pair of symbols (manchester encoded 1 bit)
 // nb: the correlation functions sum to zero, so we do not need to adjust for the DC offset
 func slicePhase0(m []uint16) int {
@@ -59,19 +234,96 @@ func slicePhase4(m []uint16) int {
 	return int(m[0]) + 5*int(m[1]) - 5*int(m[2]) - int(m[3])
 }
 
-// ProcessIQSamples processes I/Q samples and extracts ADS-B messages using dump1090's method
-func (p *ADSBProcessor) ProcessIQSamples(iqData []complex128) []*ADSBMessage {
-	// Convert I/Q to magnitude (uint16 to match dump1090)
-	magnitude := p.calculateMagnitude(iqData)
+// SampleDuration returns the time spanned by a number of samples at sampleRate
+func SampleDuration(samples uint64, sampleRate uint32) time.Duration {
+	if sampleRate == 0 {
+		return 0
+	}
+	// Split into whole seconds first so long captures cannot overflow
+	rate := uint64(sampleRate)
+	return time.Duration(samples/rate)*time.Second +
+		time.Duration((samples%rate)*uint64(time.Second)/rate)
+}
+
+// MLATClockRate is the rate of the Beast/MLAT timestamp counter in Hz
+const MLATClockRate = 12000000
+
+// MLATTicks converts a receiver sample count to ticks of the 12 MHz MLAT
+// clock. At 2.4 MHz one sample is exactly five ticks.
+func MLATTicks(samples uint64, sampleRate uint32) uint64 {
+	if sampleRate == 0 {
+		return 0
+	}
+	rate := uint64(sampleRate)
+	return samples/rate*MLATClockRate + samples%rate*MLATClockRate/rate
+}
+
+// ProcessIQSamples processes I/Q samples and extracts ADS-B messages using dump1090's method.
+// baseTime is the reception time of the first sample; message timestamps are
+// derived from their sample offset so processing delays do not skew them.
+// With buffer overlap, the samples left unscanned at the end of the previous
+// buffer are demodulated ahead of iqData, so messages straddling the boundary
+// are found once.
+// It reuses internal buffers and must not be called concurrently.
+func (p *ADSBProcessor) ProcessIQSamples(iqData []complex128, baseTime time.Time) []*ADSBMessage {
+	carried := len(p.carry)
+
+	tail := minDemodSamples
+	if p.bufferOverlap {
+		tail = overlapSamples
+	}
+
+	// Too short to hold a message: only the sample clock moves on, and the
+	// samples wait in the carry for the next buffer
+	if carried+len(iqData) < tail {
+		if p.bufferOverlap {
+			p.carry = append(p.carry, p.calculateMagnitude(iqData)...)
+		}
+		p.sampleClock += uint64(len(iqData))
+		return nil
+	}
+
+	// Convert I/Q to magnitude (uint16 to match dump1090) after the carried
+	// samples, which came before baseTime and the sample clock
+	if cap(p.magnitude) < carried+len(iqData) {
+		p.magnitude = make([]uint16, carried+len(iqData))
+	}
+	magnitude := p.magnitude[:carried+len(iqData)]
+	copy(magnitude, p.carry)
+	p.magnitudeInto(magnitude[carried:], iqData)
+	p.sampleClock -= uint64(carried)
 
 	// Demodulate using dump1090's approach
-	return p.demodulate2400(magnitude)
+	messages, next := p.demodulateFrom(magnitude, baseTime.Add(-SampleDuration(uint64(carried), p.sampleRate)), tail)
+	p.carry = p.carry[:0]
+	if p.bufferOverlap && next < len(magnitude) {
+		p.carry = append(p.carry, magnitude[next:]...)
+	}
+	p.sampleClock += uint64(carried)
+
+	// Forget frames that have fallen out of the dedup and multipath windows
+	end := baseTime.Add(SampleDuration(uint64(len(iqData)), p.sampleRate))
+	p.pruneRecentFrames(end)
+	p.pruneRecentLogical(end)
+	p.addresses.Prune(end)
+	p.sampleClock += uint64(len(iqData))
+
+	return messages
 }
 
-// calculateMagnitude converts I/Q samples to magnitude (similar to dump1090's magnitude calculation)
+// calculateMagnitude converts I/Q samples to magnitude (similar to dump1090's magnitude calculation).
+// The result is only valid until the next call.
 func (p *ADSBProcessor) calculateMagnitude(iqData []complex128) []uint16 {
-	magnitude := make([]uint16, len(iqData))
+	if cap(p.magnitude) < len(iqData) {
+		p.magnitude = make([]uint16, len(iqData))
+	}
+	magnitude := p.magnitude[:len(iqData)]
+	p.magnitudeInto(magnitude, iqData)
+	return magnitude
+}
 
+// magnitudeInto converts iqData to magnitudes in m, which must be as long
+func (p *ADSBProcessor) magnitudeInto(m []uint16, iqData []complex128) {
 	for i, sample := range iqData {
 		mag := cmplx.Abs(sample)
 		// Scale to uint16 range similar to dump1090
@@ -79,27 +331,63 @@ func (p *ADSBProcessor) calculateMagnitude(iqData []complex128) []uint16 {
 		if scaled > 65535 {
 			scaled = 65535
 		}
-		magnitude[i] = uint16(scaled)
+		m[i] = uint16(scaled)
 	}
+}
 
-	return magnitude
+// nextPreambleCandidate returns the first index from j, below end, that passes
+// the quick preamble check (rising edge 0->1, falling edges 1->2 and 12->13),
+// or end. Every phase pattern peaks at sample 1, so when sample 1 falls to
+// sample 2 there is no rising edge at j+1 either and the search steps over
+// it; on noise that skips roughly a third of the indices.
+func nextPreambleCandidate(m []uint16, j, end int) int {
+	for j < end {
+		if m[j+1] <= m[j+2] {
+			j++
+			continue
+		}
+		if m[j] < m[j+1] && m[j+12] > m[j+13] {
+			return j
+		}
+		j += 2
+	}
+	return end
 }
 
+// minDemodSamples is how many samples from a preamble candidate to the end of
+// the buffer the demodulator scans for a long message
+const minDemodSamples = 240
+
+// overlapSamples is how many samples at the end of a buffer are left for the
+// next one with buffer overlap. A preamble and long message decoded at the
+// latest phase read about 290 samples, so a preamble anywhere before the tail
+// is decoded whole and none is scanned twice.
+const overlapSamples = 300
+
 // demodulate2400 implements dump1090's 2.4MHz demodulation approach
-func (p *ADSBProcessor) demodulate2400(m []uint16) []*ADSBMessage {
-	var messages []*ADSBMessage
-	mlen := len(m)
+func (p *ADSBProcessor) demodulate2400(m []uint16, baseTime time.Time) []*ADSBMessage {
+	messages, _ := p.demodulateFrom(m, baseTime, minDemodSamples)
+	return messages
+}
 
-	for j := 0; j < mlen-240; j++ { // Need at least 240 samples for a long message
-		preamble := m[j : j+19]
+// demodulateFrom demodulates m, leaving the last tail samples unscanned, and
+// also returns the index of the first sample neither scanned for a preamble
+// nor inside a decoded frame, where the scan resumes when the buffer continues
+func (p *ADSBProcessor) demodulateFrom(m []uint16, baseTime time.Time, tail int) ([]*ADSBMessage, int) {
+	if len(m) < tail {
+		return nil, 0
+	}
 
-		// Quick check: rising edge 0->1 and falling edge 12->13
-		if !(preamble[0] < preamble[1] && preamble[12] > preamble[13]) {
-			continue
-		}
+	var messages []*ADSBMessage
+	end := len(m) - tail
+	next := end
+	for j := nextPreambleCandidate(m, 0, end); j < end; j = nextPreambleCandidate(m, j+1, end) {
+		preamble := m[j : j+19]
 
 		var high uint16
 		var baseSignal, baseNoise uint32
+		var quietSamples uint32
+		var preamblePhase int
 		validPreamble := false
 
 		// Check different phase patterns (from dump1090)
@@ -111,6 +399,8 @@ func (p *ADSBProcessor) demodulate2400(m []uint16) []*ADSBMessage {
 			high = (preamble[1] + preamble[3] + preamble[9] + preamble[11] + preamble[12]) / 4
 			baseSignal = uint32(preamble[1]) + uint32(preamble[3]) + uint32(preamble[9])
 			baseNoise = uint32(preamble[5]) + uint32(preamble[6]) + uint32(preamble[7])
+			quietSamples = 3
+			preamblePhase = 3
 			validPreamble = true
 		} else if preamble[1] > preamble[2] &&
 			preamble[2] < preamble[3] && preamble[3] > preamble[4] &&
@@ -120,6 +410,8 @@ func (p *ADSBProcessor) demodulate2400(m []uint16) []*ADSBMessage {
 			high = (preamble[1] + preamble[3] + preamble[9] + preamble[12]) / 4
 			baseSignal = uint32(preamble[1]) + uint32(preamble[3]) + uint32(preamble[9]) + uint32(preamble[12])
 			baseNoise = uint32(preamble[5]) + uint32(preamble[6]) + uint32(preamble[7]) + uint32(preamble[8])
+			quietSamples = 4
+			preamblePhase = 4
 			validPreamble = true
 		}
 		// Add other phase patterns as needed...
@@ -128,8 +420,13 @@ func (p *ADSBProcessor) demodulate2400(m []uint16) []*ADSBMessage {
 			continue
 		}
 
-		// Check for enough signal (about 3.5dB SNR)
-		if baseSignal*2 < 3*baseNoise {
+		// Every candidate feeds the noise floor, before any gate, so it
+		// tracks the channel rather than the candidates that pass
+		p.updateNoiseFloor(float64(baseNoise) / float64(quietSamples))
+
+		// Check for enough signal (3.5dB SNR by default)
+		if uint64(baseSignal)*snrScale < p.snrRatio*uint64(baseNoise) {
+			p.rejectedSNR++
 			continue
 		}
 
@@ -137,56 +434,157 @@ func (p *ADSBProcessor) demodulate2400(m []uint16) []*ADSBMessage {
 		if preamble[5] >= high || preamble[6] >= high || preamble[7] >= high ||
 			preamble[8] >= high || preamble[14] >= high || preamble[15] >= high ||
 			preamble[16] >= high || preamble[17] >= high || preamble[18] >= high {
+			p.rejectedQuiet++
+			continue
+		}
+
+		// The same SNR applies against the channel noise floor, so a noise
+		// spike does not pass on a locally quiet gap
+		if uint64(baseSignal)*snrScale < p.snrRatio*uint64(p.noiseFloor*float64(quietSamples)) {
+			p.rejectedNoiseFloor++
 			continue
 		}
 
 		p.preambleCount++
 
-		// Try all phases and find the best scoring message
-		bestMessage := p.tryAllPhases(m[j:], j)
-		if bestMessage != nil {
+		// Try all phases and find the best scoring message, or only the one
+		// after the preamble's phase, where its data bits start
+		firstPhase, lastPhase := firstTryPhase, lastTryPhase
+		if p.singlePhase {
+			firstPhase, lastPhase = preamblePhase+1, preamblePhase+1
+		}
+		bestMessage := p.tryPhases(m[j:], baseTime.Add(SampleDuration(uint64(j), p.sampleRate)), firstPhase, lastPhase)
+		if bestMessage != nil && bestMessage.CRCType == "comm-d" {
+			// Comm-D ELM segments from known aircraft are passed on
+			// invalid for reassembly and the whole frame is skipped
+			bestMessage.Signal = signalDBFS(high)
+			bestMessage.SampleClock = p.sampleClock + uint64(j)
 			messages = append(messages, bestMessage)
+			p.commDFrames++
+			j += messageSamples(MessageLength(bestMessage.GetDF()))
+			next = max(next, j+1)
+		} else if bestMessage != nil {
+			bestMessage.Signal = signalDBFS(high)
+			bestMessage.SampleClock = p.sampleClock + uint64(j)
 
 			if bestMessage.Valid {
-				p.validMessages++
-			} else {
+				p.tracer.Debugf(bestMessage.GetICAO(), "Demodulated: DF=%d, ICAO=%06X, phase=%d, score=%d, crc=%s, corrected=%d",
+					bestMessage.GetDF(), bestMessage.GetICAO(), bestMessage.Phase, bestMessage.Score, bestMessage.CRCType, bestMessage.ErrorsCorrected)
+			}
+
+			// Emit one copy of each transmission and its reflections, keeping
+			// the best one, but still skip past the others
+			if !bestMessage.Valid {
+				messages = append(messages, bestMessage)
 				p.rejectedBad++
+			} else if p.isDuplicate(bestMessage) || p.isReflection(bestMessage) {
+				p.keepBetterCopy(messages, bestMessage)
+				p.learnAddress(bestMessage)
+			} else {
+				messages = append(messages, bestMessage)
+				p.validMessages++
+				p.countCorrection(bestMessage)
+				p.countType(bestMessage)
+				p.countPhase(bestMessage)
+				p.learnAddress(bestMessage)
 			}
 
 			// Skip ahead to avoid overlapping messages
-			msgLen := 14 // Assume long message for now
-			if bestMessage.Data[0]>>3 == 0 || bestMessage.Data[0]>>3 == 4 || bestMessage.Data[0]>>3 == 5 || bestMessage.Data[0]>>3 == 11 {
-				msgLen = 7 // Short message
-			}
-			j += msgLen * 12 / 5 // samples per message
+			j += messageSamples(MessageLength(bestMessage.GetDF()))
+			next = max(next, j+1)
 		} else {
 			p.rejectedUnknown++
 		}
 	}
 
-	return messages
+	return messages, next
+}
+
+// learnAddress records the address of a valid frame that sends it in the
+// clear, to verify later frames that overlay it on their parity. Corrected
+// frames are left out, as a wrong correction would invent an aircraft.
+func (p *ADSBProcessor) learnAddress(msg *ADSBMessage) {
+	if msg.ErrorsCorrected > 0 {
+		return
+	}
+	switch msg.GetDF() {
+	case 11, 17:
+	case 18:
+		// Only CF0 carries an ICAO address
+		if msg.GetCF() != 0 {
+			return
+		}
+	default:
+		return
+	}
+	p.addresses.Add(msg.GetICAO(), msg.Timestamp)
+}
+
+// Addresses returns the table of addresses recently received in the clear
+func (p *ADSBProcessor) Addresses() *AddressTable {
+	return p.addresses
+}
+
+// signalDBFS converts a preamble pulse magnitude to dB relative to the
+// magnitude full scale
+func signalDBFS(high uint16) float64 {
+	if high == 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(float64(high)/math.MaxUint16)
+}
+
+// countCorrection records the bit errors repaired in an emitted message
+func (p *ADSBProcessor) countCorrection(msg *ADSBMessage) {
+	switch msg.ErrorsCorrected {
+	case 1:
+		p.singleBitErrors++
+	case 2:
+		p.twoBitErrors++
+	default:
+		return
+	}
+	p.correctedMessages++
+}
+
+// uncountCorrection reverses countCorrection for a message replaced by a
+// better copy
+func (p *ADSBProcessor) uncountCorrection(msg *ADSBMessage) {
+	switch msg.ErrorsCorrected {
+	case 1:
+		p.singleBitErrors--
+	case 2:
+		p.twoBitErrors--
+	default:
+		return
+	}
+	p.correctedMessages--
+}
+
+// messageSamples returns how many 2.4 MHz samples a message of msgLen bytes spans
+func messageSamples(msgLen int) int {
+	return msgLen * 8 * 12 / 5
 }
 
-// tryAllPhases tries decoding with different phases and returns the best scoring message
-func (p *ADSBProcessor) tryAllPhases(m []uint16, position int) *ADSBMessage {
+// tryPhases tries decoding with phases first to last and returns the best
+// scoring message, stamped with the reception time of its preamble
+func (p *ADSBProcessor) tryPhases(m []uint16, timestamp time.Time, first, last int) *ADSBMessage {
 	var bestMessage *ADSBMessage
 	bestScore := -1
 
-	// Try phases 4-8 like dump1090
-	for tryPhase := 4; tryPhase <= 8; tryPhase++ {
+	for tryPhase := first; tryPhase <= last; tryPhase++ {
 		message := p.decodeBitsWithPhase(m, tryPhase)
 		if message == nil {
 			continue
 		}
 
 		message.Phase = tryPhase
-		message.Timestamp = time.Now()
+		message.Timestamp = timestamp
 
-		// Enhanced CRC validation with error correction (like dump1090)
-		singleBit, twoBit, corrected := ValidateAndCorrectMessage(message)
-		p.singleBitErrors += singleBit
-		p.twoBitErrors += twoBit
-		p.correctedMessages += corrected
+		// Enhanced CRC validation with error correction (like dump1090).
+		// Corrections are counted once the best phase is emitted.
+		ValidateMessageKnown(message, p.errorCorrection, p.addresses)
+		p.checkDF11Interrogator(message)
 
 		// Score the message (dump1090-style scoring)
 		score := p.scoreMessage(message)
@@ -201,6 +599,25 @@ func (p *ADSBProcessor) tryAllPhases(m []uint16, position int) *ADSBMessage {
 	return bestMessage
 }
 
+// Demodulation phases tried after a preamble, as in dump1090. A phase is the
+// data start offset in fifths of a 2.4 MHz sample.
+const (
+	firstTryPhase = 4
+	lastTryPhase  = 8
+)
+
+// checkDF11Interrogator rejects a DF11 reply whose interrogator code is not
+// accepted. Corrected replies always have code 0.
+func (p *ADSBProcessor) checkDF11Interrogator(msg *ADSBMessage) {
+	if p.df11IIDs == nil || !msg.Valid || msg.CRCType != "valid" || msg.GetDF() != 11 {
+		return
+	}
+	if !p.df11IIDs[uint8(df11Interrogator(msg))] {
+		msg.Valid = false
+		msg.CRCType = "invalid-iid"
+	}
+}
+
 // decodeBitsWithPhase decodes 112 bits using the specified phase
 func (p *ADSBProcessor) decodeBitsWithPhase(m []uint16, tryPhase int) *ADSBMessage {
 	const MODES_LONG_MSG_BYTES = 14
@@ -294,8 +711,7 @@ func (p *ADSBProcessor) decodeBitsWithPhase(m []uint16, tryPhase int) *ADSBMessa
 
 		// Early termination for short messages
 		if i == 0 {
-			df := msg[0] >> 3
-			if df == 0 || df == 4 || df == 5 || df == 11 {
+			if MessageLength(DecodeDF(msg[0])) == ShortMessageBytes {
 				// Short message - decode only 7 bytes
 				if i+1 < 7 {
 					continue
@@ -325,6 +741,12 @@ func (p *ADSBProcessor) bitValue(correlation int) uint8 {
 
 // scoreMessage scores a decoded message (enhanced dump1090-style scoring)
 func (p *ADSBProcessor) scoreMessage(msg *ADSBMessage) int {
+	// Comm-D is recognised but unverifiable; score it above a failed CRC so
+	// its length is honoured, but below any verified phase
+	if msg.CRCType == "comm-d" {
+		return 0
+	}
+
 	if !msg.Valid {
 		return -1 // Invalid CRC
 	}
@@ -343,7 +765,7 @@ func (p *ADSBProcessor) scoreMessage(msg *ADSBMessage) int {
 	}
 
 	// Check DF (Downlink Format) validity
-	df := msg.Data[0] >> 3
+	df := DecodeDF(msg.Data[0])
 	switch df {
 	case 0, 4, 5, 11, 16, 17, 18, 20, 21, 24:
 		// Valid DF codes
@@ -373,3 +795,97 @@ func (p *ADSBProcessor) scoreMessage(msg *ADSBMessage) int {
 func (p *ADSBProcessor) GetStats() (uint64, uint64, uint64, uint64, uint64, uint64) {
 	return p.messageCount, p.preambleCount, p.validMessages, p.correctedMessages, p.singleBitErrors, p.twoBitErrors
 }
+
+// GetPreambleRejects returns the number of preambles rejected by the SNR gate,
+// by the quiet-bit gate and by the SNR gate against the noise floor
+func (p *ADSBProcessor) GetPreambleRejects() (uint64, uint64, uint64) {
+	return p.rejectedSNR, p.rejectedQuiet, p.rejectedNoiseFloor
+}
+
+// GetNoiseFloor returns the channel noise floor in dBFS, or -Inf before any
+// preamble passed the local checks
+func (p *ADSBProcessor) GetNoiseFloor() float64 {
+	if p.noiseFloor == 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(p.noiseFloor/math.MaxUint16)
+}
+
+// GetBadCount returns the number of preambles that did not yield a message
+func (p *ADSBProcessor) GetBadCount() uint64 {
+	return p.rejectedBad + p.rejectedUnknown
+}
+
+// GetCommDCount returns the number of Comm-D ELM frames received
+func (p *ADSBProcessor) GetCommDCount() uint64 {
+	return p.commDFrames
+}
+
+// countType counts a valid message under its downlink format, and for
+// extended squitter also under its type code
+func (p *ADSBProcessor) countType(msg *ADSBMessage) {
+	df := msg.GetDF()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.typeCounts[fmt.Sprintf("DF%d", df)]++
+	if df == 17 || df == 18 {
+		p.typeCounts[fmt.Sprintf("DF%d/TC%d", df, msg.GetTypeCode())]++
+	}
+}
+
+// countPhase counts a valid message under the phase it was decoded at
+func (p *ADSBProcessor) countPhase(msg *ADSBMessage) {
+	if msg.Phase < firstTryPhase || msg.Phase > lastTryPhase {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.phaseCounts[msg.Phase-firstTryPhase]++
+}
+
+// GetPhaseStats returns the valid messages decoded at each phase, keyed by
+// phase (4-8). A healthy receiver spreads across the phases; most messages
+// landing on one or two suggests a sample clock offset.
+func (p *ADSBProcessor) GetPhaseStats() map[int]uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := make(map[int]uint64, len(p.phaseCounts))
+	for i, count := range p.phaseCounts {
+		stats[firstTryPhase+i] = count
+	}
+	return stats
+}
+
+// GetTypeBreakdown returns a copy of the valid message counts keyed by
+// downlink format ("DF11") and, for DF17/18, by type code ("DF17/TC19").
+// A DF17 message is counted under both keys.
+func (p *ADSBProcessor) GetTypeBreakdown() map[string]uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	breakdown := make(map[string]uint64, len(p.typeCounts))
+	for key, count := range p.typeCounts {
+		breakdown[key] = count
+	}
+	return breakdown
+}
+
+// GetDuplicateCount returns the number of duplicate frames suppressed
+func (p *ADSBProcessor) GetDuplicateCount() uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.duplicates
+}
+
+// GetReflectionCount returns the number of multipath copies suppressed that
+// were not exact duplicates
+func (p *ADSBProcessor) GetReflectionCount() uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.reflections
+}
@@ -0,0 +1,36 @@
+package adsb
+
+// AircraftSize is the upper bound of an aircraft's dimensions in metres, as
+// reported by the length/width code of a surface operational status message
+type AircraftSize struct {
+	Length float64 `json:"length"`
+	Width  float64 `json:"width"`
+}
+
+// aircraftSizes maps length/width codes 1-15 to dimensions; code 0 means no data
+var aircraftSizes = [16]AircraftSize{
+	1:  {15, 23},
+	2:  {25, 28.5},
+	3:  {25, 34},
+	4:  {35, 33},
+	5:  {35, 38},
+	6:  {45, 39.5},
+	7:  {45, 45},
+	8:  {55, 45},
+	9:  {55, 52},
+	10: {65, 59.5},
+	11: {65, 67},
+	12: {75, 72.5},
+	13: {75, 80},
+	14: {85, 80},
+	15: {85, 90},
+}
+
+// AircraftSizeFromCode converts a 4-bit length/width code to dimensions,
+// returning false for code 0 (no data)
+func AircraftSizeFromCode(code uint8) (AircraftSize, bool) {
+	if code == 0 || int(code) >= len(aircraftSizes) {
+		return AircraftSize{}, false
+	}
+	return aircraftSizes[code], true
+}
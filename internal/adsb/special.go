@@ -0,0 +1,67 @@
+package adsb
+
+import "sort"
+
+// AddressClass is the kind of holder a 24-bit address belongs to
+type AddressClass int
+
+const (
+	AddressCivil     AddressClass = iota // Allocated to a state, civil or unknown use
+	AddressMilitary                      // A state's block reserved for military aircraft
+	AddressAnonymous                     // Allocated to no state, e.g. TIS-B/ADS-R track files
+)
+
+// specialBlock is a part of a state's allocation set aside for one use
+type specialBlock struct {
+	start uint32
+	end   uint32
+	class AddressClass
+}
+
+// specialBlocks are the special-use parts of state allocations, after the
+// military ranges published by tar1090. Sorted by start address with no
+// overlapping blocks; each lies within one entry of icaoAllocations.
+var specialBlocks = []specialBlock{
+	{0x33FF00, 0x33FFFF, AddressMilitary}, // Italy
+	{0x350000, 0x37FFFF, AddressMilitary}, // Spain
+	{0x3AA000, 0x3AFFFF, AddressMilitary}, // France
+	{0x3B7000, 0x3BFFFF, AddressMilitary}, // France
+	{0x3EA000, 0x3EBFFF, AddressMilitary}, // Germany
+	{0x3F4000, 0x3FBFFF, AddressMilitary}, // Germany
+	{0x43C000, 0x43CFFF, AddressMilitary}, // United Kingdom
+	{0x444000, 0x446FFF, AddressMilitary}, // Austria
+	{0x44F000, 0x44FFFF, AddressMilitary}, // Belgium
+	{0x457000, 0x457FFF, AddressMilitary}, // Bulgaria
+	{0x45F400, 0x45F4FF, AddressMilitary}, // Denmark
+	{0x468000, 0x4683FF, AddressMilitary}, // Greece
+	{0x473C00, 0x473C0F, AddressMilitary}, // Hungary
+	{0x478100, 0x4781FF, AddressMilitary}, // Norway
+	{0x480000, 0x480FFF, AddressMilitary}, // Netherlands
+	{0x48D800, 0x48D87F, AddressMilitary}, // Poland
+	{0x497C00, 0x497CFF, AddressMilitary}, // Portugal
+	{0x498420, 0x49842F, AddressMilitary}, // Czechia
+	{0x4B7000, 0x4B7FFF, AddressMilitary}, // Switzerland
+	{0x4B8200, 0x4B82FF, AddressMilitary}, // Turkey
+	{0x738A00, 0x738AFF, AddressMilitary}, // Israel
+	{0x7CF800, 0x7CFAFF, AddressMilitary}, // Australia
+	{0xADF7C8, 0xAFFFFF, AddressMilitary}, // United States
+	{0xC20000, 0xC3FFFF, AddressMilitary}, // Canada
+	{0xE40000, 0xE41FFF, AddressMilitary}, // Brazil
+}
+
+// ClassifyICAO returns the class of a 24-bit address. Addresses outside every
+// state allocation are anonymous; within one, the special-use blocks decide.
+func ClassifyICAO(icao uint32) AddressClass {
+	if CountryForICAO(icao) == "" {
+		return AddressAnonymous
+	}
+
+	// First block whose end is at or above the address
+	i := sort.Search(len(specialBlocks), func(i int) bool {
+		return specialBlocks[i].end >= icao
+	})
+	if i < len(specialBlocks) && specialBlocks[i].start <= icao {
+		return specialBlocks[i].class
+	}
+	return AddressCivil
+}
@@ -0,0 +1,40 @@
+package adsb
+
+// Emergency states reported in output (readsb naming)
+const (
+	EmergencyGeneral  = "general"  // 7700
+	EmergencyNoRadio  = "nordo"    // 7600
+	EmergencyUnlawful = "unlawful" // 7500
+)
+
+// DecodeID13 converts a 13-bit Mode A identity field (C1 A1 C2 A2 C4 A4 X B1 D1
+// B2 D2 B4 D4) into the squawk as a four-digit decimal number, e.g. 7700
+func DecodeID13(id uint16) int {
+	bit := func(mask uint16) int {
+		if id&mask != 0 {
+			return 1
+		}
+		return 0
+	}
+
+	a := bit(0x0800) | bit(0x0200)<<1 | bit(0x0080)<<2
+	b := bit(0x0020) | bit(0x0008)<<1 | bit(0x0002)<<2
+	c := bit(0x1000) | bit(0x0400)<<1 | bit(0x0100)<<2
+	d := bit(0x0010) | bit(0x0004)<<1 | bit(0x0001)<<2
+
+	return a*SquawkAMultiplier + b*SquawkBMultiplier + c*SquawkCMultiplier + d*SquawkDMultiplier
+}
+
+// SquawkEmergency returns the emergency state signalled by a special squawk,
+// or an empty string for ordinary codes
+func SquawkEmergency(squawk int) string {
+	switch squawk {
+	case 7500:
+		return EmergencyUnlawful
+	case 7600:
+		return EmergencyNoRadio
+	case 7700:
+		return EmergencyGeneral
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package adsb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDecodeID13 tests Mode A identity decoding
+func TestDecodeID13(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       uint16
+		expected int
+	}{
+		{name: "DF5 frame 2A00516D492B80", id: 0x116D, expected: 356},
+		{name: "7700", id: 0x0AAA, expected: 7700},
+		{name: "7500", id: 0x0AA2, expected: 7500},
+		{name: "7600", id: 0x0A8A, expected: 7600},
+		{name: "1200", id: 0x0808, expected: 1200},
+		{name: "X bit ignored", id: 0x0040, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, DecodeID13(tt.id))
+		})
+	}
+}
+
+// TestSquawkEmergency tests the special emergency squawk mapping
+func TestSquawkEmergency(t *testing.T) {
+	tests := []struct {
+		squawk   int
+		expected string
+	}{
+		{squawk: 7500, expected: EmergencyUnlawful},
+		{squawk: 7600, expected: EmergencyNoRadio},
+		{squawk: 7700, expected: EmergencyGeneral},
+		{squawk: 1200, expected: ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, SquawkEmergency(tt.squawk), "squawk %04d", tt.squawk)
+	}
+}
@@ -0,0 +1,101 @@
+package adsb
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultTraceRateLimit caps traced debug lines per second for a single aircraft
+const DefaultTraceRateLimit = 100
+
+// Tracer gates per-aircraft debug logging. In verbose mode every message is
+// logged at debug level as before; otherwise only the traced ICAO is logged,
+// through a separate debug-level logger so everything else stays at info.
+type Tracer struct {
+	logger  *logrus.Logger
+	verbose bool
+
+	traceLogger *logrus.Logger
+	traceICAO   uint32
+	tracing     bool
+
+	// Rate limiting for traced lines
+	mu          sync.Mutex
+	rateLimit   int
+	windowStart time.Time
+	windowCount int
+	dropped     int
+}
+
+// NewTracer creates a tracer writing through the given logger
+func NewTracer(logger *logrus.Logger, verbose bool) *Tracer {
+	return &Tracer{
+		logger:    logger,
+		verbose:   verbose,
+		rateLimit: DefaultTraceRateLimit,
+	}
+}
+
+// SetTraceICAO enables debug tracing for a single aircraft
+func (t *Tracer) SetTraceICAO(icao uint32) {
+	traceLogger := logrus.New()
+	traceLogger.SetOutput(t.logger.Out)
+	traceLogger.SetFormatter(t.logger.Formatter)
+	traceLogger.SetLevel(logrus.DebugLevel)
+
+	t.traceLogger = traceLogger
+	t.traceICAO = icao
+	t.tracing = true
+}
+
+// Enabled reports whether debug output is produced for the given aircraft
+func (t *Tracer) Enabled(icao uint32) bool {
+	if t == nil {
+		return false
+	}
+	return t.verbose || (t.tracing && icao == t.traceICAO)
+}
+
+// Debugf logs a debug message about the given aircraft if it is being traced
+func (t *Tracer) Debugf(icao uint32, format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	if t.verbose {
+		t.logger.Debugf(format, args...)
+		return
+	}
+	if !t.tracing || icao != t.traceICAO {
+		return
+	}
+	if !t.allow(time.Now()) {
+		return
+	}
+	t.traceLogger.WithField("trace_icao", fmt.Sprintf("%06X", icao)).Debugf(format, args...)
+}
+
+// allow applies the per-second rate limit, reporting lines dropped in the
+// previous window when a new one starts
+func (t *Tracer) allow(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.windowStart) >= time.Second {
+		if t.dropped > 0 {
+			t.traceLogger.WithField("dropped", t.dropped).Debug("Trace rate limit reached")
+		}
+		t.windowStart = now
+		t.windowCount = 0
+		t.dropped = 0
+	}
+
+	if t.windowCount >= t.rateLimit {
+		t.dropped++
+		return false
+	}
+	t.windowCount++
+	return true
+}
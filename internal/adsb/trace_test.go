@@ -0,0 +1,81 @@
+package adsb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTraceTestLogger returns an info-level logger writing into a buffer
+func newTraceTestLogger() (*logrus.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetLevel(logrus.InfoLevel)
+	return logger, &buf
+}
+
+// TestTracer tests that only the traced ICAO produces debug output
+func TestTracer(t *testing.T) {
+	logger, buf := newTraceTestLogger()
+	tracer := NewTracer(logger, false)
+	tracer.SetTraceICAO(0x4840D6)
+
+	tracer.Debugf(0x123456, "other aircraft")
+	assert.Empty(t, buf.String())
+	assert.False(t, tracer.Enabled(0x123456))
+
+	tracer.Debugf(0x4840D6, "traced aircraft")
+	assert.Contains(t, buf.String(), "traced aircraft")
+	assert.Contains(t, buf.String(), "trace_icao=4840D6")
+	assert.True(t, tracer.Enabled(0x4840D6))
+
+	// The main logger stays at info level
+	assert.Equal(t, logrus.InfoLevel, logger.GetLevel())
+}
+
+// TestTracer_CPRDecoder tests that CPR debug output is gated on the traced ICAO
+func TestTracer_CPRDecoder(t *testing.T) {
+	logger, buf := newTraceTestLogger()
+	tracer := NewTracer(logger, false)
+	tracer.SetTraceICAO(0x40621D)
+
+	decoder := NewCPRDecoder(logger, false)
+	decoder.SetTracer(tracer)
+
+	// Non-matching aircraft produces no debug output
+	decoder.DecodeCPRPosition(0x123456, 0, 93000, 51372)
+	decoder.DecodeCPRPosition(0x123456, 1, 74158, 50194)
+	assert.Empty(t, buf.String())
+
+	// Traced aircraft logs CPR internals
+	decoder.DecodeCPRPosition(0x40621D, 0, 93000, 51372)
+	decoder.DecodeCPRPosition(0x40621D, 1, 74158, 50194)
+	assert.Contains(t, buf.String(), "Both frames CPR")
+	assert.NotContains(t, buf.String(), "123456")
+}
+
+// TestTracer_RateLimit tests that traced output is capped per second
+func TestTracer_RateLimit(t *testing.T) {
+	logger, buf := newTraceTestLogger()
+	tracer := NewTracer(logger, false)
+	tracer.SetTraceICAO(0x4840D6)
+	tracer.rateLimit = 2
+
+	for i := 0; i < 5; i++ {
+		tracer.Debugf(0x4840D6, "line %d", i)
+	}
+
+	assert.Equal(t, 2, strings.Count(buf.String(), "msg=\"line"))
+	assert.Equal(t, 3, tracer.dropped)
+}
+
+// TestTracer_Nil tests that a nil tracer is a no-op
+func TestTracer_Nil(t *testing.T) {
+	var tracer *Tracer
+	assert.False(t, tracer.Enabled(0x4840D6))
+	tracer.Debugf(0x4840D6, "ignored")
+}
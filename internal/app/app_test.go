@@ -1,10 +1,35 @@
 package app
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go1090/internal/adsb"
+	"go1090/internal/beast"
+	"go1090/internal/stream"
 )
 
 // TestConfig tests the configuration struct and constants
@@ -110,6 +135,8 @@ func TestNewApplication(t *testing.T) {
 
 	assert.NotNil(t, app)
 	assert.NotNil(t, app.logger)
+	assert.NotNil(t, app.registry)
+	assert.Nil(t, app.cprDecoder) // Created with the other components; it alone tracks CPR frames
 	// Note: config fields are private, so we test functionality instead
 }
 
@@ -276,13 +303,2634 @@ func TestApplication_Context(t *testing.T) {
 	// Context functionality is internal, just verify app creation
 }
 
-// Cleanup test logs
-func TestMain(m *testing.M) {
-	// Run tests
-	code := m.Run()
+// TestApplication_ConvertToJSON tests JSON output of Comm-B selected altitude
+func TestApplication_ConvertToJSON(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
 
-	// Cleanup
-	os.RemoveAll("./test_logs")
+	// DF20 reply carrying BDS 4,0: MCP/FMS 3008 ft, QNH 1020 mb
+	data, err := hex.DecodeString("A000029C85E42F313000007047D3")
+	require.NoError(t, err)
+	msg := &adsb.ADSBMessage{Valid: true, CRCType: "valid", Signal: -6}
+	copy(msg.Data[:], data)
 
-	os.Exit(code)
+	decoded := app.decodeMessage(msg)
+	require.NotNil(t, decoded)
+
+	line, err := app.formatMessage(decoded)
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(line), &fields))
+	assert.Equal(t, float64(20), fields["df"])
+	assert.Equal(t, float64(3008), fields["nav_altitude_mcp"])
+	assert.Equal(t, float64(3008), fields["nav_altitude_fms"])
+	assert.InDelta(t, 1020.0, fields["nav_qnh"], 0.05)
+	assert.NotContains(t, fields, "lat")
+	assert.Equal(t, false, fields["spontaneous"])
+	assert.Equal(t, float64(85), fields["confidence"]) // Score left at 0
+}
+
+// TestApplication_LocalQNH tests flagging a Comm-B pressure setting far from
+// the configured local QNH
+func TestApplication_LocalQNH(t *testing.T) {
+	// DF20 reply carrying BDS 4,0 with QNH 1020 mb
+	data, err := hex.DecodeString("A000029C85E42F313000007047D3")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		localQNH float64
+		expected bool
+	}{
+		{name: "Disabled", localQNH: 0, expected: false},
+		{name: "Close to local", localQNH: 1017, expected: false},
+		{name: "Far from local", localQNH: 995, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewApplication(Config{OutputFormat: OutputFormatJSON, LocalQNH: tt.localQNH})
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			require.NotNil(t, decoded.NavQNH)
+			assert.Equal(t, 1020.0, *decoded.NavQNH)
+			assert.Equal(t, tt.expected, decoded.NavQNHOutlier)
+
+			line, err := app.formatMessage(decoded)
+			require.NoError(t, err)
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &fields))
+			if tt.expected {
+				assert.Equal(t, true, fields["nav_qnh_outlier"])
+			} else {
+				assert.NotContains(t, fields, "nav_qnh_outlier")
+			}
+		})
+	}
+
+	app := NewApplication(Config{LocalQNH: 1200, Stdin: true})
+	err = app.initializeComponents()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "local QNH")
+}
+
+// TestApplication_TrackAndHeading tests roll, true track and magnetic heading
+// from Comm-B replies identified as BDS 5,0 or 6,0
+func TestApplication_TrackAndHeading(t *testing.T) {
+	tests := []struct {
+		name     string
+		frame    string
+		expected map[string]float64
+	}{
+		{
+			name:     "BDS 5,0",
+			frame:    "A000139381951536E024D4CCF6B5",
+			expected: map[string]float64{"roll": 2.109375, "true_track": 114.2578125},
+		},
+		{
+			name:     "BDS 6,0",
+			frame:    "A00004128F39F91A7E27C46ADC21",
+			expected: map[string]float64{"mag_heading": 42.71484375},
+		},
+		{
+			name:     "Fits both BDS 5,0 and 6,0",
+			frame:    "A0001393F8E00000000000000000",
+			expected: map[string]float64{},
+		},
+		{
+			name:     "BDS 4,0",
+			frame:    "A000029C85E42F313000007047D3",
+			expected: map[string]float64{},
+		},
+	}
+
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.frame)
+			require.NoError(t, err)
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			line, err := app.formatMessage(decoded)
+			require.NoError(t, err)
+
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &fields))
+			for _, key := range []string{"roll", "true_track", "mag_heading"} {
+				if expected, ok := tt.expected[key]; ok {
+					assert.Equal(t, expected, fields[key], key)
+				} else {
+					assert.NotContains(t, fields, key)
+				}
+			}
+		})
+	}
+}
+
+// TestApplication_VelocityFlags tests the intent change and IFR capability bits
+// of the velocity header for every subtype
+func TestApplication_VelocityFlags(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+
+	for subtype := byte(1); subtype <= 4; subtype++ {
+		for _, flags := range []struct{ intentChange, ifrCapable bool }{
+			{false, false}, {true, false}, {false, true}, {true, true},
+		} {
+			// DF17 velocity from 485020, rewritten to the subtype and flags under test
+			data, err := hex.DecodeString("8D485020994409940838175B284F")
+			require.NoError(t, err)
+			data[4] = 19<<3 | subtype<<1
+			data[5] &= 0x3F
+			if flags.intentChange {
+				data[5] |= 0x80
+			}
+			if flags.ifrCapable {
+				data[5] |= 0x40
+			}
+
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+
+			line, err := app.formatMessage(decoded)
+			require.NoError(t, err)
+
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &fields))
+			assert.Equal(t, flags.intentChange, fields["intent_change"], "subtype %d", subtype)
+			assert.Equal(t, flags.ifrCapable, fields["ifr_capable"], "subtype %d", subtype)
+		}
+	}
+}
+
+// TestApplication_VerticalRate tests that a vertical rate of level flight is
+// reported as 0 ft/min and one with no information is left out
+func TestApplication_VerticalRate(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+
+	tests := []struct {
+		name     string
+		sign     byte
+		raw      uint16
+		expected *int
+	}{
+		{name: "No information", raw: 0},
+		{name: "Level", raw: 1, expected: intPtr(0)},
+		{name: "Negative level", sign: 1, raw: 1, expected: intPtr(0)},
+		{name: "Climbing", raw: 14, expected: intPtr(832)},
+		{name: "Descending", sign: 1, raw: 14, expected: intPtr(-832)},
+		{name: "Descending at the limit", sign: 1, raw: 511, expected: intPtr(-32640)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// DF17 velocity from 485020, rewritten to the vertical rate under test
+			data, err := hex.DecodeString("8D485020994409940838175B284F")
+			require.NoError(t, err)
+			data[8] = data[8]&0xF0 | tt.sign<<3 | byte(tt.raw>>6)
+			data[9] = data[9]&0x03 | byte(tt.raw<<2)
+
+			rate, ok := app.extractVerticalRate(data)
+			assert.Equal(t, tt.expected != nil, ok)
+
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			if tt.expected == nil {
+				assert.Zero(t, rate)
+				assert.Nil(t, decoded.VerticalRate)
+				return
+			}
+			assert.Equal(t, *tt.expected, rate)
+			require.NotNil(t, decoded.VerticalRate)
+			assert.Equal(t, *tt.expected, *decoded.VerticalRate)
+		})
+	}
+}
+
+// TestApplication_ShortFrameME tests that the ME extractors yield nothing for
+// a short frame, even when the bytes after it hold a long frame's fields
+func TestApplication_ShortFrameME(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+
+	tests := []struct {
+		name  string
+		frame string
+	}{
+		{name: "Identification", frame: "8D4840D6202CC371C32CE0576098"},
+		{name: "Airborne velocity", frame: "8D485020994409940838175B284F"},
+		{name: "Airborne position", frame: "8D40621D58C382D690C8AC2863A7"},
+	}
+
+	for _, tt := range tests {
+		for _, first := range []byte{0x20, 0x28, 0x5D} { // DF4, DF5, DF11
+			t.Run(fmt.Sprintf("%s as DF%d", tt.name, first>>3), func(t *testing.T) {
+				data, err := hex.DecodeString(tt.frame)
+				require.NoError(t, err)
+				data[0] = first
+
+				// Misrouted with the long frame's bytes as padding
+				assert.Empty(t, app.extractCallsign(data))
+				speed, track, vrate := app.extractVelocity(data)
+				assert.Zero(t, speed)
+				assert.Zero(t, track)
+				assert.Zero(t, vrate)
+				lat, lon := app.extractPosition(data)
+				assert.Zero(t, lat)
+				assert.Zero(t, lon)
+				intentChange, ifrCapable := app.extractVelocityFlags(data)
+				assert.False(t, intentChange)
+				assert.False(t, ifrCapable)
+				status, singleAntenna := app.extractSurveillanceStatus(data)
+				assert.Zero(t, status)
+				assert.False(t, singleAntenna)
+				_, _, ok := app.extractSurfaceMovement(data)
+				assert.False(t, ok)
+				_, ok = app.extractADSBVersion(data)
+				assert.False(t, ok)
+				_, ok = app.extractAircraftSize(data)
+				assert.False(t, ok)
+				_, ok = app.extractACASRA(data)
+				assert.False(t, ok)
+				_, ok = app.extractVerticalRate(data)
+				assert.False(t, ok)
+
+				// Decoded messages carry only the short frame
+				msg := &adsb.ADSBMessage{Valid: true}
+				copy(msg.Data[:], data)
+				assert.Len(t, msg.Frame(), adsb.ShortMessageBytes)
+				if decoded := app.decodeMessage(msg); decoded != nil {
+					assert.Empty(t, decoded.Callsign)
+					assert.Nil(t, decoded.GroundSpeed)
+					assert.Nil(t, decoded.Latitude)
+					assert.Nil(t, decoded.NavAltitudeMCP)
+				}
+			})
+		}
+	}
+}
+
+// TestApplication_SurveillanceStatus tests the alert and SPI flags and the single
+// antenna bit of airborne position messages for each surveillance status
+func TestApplication_SurveillanceStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status byte
+		alert  string
+		spi    string
+	}{
+		{name: "No condition", status: 0, alert: "", spi: ""},
+		{name: "Permanent alert", status: 1, alert: "-1", spi: ""},
+		{name: "Temporary alert", status: 2, alert: "-1", spi: ""},
+		{name: "SPI", status: 3, alert: "", spi: "-1"},
+	}
+
+	app := NewApplication(Config{})
+	app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+
+	for _, tt := range tests {
+		for _, singleAntenna := range []bool{false, true} {
+			// Airborne position (TC11) from 40621D, rewritten to the status under test
+			data, err := hex.DecodeString("8D40621D58C382D690C8AC2863A7")
+			require.NoError(t, err)
+			data[4] = 11<<3 | tt.status<<1
+			if singleAntenna {
+				data[4] |= 1
+			}
+
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			require.NotNil(t, decoded.SingleAntenna)
+			assert.Equal(t, singleAntenna, *decoded.SingleAntenna, tt.name)
+
+			fields := strings.Split(app.convertToSBS(decoded), ",")
+			require.Len(t, fields, 22)
+			assert.Equal(t, "3", fields[1], tt.name)
+			assert.Equal(t, tt.alert, fields[18], tt.name)
+			assert.Equal(t, tt.spi, fields[20], tt.name)
+		}
+	}
+}
+
+// TestApplication_GroundState tests the air/ground status of DF17 messages for
+// every capability value: positions are airborne or surface by their type
+// code whatever the CA says, other messages follow the CA when it states one
+func TestApplication_GroundState(t *testing.T) {
+	type state struct{ onGround, known bool }
+	var (
+		ground   = state{true, true}
+		airborne = state{false, true}
+		unknown  = state{false, false}
+	)
+
+	tests := []struct {
+		name           string
+		ca             byte
+		identification state // Expected for an identification (TC4)
+	}{
+		{name: "Level 1", ca: caLevel1, identification: unknown},
+		{name: "Reserved 1", ca: 1, identification: unknown},
+		{name: "Reserved 2", ca: 2, identification: unknown},
+		{name: "Reserved 3", ca: 3, identification: unknown},
+		{name: "On ground", ca: caOnGround, identification: ground},
+		{name: "Airborne", ca: caAirborne, identification: airborne},
+		{name: "Either", ca: caEither, identification: unknown},
+		{name: "Downlink request", ca: caDownlinkRq, identification: unknown},
+	}
+
+	app := NewApplication(Config{})
+	groundState := func(data []byte) state {
+		onGround, known := app.extractGroundState(data)
+		return state{onGround, known}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString("8D40621D58C382D690C8AC2863A7")
+			require.NoError(t, err)
+			data[0] = 17<<3 | tt.ca
+			assert.Equal(t, airborne, groundState(data), "airborne position")
+
+			data[4] = 19<<3 | 1<<1
+			assert.Equal(t, airborne, groundState(data), "airborne velocity")
+
+			data[4] = 6<<3 | data[4]&0x07
+			assert.Equal(t, ground, groundState(data), "surface position")
+
+			data[4] = 4 << 3
+			assert.Equal(t, tt.identification, groundState(data), "identification")
+		})
+	}
+
+	// DF18 has no CA field, so only the type code counts
+	data, err := hex.DecodeString("9040621D58C382D690C8AC2863A7")
+	require.NoError(t, err)
+	data[0] = 18<<3 | caOnGround
+	assert.Equal(t, airborne, groundState(data))
+	data[4] = 4 << 3
+	assert.Equal(t, unknown, groundState(data))
+
+	// DF11 states it through the CA, surveillance replies through the FS
+	for _, tt := range []struct {
+		first    byte
+		expected state
+	}{
+		{first: 11<<3 | caOnGround, expected: ground},
+		{first: 11<<3 | caAirborne, expected: airborne},
+		{first: 11<<3 | caEither, expected: unknown},
+		{first: 4<<3 | fsOnGround, expected: ground},
+		{first: 4<<3 | fsAlertAirborne, expected: airborne},
+		{first: 20<<3 | fsAlertOnGround, expected: ground},
+		{first: 5<<3 | fsSPI, expected: unknown},
+	} {
+		data := []byte{tt.first, 0x40, 0x62, 0x1D, 0x00, 0x00, 0x00}
+		assert.Equal(t, tt.expected, groundState(data), "DF%d/%d", tt.first>>3, tt.first&7)
+	}
+}
+
+// TestApplication_GroundStateSequence tests that the air/ground state of an
+// aircraft carries over to its messages that do not state one
+func TestApplication_GroundStateSequence(t *testing.T) {
+	app := NewApplication(Config{})
+	app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+
+	// DF17 from 40621D with CA 0, which leaves the air/ground status open
+	identification := "8840621D202CC371C32CE0576098"
+	steps := []struct {
+		name     string
+		frame    string
+		onGround bool
+	}{
+		{name: "Identification, nothing known", frame: identification, onGround: false},
+		{name: "Surface position", frame: "8840621D30C382D690C8AC2863A7", onGround: true},
+		{name: "Identification after surface position", frame: identification, onGround: true},
+		{name: "DF4 on the ground", frame: "2140621DC08AD3", onGround: true},
+		{name: "Airborne velocity", frame: "8840621D994409940838175B284F", onGround: false},
+		{name: "Identification after velocity", frame: identification, onGround: false},
+		{name: "DF4 either, SPI", frame: "2540621D6F7F9F", onGround: false},
+		{name: "DF17 CA on ground", frame: "8C40621D202CC371C32CE0576098", onGround: true},
+		{name: "Airborne position with CA on ground", frame: "8C40621D58C382D690C8AC2863A7", onGround: false},
+		{name: "Identification after airborne position", frame: identification, onGround: false},
+	}
+
+	for _, step := range steps {
+		data, err := hex.DecodeString(step.frame)
+		require.NoError(t, err)
+		msg := &adsb.ADSBMessage{Valid: true, Timestamp: time.Now()}
+		copy(msg.Data[:], data)
+
+		decoded := app.decodeMessage(msg)
+		require.NotNil(t, decoded, step.name)
+		app.registry.Update(decoded)
+		assert.Equal(t, step.onGround, decoded.OnGround, step.name)
+	}
+}
+
+// TestApplication_ACASRA tests decoding of TCAS resolution advisories (TC28
+// subtype 2) with each form of threat identity
+func TestApplication_ACASRA(t *testing.T) {
+	app := NewApplication(Config{})
+
+	t.Run("Threat address", func(t *testing.T) {
+		// RA in force against 40621D with a "not below" complement
+		data, err := hex.DecodeString("8D4840D6E2820205018874000000")
+		require.NoError(t, err)
+		msg := &adsb.ADSBMessage{Valid: true}
+		copy(msg.Data[:], data)
+
+		decoded := app.decodeMessage(msg)
+		require.NotNil(t, decoded)
+		require.NotNil(t, decoded.ACASRA)
+		ra := decoded.ACASRA
+		assert.True(t, ra.Active)
+		assert.False(t, ra.Terminated)
+		assert.False(t, ra.MultipleThreats)
+		assert.Equal(t, uint16(0x2080), ra.ARA)
+		assert.Equal(t, []string{"not below"}, ra.RAC)
+		assert.Equal(t, "40621D", ra.ThreatHex)
+		assert.Nil(t, ra.ThreatAltitude)
+
+		line, err := app.convertToJSON(decoded)
+		require.NoError(t, err)
+		assert.Contains(t, line, `"acas_ra":{"active":true,"terminated":false,"multiple_threats":false,"ara":8320,"rac":["not below"],"threat_hex":"40621D"}`)
+	})
+
+	t.Run("Threat position", func(t *testing.T) {
+		// Terminated multi-threat RA, threat at 5000 ft, 2.5 NM, 90 degrees
+		data, err := hex.DecodeString("8D4840D6E20000B8760690000000")
+		require.NoError(t, err)
+		msg := &adsb.ADSBMessage{Valid: true}
+		copy(msg.Data[:], data)
+
+		decoded := app.decodeMessage(msg)
+		require.NotNil(t, decoded)
+		require.NotNil(t, decoded.ACASRA)
+		ra := decoded.ACASRA
+		assert.True(t, ra.Active)
+		assert.True(t, ra.Terminated)
+		assert.True(t, ra.MultipleThreats)
+		assert.Equal(t, []string{"not left"}, ra.RAC)
+		assert.Empty(t, ra.ThreatHex)
+		require.NotNil(t, ra.ThreatAltitude)
+		assert.Equal(t, 5000, *ra.ThreatAltitude)
+		require.NotNil(t, ra.ThreatRange)
+		assert.InDelta(t, 2.5, *ra.ThreatRange, 1e-9)
+		require.NotNil(t, ra.ThreatBearing)
+		assert.Equal(t, 90, *ra.ThreatBearing)
+	})
+
+	t.Run("Emergency subtype", func(t *testing.T) {
+		// TC28 subtype 1 carries no RA
+		data, err := hex.DecodeString("8D4840D6E1000000000000000000")
+		require.NoError(t, err)
+		msg := &adsb.ADSBMessage{Valid: true}
+		copy(msg.Data[:], data)
+
+		decoded := app.decodeMessage(msg)
+		require.NotNil(t, decoded)
+		assert.Nil(t, decoded.ACASRA)
+	})
+}
+
+// TestApplication_ELM tests that Comm-D segments are reassembled into one
+// DF24 record in JSON output and left out of SBS output
+func TestApplication_ELM(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	segment := func(number int, payload string) *adsb.ADSBMessage {
+		msg := &adsb.ADSBMessage{Timestamp: start.Add(time.Duration(number) * time.Millisecond), CRCType: "comm-d"}
+		msg.Data[0] = 0xC0 | byte(number)
+		copy(msg.Data[1:11], payload)
+		ap := adsb.CalculateCRC(msg.Data[:11]) ^ 0x4840D6
+		msg.Data[11], msg.Data[12], msg.Data[13] = byte(ap>>16), byte(ap>>8), byte(ap)
+		return msg
+	}
+
+	for _, format := range []string{OutputFormatJSON, OutputFormatSBS} {
+		app := NewApplication(Config{LogDir: t.TempDir(), OutputFormat: format})
+		var stdout bytes.Buffer
+		app.stdout = &stdout
+		require.NoError(t, app.initializeOutput())
+		defer app.logRotator.Close()
+		known := adsb.NewAddressTable(adsb.DefaultAddressTTL)
+		known.Add(0x4840D6, start)
+		app.elm.SetAddresses(known)
+		app.startOutputWriter()
+
+		// Noise from an unknown address is dropped
+		noise := segment(0, "NOISENOISE")
+		noise.Data[13] ^= 0x01
+		app.handleELMSegment(noise)
+		app.handleELMSegment(segment(1, "0123456789"))
+		app.handleELMSegment(segment(0, "ABCDEFGHIJ"))
+		app.expireELM(start.Add(adsb.DefaultELMTimeout))
+		app.stopOutputWriter()
+
+		if format == OutputFormatSBS {
+			assert.Empty(t, stdout.String())
+			continue
+		}
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &record))
+		assert.Equal(t, "4840d6", record["hex"])
+		assert.Equal(t, float64(24), record["df"])
+		assert.Equal(t, hex.EncodeToString([]byte("ABCDEFGHIJ0123456789")), record["elm"])
+		assert.Equal(t, float64(2), record["elm_segments"])
+	}
+}
+
+// TestApplication_ELMAnnounced tests that a single-segment transfer is only
+// written after a reply from the aircraft announced it
+func TestApplication_ELMAnnounced(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	segment := func(at time.Time) *adsb.ADSBMessage {
+		msg := &adsb.ADSBMessage{Timestamp: at, CRCType: "comm-d"}
+		msg.Data[0] = 0xC0
+		copy(msg.Data[1:11], "ABCDEFGHIJ")
+		ap := adsb.CalculateCRC(msg.Data[:11]) ^ 0x4840D6
+		msg.Data[11], msg.Data[12], msg.Data[13] = byte(ap>>16), byte(ap>>8), byte(ap)
+		return msg
+	}
+
+	app := NewApplication(Config{LogDir: t.TempDir(), OutputFormat: OutputFormatJSON})
+	var stdout bytes.Buffer
+	app.stdout = &stdout
+	require.NoError(t, app.initializeOutput())
+	defer app.logRotator.Close()
+	known := adsb.NewAddressTable(adsb.DefaultAddressTTL)
+	known.Add(0x4840D6, start)
+	app.elm.SetAddresses(known)
+	app.startOutputWriter()
+
+	app.handleELMSegment(segment(start))
+	app.expireELM(start.Add(adsb.DefaultELMTimeout))
+
+	// A DF20 reply with DR 16 announces a one-segment ELM
+	announcement := &adsb.ADSBMessage{Timestamp: start.Add(5 * time.Second), Valid: true}
+	announcement.Data[0], announcement.Data[1] = 0xA0, 0x80
+	ap := adsb.CalculateCRC(announcement.Data[:11]) ^ 0x4840D6
+	announcement.Data[11], announcement.Data[12], announcement.Data[13] = byte(ap>>16), byte(ap>>8), byte(ap)
+	app.noteELMAnnouncement(announcement)
+	app.handleELMSegment(segment(start.Add(6 * time.Second)))
+	app.expireELM(start.Add(6*time.Second + adsb.DefaultELMTimeout))
+	app.stopOutputWriter()
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 1)
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, hex.EncodeToString([]byte("ABCDEFGHIJ")), record["elm"])
+	assert.Equal(t, uint64(1), app.elm.GetUnannouncedCount())
+}
+
+// TestApplication_MLATFeed tests that MLAT frames carry the receiver sample
+// clock: monotonic, in whole samples, and continuous across buffers
+func TestApplication_MLATFeed(t *testing.T) {
+	app := NewApplication(Config{SampleRate: DefaultSampleRate})
+	app.logger.SetOutput(io.Discard)
+	app.adsbProcessor = adsb.NewADSBProcessor(DefaultSampleRate, app.logger)
+	app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+
+	// A Unix socket stands in for the TCP listener
+	path := filepath.Join(t.TempDir(), "mlat.sock")
+	app.mlatServer = stream.NewUnixServer(path, app.logger)
+	require.NoError(t, app.mlatServer.Listen())
+	app.mlatEncoder = beast.NewEncoder()
+	app.mlatEncoder.SetPreserveTimestamps(true)
+	go func() { _ = app.mlatServer.Start(app.ctx) }()
+	defer app.cancel()
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return app.mlatServer.ClientCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// The same two frames in each of two buffers
+	frames := make([][]byte, 2)
+	for i, frame := range []string{"8D4840D6202CC371C32CE0576098", "8D40621D58C382D690C8AC2863A7"} {
+		frames[i], err = hex.DecodeString(frame)
+		require.NoError(t, err)
+	}
+	// Trailing silence keeps the repeats outside the multipath window
+	buffer := append(modulateIQ(frames), bytes.Repeat([]byte{128}, 2*DefaultSampleRate/200)...)
+	dataChan := make(chan []byte, 2)
+	dataChan <- buffer
+	dataChan <- buffer
+	close(dataChan)
+	app.processIQData(dataChan)
+
+	decoder := beast.NewDecoder(app.logger)
+	var messages []*beast.Message
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	chunk := make([]byte, 1024)
+	for len(messages) < 4 {
+		n, err := conn.Read(chunk)
+		require.NoError(t, err)
+		decoded, err := decoder.Decode(chunk[:n])
+		require.NoError(t, err)
+		messages = append(messages, decoded...)
+	}
+	require.Len(t, messages, 4)
+
+	for i, msg := range messages {
+		assert.Equal(t, byte(beast.ModeSLong), msg.MessageType)
+		assert.Equal(t, frames[i%2], msg.Data)
+		// One 2.4 MHz sample is exactly five 12 MHz ticks
+		assert.Zero(t, msg.Counter%5, "counter %d is not a whole sample", msg.Counter)
+		if i > 0 {
+			assert.Greater(t, msg.Counter, messages[i-1].Counter)
+		}
+	}
+
+	// The second buffer continues the clock of the first
+	bufferTicks := adsb.MLATTicks(uint64(len(buffer)/2), DefaultSampleRate)
+	assert.Equal(t, bufferTicks, messages[2].Counter-messages[0].Counter)
+	assert.Equal(t, bufferTicks, messages[3].Counter-messages[1].Counter)
+}
+
+// TestApplication_CSVOutput tests CSV records and that the header starts each log file once
+func TestApplication_CSVOutput(t *testing.T) {
+	logDir := t.TempDir()
+	var stdout bytes.Buffer
+
+	app := NewApplication(Config{LogDir: logDir, OutputFormat: OutputFormatCSV})
+	app.stdout = &stdout
+	require.NoError(t, app.initializeOutput())
+	defer app.logRotator.Close()
+
+	alt, gs, lat, lon := 38000, 450, 52.2572, 3.91937
+	decoded := &adsb.DecodedMessage{
+		Timestamp:   time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		ICAO:        0x4840D6,
+		Hex:         "4840d6",
+		DF:          17,
+		TypeCode:    11,
+		Callsign:    "KLM1023 ",
+		Altitude:    &alt,
+		GroundSpeed: &gs,
+		Latitude:    &lat,
+		Longitude:   &lon,
+		Signal:      -12.34,
+		CRCType:     "valid",
+	}
+	line, err := app.formatMessage(decoded)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-01T12:00:00Z,4840D6,17,11,KLM1023,38000,450,,,52.25720,3.91937,,-12.3,valid", line)
+
+	app.startOutputWriter()
+	app.enqueueOutput(decoded)
+	app.enqueueOutput(decoded)
+	app.stopOutputWriter()
+
+	header := strings.Join(csvColumns, ",") + "\n"
+	logFile := app.logRotator.GetCurrentLogFile()
+	content, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, header+line+"\n"+line+"\n", string(content))
+	assert.Equal(t, header+line+"\n"+line+"\n", stdout.String())
+
+	// A recreated log file gets its own header
+	require.NoError(t, os.Rename(logFile, logFile+".1"))
+	require.NoError(t, app.Reload())
+	require.NoError(t, app.writeOutput(decoded))
+	content, err = os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, header+line+"\n", string(content))
+}
+
+// TestApplication_SplitBy tests that --split-by writes each message to the
+// daily log file of its aircraft or downlink format
+func TestApplication_SplitBy(t *testing.T) {
+	alt := 38000
+	messages := []*adsb.DecodedMessage{
+		{Timestamp: time.Now(), ICAO: 0x484412, Hex: "484412", DF: 17, TypeCode: 11, Altitude: &alt},
+		{Timestamp: time.Now(), ICAO: 0x4840D6, Hex: "4840d6", DF: 4, Altitude: &alt},
+		{Timestamp: time.Now(), ICAO: 0x484412, Hex: "484412", DF: 4, Altitude: &alt},
+	}
+
+	tests := []struct {
+		splitBy  string
+		expected map[string][]int // File key to the messages it holds
+	}{
+		{splitBy: SplitByICAO, expected: map[string][]int{"484412": {0, 2}, "4840d6": {1}}},
+		{splitBy: SplitByType, expected: map[string][]int{"df17": {0}, "df4": {1, 2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.splitBy, func(t *testing.T) {
+			logDir := t.TempDir()
+			app := NewApplication(Config{LogDir: logDir, LogRotateUTC: true, OutputFormat: OutputFormatJSON, SplitBy: tt.splitBy})
+			app.stdout = io.Discard
+			require.NoError(t, app.initializeOutput())
+			assert.Nil(t, app.logRotator)
+
+			// A file of the first key left from an earlier day
+			var firstKey string
+			for key, indexes := range tt.expected {
+				if indexes[0] == 0 {
+					firstKey = key
+				}
+			}
+			earlier := filepath.Join(logDir, "adsb_2000-01-01_"+firstKey+".log")
+			require.NoError(t, os.WriteFile(earlier, []byte("old\n"), 0644))
+
+			app.startOutputWriter()
+			for _, msg := range messages {
+				app.enqueueOutput(msg)
+			}
+			app.stopOutputWriter()
+
+			// It is compressed when the key is first opened
+			assert.NoFileExists(t, earlier)
+			assert.FileExists(t, earlier+".gz")
+			assert.Len(t, app.splitChecked, len(tt.expected))
+
+			date := time.Now().UTC().Format("2006-01-02")
+			for key, indexes := range tt.expected {
+				var expected string
+				for _, i := range indexes {
+					line, err := app.formatMessage(messages[i])
+					require.NoError(t, err)
+					expected += line + "\n"
+				}
+				content, err := os.ReadFile(filepath.Join(logDir, "adsb_"+date+"_"+key+".log"))
+				require.NoError(t, err, key)
+				assert.Equal(t, expected, string(content), key)
+			}
+
+			// The combined log is not created
+			assert.NoFileExists(t, filepath.Join(logDir, "adsb_"+date+".log"))
+		})
+	}
+
+	err := NewApplication(Config{SplitBy: "hour", Stdin: true}).initializeComponents()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported split mode "hour"`)
+}
+
+// TestApplication_SBSAnnouncements tests that SBS output announces a new
+// aircraft with an AIR record before its first MSG, and a new callsign with
+// an ID record, on every destination
+func TestApplication_SBSAnnouncements(t *testing.T) {
+	var stdout bytes.Buffer
+
+	app := NewApplication(Config{LogDir: t.TempDir(), OutputFormat: OutputFormatSBS})
+	app.stdout = &stdout
+	require.NoError(t, app.initializeOutput())
+	defer app.logRotator.Close()
+
+	alt := 38000
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	altitude := &adsb.DecodedMessage{Timestamp: timestamp, ICAO: 0x4840D6, Hex: "4840d6", DF: 17, TypeCode: 11, TransmissionType: 3, Altitude: &alt}
+	ident := &adsb.DecodedMessage{Timestamp: timestamp, ICAO: 0x4840D6, Hex: "4840d6", DF: 17, TypeCode: 4, TransmissionType: 1, Callsign: "KLM1023 "}
+
+	app.startOutputWriter()
+	app.enqueueOutput(altitude)
+	app.enqueueOutput(altitude)
+	app.enqueueOutput(ident)
+	app.stopOutputWriter()
+
+	expected := []string{
+		"AIR,,1,1,4840D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000",
+		app.convertToSBS(altitude),
+		app.convertToSBS(altitude),
+		"ID,,1,1,4840D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,KLM1023",
+		app.convertToSBS(ident),
+	}
+	assert.Equal(t, strings.Join(expected, "\n")+"\n", stdout.String())
+	content, err := os.ReadFile(app.logRotator.GetCurrentLogFile())
+	require.NoError(t, err)
+	assert.Equal(t, stdout.String(), string(content))
+}
+
+// TestApplication_EmitRaw tests that --emit-raw-comment traces SBS and JSON
+// records to the frame they were decoded from, and is off by default
+func TestApplication_EmitRaw(t *testing.T) {
+	frame, err := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	require.NoError(t, err)
+	msg := &adsb.ADSBMessage{Valid: true, Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	copy(msg.Data[:], frame)
+
+	app := NewApplication(Config{OutputFormat: OutputFormatSBS, EmitRaw: true})
+	decoded := app.decodeMessage(msg)
+	require.NotNil(t, decoded)
+	assert.Equal(t, strings.ToUpper(hex.EncodeToString(frame)), decoded.Raw)
+
+	lines, err := app.lineFormatter()(decoded)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"AIR,,1,1,4840D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000",
+		"ID,,1,1,4840D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,KLM1023",
+		"*8D4840D6202CC371C32CE0576098;",
+		app.convertToSBS(decoded),
+	}, strings.Split(lines, "\n"))
+
+	app.config.OutputFormat = OutputFormatJSON
+	line, err := app.formatMessage(decoded)
+	require.NoError(t, err)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(line), &fields))
+	raw, err := hex.DecodeString(fields["raw"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, frame, raw)
+
+	// Off by default
+	plain := NewApplication(Config{OutputFormat: OutputFormatJSON})
+	decoded = plain.decodeMessage(msg)
+	require.NotNil(t, decoded)
+	line, err = plain.formatMessage(decoded)
+	require.NoError(t, err)
+	assert.NotContains(t, line, `"raw"`)
+}
+
+// TestApplication_PayloadHex tests that --payload-hex adds the ME field of an
+// extended squitter and the MB field of a Comm-B reply to JSON output
+func TestApplication_PayloadHex(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		key   string
+		other string
+		want  string
+	}{
+		{name: "DF17 ME", frame: "8D4840D6202CC371C32CE0576098", key: "me_hex", other: "mb_hex", want: "202cc371c32ce0"},
+		{name: "DF20 MB", frame: "A000029C85E42F313000007047D3", key: "mb_hex", other: "me_hex", want: "85e42f31300000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.frame)
+			require.NoError(t, err)
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+
+			app := NewApplication(Config{OutputFormat: OutputFormatJSON, PayloadHex: true})
+			line, err := app.formatMessage(app.decodeMessage(msg))
+			require.NoError(t, err)
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &fields))
+			assert.Equal(t, tt.want, fields[tt.key])
+			assert.Equal(t, hex.EncodeToString(data[4:11]), fields[tt.key])
+			assert.NotContains(t, fields, tt.other)
+
+			// Off by default
+			plain := NewApplication(Config{OutputFormat: OutputFormatJSON})
+			line, err = plain.formatMessage(plain.decodeMessage(msg))
+			require.NoError(t, err)
+			assert.NotContains(t, line, tt.key)
+		})
+	}
+}
+
+// TestApplication_PositionsOnly tests that --positions-only drops every message
+// without a position from all outputs
+func TestApplication_PositionsOnly(t *testing.T) {
+	logDir := t.TempDir()
+	var stdout bytes.Buffer
+
+	app := NewApplication(Config{LogDir: logDir, OutputFormat: OutputFormatJSON, PositionOnly: true})
+	app.stdout = &stdout
+	require.NoError(t, app.initializeOutput())
+	defer app.logRotator.Close()
+
+	alt, gs, vrate, lat, lon := 38000, 450, -832, 52.2657, 3.9389
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	position := &adsb.DecodedMessage{Timestamp: timestamp, Hex: "40621d", DF: 17, TypeCode: 11, Altitude: &alt, Latitude: &lat, Longitude: &lon}
+	mix := []*adsb.DecodedMessage{
+		{Timestamp: timestamp, Hex: "4840d6", DF: 17, TypeCode: 4, Callsign: "KLM1023 "},
+		position,
+		{Timestamp: timestamp, Hex: "485020", DF: 17, TypeCode: 19, GroundSpeed: &gs, VerticalRate: &vrate},
+		{Timestamp: timestamp, Hex: "40621d", DF: 4, Altitude: &alt},
+	}
+
+	app.startOutputWriter()
+	for _, decoded := range mix {
+		app.enqueueOutput(decoded)
+	}
+	app.stopOutputWriter()
+
+	line, err := app.formatMessage(position)
+	require.NoError(t, err)
+	assert.Equal(t, line+"\n", stdout.String())
+	content, err := os.ReadFile(app.logRotator.GetCurrentLogFile())
+	require.NoError(t, err)
+	assert.Equal(t, line+"\n", string(content))
+}
+
+// TestApplication_MergePositionFrames tests that --merge-position-frames
+// writes one positioned line for an even/odd pair instead of two
+func TestApplication_MergePositionFrames(t *testing.T) {
+	for _, merge := range []bool{false, true} {
+		t.Run(fmt.Sprintf("merge=%v", merge), func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			app := NewApplication(Config{LogDir: t.TempDir(), OutputFormat: OutputFormatJSON, MergeFrames: merge})
+			app.stdout = &stdout
+			app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+			app.cprDecoder.SetRequirePair(true)
+			require.NoError(t, app.initializeOutput())
+			defer app.logRotator.Close()
+
+			app.startOutputWriter()
+			timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			for i, frame := range []string{"8D40621D58C382D690C8AC2863A7", "8D40621D58C386435CC412692AD6"} {
+				data, err := hex.DecodeString(frame)
+				require.NoError(t, err)
+				msg := &adsb.ADSBMessage{Valid: true, Timestamp: timestamp.Add(time.Duration(i) * time.Second)}
+				copy(msg.Data[:], data)
+				decoded := app.decodeMessage(msg)
+				require.NotNil(t, decoded)
+				app.enqueueOutput(decoded)
+			}
+			app.stopOutputWriter()
+
+			lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			if !merge {
+				require.Len(t, lines, 2)
+				assert.NotContains(t, lines[0], `"lat"`)
+				assert.Contains(t, lines[1], `"lat"`)
+				return
+			}
+			require.Len(t, lines, 1)
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(lines[0]), &fields))
+			assert.InDelta(t, 52.2657, fields["lat"], 0.001)
+			assert.InDelta(t, 3.9389, fields["lon"], 0.001)
+			assert.Equal(t, 38000.0, fields["alt_baro"])
+		})
+	}
+}
+
+// TestApplication_GeoJSONOutput tests that geojson output writes one parseable
+// Feature per position, with coordinates in lon, lat order
+func TestApplication_GeoJSONOutput(t *testing.T) {
+	var stdout bytes.Buffer
+
+	app := NewApplication(Config{LogDir: t.TempDir(), OutputFormat: OutputFormatGeoJSON})
+	app.stdout = &stdout
+	require.NoError(t, app.initializeOutput())
+	defer app.logRotator.Close()
+
+	alt, gs, track, lat, lon := 38000, 450, 243.5, 52.2657, 3.9389
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	app.registry.Update(&adsb.DecodedMessage{Timestamp: timestamp, ICAO: 0x40621D, Hex: "40621d", DF: 17, TypeCode: 4, Callsign: "BAW123  "})
+	app.registry.Update(&adsb.DecodedMessage{Timestamp: timestamp, ICAO: 0x40621D, Hex: "40621d", DF: 17, TypeCode: 19, GroundSpeed: &gs, Track: &track})
+
+	app.startOutputWriter()
+	app.enqueueOutput(&adsb.DecodedMessage{Timestamp: timestamp, ICAO: 0x40621D, Hex: "40621d", DF: 17, TypeCode: 19, GroundSpeed: &gs, Track: &track})
+	app.enqueueOutput(&adsb.DecodedMessage{Timestamp: timestamp, ICAO: 0x40621D, Hex: "40621d", DF: 17, TypeCode: 11, Altitude: &alt, Latitude: &lat, Longitude: &lon})
+	app.stopOutputWriter()
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 1)
+
+	var feature struct {
+		Type     string `json:"type"`
+		Geometry struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &feature))
+	assert.Equal(t, "Feature", feature.Type)
+	assert.Equal(t, "Point", feature.Geometry.Type)
+	assert.Equal(t, []float64{lon, lat}, feature.Geometry.Coordinates)
+	assert.Equal(t, map[string]interface{}{
+		"icao":      "40621d",
+		"callsign":  "BAW123",
+		"alt":       float64(alt),
+		"on_ground": false,
+		"timestamp": "2024-01-01T12:00:00Z",
+	}, feature.Properties)
+
+	_, err := app.formatMessage(&adsb.DecodedMessage{Hex: "40621d", DF: 4, Altitude: &alt})
+	assert.Error(t, err)
+}
+
+// TestApplication_TISBIdentification tests that a DF18 TIS-B identification
+// frame yields its callsign attributed to TIS-B, and that coarse TIS-B is not
+// decoded with the type-code formats
+func TestApplication_TISBIdentification(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+
+	// KLM1023 identification relayed as fine TIS-B (DF18, CF2)
+	frame := func(first byte) *adsb.ADSBMessage {
+		data, err := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+		require.NoError(t, err)
+		data[0] = first
+		parity := adsb.CalculateCRC(data[:11])
+		data[11], data[12], data[13] = byte(parity>>16), byte(parity>>8), byte(parity)
+
+		msg := &adsb.ADSBMessage{Valid: true}
+		copy(msg.Data[:], data)
+		return msg
+	}
+
+	decoded := app.decodeMessage(frame(0x92))
+	require.NotNil(t, decoded)
+	assert.Equal(t, uint8(18), decoded.DF)
+	assert.Equal(t, uint8(4), decoded.TypeCode)
+	assert.Equal(t, "KLM1023", strings.TrimSpace(decoded.Callsign))
+	assert.Equal(t, adsb.SourceTISB, decoded.Source)
+
+	line, err := app.formatMessage(decoded)
+	require.NoError(t, err)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(line), &fields))
+	assert.Equal(t, "tisb", fields["source"])
+	assert.Equal(t, "KLM1023", strings.TrimSpace(fields["flight"].(string)))
+
+	// The aircraft's own squitter is attributed to its transponder
+	decoded = app.decodeMessage(frame(0x8D))
+	require.NotNil(t, decoded)
+	assert.Equal(t, adsb.SourceADSB, decoded.Source)
+
+	// Coarse TIS-B (CF3) has no type code to decode
+	assert.Nil(t, app.decodeMessage(frame(0x93)))
+}
+
+// TestApplication_ADSBVersion tests decoding the version from TC31 operational status
+func TestApplication_ADSBVersion(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+
+	tests := []struct {
+		name     string
+		frame    string
+		expected *int
+	}{
+		{name: "Airborne status, version 2", frame: "8D4840D6F8230002004AB8000000", expected: intPtr(2)},
+		{name: "Airborne status, version 1", frame: "8D4840D6F8230002002AB8000000", expected: intPtr(1)},
+		{name: "Surface status, version 0", frame: "8D4840D6F9230002000AB8000000", expected: intPtr(0)},
+		{name: "Reserved subtype", frame: "8D4840D6FA230002004AB8000000", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.frame)
+			require.NoError(t, err)
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			assert.Equal(t, uint8(31), decoded.TypeCode)
+			assert.Equal(t, tt.expected, decoded.ADSBVersion)
+
+			line, err := app.formatMessage(decoded)
+			require.NoError(t, err)
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &fields))
+			if tt.expected != nil {
+				assert.Equal(t, float64(*tt.expected), fields["adsb_version"])
+			} else {
+				assert.NotContains(t, fields, "adsb_version")
+			}
+			assert.Equal(t, true, fields["spontaneous"])
+		})
+	}
+}
+
+// TestApplication_OpStatusEquipage tests the capability class and operational
+// mode flags of TC31 operational status
+func TestApplication_OpStatusEquipage(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+
+	tests := []struct {
+		name     string
+		frame    string
+		tcas     *bool
+		es1090In *bool
+		uatIn    *bool
+		raActive *bool
+	}{
+		{name: "Airborne, version 2, TCAS only", frame: "8D4840D6F8230002004AB8000000", tcas: boolPtr(true), es1090In: boolPtr(false), uatIn: boolPtr(false), raActive: boolPtr(false)},
+		{name: "Airborne, version 2, dual link with RA", frame: "8D4840D6F8302022004AB8000000", tcas: boolPtr(true), es1090In: boolPtr(true), uatIn: boolPtr(true), raActive: boolPtr(true)},
+		{name: "Airborne, version 1, NOT-ACAS set", frame: "8D4840D6F8230002002AB8000000", tcas: boolPtr(false), raActive: boolPtr(false)},
+		{name: "Surface, version 2", frame: "8D4840D6F9110002004AB8000000", es1090In: boolPtr(true), uatIn: boolPtr(true), raActive: boolPtr(false)},
+		{name: "Reserved capability format", frame: "8D4840D6F8630002004AB8000000", raActive: boolPtr(false)},
+		{name: "Version 0", frame: "8D4840D6F8230002000AB8000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.frame)
+			require.NoError(t, err)
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			assert.Equal(t, tt.tcas, decoded.TCAS)
+			assert.Equal(t, tt.es1090In, decoded.ES1090In)
+			assert.Equal(t, tt.uatIn, decoded.UATIn)
+			assert.Equal(t, tt.raActive, decoded.RAActive)
+
+			line, err := app.formatMessage(decoded)
+			require.NoError(t, err)
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &fields))
+			for key, want := range map[string]*bool{"tcas": tt.tcas, "1090es_in": tt.es1090In, "uat_in": tt.uatIn, "tcas_ra_active": tt.raActive} {
+				if want != nil {
+					assert.Equal(t, *want, fields[key], key)
+				} else {
+					assert.NotContains(t, fields, key)
+				}
+			}
+		})
+	}
+}
+
+// TestApplication_NICBaro tests the barometric altitude integrity bit of
+// airborne operational status
+func TestApplication_NICBaro(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+
+	tests := []struct {
+		name     string
+		frame    string
+		expected *bool
+	}{
+		{name: "Airborne, version 2, cross-checked", frame: "8D4840D6F8230002004AB8000000", expected: boolPtr(true)},
+		{name: "Airborne, version 2, not cross-checked", frame: "8D4840D6F8230002004AB0000000", expected: boolPtr(false)},
+		{name: "Airborne, version 1", frame: "8D4840D6F8230002002AB8000000", expected: boolPtr(true)},
+		{name: "Version 0", frame: "8D4840D6F8230002000AB8000000"},
+		{name: "Surface bit is track/heading", frame: "8D4840D6F9110002004AB8000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.frame)
+			require.NoError(t, err)
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			assert.Equal(t, tt.expected, decoded.NICBaro)
+
+			line, err := app.formatMessage(decoded)
+			require.NoError(t, err)
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &fields))
+			if tt.expected != nil {
+				assert.Equal(t, *tt.expected, fields["nic_baro"])
+			} else {
+				assert.NotContains(t, fields, "nic_baro")
+			}
+		})
+	}
+}
+
+// TestApplication_NICSupplements tests NIC supplement-A of operational status
+// and supplement-C of version 2 surface status
+func TestApplication_NICSupplements(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+
+	tests := []struct {
+		name  string
+		frame string
+		suppA *bool
+		suppC *bool
+	}{
+		{name: "Airborne, version 2, A clear", frame: "8D4840D6F8230002004AB8000000", suppA: boolPtr(false)},
+		{name: "Airborne, version 2, A set", frame: "8D4840D6F8230002005AB8000000", suppA: boolPtr(true)},
+		{name: "Airborne, version 1, A set", frame: "8D4840D6F8230002003AB8000000", suppA: boolPtr(true)},
+		{name: "Surface, version 2, C set", frame: "8D4840D6F9111002004AB8000000", suppA: boolPtr(false), suppC: boolPtr(true)},
+		{name: "Surface, version 1", frame: "8D4840D6F9111002002AB8000000", suppA: boolPtr(false)},
+		{name: "Version 0", frame: "8D4840D6F8230002001AB8000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.frame)
+			require.NoError(t, err)
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			assert.Equal(t, tt.suppA, decoded.NICSuppA)
+			assert.Equal(t, tt.suppC, decoded.NICSuppC)
+		})
+	}
+}
+
+// TestApplication_AircraftSize tests the length/width code of surface operational status
+func TestApplication_AircraftSize(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+
+	tests := []struct {
+		name     string
+		frame    string
+		code     byte
+		expected *adsb.AircraftSize
+	}{
+		{name: "No data", frame: "8D4840D6F9230002004AB8000000", code: 0},
+		{name: "Smallest", frame: "8D4840D6F9230002004AB8000000", code: 1, expected: &adsb.AircraftSize{Length: 15, Width: 23}},
+		{name: "Medium", frame: "8D4840D6F9230002004AB8000000", code: 6, expected: &adsb.AircraftSize{Length: 45, Width: 39.5}},
+		{name: "Largest", frame: "8D4840D6F9230002004AB8000000", code: 15, expected: &adsb.AircraftSize{Length: 85, Width: 90}},
+		{name: "Version 0 has no size", frame: "8D4840D6F9230002000AB8000000", code: 6},
+		{name: "Airborne status has no size", frame: "8D4840D6F8230002004AB8000000", code: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.frame)
+			require.NoError(t, err)
+			data[6] = data[6]&0xF0 | tt.code // ME bits 21-24
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			assert.Equal(t, tt.expected, decoded.Size)
+
+			line, err := app.formatMessage(decoded)
+			require.NoError(t, err)
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &fields))
+			if tt.expected != nil {
+				assert.Equal(t, map[string]interface{}{"length": tt.expected.Length, "width": tt.expected.Width}, fields["aircraft_size"])
+			} else {
+				assert.NotContains(t, fields, "aircraft_size")
+			}
+		})
+	}
+}
+
+// TestApplication_Reload tests that reload reopens the log file and re-reads the ICAO filter
+func TestApplication_Reload(t *testing.T) {
+	logDir := t.TempDir()
+	filterFile := filepath.Join(t.TempDir(), "icao.txt")
+	require.NoError(t, os.WriteFile(filterFile, []byte("4840D6\n"), 0644))
+
+	app := NewApplication(Config{LogDir: logDir, FilterFile: filterFile})
+	require.NoError(t, app.initializeOutput())
+	defer app.logRotator.Close()
+
+	assert.True(t, app.allowsICAO(0x4840D6))
+	assert.False(t, app.allowsICAO(0xABCDEF))
+
+	before, err := app.logRotator.GetWriter()
+	require.NoError(t, err)
+
+	// Move the log away and swap the filter to a different aircraft
+	logFile := app.logRotator.GetCurrentLogFile()
+	require.NoError(t, os.Rename(logFile, logFile+".1"))
+	require.NoError(t, os.WriteFile(filterFile, []byte("ABCDEF\n"), 0644))
+
+	require.NoError(t, app.Reload())
+
+	after, err := app.logRotator.GetWriter()
+	require.NoError(t, err)
+	assert.NotSame(t, before, after)
+	assert.FileExists(t, logFile)
+
+	assert.False(t, app.allowsICAO(0x4840D6))
+	assert.True(t, app.allowsICAO(0xABCDEF))
+
+	// A broken filter file keeps the previous filter active
+	require.NoError(t, os.WriteFile(filterFile, []byte("not-hex\n"), 0644))
+	assert.Error(t, app.Reload())
+	assert.True(t, app.allowsICAO(0xABCDEF))
+}
+
+// TestApplication_DecodeAirbornePosition tests barometric vs geometric altitude decoding
+func TestApplication_DecodeAirbornePosition(t *testing.T) {
+	tests := []struct {
+		name         string
+		frame        string
+		expectBaro   *int
+		expectGeom   *int
+		expectFields []string
+	}{
+		{
+			name:         "TC11 barometric altitude",
+			frame:        "8D40621D58C382D690C8AC2863A7",
+			expectBaro:   intPtr(38000),
+			expectFields: []string{"alt_baro"},
+		},
+		{
+			name:         "TC20 GNSS height",
+			frame:        "8D40621DA0C382D690C8AC5C84CA",
+			expectGeom:   intPtr(38000),
+			expectFields: []string{"alt_geom"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewApplication(Config{})
+			app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+
+			data, err := hex.DecodeString(tt.frame)
+			require.NoError(t, err)
+			msg := &adsb.ADSBMessage{Valid: true}
+			copy(msg.Data[:], data)
+
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			assert.Equal(t, 3, decoded.TransmissionType)
+			assert.Equal(t, tt.expectBaro, decoded.Altitude)
+			assert.Equal(t, tt.expectGeom, decoded.AltitudeGeom)
+
+			// CPR extraction ran and stored the even frame
+			diagnostics := app.cprDecoder.GetDiagnostics()
+			require.Len(t, diagnostics, 1)
+			assert.NotNil(t, diagnostics[0].EvenFrame)
+
+			line, err := app.convertToJSON(decoded)
+			require.NoError(t, err)
+			for _, field := range tt.expectFields {
+				assert.Contains(t, line, `"`+field+`":38000`)
+			}
+			assert.Contains(t, app.convertToSBS(decoded), ",38000,")
+		})
+	}
+}
+
+// TestApplication_SurfaceMovement tests ground speed and track from surface position messages
+func TestApplication_SurfaceMovement(t *testing.T) {
+	app := NewApplication(Config{})
+
+	speeds := []struct {
+		movement uint8
+		speed    float64
+		ok       bool
+	}{
+		{0, 0, false}, // Not available
+		{1, 0, true},  // Stopped
+		{2, 0.125, true},
+		{8, 0.875, true},
+		{9, 1, true},
+		{12, 1.75, true},
+		{13, 2, true},
+		{38, 14.5, true},
+		{39, 15, true},
+		{93, 69, true},
+		{94, 70, true},
+		{108, 98, true},
+		{109, 100, true},
+		{123, 170, true},
+		{124, 175, true},
+		{125, 0, false}, // Reserved
+	}
+	for _, tt := range speeds {
+		// TC7 surface position with the movement under test and a valid track of 45 degrees
+		data := make([]byte, 14)
+		data[0] = 0x8D
+		data[4] = 7<<3 | tt.movement>>4
+		data[5] = tt.movement<<4 | 0x08 | 16>>4
+		data[6] = (16 & 0x0F) << 4
+
+		speed, heading, ok := app.extractSurfaceMovement(data)
+		assert.Equal(t, tt.ok, ok, "movement %d", tt.movement)
+		assert.Equal(t, tt.speed, speed, "movement %d", tt.movement)
+		assert.Equal(t, 45.0, heading, "movement %d", tt.movement)
+	}
+
+	// Surface position from 484175: movement 42 (18 kt), track code 50 (140.6 degrees)
+	app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+	data, err := hex.DecodeString("8C4841753AAB238733C8CD4020B1")
+	require.NoError(t, err)
+	msg := &adsb.ADSBMessage{Valid: true}
+	copy(msg.Data[:], data)
+
+	decoded := app.decodeMessage(msg)
+	require.NotNil(t, decoded)
+	assert.Equal(t, 2, decoded.TransmissionType)
+	require.NotNil(t, decoded.GroundSpeed)
+	assert.Equal(t, 18, *decoded.GroundSpeed)
+	require.NotNil(t, decoded.Track)
+	assert.InDelta(t, 140.625, *decoded.Track, 1e-9)
+	assert.Contains(t, app.convertToSBS(decoded), ",18,140.6,")
+
+	// Track status bit clear
+	data[5] &^= 0x08
+	copy(msg.Data[:], data)
+	decoded = app.decodeMessage(msg)
+	require.NotNil(t, decoded)
+	assert.Nil(t, decoded.Track)
+}
+
+// TestApplication_EmergencySquawks tests that special squawks flag an emergency
+func TestApplication_EmergencySquawks(t *testing.T) {
+	tests := []struct {
+		name            string
+		id13            uint16
+		expectSquawk    string
+		expectEmergency string
+	}{
+		{name: "Hijack", id13: 0x0AA2, expectSquawk: "7500", expectEmergency: adsb.EmergencyUnlawful},
+		{name: "Radio failure", id13: 0x0A8A, expectSquawk: "7600", expectEmergency: adsb.EmergencyNoRadio},
+		{name: "General emergency", id13: 0x0AAA, expectSquawk: "7700", expectEmergency: adsb.EmergencyGeneral},
+		{name: "VFR", id13: 0x0808, expectSquawk: "1200"},
+	}
+
+	app := NewApplication(Config{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// DF5 surveillance identity reply
+			msg := &adsb.ADSBMessage{Valid: true}
+			msg.Data[0] = 5 << 3
+			msg.Data[2] = byte(tt.id13 >> 8)
+			msg.Data[3] = byte(tt.id13)
+
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			assert.Equal(t, tt.expectSquawk, decoded.Squawk)
+			assert.Equal(t, tt.expectEmergency, decoded.Emergency)
+			assert.Equal(t, tt.expectEmergency != "", decoded.Alert)
+
+			// SBS columns: squawk, alert, emergency, spi, on ground
+			fields := strings.Split(app.convertToSBS(decoded), ",")
+			require.Len(t, fields, 22)
+			assert.Equal(t, tt.expectSquawk, fields[17])
+			if tt.expectEmergency != "" {
+				assert.Equal(t, "-1", fields[18])
+				assert.Equal(t, "-1", fields[19])
+			} else {
+				assert.Empty(t, fields[18])
+				assert.Empty(t, fields[19])
+			}
+		})
+	}
+}
+
+// TestApplication_FlightStatus tests the SBS alert, emergency, SPI and
+// on-ground columns of surveillance replies from their flight status, alone
+// and with an emergency squawk
+func TestApplication_FlightStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		fs       byte
+		id13     uint16
+		alert    bool
+		spi      bool
+		onGround bool
+	}{
+		{name: "Airborne", fs: fsAirborne, id13: 0x0808},
+		{name: "On ground", fs: fsOnGround, id13: 0x0808, onGround: true},
+		{name: "Alert, airborne", fs: fsAlertAirborne, id13: 0x0808, alert: true},
+		{name: "Alert, on ground", fs: fsAlertOnGround, id13: 0x0808, alert: true, onGround: true},
+		{name: "Alert and SPI", fs: fsAlertSPI, id13: 0x0808, alert: true, spi: true},
+		{name: "SPI", fs: fsSPI, id13: 0x0808, spi: true},
+		{name: "SPI with 7700", fs: fsSPI, id13: 0x0AAA, alert: true, spi: true},
+		{name: "Alert with 7700", fs: fsAlertAirborne, id13: 0x0AAA, alert: true},
+	}
+
+	app := NewApplication(Config{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, df := range []byte{5, 21} {
+				msg := &adsb.ADSBMessage{Valid: true}
+				msg.Data[0] = df<<3 | tt.fs
+				msg.Data[2] = byte(tt.id13 >> 8)
+				msg.Data[3] = byte(tt.id13)
+
+				decoded := app.decodeMessage(msg)
+				require.NotNil(t, decoded)
+				assert.Equal(t, tt.alert, decoded.Alert)
+				assert.Equal(t, tt.spi, decoded.SPI)
+				assert.Equal(t, tt.onGround, decoded.OnGround)
+
+				// SBS columns: alert, emergency, spi, on ground
+				fields := strings.Split(app.convertToSBS(decoded), ",")
+				require.Len(t, fields, 22)
+				assert.Equal(t, sbsFlag(tt.alert), fields[18])
+				assert.Equal(t, sbsFlag(tt.id13 == 0x0AAA), fields[19])
+				assert.Equal(t, sbsFlag(tt.spi), fields[20])
+				assert.Equal(t, tt.onGround, fields[21] == "1")
+			}
+		})
+	}
+
+	// Altitude replies carry the flight status too
+	msg := &adsb.ADSBMessage{Valid: true}
+	msg.Data[0] = 4<<3 | fsAlertSPI
+	decoded := app.decodeMessage(msg)
+	require.NotNil(t, decoded)
+	assert.True(t, decoded.Alert)
+	assert.True(t, decoded.SPI)
+	assert.Empty(t, decoded.Emergency)
+
+	// but no code: an alert is an emergency when the aircraft's latest code
+	// is an emergency one
+	altitudeReply := func(squawk string, fs byte) *adsb.DecodedMessage {
+		msg := &adsb.ADSBMessage{Valid: true}
+		msg.Data[0] = 20<<3 | fs
+		app.registry.Update(&adsb.DecodedMessage{Timestamp: time.Now(), ICAO: msg.GetICAO(), Squawk: squawk})
+		decoded := app.decodeMessage(msg)
+		require.NotNil(t, decoded)
+		return decoded
+	}
+	for _, tt := range []struct {
+		name      string
+		squawk    string
+		fs        byte
+		emergency string
+	}{
+		{name: "Alert after 7600", squawk: "7600", fs: fsAlertAirborne, emergency: adsb.EmergencyNoRadio},
+		{name: "Alert after 7500 on ground", squawk: "7500", fs: fsAlertOnGround, emergency: adsb.EmergencyUnlawful},
+		{name: "Alert and SPI after 7700", squawk: "7700", fs: fsAlertSPI, emergency: adsb.EmergencyGeneral},
+		{name: "No alert after 7700", squawk: "7700", fs: fsAirborne},
+		{name: "Alert after 1200", squawk: "1200", fs: fsAlertAirborne},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded := altitudeReply(tt.squawk, tt.fs)
+			assert.Equal(t, tt.emergency, decoded.Emergency)
+			fields := strings.Split(app.convertToSBS(decoded), ",")
+			assert.Equal(t, sbsFlag(tt.emergency != ""), fields[19])
+		})
+	}
+}
+
+// slowWriter simulates a slow disk or blocked stdout pipe
+type slowWriter struct {
+	delay time.Duration
+	mu    sync.Mutex
+	lines int
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lines++
+	return len(p), nil
+}
+
+func (w *slowWriter) Lines() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lines
+}
+
+// TestApplication_OutputQueue tests that a slow writer does not stall decoding
+func TestApplication_OutputQueue(t *testing.T) {
+	app := NewApplication(Config{LogDir: t.TempDir()})
+	require.NoError(t, app.initializeOutput())
+	defer app.logRotator.Close()
+
+	writer := &slowWriter{delay: 20 * time.Millisecond}
+	app.stdout = writer
+	app.outputQueue = make(chan *adsb.DecodedMessage, 10)
+	app.startOutputWriter()
+
+	// DF5 reply squawking 1200
+	msg := &adsb.ADSBMessage{Valid: true}
+	msg.Data[0] = 5 << 3
+	msg.Data[2] = 0x08
+	msg.Data[3] = 0x08
+
+	const total = 100
+	start := time.Now()
+	for i := 0; i < total; i++ {
+		require.NoError(t, app.writeADSBMessage(msg))
+	}
+
+	// Writing synchronously would take total*delay = 2s
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// Shutdown flushes everything that was queued
+	app.stopOutputWriter()
+	dropped := app.GetOutputDropped()
+	assert.Greater(t, dropped, uint64(0))
+	assert.Equal(t, total, writer.Lines()+int(dropped))
+}
+
+// TestApplication_ShutdownFlush tests that shutdown writes queued output and
+// closes the sinks, finishing live-compressed split files, and that decoding
+// still running cannot queue into a stopped writer
+func TestApplication_ShutdownFlush(t *testing.T) {
+	logDir := t.TempDir()
+	app := NewApplication(Config{LogDir: logDir, LogRotateUTC: true, OutputFormat: OutputFormatJSON, SplitBy: SplitByICAO, CompressLive: true})
+	app.logger.SetOutput(io.Discard)
+	app.stdout = io.Discard
+	require.NoError(t, app.initializeOutput())
+
+	app.startOutputWriter()
+	msg := &adsb.DecodedMessage{Timestamp: time.Now(), ICAO: 0x484412, Hex: "484412", DF: 17}
+	app.enqueueOutput(msg)
+	app.shutdown()
+	app.enqueueOutput(msg)
+
+	path := filepath.Join(logDir, "adsb_"+time.Now().UTC().Format("2006-01-02")+"_484412.log.gz")
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err, "gzip trailer missing")
+	assert.Contains(t, string(content), `"484412"`)
+}
+
+// TestApplication_ReadInputShutdown tests that reading stops at shutdown
+// even while blocked on a pipe that stays open
+func TestApplication_ReadInputShutdown(t *testing.T) {
+	app := NewApplication(Config{Stdin: true})
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	dataChan := make(chan []byte, 1)
+	done := make(chan error, 1)
+	go func() { done <- app.readInput(reader, dataChan) }()
+
+	app.cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("readInput did not stop at shutdown")
+	}
+	_, open := <-dataChan
+	assert.False(t, open)
+}
+
+// TestApplication_ReadInput tests chunked reading of piped I/Q samples
+func TestApplication_ReadInput(t *testing.T) {
+	app := NewApplication(Config{Stdin: true})
+
+	// One and a half chunks plus a dangling odd byte, delivered in partial reads
+	input := make([]byte, inputChunkSize+inputChunkSize/2+1)
+	for i := range input {
+		input[i] = byte(i)
+	}
+
+	dataChan := make(chan []byte, 10)
+	require.NoError(t, app.readInput(iotest.HalfReader(bytes.NewReader(input)), dataChan))
+
+	var chunks [][]byte
+	for chunk := range dataChan {
+		chunks = append(chunks, chunk)
+	}
+	require.Len(t, chunks, 2)
+	assert.Len(t, chunks[0], inputChunkSize)
+	assert.Len(t, chunks[1], inputChunkSize/2)
+	assert.Equal(t, input[:inputChunkSize], chunks[0])
+}
+
+// TestApplication_IQFormats tests that each --iq-format round-trips a known
+// buffer to within its quantisation, on the unsigned 8-bit scale
+func TestApplication_IQFormats(t *testing.T) {
+	samples := []complex128{0, complex(127.5, -127.5), complex(-100, 50.25), complex(0.5, -0.5), complex(-127.5, 12)}
+
+	encode := map[string]func(v float64) []byte{
+		IQFormatU8: func(v float64) []byte {
+			return []byte{byte(math.Round(v + 127.5))}
+		},
+		IQFormatCS16: func(v float64) []byte {
+			b := make([]byte, 2)
+			binary.LittleEndian.PutUint16(b, uint16(int16(math.Min(math.Round(v/127.5*32768), math.MaxInt16))))
+			return b
+		},
+		IQFormatCF32: func(v float64) []byte {
+			b := make([]byte, 4)
+			binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v/127.5)))
+			return b
+		},
+	}
+
+	tests := []struct {
+		format    string
+		tolerance float64
+	}{
+		{format: IQFormatU8, tolerance: 0.5},
+		{format: IQFormatCS16, tolerance: 2 * 127.5 / 32768}, // Full scale clips to 32767
+		{format: IQFormatCF32, tolerance: 1e-4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var data []byte
+			for _, s := range samples {
+				data = append(data, encode[tt.format](real(s))...)
+				data = append(data, encode[tt.format](imag(s))...)
+			}
+			require.Len(t, data, len(samples)*iqSampleBytes(tt.format))
+
+			app := NewApplication(Config{Stdin: true, IQFormat: tt.format})
+			iq := app.inputToIQ(data)
+			require.Len(t, iq, len(samples))
+			for i, s := range samples {
+				assert.InDelta(t, real(s), real(iq[i]), tt.tolerance, "sample %d I", i)
+				assert.InDelta(t, imag(s), imag(iq[i]), tt.tolerance, "sample %d Q", i)
+			}
+
+			// A partial sample at the end of the input is dropped
+			dataChan := make(chan []byte, 1)
+			require.NoError(t, app.readInput(bytes.NewReader(append(data, data[:iqSampleBytes(tt.format)-1]...)), dataChan))
+			assert.Equal(t, data, <-dataChan)
+		})
+	}
+
+	err := NewApplication(Config{IQFormat: IQFormatCS16}).initializeComponents()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "I/Q format cs16 needs --stdin")
+
+	err = NewApplication(Config{IQFormat: "cs8", Stdin: true}).initializeComponents()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported I/Q format "cs8"`)
+}
+
+// TestApplication_StdinPipeline tests that piped input is consumed end to end
+func TestApplication_StdinPipeline(t *testing.T) {
+	app := NewApplication(Config{Stdin: true, SampleRate: DefaultSampleRate})
+	app.adsbProcessor = adsb.NewADSBProcessor(DefaultSampleRate, app.logger)
+	app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+
+	input := bytes.Repeat([]byte{127, 128}, inputChunkSize)
+	dataChan := make(chan []byte, 1)
+
+	go func() {
+		assert.NoError(t, app.readInput(bytes.NewReader(input), dataChan))
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		app.processIQData(dataChan)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		assert.Empty(t, dataChan)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline did not finish consuming input")
+	}
+}
+
+// TestApplication_EmptyBuffers tests that nil, empty and sub-pair buffers pass
+// through the processing loop without a panic or a decode
+func TestApplication_EmptyBuffers(t *testing.T) {
+	app := NewApplication(Config{SampleRate: DefaultSampleRate})
+	app.adsbProcessor = adsb.NewADSBProcessor(DefaultSampleRate, app.logger)
+	app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+
+	buffers := [][]byte{nil, {}, {127}, {127, 128}, {127, 128, 129}, bytes.Repeat([]byte{127, 128}, 100)}
+	dataChan := make(chan []byte, len(buffers))
+	for _, buffer := range buffers {
+		dataChan <- buffer
+	}
+	close(dataChan)
+
+	assert.NotPanics(t, func() { app.processIQData(dataChan) })
+	assert.Empty(t, app.bytesToIQ(nil))
+	assert.Empty(t, app.bytesToIQ([]byte{127}))
+
+	_, preambles, valid, _, _, _ := app.adsbProcessor.GetStats()
+	assert.Zero(t, preambles)
+	assert.Zero(t, valid)
+}
+
+// TestApplication_RawSave tests that a raw capture taken while decoding
+// replays through the --stdin reader and decodes the same frame
+func TestApplication_RawSave(t *testing.T) {
+	frame, err := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	require.NoError(t, err)
+	input := modulateIQ([][]byte{frame})
+
+	t.Run("Round trip", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "capture.iq")
+		app := NewApplication(Config{SampleRate: DefaultSampleRate})
+		app.adsbProcessor = adsb.NewADSBProcessor(DefaultSampleRate, app.logger)
+		app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+		app.rawCapture, err = newRawCapture(path, 0, app.logger)
+		require.NoError(t, err)
+
+		dataChan := make(chan []byte, 1)
+		dataChan <- input
+		close(dataChan)
+		app.processIQData(dataChan)
+		require.NoError(t, app.rawCapture.Close())
+
+		file, err := os.Open(path)
+		require.NoError(t, err)
+		defer file.Close()
+
+		replay := NewApplication(Config{Stdin: true})
+		replayChan := make(chan []byte, 10)
+		require.NoError(t, replay.readInput(file, replayChan))
+		var replayed []byte
+		for chunk := range replayChan {
+			replayed = append(replayed, chunk...)
+		}
+		assert.Equal(t, input, replayed)
+
+		messages := adsb.NewADSBProcessor(DefaultSampleRate, replay.logger).ProcessIQSamples(replay.bytesToIQ(replayed), time.Now())
+		require.Len(t, messages, 1)
+		assert.Equal(t, frame, messages[0].Data[:])
+	})
+
+	t.Run("Size limit", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "capture.iq")
+		capture, err := newRawCapture(path, 1001, logrus.New())
+		require.NoError(t, err)
+
+		capture.Write(input[:600])
+		capture.Write(input[600:])
+		capture.Write(input)
+		require.NoError(t, capture.Close())
+
+		saved, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, input[:1000], saved) // Whole I/Q pairs only
+	})
+
+	t.Run("Stalled writer", func(t *testing.T) {
+		// Nothing reads the pipe until the end, so the writer blocks on it
+		reader, writer, err := os.Pipe()
+		require.NoError(t, err)
+		defer reader.Close()
+		capture := startRawCapture("pipe", writer, 0, logrus.New())
+
+		chunk := make([]byte, inputChunkSize)
+		start := time.Now()
+		for i := 0; i < 2*rawCaptureQueueSize; i++ {
+			capture.Write(chunk)
+		}
+		assert.Less(t, time.Since(start), time.Second)
+		assert.NotZero(t, capture.Dropped())
+
+		go io.Copy(io.Discard, reader)
+		require.NoError(t, capture.Close())
+	})
+}
+
+// TestApplication_Duration tests that a limited run shuts down on its own and
+// logs final statistics
+func TestApplication_Duration(t *testing.T) {
+	// Keep the input open so only the duration can end the run
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	stdin := os.Stdin
+	os.Stdin = reader
+	defer func() {
+		os.Stdin = stdin
+		reader.Close()
+	}()
+	go func() {
+		chunk := bytes.Repeat([]byte{127, 128}, 4096)
+		for {
+			if _, err := writer.Write(chunk); err != nil {
+				writer.Close()
+				return
+			}
+		}
+	}()
+
+	app := NewApplication(Config{
+		Stdin:        true,
+		SampleRate:   DefaultSampleRate,
+		LogDir:       t.TempDir(),
+		OutputFormat: OutputFormatSBS,
+		Duration:     50 * time.Millisecond,
+	})
+	var logs bytes.Buffer
+	app.logger.SetOutput(&logs)
+	app.stdout = &bytes.Buffer{}
+
+	done := make(chan error, 1)
+	go func() { done <- app.Start() }()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after the run duration")
+	}
+
+	assert.Contains(t, logs.String(), "Run duration reached")
+	assert.Contains(t, logs.String(), "Final ADS-B processing statistics")
+	assert.NotContains(t, logs.String(), "Shutdown timeout")
+}
+
+// TestReceiverHealth tests readiness in the unconfigured, healthy and stalled states
+func TestReceiverHealth(t *testing.T) {
+	var health receiverHealth
+	now := time.Now()
+
+	ready, reason := health.Ready(now, time.Minute)
+	assert.False(t, ready)
+	assert.Contains(t, reason, "not configured")
+
+	health.MarkConfigured()
+	ready, _ = health.Ready(now, time.Minute)
+	assert.False(t, ready, "configured but no activity yet")
+
+	health.MarkActivity(now)
+	ready, reason = health.Ready(now.Add(30*time.Second), time.Minute)
+	assert.True(t, ready)
+	assert.Empty(t, reason)
+
+	ready, reason = health.Ready(now.Add(2*time.Minute), time.Minute)
+	assert.False(t, ready)
+	assert.Contains(t, reason, "2m0s")
+}
+
+// TestApplication_Bind tests the listen address and rejection of invalid bind addresses
+func TestApplication_Bind(t *testing.T) {
+	assert.Equal(t, "0.0.0.0:8080", NewApplication(Config{}).listenAddr(8080))
+	assert.Equal(t, "127.0.0.1:8080", NewApplication(Config{Bind: "127.0.0.1"}).listenAddr(8080))
+	assert.Equal(t, "[::1]:8080", NewApplication(Config{Bind: "::1"}).listenAddr(8080))
+
+	app := NewApplication(Config{Bind: "not-an-address", Stdin: true})
+	err := app.initializeComponents()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid bind address")
+}
+
+// TestApplication_CoordPrecision tests that SBS and JSON positions honor the
+// configured number of decimal places
+func TestApplication_CoordPrecision(t *testing.T) {
+	lat, lon := 52.2657183, 3.9389131
+
+	tests := []struct {
+		name      string
+		precision int
+		sbsLat    string
+		sbsLon    string
+		jsonLat   float64
+	}{
+		{name: "Default", precision: 0, sbsLat: "52.265718", sbsLon: "3.938913", jsonLat: 52.265718},
+		{name: "Minimum", precision: 3, sbsLat: "52.266", sbsLon: "3.939", jsonLat: 52.266},
+		{name: "Maximum", precision: 8, sbsLat: "52.26571830", sbsLon: "3.93891310", jsonLat: 52.2657183},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded := &adsb.DecodedMessage{
+				Timestamp:        time.Date(2024, 1, 15, 14, 30, 45, 0, time.UTC),
+				ICAO:             0x40621D,
+				Hex:              "40621d",
+				DF:               17,
+				TransmissionType: 3,
+				Latitude:         &lat,
+				Longitude:        &lon,
+			}
+
+			app := NewApplication(Config{CoordDigits: tt.precision})
+			fields := strings.Split(app.convertToSBS(decoded), ",")
+			assert.Equal(t, tt.sbsLat, fields[14])
+			assert.Equal(t, tt.sbsLon, fields[15])
+
+			line, err := app.convertToJSON(decoded)
+			require.NoError(t, err)
+			var parsed map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &parsed))
+			assert.Equal(t, tt.jsonLat, parsed["lat"])
+
+			// The message itself keeps full precision
+			assert.Equal(t, lat, *decoded.Latitude)
+		})
+	}
+
+	for _, precision := range []int{2, 9} {
+		app := NewApplication(Config{CoordDigits: precision, Stdin: true})
+		err := app.initializeComponents()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "coordinate precision")
+	}
+}
+
+// TestApplication_RSSI tests that the preamble level measured by the
+// demodulator reaches JSON output as a plausible rssi in dBFS
+func TestApplication_RSSI(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+	app.adsbProcessor = adsb.NewADSBProcessor(DefaultSampleRate, app.logger)
+	app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+
+	frame, err := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	require.NoError(t, err)
+	messages := app.adsbProcessor.ProcessIQSamples(app.bytesToIQ(modulateIQ([][]byte{frame})), time.Now())
+	require.Len(t, messages, 1)
+
+	decoded := app.decodeMessage(messages[0])
+	require.NotNil(t, decoded)
+	line, err := app.convertToJSON(decoded)
+	require.NoError(t, err)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(line), &record))
+	rssi, ok := record["rssi"].(float64)
+	require.True(t, ok, "rssi missing from %s", line)
+	assert.Less(t, rssi, 0.0)
+	assert.Greater(t, rssi, -50.0)
+	assert.Equal(t, math.Round(decoded.Signal*10)/10, rssi)
+
+	// A silent preamble has no level to report
+	decoded.Signal = math.Inf(-1)
+	line, err = app.convertToJSON(decoded)
+	require.NoError(t, err)
+	assert.NotContains(t, line, "rssi")
+}
+
+// TestApplication_DataEndpoints tests the shape of receiver.json, stats.json
+// and aircraft.json, and that the status page is served
+func TestApplication_DataEndpoints(t *testing.T) {
+	app := NewApplication(Config{Latitude: 52.3, Longitude: 4.76})
+	handler := app.newHTTPServer().Handler()
+
+	get := func(path string) map[string]interface{} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+		return doc
+	}
+
+	receiver := get("/data/receiver.json")
+	assert.Equal(t, Version, receiver["version"])
+	assert.Equal(t, float64(1000), receiver["refresh"])
+	assert.Equal(t, 52.3, receiver["lat"])
+	assert.Equal(t, 4.76, receiver["lon"])
+
+	stats := get("/data/stats.json")
+	for _, period := range []string{"latest", "last1min", "last5min", "last15min", "total"} {
+		require.Contains(t, stats, period)
+		p := stats[period].(map[string]interface{})
+		assert.Contains(t, p, "start")
+		assert.Contains(t, p, "end")
+		assert.Contains(t, p, "messages")
+
+		local := p["local"].(map[string]interface{})
+		for _, field := range []string{"samples_processed", "samples_dropped", "modeac", "modes", "bad", "unknown_icao"} {
+			assert.Contains(t, local, field, "%s.local", period)
+		}
+		assert.Len(t, local["accepted"], 2)
+	}
+
+	lat, lon, alt := 52.2657, 3.9389, 38000
+	now := time.Now()
+	app.registry.Update(&adsb.DecodedMessage{Timestamp: now.Add(-3 * time.Second), ICAO: 0x40621D, Altitude: &alt, Latitude: &lat, Longitude: &lon})
+	app.registry.Update(&adsb.DecodedMessage{Timestamp: now, ICAO: 0x4840D6, Callsign: "KLM1023 "})
+	aircraft := get("/data/aircraft.json")
+	assert.Contains(t, aircraft, "now")
+	assert.Equal(t, float64(2), aircraft["messages"])
+	list := aircraft["aircraft"].([]interface{})
+	require.Len(t, list, 2)
+	positioned := list[0].(map[string]interface{})
+	assert.Equal(t, "40621d", positioned["hex"])
+	assert.Equal(t, float64(38000), positioned["alt_baro"])
+	assert.Equal(t, lat, positioned["lat"])
+	assert.Equal(t, lon, positioned["lon"])
+	assert.InDelta(t, 3, positioned["seen_pos"], 1)
+	assert.InDelta(t, 3, positioned["seen"], 1)
+	identified := list[1].(map[string]interface{})
+	assert.Equal(t, "4840d6", identified["hex"])
+	assert.Equal(t, "KLM1023 ", identified["flight"])
+	assert.NotContains(t, identified, "lat")
+	assert.NotContains(t, identified, "seen_pos")
+
+	// The status page is served at the root
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	// Without a configured position lat/lon are left out
+	receiver = func() map[string]interface{} {
+		data, err := json.Marshal(NewApplication(Config{}).receiverInfo())
+		require.NoError(t, err)
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &doc))
+		return doc
+	}()
+	assert.NotContains(t, receiver, "lat")
+	assert.NotContains(t, receiver, "lon")
+}
+
+// TestTopMessageTypes tests ordering and truncation of the message type breakdown
+func TestTopMessageTypes(t *testing.T) {
+	breakdown := map[string]uint64{
+		"DF17":      9,
+		"DF17/TC11": 5,
+		"DF17/TC19": 3,
+		"DF11":      3,
+		"DF4":       1,
+	}
+
+	top := topMessageTypes(breakdown, 4)
+	assert.Equal(t, "DF17=9 DF17/TC11=5 DF11=3 DF17/TC19=3", formatMessageTypes(top))
+	assert.Len(t, topMessageTypes(breakdown, 10), 5)
+	assert.Empty(t, formatMessageTypes(topMessageTypes(nil, 4)))
+}
+
+// TestFormatPhaseStats tests that the phase distribution is logged in phase order
+func TestFormatPhaseStats(t *testing.T) {
+	stats := map[int]uint64{8: 1, 4: 12, 6: 0, 5: 30, 7: 2}
+	assert.Equal(t, "4=12 5=30 6=0 7=2 8=1", formatPhaseStats(stats))
+}
+
+// TestApplication_FinalStatistics tests that shutdown logs one final summary
+// and that a run without preambles reports a 0.00% success rate
+func TestApplication_FinalStatistics(t *testing.T) {
+	app := NewApplication(Config{})
+	app.adsbProcessor = adsb.NewADSBProcessor(DefaultSampleRate, app.logger)
+
+	var logs bytes.Buffer
+	app.logger.SetOutput(&logs)
+	app.logger.SetFormatter(&logrus.JSONFormatter{})
+
+	app.shutdown()
+
+	var summaries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		if entry["msg"] == "Final ADS-B processing statistics" {
+			summaries = append(summaries, entry)
+		}
+	}
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "0.00%", summaries[0]["success_rate"])
+	assert.Equal(t, float64(0), summaries[0]["preambles_found"])
+
+	assert.Equal(t, "0.00%", successRate(0, 0))
+	assert.Equal(t, "75.00%", successRate(3, 4))
+}
+
+// TestApplication_StatsCSV tests that each run appends one well-formed row to
+// --stats-csv, with the header written only when the file is created
+func TestApplication_StatsCSV(t *testing.T) {
+	frame, err := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "runs.csv")
+
+	runSession := func(input []byte) {
+		app := NewApplication(Config{SampleRate: DefaultSampleRate, StatsCSV: path})
+		app.logger.SetOutput(io.Discard)
+		app.adsbProcessor = adsb.NewADSBProcessor(DefaultSampleRate, app.logger)
+		app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+
+		dataChan := make(chan []byte, 1)
+		dataChan <- input
+		close(dataChan)
+		app.processIQData(dataChan)
+		app.shutdown()
+	}
+	runSession(modulateIQ([][]byte{frame}))
+	runSession(bytes.Repeat([]byte{127, 128}, 4096))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, statsCSVColumns, records[0])
+
+	row := records[1]
+	_, err = time.Parse(time.RFC3339, row[0])
+	assert.NoError(t, err)
+	_, err = strconv.ParseFloat(row[1], 64)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", row[4])
+	assert.Equal(t, "100.00", row[6])
+	assert.Equal(t, "1", row[7])
+
+	var config Config
+	require.NoError(t, json.Unmarshal([]byte(row[8]), &config))
+	assert.Equal(t, path, config.StatsCSV)
+
+	assert.Equal(t, "0", records[2][4])
+	assert.Equal(t, "0.00", records[2][6])
+	assert.Equal(t, "0", records[2][7])
+}
+
+// TestStatsTracker tests per-period counter differences
+func TestStatsTracker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newStatsTracker(start)
+
+	// Ten minutes at 100 preambles and 10 messages (1 corrected) per minute
+	for minute := 1; minute <= 10; minute++ {
+		n := uint64(minute)
+		tracker.Update(statsCounters{
+			samples:   n * 1000,
+			preambles: n * 100,
+			bad:       n * 90,
+			valid:     n * 10,
+			corrected: n,
+			messages:  n * 10,
+			noise:     -40 + float64(minute)/10,
+		}, start.Add(time.Duration(minute)*time.Minute))
+	}
+
+	report := tracker.Report()
+	assert.Equal(t, uint64(1000), report.Total.Local.ModeS)
+	assert.Equal(t, []uint64{90, 10}, report.Total.Local.Accepted)
+	assert.Equal(t, uint64(100), report.Last1Min.Local.ModeS)
+	assert.Equal(t, uint64(10), report.Last1Min.Messages)
+	assert.Equal(t, uint64(500), report.Last5Min.Local.ModeS)
+	assert.Equal(t, uint64(450), report.Last5Min.Local.Bad)
+
+	// Fifteen minutes have not passed, so the period covers everything kept
+	assert.Equal(t, uint64(1000), report.Last15Min.Local.ModeS)
+	assert.Equal(t, unixSeconds(start), report.Total.Start)
+	assert.Equal(t, unixSeconds(start.Add(10*time.Minute)), report.Total.End)
+
+	// The noise floor is the latest level, not a difference
+	assert.Equal(t, -39.0, report.Last1Min.Local.Noise)
+	assert.Equal(t, -39.0, report.Total.Local.Noise)
+}
+
+// TestRunSelfTest tests that the embedded vectors pass and that a wrong
+// expectation is reported as a failure
+func TestRunSelfTest(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, RunSelfTest(&out))
+	assert.Equal(t, len(selfTestCases), strings.Count(out.String(), "PASS  "))
+	assert.NotContains(t, out.String(), "FAIL")
+
+	tc := selfTestCases[0]
+	tc.callsign = "WRONG"
+	err := runSelfTestCase(tc)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `callsign "KLM1023", expected "WRONG"`)
+
+	// A corrupted frame never reaches the decoder
+	tc = selfTestCases[0]
+	tc.frames = []string{"8D4840D6202CC371C32CE0576000"}
+	require.Error(t, runSelfTestCase(tc))
+}
+
+// TestDecodeFrame tests decoding single frames given on the command line
+func TestDecodeFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		frame    string
+		contains []string
+		excludes []string
+		err      string
+	}{
+		{
+			name:     "Identification",
+			frame:    "8D4840D6202CC371C32CE0576098",
+			contains: []string{"crc:          valid\n", "hex:          4840d6\n", "df:           17\n", "tc:           4\n", "flight:       KLM1023\n"},
+		},
+		{
+			name:     "Identification with a bit error, AVR framed",
+			frame:    "*8D4840D6202CC370C32CE0576098;",
+			contains: []string{"frame:        8D4840D6202CC371C32CE0576098\n", "crc:          corrected-1\n", "flight:       KLM1023\n"},
+		},
+		{
+			name:     "Airborne position",
+			frame:    "8D40621D58C382D690C8AC2863A7",
+			contains: []string{"tc:              11\n", "alt_baro:        38000\n", "position:        needs the matching even/odd CPR frame\n"},
+			excludes: []string{"lat:", "lon:"},
+		},
+		{name: "Bad CRC", frame: "8D4840D6202CC371C32CE0576000", err: "failed CRC check"},
+		{name: "Short for its DF", frame: "8D4840D6202CC3", err: "DF17 frame is 7 bytes, expected 14"},
+		{name: "Not hex", frame: "8D4840D6202CC371C32CE05760ZZ", err: "failed to parse frame"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := DecodeFrame(&out, tt.frame)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				assert.Empty(t, out.String())
+				return
+			}
+			require.NoError(t, err)
+			for _, s := range tt.contains {
+				assert.Contains(t, out.String(), s)
+			}
+			for _, s := range tt.excludes {
+				assert.NotContains(t, out.String(), s)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+// Cleanup test logs
+func TestMain(m *testing.M) {
+	// Run tests
+	code := m.Run()
+
+	// Cleanup
+	os.RemoveAll("./test_logs")
+
+	os.Exit(code)
+}
+
+// TestApplication_ScanOffset tests the frequencies tried by --scan-offset and
+// the choice among their measured decode rates
+func TestApplication_ScanOffset(t *testing.T) {
+	assert.Equal(t, []uint32{1089950000, 1089975000, 1090000000, 1090025000, 1090050000},
+		scanFrequencies(DefaultFrequency, ScanSpan, ScanStep))
+
+	rates := func(values ...float64) []scanResult {
+		results := make([]scanResult, len(values))
+		for i, frequency := range scanFrequencies(DefaultFrequency, ScanSpan, ScanStep) {
+			results[i] = scanResult{frequency: frequency, rate: values[i]}
+		}
+		return results
+	}
+
+	tests := []struct {
+		name     string
+		results  []scanResult
+		expected uint32
+	}{
+		{name: "Best off centre", results: rates(12.5, 30, 21, 8, 0), expected: 1089975000},
+		{name: "Best at the edge", results: rates(0, 1, 2, 3, 4.5), expected: 1090050000},
+		{name: "Tie keeps the nearest to centre", results: rates(30, 30, 30, 30, 30), expected: DefaultFrequency},
+		{name: "Tie either side of centre", results: rates(0, 25, 10, 25, 0), expected: 1089975000},
+		{name: "No traffic keeps centre", results: rates(0, 0, 0, 0, 0), expected: DefaultFrequency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			best, ok := bestScanFrequency(tt.results, DefaultFrequency)
+			require.True(t, ok)
+			assert.Equal(t, tt.expected, best.frequency)
+		})
+	}
+
+	_, ok := bestScanFrequency(nil, DefaultFrequency)
+	assert.False(t, ok)
+
+	// Samples piped in cannot be retuned
+	app := NewApplication(Config{ScanOffset: true, Stdin: true})
+	err := app.initializeComponents()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "frequency scan")
+}
+
+// TestApplication_MaxProcs tests that --max-procs caps GOMAXPROCS and that
+// the default leaves it alone
+func TestApplication_MaxProcs(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+
+	before := runtime.GOMAXPROCS(0)
+	assert.Equal(t, before, NewApplication(Config{}).applyMaxProcs())
+
+	assert.Equal(t, 1, NewApplication(Config{MaxProcs: 1}).applyMaxProcs())
+	assert.Equal(t, 1, runtime.GOMAXPROCS(0))
+
+	app := NewApplication(Config{MaxProcs: -1, Stdin: true})
+	err := app.initializeComponents()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "max procs")
+}
+
+// TestApplication_TimestampSource tests output timestamps when the system
+// clock steps back 10 s: monotonic ones keep counting samples and never go
+// backwards, wall-clock ones follow the step
+func TestApplication_TimestampSource(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// Buffers of 100 ms arrive on time, with the clock stepped back before the third
+	arrivals := []time.Time{
+		start,
+		start.Add(100 * time.Millisecond),
+		start.Add(200*time.Millisecond - 10*time.Second),
+		start.Add(300*time.Millisecond - 10*time.Second),
+	}
+	const bufferSamples = DefaultSampleRate / 10
+
+	tests := []struct {
+		source   string
+		expected []time.Duration // From start
+	}{
+		{source: TimestampMonotonic, expected: []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}},
+		{source: TimestampWall, expected: []time.Duration{-100 * time.Millisecond, 0, 100*time.Millisecond - 10*time.Second, 200*time.Millisecond - 10*time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			var stdout bytes.Buffer
+			app := NewApplication(Config{LogDir: t.TempDir(), OutputFormat: OutputFormatJSON, TimestampSource: tt.source})
+			app.stdout = &stdout
+			require.NoError(t, app.initializeOutput())
+			defer app.logRotator.Close()
+
+			clock := newReceptionClock(tt.source, DefaultSampleRate)
+			next := 0
+			clock.now = func() time.Time {
+				next++
+				return arrivals[next-1]
+			}
+			// The monotonic clock does not step
+			clock.since = func(time.Time) time.Duration {
+				return time.Duration(next-1) * 100 * time.Millisecond
+			}
+
+			app.startOutputWriter()
+			for range arrivals {
+				app.enqueueOutput(&adsb.DecodedMessage{Timestamp: clock.bufferTime(bufferSamples), Hex: "4840d6", DF: 17})
+			}
+			app.stopOutputWriter()
+
+			lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			require.Len(t, lines, len(tt.expected))
+			for i, line := range lines {
+				var fields struct{ Timestamp time.Time }
+				require.NoError(t, json.Unmarshal([]byte(line), &fields))
+				assert.Equal(t, start.Add(tt.expected[i]), fields.Timestamp.UTC(), "buffer %d", i)
+			}
+		})
+	}
+
+	// Records stamped before the last one written, such as reassembled
+	// ELMs, are held to it unless the wall clock is asked for
+	app := NewApplication(Config{})
+	earlier := &adsb.DecodedMessage{Timestamp: start.Add(-time.Second)}
+	app.monotonicTimestamp(&adsb.DecodedMessage{Timestamp: start})
+	app.monotonicTimestamp(earlier)
+	assert.Equal(t, start, earlier.Timestamp)
+
+	err := NewApplication(Config{TimestampSource: "gps", Stdin: true}).initializeComponents()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported timestamp source "gps"`)
+}
+
+// TestReceptionClock_CatchUp tests that the monotonic clock catches up with
+// the time elapsed once dropped buffers leave it over maxClockLag behind,
+// and is never pulled back by input arriving faster than real time
+func TestReceptionClock_CatchUp(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	const bufferSamples = DefaultSampleRate / 10 // 100 ms
+
+	clock := newReceptionClock(TimestampMonotonic, DefaultSampleRate)
+	clock.now = func() time.Time { return start }
+	var elapsed time.Duration
+	clock.since = func(time.Time) time.Duration { return elapsed }
+
+	stamp := func(arrival time.Duration) time.Duration {
+		elapsed = arrival
+		return clock.bufferTime(bufferSamples).Sub(start)
+	}
+
+	assert.Equal(t, time.Duration(0), stamp(100*time.Millisecond))
+	// Half a second of drops is within the bound
+	assert.Equal(t, 100*time.Millisecond, stamp(700*time.Millisecond))
+	// Two seconds more and the clock jumps to the buffer's reception
+	assert.Equal(t, 2600*time.Millisecond, stamp(2700*time.Millisecond))
+	// and counts on from there
+	assert.Equal(t, 2700*time.Millisecond, stamp(2800*time.Millisecond))
+
+	// Buffers arriving at once are stamped by sample count alone
+	assert.Equal(t, 2800*time.Millisecond, stamp(2800*time.Millisecond))
+	assert.Equal(t, 2900*time.Millisecond, stamp(2800*time.Millisecond))
+}
+
+// TestApplication_DemodPhases tests that --demod-phases accepts only the
+// known search modes
+func TestApplication_DemodPhases(t *testing.T) {
+	for _, mode := range []string{"", DemodPhasesBest, DemodPhasesSingle} {
+		app := NewApplication(Config{DemodPhases: mode, SampleRate: DefaultSampleRate, Stdin: true, LogDir: t.TempDir()})
+		require.NoError(t, app.initializeComponents(), mode)
+		app.logRotator.Close()
+	}
+
+	err := NewApplication(Config{DemodPhases: "fast", Stdin: true}).initializeComponents()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported demod phases "fast"`)
+}
+
+// TestApplication_RegisterHandler tests that a registered handler is called
+// with every decoded message, unaffected by the output filters
+func TestApplication_RegisterHandler(t *testing.T) {
+	var stdout bytes.Buffer
+
+	app := NewApplication(Config{LogDir: t.TempDir(), OutputFormat: OutputFormatJSON, PositionOnly: true})
+	app.stdout = &stdout
+
+	var received []*adsb.DecodedMessage
+	app.RegisterHandler(adsb.MessageHandlerFunc(func(msg *adsb.DecodedMessage) error {
+		received = append(received, msg)
+		return nil
+	}))
+	require.NoError(t, app.initializeOutput())
+	defer app.logRotator.Close()
+
+	alt := 38000
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	identification := &adsb.DecodedMessage{Timestamp: timestamp, ICAO: 0x4840D6, Hex: "4840d6", DF: 17, TypeCode: 4, Callsign: "KLM1023 "}
+	altitude := &adsb.DecodedMessage{Timestamp: timestamp, ICAO: 0x40621D, Hex: "40621d", DF: 4, Altitude: &alt}
+
+	app.startOutputWriter()
+	app.enqueueOutput(identification)
+	app.enqueueOutput(altitude)
+	app.stopOutputWriter()
+
+	require.Len(t, received, 2)
+	assert.Same(t, identification, received[0])
+	assert.Equal(t, "KLM1023 ", received[0].Callsign)
+	assert.Same(t, altitude, received[1])
+	assert.Equal(t, 38000, *received[1].Altitude)
+	assert.Empty(t, stdout.String(), "positions-only still applies to stdout")
+}
+
+// TestApplication_UDPOutput tests that --sbs-udp and --json-udp send each
+// record in their own format, whatever the output format
+func TestApplication_UDPOutput(t *testing.T) {
+	listen := func() *net.UDPConn {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	sbs, jsonRx := listen(), listen()
+
+	app := NewApplication(Config{
+		LogDir:       t.TempDir(),
+		OutputFormat: OutputFormatCSV,
+		SBSUDP:       sbs.LocalAddr().String(),
+		JSONUDP:      jsonRx.LocalAddr().String(),
+	})
+	app.stdout = io.Discard
+	require.NoError(t, app.initializeOutput())
+	defer app.logRotator.Close()
+	require.NoError(t, app.openUDPSinks())
+
+	alt := 38000
+	msg := &adsb.DecodedMessage{Timestamp: time.Now(), ICAO: 0x484412, Hex: "484412", DF: 17, TypeCode: 11, Altitude: &alt}
+	app.startOutputWriter()
+	app.enqueueOutput(msg)
+	app.stopOutputWriter()
+
+	receive := func(conn *net.UDPConn) string {
+		buf := make([]byte, 65536)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	// SBS announces the aircraft before its first MSG record
+	lines := strings.Split(strings.TrimSuffix(receive(sbs), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "AIR,"), lines[0])
+	assert.Equal(t, app.convertToSBS(msg), lines[1])
+
+	expected, err := app.convertToJSON(msg)
+	require.NoError(t, err)
+	assert.Equal(t, expected+"\n", receive(jsonRx))
+}
+
+// TestApplication_SurveillanceAddress tests that surveillance and Comm-B
+// replies from known aircraft validate and are reported under the address
+// overlaid on their parity
+func TestApplication_SurveillanceAddress(t *testing.T) {
+	app := NewApplication(Config{OutputFormat: OutputFormatJSON})
+	now := time.Now()
+	known := adsb.NewAddressTable(adsb.DefaultAddressTTL)
+	known.Add(0x40621D, now)
+	known.Add(0x3C4DD2, now)
+
+	tests := []struct {
+		name   string
+		frame  string
+		hex    string
+		squawk string
+	}{
+		{name: "DF5 squawk 7700", frame: "28000AAA0AC6D4", hex: "40621d", squawk: "7700"},
+		{name: "DF20 BDS 5,0", frame: "A000139381951536E024D4CCF6B5", hex: "3c4dd2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.frame)
+			require.NoError(t, err)
+			msg := &adsb.ADSBMessage{Timestamp: now}
+			copy(msg.Data[:], data)
+			adsb.ValidateMessageKnown(msg, true, known)
+			require.True(t, msg.Valid)
+
+			decoded := app.decodeMessage(msg)
+			require.NotNil(t, decoded)
+			assert.Equal(t, tt.hex, decoded.Hex)
+			if tt.squawk != "" {
+				assert.Equal(t, tt.squawk, decoded.Squawk)
+			}
+		})
+	}
 }
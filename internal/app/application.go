@@ -2,9 +2,15 @@ package app
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"net"
 	"os"
 	"os/signal"
+	"runtime"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -13,8 +19,14 @@ import (
 
 	"go1090/internal/adsb"
 	"go1090/internal/basestation"
+	"go1090/internal/beast"
+	"go1090/internal/filter"
 	"go1090/internal/logging"
+	"go1090/internal/output"
+	"go1090/internal/registry"
 	"go1090/internal/rtlsdr"
+	"go1090/internal/stream"
+	"go1090/internal/web"
 )
 
 // Application represents the main application
@@ -26,14 +38,72 @@ type Application struct {
 	baseStation   *basestation.Writer
 	logRotator    *logging.LogRotator
 	cprDecoder    *adsb.CPRDecoder
+	tracer        *adsb.Tracer
 	ctx           context.Context
 	cancel        context.CancelFunc
 	wg            sync.WaitGroup
 	verbose       bool
 
-	// Aircraft position tracking for CPR decoding
-	aircraftPositions map[uint32]*adsb.AircraftPosition
-	positionMutex     sync.RWMutex
+	// Readiness state for the HTTP probes
+	health     receiverHealth
+	httpServer *web.Server
+
+	// Broadcasts output lines over a Unix domain socket
+	socketServer *stream.Server
+	udpSinks     []*output.UDPSink
+
+	// Serves raw frames stamped with the receiver clock to MLAT clients
+	mlatServer  *stream.Server
+	mlatEncoder *beast.Encoder
+
+	// Reassembles Comm-D ELM segments into transfers
+	elm *adsb.ELMReassembler
+
+	// Cross-checks updates against each aircraft's previous state
+	plausibility *adsb.PlausibilityFilter
+
+	// Per-aircraft state accumulated across messages
+	registry *registry.Registry
+
+	// Counters published by the processing loop for stats.json
+	stats *statsTracker
+
+	// Most aircraft tracked at once, for --stats-csv
+	peakAircraft int64
+
+	// Closed once the processing loop has consumed all of its input
+	inputDone chan struct{}
+
+	// Records the raw I/Q stream for later replay; nil when not requested
+	rawCapture *rawCapture
+
+	// Output queue drained by a dedicated writer goroutine into the sinks.
+	// The queue is never closed, as decoding may still be queueing when
+	// shutdown gives up waiting for it; outputStop tells the writer to
+	// drain what is left and finish.
+	outputQueue   chan *adsb.DecodedMessage
+	outputStop    chan struct{}
+	outputDone    chan struct{}
+	outputDropped uint64
+	sinks         *output.MultiSink
+	stdout        io.Writer
+
+	// Per-key log files with --split-by, nil otherwise, and the keys whose
+	// files from earlier days were looked for on splitCheckedDate
+	splitSink        *output.SplitSink
+	splitChecked     map[string]bool
+	splitCheckedDate string
+
+	// Timestamp of the last message written, kept by the output writer
+	lastOutputTime time.Time
+
+	// Handlers registered by an embedding program, called after the
+	// built-in outputs
+	handlers []adsb.MessageHandler
+
+	// ICAO allow/deny list, replaced on reload
+	icaoFilter  *filter.ICAOFilter
+	filterMutex sync.RWMutex
 }
 
 // NewApplication creates a new application instance
@@ -48,12 +118,19 @@ func NewApplication(config Config) *Application {
 	}
 
 	return &Application{
-		config:            config,
-		logger:            logger,
-		ctx:               ctx,
-		cancel:            cancel,
-		verbose:           config.Verbose,
-		aircraftPositions: make(map[uint32]*adsb.AircraftPosition),
+		config:       config,
+		logger:       logger,
+		ctx:          ctx,
+		cancel:       cancel,
+		verbose:      config.Verbose,
+		tracer:       adsb.NewTracer(logger, config.Verbose),
+		elm:          adsb.NewELMReassembler(adsb.DefaultELMTimeout),
+		plausibility: adsb.NewPlausibilityFilter(),
+		registry:     registry.New(),
+		stats:        newStatsTracker(time.Now()),
+		outputQueue:  make(chan *adsb.DecodedMessage, DefaultOutputQueueSize),
+		inputDone:    make(chan struct{}),
+		stdout:       os.Stdout,
 	}
 }
 
@@ -74,15 +151,41 @@ func (app *Application) Start() error {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP reopens the log file and reloads the ICAO filter
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	defer signal.Stop(hupChan)
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.handleReloadSignals(hupChan)
+	}()
+
 	// Start processing
 	if err := app.run(); err != nil {
 		app.logger.WithError(err).Error("Application error")
 		return err
 	}
 
-	// Wait for shutdown signal
-	<-sigChan
-	app.logger.Info("Received shutdown signal")
+	// A limited run shuts down on its own
+	var durationC <-chan time.Time
+	if app.config.Duration > 0 {
+		timer := time.NewTimer(app.config.Duration)
+		defer timer.Stop()
+		durationC = timer.C
+	}
+
+	// Wait for shutdown signal, the run duration, or for piped input to be
+	// fully processed
+	select {
+	case <-sigChan:
+		app.logger.Info("Received shutdown signal")
+	case <-durationC:
+		app.logger.WithField("duration", app.config.Duration).Info("Run duration reached")
+	case <-app.inputDone:
+		app.logger.Info("Input processed")
+	}
 	app.shutdown()
 
 	return nil
@@ -92,28 +195,216 @@ func (app *Application) Start() error {
 func (app *Application) initializeComponents() error {
 	var err error
 
-	// Initialize RTL-SDR device
-	app.rtlsdr, err = rtlsdr.NewRTLSDRDevice(app.config.DeviceIndex)
-	if err != nil {
-		return fmt.Errorf("failed to initialize RTL-SDR: %w", err)
+	// Validate output format before touching the hardware
+	switch app.config.OutputFormat {
+	case "", OutputFormatSBS, OutputFormatJSON, OutputFormatCSV, OutputFormatGeoJSON:
+	default:
+		return fmt.Errorf("unsupported output format %q", app.config.OutputFormat)
+	}
+
+	switch app.config.DemodPhases {
+	case "", DemodPhasesBest, DemodPhasesSingle:
+	default:
+		return fmt.Errorf("unsupported demod phases %q", app.config.DemodPhases)
+	}
+
+	switch app.config.SplitBy {
+	case "", SplitByNone, SplitByICAO, SplitByType:
+	default:
+		return fmt.Errorf("unsupported split mode %q", app.config.SplitBy)
+	}
+
+	switch app.config.TimestampSource {
+	case "", TimestampMonotonic, TimestampWall:
+	default:
+		return fmt.Errorf("unsupported timestamp source %q", app.config.TimestampSource)
+	}
+
+	// Coordinates are rounded at the formatting boundary only
+	if d := app.config.CoordDigits; d != 0 && (d < MinCoordPrecision || d > MaxCoordPrecision) {
+		return fmt.Errorf("coordinate precision %d out of range %d-%d", d, MinCoordPrecision, MaxCoordPrecision)
+	}
+
+	// The pressure setting field spans 800 to 1100 mb
+	if q := app.config.LocalQNH; q != 0 && (q < 800 || q > 1100) {
+		return fmt.Errorf("local QNH %.1f mb out of range 800-1100", q)
+	}
+
+	// Validate the listen address before touching the hardware
+	if app.config.Bind != "" && net.ParseIP(app.config.Bind) == nil {
+		return fmt.Errorf("invalid bind address %q", app.config.Bind)
+	}
+
+	// Zero disables the last-position fallback; negative is a mistake
+	if app.config.PosMaxAge < 0 {
+		return fmt.Errorf("invalid position max age %s", app.config.PosMaxAge)
+	}
+	if app.config.ZoneRetryFrames < 0 {
+		return fmt.Errorf("invalid CPR retry frame count %d", app.config.ZoneRetryFrames)
+	}
+
+	// Zero leaves the raw capture uncapped
+	if app.config.RawSaveMaxMB < 0 {
+		return fmt.Errorf("invalid raw capture size limit %d MB", app.config.RawSaveMaxMB)
+	}
+
+	// A frequency scan needs a tuner to retune
+	if app.config.ScanOffset && app.config.Stdin {
+		return fmt.Errorf("frequency scan needs an RTL-SDR, not --stdin")
+	}
+
+	// The RTL-SDR delivers unsigned 8-bit samples only
+	switch app.config.IQFormat {
+	case "", IQFormatU8:
+	case IQFormatCS16, IQFormatCF32:
+		if !app.config.Stdin {
+			return fmt.Errorf("I/Q format %s needs --stdin", app.config.IQFormat)
+		}
+	default:
+		return fmt.Errorf("unsupported I/Q format %q", app.config.IQFormat)
+	}
+
+	// Zero disables the track history
+	if app.config.TrackHistory < 0 {
+		return fmt.Errorf("invalid track history size %d", app.config.TrackHistory)
+	}
+	app.registry.SetTrackHistory(app.config.TrackHistory)
+
+	// Zero sends new stream clients only live output
+	if app.config.ReplayBuffer < 0 {
+		return fmt.Errorf("invalid replay buffer %d", app.config.ReplayBuffer)
+	}
+
+	// Zero leaves only the final summary
+	if app.config.StatsPeriod < 0 {
+		return fmt.Errorf("invalid stats interval %s", app.config.StatsPeriod)
+	}
+
+	// Zero leaves the Go default of every CPU
+	if app.config.MaxProcs < 0 {
+		return fmt.Errorf("invalid max procs %d", app.config.MaxProcs)
+	}
+	app.logger.WithField("max_procs", app.applyMaxProcs()).Info("CPU parallelism")
+
+	// DF11 interrogator codes are 7 bits
+	for _, iid := range app.config.DF11IIDs {
+		if iid > 0x7F {
+			return fmt.Errorf("invalid DF11 interrogator code %d", iid)
+		}
+	}
+
+	// Enable per-aircraft tracing
+	if app.config.TraceICAO != "" {
+		icao, err := strconv.ParseUint(app.config.TraceICAO, 16, 24)
+		if err != nil {
+			return fmt.Errorf("invalid trace ICAO %q: %w", app.config.TraceICAO, err)
+		}
+		app.tracer.SetTraceICAO(uint32(icao))
 	}
 
-	// Configure RTL-SDR
-	if err := app.rtlsdr.Configure(app.config.Frequency, app.config.SampleRate, app.config.Gain); err != nil {
-		return fmt.Errorf("failed to configure RTL-SDR: %w", err)
+	// Initialize RTL-SDR device, unless samples are piped in
+	if !app.config.Stdin {
+		app.rtlsdr, err = rtlsdr.NewRTLSDRDevice(app.config.DeviceIndex)
+		if err != nil {
+			return fmt.Errorf("failed to initialize RTL-SDR: %w", err)
+		}
+
+		// Configure RTL-SDR
+		app.rtlsdr.SetTuningOptions(rtlsdr.TuningOptions{
+			PPM:          app.config.PPM,
+			BiasTee:      app.config.BiasTee,
+			OffsetTuning: app.config.OffsetTuning,
+		})
+		if err := app.rtlsdr.Configure(app.config.Frequency, app.config.SampleRate, app.config.Gain); err != nil {
+			return fmt.Errorf("failed to configure RTL-SDR: %w", err)
+		}
+		if app.config.ScanOffset {
+			if err := app.scanOffset(); err != nil {
+				return fmt.Errorf("failed to scan frequency offset: %w", err)
+			}
+		}
 	}
+	app.health.MarkConfigured()
 
 	// Initialize ADS-B processor
 	app.adsbProcessor = adsb.NewADSBProcessor(app.config.SampleRate, app.logger)
+	app.adsbProcessor.SetSNRThreshold(app.config.SNRThreshold)
+	app.adsbProcessor.SetErrorCorrection(!app.config.StrictCRC)
+	app.adsbProcessor.SetSinglePhase(app.config.DemodPhases == DemodPhasesSingle)
+	app.adsbProcessor.SetBufferOverlap(!app.config.NoOverlap)
+	app.elm.SetAddresses(app.adsbProcessor.Addresses())
+	iids := make([]uint8, len(app.config.DF11IIDs))
+	for i, iid := range app.config.DF11IIDs {
+		iids[i] = uint8(iid)
+	}
+	app.adsbProcessor.SetDF11Interrogators(app.config.StrictDF11, iids)
+	app.adsbProcessor.SetTracer(app.tracer)
 
 	// Initialize CPR decoder
 	app.cprDecoder = adsb.NewCPRDecoder(app.logger, app.verbose)
+	app.cprDecoder.SetTracer(app.tracer)
+	app.cprDecoder.SetMaxTracked(app.config.MaxTracked)
+	app.cprDecoder.SetRequirePair(app.config.CPRPairOnly)
+	app.cprDecoder.SetPositionMaxAge(app.config.PosMaxAge)
+	app.cprDecoder.SetZoneRetryFrames(app.config.ZoneRetryFrames)
+	if app.hasReceiverPosition() {
+		app.cprDecoder.SetReferencePosition(app.config.Latitude, app.config.Longitude)
+	}
+
+	if app.config.RawSave != "" {
+		limit := int64(app.config.RawSaveMaxMB) * 1024 * 1024
+		if app.rawCapture, err = newRawCapture(app.config.RawSave, limit, app.logger); err != nil {
+			return err
+		}
+	}
+
+	if err := app.initializeOutput(); err != nil {
+		return err
+	}
+
+	return app.initializeServers()
+}
+
+// initializeOutput initializes the log rotator, BaseStation writer and ICAO
+// filter. With --split-by the per-key files replace the combined log, so no
+// rotator is created.
+func (app *Application) initializeOutput() error {
+	if app.splitKey() == nil {
+		if err := app.initializeLogRotator(); err != nil {
+			return err
+		}
+	}
+
+	// Load ICAO filter
+	if err := app.loadICAOFilter(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// initializeLogRotator opens the combined daily log and the BaseStation
+// writer on it
+func (app *Application) initializeLogRotator() error {
+	var err error
 
 	// Initialize log rotator
 	app.logRotator, err = logging.NewLogRotator(app.config.LogDir, app.config.LogRotateUTC, app.logger)
 	if err != nil {
 		return fmt.Errorf("failed to initialize log rotator: %w", err)
 	}
+	if app.config.CompressLive {
+		if err := app.logRotator.SetCompressLive(true); err != nil {
+			return fmt.Errorf("failed to enable live log compression: %w", err)
+		}
+	}
+
+	// CSV files start with a header row, including each rotated file
+	if app.config.OutputFormat == OutputFormatCSV {
+		if err := app.logRotator.SetHeader(csvHeader()); err != nil {
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+	}
 
 	// Initialize BaseStation writer
 	app.baseStation = basestation.NewWriter(app.logRotator, app.logger)
@@ -121,6 +412,81 @@ func (app *Application) initializeComponents() error {
 	return nil
 }
 
+// loadICAOFilter (re)reads the ICAO allow/deny list file, if one was provided
+func (app *Application) loadICAOFilter() error {
+	if app.config.FilterFile == "" {
+		return nil
+	}
+
+	icaoFilter, err := filter.LoadICAOFilter(app.config.FilterFile)
+	if err != nil {
+		return fmt.Errorf("failed to load ICAO filter: %w", err)
+	}
+
+	app.filterMutex.Lock()
+	app.icaoFilter = icaoFilter
+	app.filterMutex.Unlock()
+
+	allow, deny := icaoFilter.Len()
+	app.logger.WithFields(logrus.Fields{
+		"file":  app.config.FilterFile,
+		"allow": allow,
+		"deny":  deny,
+	}).Info("Loaded ICAO filter")
+
+	return nil
+}
+
+// allowsICAO reports whether the ICAO filter lets an aircraft through
+func (app *Application) allowsICAO(icao uint32) bool {
+	app.filterMutex.RLock()
+	defer app.filterMutex.RUnlock()
+	return app.icaoFilter.Allows(icao)
+}
+
+// Reload reopens the current log file and re-reads the ICAO filter without
+// interrupting decoding. A filter that fails to load leaves the old one active.
+func (app *Application) Reload() error {
+	app.logger.Info("Reloading log file and filters")
+
+	if app.logRotator != nil {
+		if err := app.logRotator.Reopen(); err != nil {
+			return fmt.Errorf("failed to reopen log file: %w", err)
+		}
+	}
+	// Split log files are opened again as they are next written
+	if app.splitSink != nil {
+		if err := app.splitSink.Close(); err != nil {
+			return fmt.Errorf("failed to reopen split log files: %w", err)
+		}
+	}
+
+	return app.loadICAOFilter()
+}
+
+// handleReloadSignals calls Reload for each SIGHUP until shutdown
+func (app *Application) handleReloadSignals(hupChan <-chan os.Signal) {
+	for {
+		select {
+		case <-app.ctx.Done():
+			return
+		case <-hupChan:
+			if err := app.Reload(); err != nil {
+				app.logger.WithError(err).Error("Reload failed")
+			}
+		}
+	}
+}
+
+// applyMaxProcs caps GOMAXPROCS at the configured value, when set, and returns
+// the effective parallelism
+func (app *Application) applyMaxProcs() int {
+	if app.config.MaxProcs > 0 {
+		runtime.GOMAXPROCS(app.config.MaxProcs)
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
 // run runs the main application loop
 func (app *Application) run() error {
 	app.logger.Info("Starting RTL-SDR capture and ADS-B demodulation")
@@ -128,29 +494,79 @@ func (app *Application) run() error {
 	// Create data channel for RTL-SDR I/Q samples
 	dataChan := make(chan []byte, 100)
 
-	// Start RTL-SDR data capture
-	app.wg.Add(1)
-	go func() {
-		defer app.wg.Done()
-		if err := app.rtlsdr.StartCapture(app.ctx, dataChan); err != nil {
-			app.logger.WithError(err).Error("RTL-SDR capture failed")
-		}
-	}()
+	if app.config.Stdin {
+		// Read I/Q samples from standard input
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			if err := app.readInput(os.Stdin, dataChan); err != nil {
+				app.logger.WithError(err).Error("Input read failed")
+			}
+		}()
+	} else {
+		// Start RTL-SDR data capture
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			if err := app.rtlsdr.StartCapture(app.ctx, dataChan); err != nil {
+				app.logger.WithError(err).Error("RTL-SDR capture failed")
+			}
+		}()
+	}
 
-	// Start log rotation
-	app.wg.Add(1)
-	go func() {
-		defer app.wg.Done()
-		app.logRotator.Start(app.ctx)
-	}()
+	// Start output writer
+	app.startOutputWriter()
+
+	// Start log rotation; split log files check the date as they are written
+	if app.logRotator != nil {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			app.logRotator.Start(app.ctx)
+		}()
+	}
 
 	// Process I/Q data and demodulate ADS-B
 	app.wg.Add(1)
 	go func() {
 		defer app.wg.Done()
 		app.processIQData(dataChan)
+		close(app.inputDone)
 	}()
 
+	// Start HTTP server
+	if app.httpServer != nil {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			if err := app.httpServer.Start(app.ctx); err != nil {
+				app.logger.WithError(err).Error("HTTP server failed")
+			}
+		}()
+	}
+
+	// Serve output over the Unix socket
+	if app.socketServer != nil {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			if err := app.socketServer.Start(app.ctx); err != nil {
+				app.logger.WithError(err).Error("Output socket failed")
+			}
+		}()
+	}
+
+	// Serve Beast frames to MLAT clients
+	if app.mlatServer != nil {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			if err := app.mlatServer.Start(app.ctx); err != nil {
+				app.logger.WithError(err).Error("MLAT server failed")
+			}
+		}()
+	}
+
 	// Start statistics reporting
 	app.wg.Add(1)
 	go func() {
@@ -167,18 +583,33 @@ func (app *Application) processIQData(dataChan <-chan []byte) {
 	sampleCount := 0
 	dataPackets := 0
 
+	// Reception time is derived from when buffers arrive, not from when they
+	// happen to be processed
+	clock := newReceptionClock(app.config.TimestampSource, app.config.SampleRate)
+
 	for {
 		select {
 		case <-app.ctx.Done():
 			app.logger.Info("I/Q data processing stopped")
 			return
-		case data := <-dataChan:
-			if data == nil {
+		case data, ok := <-dataChan:
+			if !ok {
+				app.logger.Info("I/Q data processing finished")
+				return
+			}
+			// Nothing to decode without a whole I/Q pair
+			samples := len(data) / iqSampleBytes(app.config.IQFormat)
+			if samples == 0 {
 				continue
 			}
+			if app.rawCapture != nil {
+				app.rawCapture.Write(data)
+			}
+
+			baseTime := clock.bufferTime(samples)
 
 			dataPackets++
-			sampleCount += len(data) / 2 // I/Q pairs
+			sampleCount += samples
 
 			// Log periodic statistics
 			if dataPackets%100 == 0 {
@@ -190,7 +621,7 @@ func (app *Application) processIQData(dataChan <-chan []byte) {
 			}
 
 			// Convert raw bytes to I/Q samples
-			iqSamples := app.bytesToIQ(data)
+			iqSamples := app.inputToIQ(data)
 
 			// Log first few samples for debugging
 			if dataPackets <= 3 {
@@ -202,16 +633,27 @@ func (app *Application) processIQData(dataChan <-chan []byte) {
 			}
 
 			// Process with ADS-B decoder
-			messages := app.adsbProcessor.ProcessIQSamples(iqSamples)
+			_, preamblesBefore, _, _, _, _ := app.adsbProcessor.GetStats()
+			messages := app.adsbProcessor.ProcessIQSamples(iqSamples, baseTime)
+			if _, preambles, _, _, _, _ := app.adsbProcessor.GetStats(); len(messages) > 0 || preambles > preamblesBefore {
+				app.health.MarkActivity(time.Now())
+			}
+
+			app.publishStats(uint64(sampleCount))
 
-			// Convert valid messages to SBS format
+			// Convert valid messages to the configured output format
 			for _, msg := range messages {
 				if msg.Valid {
+					app.noteELMAnnouncement(msg)
+					app.writeMLAT(msg)
 					if err := app.writeADSBMessage(msg); err != nil {
-						app.logger.WithError(err).Debug("Failed to write SBS message")
+						app.logger.WithError(err).Debug("Failed to write message")
 					}
+				} else if msg.CRCType == "comm-d" {
+					app.handleELMSegment(msg)
 				}
 			}
+			app.expireELM(baseTime)
 		}
 	}
 }
@@ -229,170 +671,342 @@ func (app *Application) bytesToIQ(data []byte) []complex128 {
 	return samples
 }
 
-// writeADSBMessage converts ADS-B message to SBS format and writes it
+// writeADSBMessage decodes an ADS-B message and queues it for the output sinks
 func (app *Application) writeADSBMessage(msg *adsb.ADSBMessage) error {
-	// Convert ADS-B message to BaseStation format
-	sbs := app.convertToSBS(msg)
-	if sbs == "" {
+	decoded := app.decodeMessage(msg)
+	if decoded == nil {
 		return nil // Skip unsupported message types
 	}
 
-	// Get current writer
-	writer, err := app.logRotator.GetWriter()
-	if err != nil {
-		return fmt.Errorf("failed to get log writer: %w", err)
+	if !app.allowsICAO(decoded.ICAO) {
+		return nil
 	}
 
-	// Write to log and stdout
-	line := sbs + "\n"
-	if _, err := writer.Write([]byte(line)); err != nil {
-		return fmt.Errorf("failed to write to log: %w", err)
+	if ok, reason := app.plausibility.Check(decoded); !ok {
+		app.tracer.Debugf(decoded.ICAO, "Implausible update (%s)", reason)
+		if app.config.Plausibility {
+			return nil
+		}
 	}
 
-	// Also print to stdout like dump1090
-	fmt.Print(line)
+	// Record per-aircraft state and fill in what the aircraft reported earlier
+	app.registry.Update(decoded)
+	app.notePeakAircraft(app.registry.Len())
+	if decoded.RAEvent != "" {
+		app.logger.WithFields(logrus.Fields{
+			"icao":  decoded.Hex,
+			"event": decoded.RAEvent,
+		}).Info("TCAS resolution advisory")
+	}
 
+	app.enqueueOutput(decoded)
 	return nil
 }
 
-// convertToSBS converts ADS-B message to SBS (BaseStation) format
-func (app *Application) convertToSBS(msg *adsb.ADSBMessage) string {
-	now := time.Now().UTC()
-	dateStr := now.Format("2006/01/02")
-	timeStr := now.Format("15:04:05.000")
+// formatMessage renders a decoded message in the configured output format
+func (app *Application) formatMessage(decoded *adsb.DecodedMessage) (string, error) {
+	switch app.config.OutputFormat {
+	case OutputFormatJSON:
+		return app.convertToJSON(decoded)
+	case OutputFormatCSV:
+		return app.convertToCSV(decoded)
+	case OutputFormatGeoJSON:
+		return app.convertToGeoJSON(decoded)
+	default:
+		return app.convertToSBS(decoded), nil
+	}
+}
+
+// convertToSBS converts a decoded message to SBS (BaseStation) format
+func (app *Application) convertToSBS(decoded *adsb.DecodedMessage) string {
+	dateStr := decoded.Timestamp.Format("2006/01/02")
+	timeStr := decoded.Timestamp.Format("15:04:05.000")
 
-	icao := fmt.Sprintf("%06X", msg.GetICAO())
-	df := msg.GetDF()
+	icao := fmt.Sprintf("%06X", decoded.ICAO)
 
 	sessionID := "1"
 	aircraftID := "1"
 	flightID := "1"
 
-	switch df {
-	case 17, 18: // Extended Squitter
-		typeCode := msg.GetTypeCode()
-		transmissionType := "3" // Default to airborne position
+	// SBS has a single altitude column; fall back to geometric height when
+	// no barometric altitude was decoded, as dump1090 does
+	altitude := ""
+	if decoded.Altitude != nil {
+		altitude = fmt.Sprintf("%d", *decoded.Altitude)
+	} else if decoded.AltitudeGeom != nil {
+		altitude = fmt.Sprintf("%d", *decoded.AltitudeGeom)
+	}
 
-		if app.verbose {
-			app.logger.Debugf("Extended Squitter: DF=%d, TypeCode=%d, ICAO=%06X", df, typeCode, msg.GetICAO())
-		}
+	isOnGround := "0"
+	if decoded.OnGround {
+		isOnGround = "1"
+	}
 
-		// Initialize all fields as empty
-		callsign := ""
-		altitude := ""
-		groundSpeed := ""
-		track := ""
-		latitude := ""
-		longitude := ""
-		verticalRate := ""
-		squawk := ""
-		alert := ""
-		emergency := ""
-		spi := ""
-		isOnGround := app.extractGroundState(msg.Data[:])
-
-		// Parse based on type code
-		switch {
-		case typeCode >= 1 && typeCode <= 4:
-			// Aircraft identification
-			transmissionType = "1"
-			callsign = app.extractCallsign(msg.Data[:])
-
-		case typeCode >= 5 && typeCode <= 8:
-			// Surface position
-			transmissionType = "2"
-			isOnGround = "1"
-			if lat, lon := app.extractPosition(msg.Data[:]); lat != 0 || lon != 0 {
-				latitude = fmt.Sprintf("%.6f", lat)
-				longitude = fmt.Sprintf("%.6f", lon)
-			}
+	alert := sbsFlag(decoded.Alert)
+	emergency := sbsFlag(decoded.Emergency != "")
+	spi := sbsFlag(decoded.SPI)
 
-		case typeCode >= 9 && typeCode <= 18:
-			// Airborne position
-			transmissionType = "3"
-			if alt := app.extractAltitude(msg.Data[:]); alt != 0 {
-				altitude = fmt.Sprintf("%d", alt)
-			}
-			// Extract position (lat/lon)
-			if lat, lon := app.extractPosition(msg.Data[:]); lat != 0 || lon != 0 {
-				latitude = fmt.Sprintf("%.6f", lat)
-				longitude = fmt.Sprintf("%.6f", lon)
-			}
+	if decoded.TransmissionType == 5 {
+		return fmt.Sprintf("MSG,%d,%s,%s,%s,%s,%s,%s,%s,%s,,%s,,,,,,%s,%s,%s,%s,%s",
+			decoded.TransmissionType, sessionID, aircraftID, icao, flightID,
+			dateStr, timeStr, dateStr, timeStr,
+			altitude, decoded.Squawk, alert, emergency, spi, isOnGround)
+	}
 
-		case typeCode >= 19 && typeCode <= 22:
-			// Airborne velocity
-			transmissionType = "4"
-			if speed, trk, vrate := app.extractVelocity(msg.Data[:]); speed > 0 || trk > 0 || vrate != 0 {
-				if speed > 0 {
-					groundSpeed = fmt.Sprintf("%d", speed)
-				}
-				if trk > 0 {
-					track = fmt.Sprintf("%.1f", trk)
-				}
-				if vrate != 0 {
-					verticalRate = fmt.Sprintf("%d", vrate)
-				}
-			}
+	groundSpeed := ""
+	if decoded.GroundSpeed != nil {
+		groundSpeed = fmt.Sprintf("%d", *decoded.GroundSpeed)
+	}
+	// MSG,3 carries no track; one fused from velocity is for JSON and CSV
+	track := ""
+	if decoded.Track != nil && decoded.TransmissionType != 3 {
+		track = fmt.Sprintf("%.1f", *decoded.Track)
+	}
+	latitude := ""
+	longitude := ""
+	if decoded.Latitude != nil && decoded.Longitude != nil {
+		precision := app.coordPrecision()
+		latitude = strconv.FormatFloat(*decoded.Latitude, 'f', precision, 64)
+		longitude = strconv.FormatFloat(*decoded.Longitude, 'f', precision, 64)
+	}
+	verticalRate := ""
+	if decoded.VerticalRate != nil {
+		verticalRate = fmt.Sprintf("%d", *decoded.VerticalRate)
+	}
+
+	return fmt.Sprintf("MSG,%d,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s",
+		decoded.TransmissionType, sessionID, aircraftID, icao, flightID,
+		dateStr, timeStr, dateStr, timeStr,
+		decoded.Callsign, altitude, groundSpeed, track, latitude, longitude,
+		verticalRate, decoded.Squawk, alert, emergency, spi, isOnGround)
+}
+
+// coordPrecision returns the decimal places used for output coordinates
+func (app *Application) coordPrecision() int {
+	if app.config.CoordDigits == 0 {
+		return DefaultCoordPrecision
+	}
+	return app.config.CoordDigits
+}
+
+// roundCoord rounds a coordinate to the output precision
+func (app *Application) roundCoord(v float64) float64 {
+	scale := math.Pow10(app.coordPrecision())
+	return math.Round(v*scale) / scale
+}
+
+// sbsFlag renders an SBS boolean field, leaving it empty when not set
+func sbsFlag(set bool) string {
+	if set {
+		return "-1"
+	}
+	return ""
+}
+
+// convertToJSON converts a decoded message to a single line of JSON
+func (app *Application) convertToJSON(decoded *adsb.DecodedMessage) (string, error) {
+	// Round a copy so the registry and other outputs keep full precision
+	rounded := *decoded
+	if decoded.Latitude != nil && decoded.Longitude != nil {
+		lat, lon := app.roundCoord(*decoded.Latitude), app.roundCoord(*decoded.Longitude)
+		rounded.Latitude, rounded.Longitude = &lat, &lon
+	}
+	// RSSI to 0.1 dB like dump1090; a silent preamble (-Inf) has no JSON form
+	rounded.Signal = math.Round(decoded.Signal*10) / 10
+	if math.IsInf(rounded.Signal, 0) || math.IsNaN(rounded.Signal) {
+		rounded.Signal = 0
+	}
+	decoded = &rounded
+
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JSON message: %w", err)
+	}
+	return string(data), nil
+}
+
+// newHTTPServer creates the HTTP server with the health and data endpoints and
+// the status page registered
+func (app *Application) newHTTPServer() *web.Server {
+	server := web.NewServer(app.listenAddr(app.config.HTTPPort), app.logger)
+	server.Handle("/healthz", web.HealthzHandler())
+	server.Handle("/readyz", web.ReadyzHandler(app.ready))
+	server.Handle("/data/receiver.json", web.JSONHandler(app.receiverInfo))
+	server.Handle("/data/stats.json", web.JSONHandler(app.statsReport))
+	server.Handle("/data/track/", web.TrackHandler(app.trackReport))
+	server.Handle("/data/aircraft.json", web.JSONHandler(app.aircraftReport))
+	server.Handle("/", web.UIHandler())
+	return server
+}
+
+// listenAddr returns the host:port a network server should bind for port
+func (app *Application) listenAddr(port int) string {
+	bind := app.config.Bind
+	if bind == "" {
+		bind = DefaultBindAddress
+	}
+	return net.JoinHostPort(bind, strconv.Itoa(port))
+}
+
+// initializeServers binds the configured network listeners so an unusable
+// address fails startup rather than a background goroutine
+func (app *Application) initializeServers() error {
+	if app.config.HTTPPort > 0 {
+		app.httpServer = app.newHTTPServer()
+		if err := app.httpServer.Listen(); err != nil {
+			return fmt.Errorf("failed to start HTTP server: %w", err)
 		}
+	}
 
-		return fmt.Sprintf("MSG,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s",
-			transmissionType, sessionID, aircraftID, icao, flightID,
-			dateStr, timeStr, dateStr, timeStr,
-			callsign, altitude, groundSpeed, track, latitude, longitude,
-			verticalRate, squawk, alert, emergency, spi, isOnGround)
-
-	case 4, 5, 20, 21: // Surveillance replies
-		transmissionType := "5" // Surveillance
-
-		altitude := ""
-		squawk := ""
-		alert := ""
-		emergency := ""
-		spi := ""
-		isOnGround := app.extractGroundState(msg.Data[:])
-
-		if df == 4 || df == 20 {
-			if alt := app.extractAltitude(msg.Data[:]); alt != 0 {
-				altitude = fmt.Sprintf("%d", alt)
-			}
+	if app.config.SBSSocket != "" {
+		app.socketServer = stream.NewUnixServer(app.config.SBSSocket, app.logger)
+		app.socketServer.SetReplay(app.config.ReplayBuffer)
+		if err := app.socketServer.Listen(); err != nil {
+			return fmt.Errorf("failed to start output socket: %w", err)
 		}
+	}
 
-		if df == 5 || df == 21 {
-			if sq := app.extractSquawk(msg.Data[:]); sq != 0 {
-				squawk = fmt.Sprintf("%04d", sq)
-			}
+	if err := app.openUDPSinks(); err != nil {
+		return err
+	}
+
+	if app.config.MLATPort > 0 {
+		app.mlatServer = stream.NewServer(app.listenAddr(app.config.MLATPort), app.logger)
+		app.mlatServer.SetReplay(app.config.ReplayBuffer)
+		if err := app.mlatServer.Listen(); err != nil {
+			return fmt.Errorf("failed to start MLAT server: %w", err)
 		}
+		app.mlatEncoder = beast.NewEncoder()
+		app.mlatEncoder.SetPreserveTimestamps(true)
+	}
+	return nil
+}
 
-		return fmt.Sprintf("MSG,%s,%s,%s,%s,%s,%s,%s,%s,%s,,%s,,,,,%s,%s,%s,%s,%s",
-			transmissionType, sessionID, aircraftID, icao, flightID,
-			dateStr, timeStr, dateStr, timeStr,
-			altitude, squawk, alert, emergency, spi, isOnGround)
+// ready reports receiver readiness for the /readyz probe
+func (app *Application) ready() (bool, string) {
+	timeout := app.config.ReadyTimeout
+	if timeout <= 0 {
+		timeout = DefaultReadyTimeout
 	}
+	return app.health.Ready(time.Now(), timeout)
+}
 
-	return "" // Unsupported message type
+// sdrReopenCount returns how often the RTL-SDR was reopened, zero without a device
+func (app *Application) sdrReopenCount() uint64 {
+	if app.rtlsdr == nil {
+		return 0
+	}
+	return app.rtlsdr.GetReopenCount()
 }
 
-// reportStatistics reports processing statistics periodically
+// pruneInterval is how often per-aircraft state is pruned
+const pruneInterval = 30 * time.Second
+
+// reportStatistics reports processing statistics every stats interval, unless
+// it is zero, and prunes per-aircraft state periodically
 func (app *Application) reportStatistics() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	// A nil channel never fires
+	var statsTick <-chan time.Time
+	if app.config.StatsPeriod > 0 {
+		ticker := time.NewTicker(app.config.StatsPeriod)
+		defer ticker.Stop()
+		statsTick = ticker.C
+	}
 
 	for {
 		select {
 		case <-app.ctx.Done():
 			return
-		case <-ticker.C:
-			total, preambles, valid, corrected, singleBit, twoBit := app.adsbProcessor.GetStats()
-			app.logger.WithFields(logrus.Fields{
-				"total_processed":    total,
-				"preambles_found":    preambles,
-				"valid_messages":     valid,
-				"corrected_messages": corrected,
-				"single_bit_errors":  singleBit,
-				"two_bit_errors":     twoBit,
-				"success_rate":       fmt.Sprintf("%.2f%%", float64(valid)/float64(preambles)*100),
-			}).Info("Enhanced ADS-B processing statistics (dump1090-style)")
+		case <-statsTick:
+			app.logStatistics("Enhanced ADS-B processing statistics (dump1090-style)")
+
+			if app.config.DumpCPR {
+				app.dumpCPRDiagnostics()
+			}
+		case <-prune.C:
+			app.plausibility.Prune(time.Now())
+			app.registry.Prune()
+		}
+	}
+}
+
+// logStatistics logs the processing counters with the given message
+func (app *Application) logStatistics(message string) {
+	total, preambles, valid, corrected, singleBit, twoBit := app.adsbProcessor.GetStats()
+	snrRejects, quietRejects, floorRejects := app.adsbProcessor.GetPreambleRejects()
+	app.logger.WithFields(logrus.Fields{
+		"total_processed":     total,
+		"preambles_found":     preambles,
+		"snr_rejects":         snrRejects,
+		"noise_floor_rejects": floorRejects,
+		"quiet_bit_rejects":   quietRejects,
+		"valid_messages":      valid,
+		"corrected_messages":  corrected,
+		"single_bit_errors":   singleBit,
+		"two_bit_errors":      twoBit,
+		"duplicates":          app.adsbProcessor.GetDuplicateCount(),
+		"reflections":         app.adsbProcessor.GetReflectionCount(),
+		"comm_d_segments":     app.adsbProcessor.GetCommDCount(),
+		"elm_incomplete":      app.elm.GetIncompleteCount(),
+		"elm_unannounced":     app.elm.GetUnannouncedCount(),
+		"implausible":         app.plausibility.GetRejectedCount(),
+		"aircraft":            app.registry.Len(),
+		"top_types":           formatMessageTypes(topMessageTypes(app.adsbProcessor.GetTypeBreakdown(), topMessageTypesCount)),
+		"phases":              formatPhaseStats(app.adsbProcessor.GetPhaseStats()),
+		"output_dropped":      app.GetOutputDropped(),
+		"sdr_reopens":         app.sdrReopenCount(),
+		"success_rate":        successRate(valid, preambles),
+		"noise_floor":         noiseFloor(app.adsbProcessor.GetNoiseFloor()),
+	}).Info(message)
+}
+
+// successRate formats the share of preambles that yielded a valid message,
+// 0.00% before any preamble was found
+func successRate(valid, preambles uint64) string {
+	if preambles == 0 {
+		return "0.00%"
+	}
+	return fmt.Sprintf("%.2f%%", float64(valid)/float64(preambles)*100)
+}
+
+// noiseFloor formats the channel noise floor for the statistics log, "-"
+// before it has been measured
+func noiseFloor(dbfs float64) string {
+	if math.IsInf(dbfs, -1) {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f dBFS", dbfs)
+}
+
+// dumpCPRDiagnostics logs the CPR decoder state for every tracked aircraft
+func (app *Application) dumpCPRDiagnostics() {
+	for _, diag := range app.cprDecoder.GetDiagnostics() {
+		fields := logrus.Fields{
+			"icao":    fmt.Sprintf("%06X", diag.ICAO),
+			"j":       diag.J,
+			"even_nl": diag.EvenNL,
+			"odd_nl":  diag.OddNL,
+			"method":  diag.Method,
+			"reject":  diag.RejectReason,
 		}
+		if diag.EvenFrame != nil {
+			fields["even_lat_cpr"] = diag.EvenFrame.LatCPR
+			fields["even_lon_cpr"] = diag.EvenFrame.LonCPR
+			fields["even_age"] = diag.EvenAge.Round(time.Millisecond).String()
+		}
+		if diag.OddFrame != nil {
+			fields["odd_lat_cpr"] = diag.OddFrame.LatCPR
+			fields["odd_lon_cpr"] = diag.OddFrame.LonCPR
+			fields["odd_age"] = diag.OddAge.Round(time.Millisecond).String()
+		}
+		if diag.LastPos != nil {
+			fields["lat"] = fmt.Sprintf("%.6f", diag.LastPos.Latitude)
+			fields["lon"] = fmt.Sprintf("%.6f", diag.LastPos.Longitude)
+		}
+		app.logger.WithFields(fields).Info("CPR diagnostics")
 	}
 }
 
@@ -414,6 +1028,20 @@ func (app *Application) shutdown() {
 		app.logger.Warn("Shutdown timeout, forcing exit")
 	}
 
+	// Flush queued output and close the sinks, whether or not decoding has
+	// stopped, before the log file under them
+	app.stopOutputWriter()
+
+	if app.adsbProcessor != nil {
+		app.logStatistics("Final ADS-B processing statistics")
+
+		if app.config.StatsCSV != "" {
+			if err := app.appendStatsCSV(app.config.StatsCSV, time.Now()); err != nil {
+				app.logger.WithError(err).Warn("Failed to append run statistics")
+			}
+		}
+	}
+
 	// Cleanup resources
 	if app.rtlsdr != nil {
 		app.rtlsdr.Close()
@@ -421,6 +1049,11 @@ func (app *Application) shutdown() {
 	if app.logRotator != nil {
 		app.logRotator.Close()
 	}
+	if app.rawCapture != nil {
+		if err := app.rawCapture.Close(); err != nil {
+			app.logger.WithError(err).Warn("Failed to close raw capture")
+		}
+	}
 
 	app.logger.Info("Shutdown completed")
 }
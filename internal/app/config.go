@@ -1,20 +1,119 @@
 package app
 
+import (
+	"time"
+
+	"go1090/internal/adsb"
+	"go1090/internal/registry"
+)
+
 // Default configuration constants
 const (
 	DefaultFrequency  = 1090000000 // 1090 MHz
 	DefaultSampleRate = 2400000    // 2.4 MHz (same as dump1090)
 	DefaultGain       = 40         // Manual gain
+
+	DefaultBindAddress = "0.0.0.0" // Listen on all interfaces
+
+	DefaultSNRThreshold       = adsb.DefaultSNRThreshold       // Preamble SNR gate (dB)
+	DefaultMaxTrackedAircraft = adsb.DefaultMaxTrackedAircraft // CPR frame store cap
+	DefaultPositionMaxAge     = adsb.DefaultPositionMaxAge     // Last-position fallback limit
+	DefaultZoneRetryFrames    = adsb.DefaultZoneRetryFrames    // CPR frames kept per parity for zone crossings
+	DefaultTrackHistory       = registry.DefaultTrackHistory   // Positions kept per aircraft
+
+	DefaultStatsInterval = 30 * time.Second // Periodic statistics log
+)
+
+// Decimal places for latitude/longitude in SBS and JSON output
+const (
+	DefaultCoordPrecision = 6
+	MinCoordPrecision     = 3
+	MaxCoordPrecision     = 8
+)
+
+// Output formats
+const (
+	OutputFormatSBS  = "sbs"  // BaseStation MSG lines
+	OutputFormatJSON = "json" // One JSON object per line
+	OutputFormatCSV  = "csv"  // Flat records with a header row per file
+
+	OutputFormatGeoJSON = "geojson" // One GeoJSON Feature per position
+)
+
+// Demodulator phase search modes
+const (
+	DemodPhasesBest   = "best"   // Try phases 4-8 and keep the best message
+	DemodPhasesSingle = "single" // Try only the phase the preamble indicates
 )
 
+// Log file split modes for --split-by
+const (
+	SplitByNone = "none" // One combined daily log
+	SplitByICAO = "icao" // A daily log per aircraft address
+	SplitByType = "type" // A daily log per downlink format
+)
+
+// SplitMaxOpenFiles bounds the log files open at once with --split-by; the
+// least recently written is closed when another is needed
+const SplitMaxOpenFiles = 64
+
 // Config holds application configuration
 type Config struct {
-	Frequency    uint32
-	SampleRate   uint32
-	Gain         int
-	DeviceIndex  int
-	LogDir       string
-	LogRotateUTC bool
-	Verbose      bool
-	ShowVersion  bool
+	Frequency       uint32
+	SampleRate      uint32
+	Gain            int
+	Latitude        float64 // Receiver position; 0,0 means not configured
+	Longitude       float64
+	LocalQNH        float64 // Local QNH (mb) BDS 4,0 pressure settings are checked against; 0 disables
+	DeviceIndex     int
+	PPM             int
+	BiasTee         bool
+	OffsetTuning    bool
+	ScanOffset      bool // Pick the best centre frequency around Frequency at startup
+	LogDir          string
+	LogRotateUTC    bool
+	CompressLive    bool
+	Verbose         bool
+	ShowVersion     bool
+	DumpCPR         bool
+	PositionOnly    bool
+	MergeFrames     bool // Emit position messages only once the CPR position resolves
+	NoMilitary      bool
+	AnonOnly        bool
+	OutputFormat    string
+	EmitRaw         bool   // Carry each message's raw frame in SBS and JSON output
+	PayloadHex      bool   // Carry the raw ME/MB field in JSON output
+	CoordDigits     int    // Decimal places for lat/lon; 0 means the default
+	TimestampSource string // Output timestamp source; empty means monotonic
+	SplitBy         string // Log file split mode; empty means none
+	SNRThreshold    float64
+	StrictCRC       bool
+	DemodPhases     string // Phase search mode; empty means best
+	NoOverlap       bool   // Demodulate each buffer alone, losing frames across boundaries
+	StrictDF11      bool
+	DF11IIDs        []uint
+	Plausibility    bool
+	FilterFile      string
+	TraceICAO       string
+	MaxTracked      int
+	CPRPairOnly     bool
+	PosMaxAge       time.Duration // Last-position fallback limit; 0 disables it
+	ZoneRetryFrames int           // Earlier CPR frames kept per parity; 0 disables zone crossing retries
+	Stdin           bool
+	IQFormat        string // --stdin sample format; empty means u8
+	RawSave         string // Raw I/Q capture path; empty disables it
+	RawSaveMaxMB    int    // Raw capture size limit; 0 is unlimited
+	Bind            string
+	SBSSocket       string
+	SBSUDP          string // host:port sent SBS lines as UDP datagrams; empty disables it
+	JSONUDP         string // host:port sent JSON records as UDP datagrams; empty disables it
+	HTTPPort        int
+	TrackHistory    int // Positions kept per aircraft for /data/track; 0 disables it
+	MLATPort        int
+	ReplayBuffer    int // Recent lines/frames sent to each new stream client; 0 disables it
+	ReadyTimeout    time.Duration
+	StatsPeriod     time.Duration // Periodic statistics interval; 0 disables them
+	StatsCSV        string        // File a statistics row is appended to per run; empty disables it
+	MaxProcs        int           // GOMAXPROCS cap; 0 leaves the Go default
+	Duration        time.Duration
 }
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go1090/internal/adsb"
+)
+
+// csvColumns is the flat CSV record layout, written once as a header per file
+var csvColumns = []string{
+	"timestamp", "icao", "df", "tc", "callsign", "alt", "gs", "track",
+	"vrate", "lat", "lon", "squawk", "signal", "crc",
+}
+
+// csvHeader returns the CSV header row
+func csvHeader() string {
+	return strings.Join(csvColumns, ",")
+}
+
+// convertToCSV converts a decoded message to one CSV record. Absent optional
+// fields are empty; quoting follows encoding/csv.
+func (app *Application) convertToCSV(decoded *adsb.DecodedMessage) (string, error) {
+	record := []string{
+		decoded.Timestamp.Format(time.RFC3339Nano),
+		strings.ToUpper(decoded.Hex),
+		strconv.Itoa(int(decoded.DF)),
+		"",
+		strings.TrimSpace(decoded.Callsign),
+		csvInt(decoded.Altitude),
+		csvInt(decoded.GroundSpeed),
+		csvFloat(decoded.Track, 1),
+		csvInt(decoded.VerticalRate),
+		csvFloat(decoded.Latitude, 5),
+		csvFloat(decoded.Longitude, 5),
+		decoded.Squawk,
+		"",
+		decoded.CRCType,
+	}
+	if decoded.TypeCode != 0 {
+		record[3] = strconv.Itoa(int(decoded.TypeCode))
+	}
+	if decoded.Signal != 0 && !math.IsInf(decoded.Signal, 0) {
+		record[12] = strconv.FormatFloat(decoded.Signal, 'f', 1, 64)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(record); err != nil {
+		return "", fmt.Errorf("failed to encode CSV record: %w", err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("failed to encode CSV record: %w", err)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// csvInt formats an optional integer field
+func csvInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+// csvFloat formats an optional float field with the given precision
+func csvFloat(v *float64, prec int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', prec, 64)
+}
@@ -0,0 +1,263 @@
+package app
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"go1090/internal/adsb"
+)
+
+// decodeMessage extracts the output fields from an ADS-B message, returning nil
+// for downlink formats that are not reported
+func (app *Application) decodeMessage(msg *adsb.ADSBMessage) *adsb.DecodedMessage {
+	df := msg.GetDF()
+	icao := msg.GetICAO()
+
+	// Prefer the sample-clock reception time over processing time
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	decoded := &adsb.DecodedMessage{
+		Timestamp:   timestamp.UTC(),
+		ICAO:        icao,
+		Hex:         fmt.Sprintf("%06x", icao),
+		Country:     adsb.CountryForICAO(icao),
+		DF:          df,
+		Signal:      msg.Signal,
+		CRCType:     msg.CRCType,
+		Confidence:  adsb.Confidence(msg),
+		Spontaneous: msg.IsSpontaneous(),
+	}
+	decoded.OnGround, decoded.GroundKnown = app.extractGroundState(msg.Frame())
+	if app.config.EmitRaw {
+		decoded.Raw = fmt.Sprintf("%X", msg.Frame())
+	}
+
+	switch df {
+	case 17, 18: // Extended Squitter
+		// Coarse TIS-B and management messages carry no type code
+		if !msg.HasTypeCodeFormat() {
+			return nil
+		}
+		decoded.Source = msg.Source()
+		app.decodeExtendedSquitter(msg, decoded)
+	case 4, 5, 20, 21: // Surveillance replies
+		app.decodeSurveillance(msg, decoded)
+	default:
+		return nil // Unsupported message type
+	}
+
+	if app.config.PayloadHex {
+		app.decodePayloadHex(msg, decoded)
+	}
+
+	return decoded
+}
+
+// decodePayloadHex adds the 56-bit ME field of an extended squitter or MB
+// field of a Comm-B reply in hex, for registers that are not decoded
+func (app *Application) decodePayloadHex(msg *adsb.ADSBMessage, decoded *adsb.DecodedMessage) {
+	data := msg.Frame()
+	if !hasME(data) {
+		return
+	}
+	payload := hex.EncodeToString(data[4:11])
+	switch decoded.DF {
+	case 17, 18:
+		decoded.MEHex = payload
+	case 20, 21:
+		decoded.MBHex = payload
+	}
+}
+
+// decodeExtendedSquitter fills in the fields carried by a DF17/18 message
+func (app *Application) decodeExtendedSquitter(msg *adsb.ADSBMessage, decoded *adsb.DecodedMessage) {
+	data := msg.Frame()
+	typeCode := msg.GetTypeCode()
+	decoded.TypeCode = typeCode
+	decoded.TransmissionType = 3 // Default to airborne position
+
+	app.tracer.Debugf(decoded.ICAO, "Extended Squitter: DF=%d, TypeCode=%d, ICAO=%06X, source=%s", decoded.DF, typeCode, decoded.ICAO, decoded.Source)
+
+	// Parse based on type code
+	switch {
+	case typeCode >= 1 && typeCode <= 4:
+		// Aircraft identification
+		decoded.TransmissionType = 1
+		decoded.Callsign = app.extractCallsign(data)
+
+	case typeCode >= 5 && typeCode <= 8:
+		// Surface position
+		decoded.TransmissionType = 2
+		decoded.OnGround = true
+		speed, heading, ok := app.extractSurfaceMovement(data)
+		if ok {
+			gs := int(math.Round(speed))
+			decoded.GroundSpeed = &gs
+		}
+		if heading >= 0 {
+			decoded.Track = &heading
+		}
+		app.decodePosition(msg, decoded)
+
+	case typeCode >= 9 && typeCode <= 18:
+		// Airborne position with barometric altitude
+		decoded.TransmissionType = 3
+		if alt := app.extractAltitude(data); alt != 0 {
+			decoded.Altitude = &alt
+		}
+		app.decodeSurveillanceStatus(msg, decoded)
+		app.decodePosition(msg, decoded)
+
+	case typeCode >= 20 && typeCode <= 22:
+		// Airborne position with GNSS (geometric) height
+		decoded.TransmissionType = 3
+		if alt := app.extractAltitude(data); alt != 0 {
+			decoded.AltitudeGeom = &alt
+		}
+		app.decodeSurveillanceStatus(msg, decoded)
+		app.decodePosition(msg, decoded)
+
+	case typeCode == 31:
+		// Aircraft operational status
+		if version, ok := app.extractADSBVersion(data); ok {
+			decoded.ADSBVersion = &version
+		}
+		if size, ok := app.extractAircraftSize(data); ok {
+			decoded.Size = &size
+		}
+		if eq, ok := app.extractOpStatusEquipage(data); ok {
+			decoded.TCAS = eq.tcas
+			decoded.ES1090In = eq.es1090In
+			decoded.UATIn = eq.uatIn
+			decoded.RAActive = eq.raActive
+		}
+		if nicBaro, ok := app.extractNICBaro(data); ok {
+			decoded.NICBaro = &nicBaro
+		}
+		decoded.NICSuppA, decoded.NICSuppC = app.extractNICSupplements(data)
+
+	case typeCode == 28:
+		// Aircraft status: only the resolution advisory subtype is decoded
+		if ra, ok := app.extractACASRA(data); ok {
+			decoded.ACASRA = &ra
+		}
+
+	case typeCode == 19:
+		// Airborne velocity
+		decoded.TransmissionType = 4
+		speed, trk, _ := app.extractVelocity(data)
+		if speed > 0 {
+			decoded.GroundSpeed = &speed
+		}
+		if trk > 0 {
+			decoded.Track = &trk
+		}
+		if vrate, ok := app.extractVerticalRate(data); ok {
+			decoded.VerticalRate = &vrate
+		}
+		intentChange, ifrCapable := app.extractVelocityFlags(data)
+		decoded.IntentChange = &intentChange
+		decoded.IFRCapable = &ifrCapable
+	}
+}
+
+// decodeSurveillanceStatus maps the airborne position surveillance status to
+// the alert and SPI flags reported in SBS output
+func (app *Application) decodeSurveillanceStatus(msg *adsb.ADSBMessage, decoded *adsb.DecodedMessage) {
+	status, singleAntenna := app.extractSurveillanceStatus(msg.Frame())
+	switch status {
+	case surveillancePermanentAlert, surveillanceTemporaryAlert:
+		decoded.Alert = true
+	case surveillanceSPI:
+		decoded.SPI = true
+	}
+	decoded.SingleAntenna = &singleAntenna
+}
+
+// decodePosition fills in latitude/longitude when the CPR position is known
+func (app *Application) decodePosition(msg *adsb.ADSBMessage, decoded *adsb.DecodedMessage) {
+	if lat, lon := app.extractPosition(msg.Frame()); lat != 0 || lon != 0 {
+		decoded.Latitude = &lat
+		decoded.Longitude = &lon
+	}
+}
+
+// decodeSurveillance fills in the fields carried by a DF4/5/20/21 reply
+func (app *Application) decodeSurveillance(msg *adsb.ADSBMessage, decoded *adsb.DecodedMessage) {
+	data := msg.Frame()
+	decoded.TransmissionType = 5 // Surveillance
+
+	// The flight status flags a changed identity and SPI, which dump1090
+	// reports in the SBS alert and SPI columns
+	switch extractFlightStatus(data) {
+	case fsAlertAirborne, fsAlertOnGround:
+		decoded.Alert = true
+	case fsAlertSPI:
+		decoded.Alert = true
+		decoded.SPI = true
+	case fsSPI:
+		decoded.SPI = true
+	}
+
+	if decoded.DF == 4 || decoded.DF == 20 {
+		if alt := app.extractAltitude(data); alt != 0 {
+			decoded.Altitude = &alt
+		}
+
+		// An alert flags a changed Mode A code, which an altitude reply
+		// does not carry: the aircraft's latest code tells whether the
+		// change was to an emergency one
+		if decoded.Alert {
+			if ac, ok := app.registry.Get(decoded.ICAO); ok {
+				if sq, err := strconv.Atoi(ac.Squawk); err == nil {
+					decoded.Emergency = adsb.SquawkEmergency(sq)
+				}
+			}
+		}
+	}
+
+	if decoded.DF == 5 || decoded.DF == 21 {
+		if sq := app.extractSquawk(data); sq != 0 {
+			decoded.Squawk = fmt.Sprintf("%04d", sq)
+
+			// Special squawks 7500/7600/7700 signal an emergency
+			if emergency := adsb.SquawkEmergency(sq); emergency != "" {
+				decoded.Emergency = emergency
+				decoded.Alert = true
+			}
+		}
+	}
+
+	// Comm-B replies may carry the selected vertical intention, track and
+	// turn or heading and speed register
+	if mb := msg.GetMB(); mb != nil {
+		bds40, is40 := adsb.DecodeBDS40(mb)
+		if is40 {
+			decoded.NavAltitudeMCP = bds40.MCPAltitude
+			decoded.NavAltitudeFMS = bds40.FMSAltitude
+			decoded.NavQNH = bds40.BaroSetting
+			if app.config.LocalQNH != 0 && bds40.BaroSetting != nil {
+				decoded.NavQNHOutlier = adsb.QNHOutlier(*bds40.BaroSetting, app.config.LocalQNH)
+			}
+		}
+
+		// The replies do not name their register, so BDS 5,0 and 6,0 are
+		// taken only when the field fits exactly one of the three
+		bds50, is50 := adsb.DecodeBDS50(mb)
+		bds60, is60 := adsb.DecodeBDS60(mb)
+		switch {
+		case is40 || (is50 && is60):
+		case is50:
+			decoded.Roll = bds50.Roll
+			decoded.TrueTrack = bds50.TrueTrack
+		case is60:
+			decoded.MagHeading = bds60.MagHeading
+		}
+	}
+}
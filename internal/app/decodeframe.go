@@ -0,0 +1,99 @@
+package app
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"go1090/internal/adsb"
+)
+
+// DecodeFrame decodes a single hex frame, as pasted from another decoder,
+// and writes its fields to w one per line. AVR framing ("*...;") is
+// accepted. The frame is CRC-checked, with one- and two-bit errors corrected
+// as in live decoding; an error is returned when it cannot be validated. No
+// RTL-SDR is needed.
+func DecodeFrame(w io.Writer, frame string) error {
+	frame = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(frame), "*"), ";")
+	data, err := hex.DecodeString(frame)
+	if err != nil {
+		return fmt.Errorf("failed to parse frame %s: %w", frame, err)
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("empty frame")
+	}
+	df := adsb.DecodeDF(data[0])
+	if n := adsb.MessageLength(df); len(data) != n {
+		return fmt.Errorf("DF%d frame is %d bytes, expected %d", df, len(data), n)
+	}
+
+	msg := &adsb.ADSBMessage{}
+	copy(msg.Data[:], data)
+	adsb.ValidateMessage(msg, true)
+	if !msg.Valid {
+		return fmt.Errorf("DF%d frame failed CRC check (%s, residual %06X)", df, msg.CRCType, msg.CRC)
+	}
+
+	app := NewApplication(Config{SampleRate: DefaultSampleRate})
+	app.logger.SetOutput(io.Discard)
+	// Without a receiver position a lone CPR frame cannot be placed; the
+	// decoder's default reference would invent one
+	app.cprDecoder = adsb.NewCPRDecoder(app.logger, false)
+	app.cprDecoder.SetRequirePair(true)
+
+	decoded := app.decodeMessage(msg)
+	if decoded == nil {
+		return fmt.Errorf("DF%d frame carries nothing to decode", df)
+	}
+
+	fields, err := decodedFields(decoded)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "frame:\t%X\n", msg.Frame())
+	fmt.Fprintf(tw, "crc:\t%s\n", msg.CRCType)
+	for _, field := range fields {
+		fmt.Fprintf(tw, "%s:\t%s\n", field[0], field[1])
+	}
+	if decoded.IsPosition() && decoded.Latitude == nil {
+		fmt.Fprintf(tw, "position:\tneeds the matching even/odd CPR frame\n")
+	}
+	return tw.Flush()
+}
+
+// decodedFields lists the fields present in decoded as name and value pairs
+// in JSON output order, leaving out the timestamp
+func decodedFields(decoded *adsb.DecodedMessage) ([][2]string, error) {
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode decoded message: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil { // Opening brace
+		return nil, fmt.Errorf("failed to read decoded message: %w", err)
+	}
+
+	var fields [][2]string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read decoded message: %w", err)
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to read decoded message: %w", err)
+		}
+		if key == "timestamp" {
+			continue
+		}
+		fields = append(fields, [2]string{key.(string), strings.TrimSpace(strings.Trim(string(value), `"`))})
+	}
+	return fields, nil
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"go1090/internal/adsb"
+)
+
+// handleELMSegment passes a Comm-D segment to the reassembler, writing the
+// transfer it finishes, if any
+func (app *Application) handleELMSegment(msg *adsb.ADSBMessage) {
+	seg, ok := adsb.ParseELMSegment(msg)
+	if !ok {
+		return
+	}
+	if elm := app.elm.Add(seg); elm != nil {
+		app.writeELM(elm)
+	}
+}
+
+// noteELMAnnouncement tells the reassembler of the downlink ELM a reply
+// announces, if any, so a single-segment transfer from the aircraft is
+// accepted
+func (app *Application) noteELMAnnouncement(msg *adsb.ADSBMessage) {
+	if adsb.ELMAnnounced(msg) > 0 {
+		app.elm.Announce(msg.GetICAO(), msg.Timestamp)
+	}
+}
+
+// expireELM writes every transfer that has gone quiet by now
+func (app *Application) expireELM(now time.Time) {
+	for _, elm := range app.elm.Expire(now) {
+		app.writeELM(elm)
+	}
+}
+
+// writeELM queues a reassembled ELM transfer as a DF24 record. SBS has no
+// message type for it, so it appears in JSON and CSV output only.
+func (app *Application) writeELM(elm *adsb.ELMMessage) {
+	switch app.config.OutputFormat {
+	case OutputFormatJSON, OutputFormatCSV:
+	default:
+		return
+	}
+	if !app.allowsICAO(elm.ICAO) {
+		return
+	}
+
+	app.tracer.Debugf(elm.ICAO, "Comm-D ELM: ICAO=%06X, segments=%d", elm.ICAO, elm.Segments)
+	app.enqueueOutput(&adsb.DecodedMessage{
+		Timestamp:   elm.Timestamp.UTC(),
+		ICAO:        elm.ICAO,
+		Hex:         fmt.Sprintf("%06x", elm.ICAO),
+		Country:     adsb.CountryForICAO(elm.ICAO),
+		DF:          24,
+		ELM:         hex.EncodeToString(elm.Payload),
+		ELMSegments: elm.Segments,
+	})
+}
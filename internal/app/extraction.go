@@ -1,21 +1,31 @@
 package app
 
 import (
+	"fmt"
 	"math"
 	"strings"
 
 	"go1090/internal/adsb"
 )
 
+// hasME reports whether data is a long frame, carrying the 56-bit ME field in
+// bytes 4-10. Short frames (DF0/4/5/11) end after 7 bytes, so their padding
+// must never be read as ME bits.
+func hasME(data []byte) bool {
+	return len(data) >= 11 && adsb.MessageLength(adsb.DecodeDF(data[0])) == adsb.LongMessageBytes
+}
+
 // extractCallsign extracts callsign from aircraft identification message (dump1090 style)
 func (app *Application) extractCallsign(data []byte) string {
-	if len(data) < 11 {
+	if !hasME(data) {
 		return ""
 	}
 
+	icao := app.extractICAO(data)
+
 	// Debug: print the raw data for analysis
-	if app.verbose {
-		app.logger.Debugf("Callsign raw data: %x", data[:11])
+	if app.tracer.Enabled(icao) {
+		app.tracer.Debugf(icao, "Callsign raw data: %x", data[:11])
 	}
 
 	// ME (Message Extended) field starts at byte 4 for DF17/18
@@ -49,15 +59,15 @@ func (app *Application) extractCallsign(data []byte) string {
 	}
 
 	if !valid {
-		if app.verbose {
-			app.logger.Debugf("Invalid callsign characters detected")
+		if app.tracer.Enabled(icao) {
+			app.tracer.Debugf(icao, "Invalid callsign characters detected")
 		}
 		return ""
 	}
 
 	result := strings.TrimSpace(string(callsign[:8]))
-	if app.verbose {
-		app.logger.Debugf("Extracted callsign: '%s'", result)
+	if app.tracer.Enabled(icao) {
+		app.tracer.Debugf(icao, "Extracted callsign: '%s'", result)
 	}
 	return result
 }
@@ -153,66 +163,30 @@ func (app *Application) getBitsUint16(data []byte, firstBit, lastBit int) uint16
 	return uint16((result >> shift) & ((1 << nbi) - 1))
 }
 
-// extractAltitude extracts altitude from surveillance or position messages
+// extractAltitude extracts altitude in feet from surveillance replies (AC13,
+// bits 20-32) or airborne position squitters (AC12, ME bits 9-20). It returns
+// 0 when the altitude is unavailable.
 func (app *Application) extractAltitude(data []byte) int {
-	if len(data) < 6 {
+	if len(data) < 7 {
 		return 0
 	}
 
-	// Extract 13-bit altitude field (different positions for different message types)
-	df := (data[0] >> 3) & 0x1F
-
-	var altCode uint16
-
-	if df == 4 || df == 20 {
-		// Surveillance altitude reply - bits 20-32
-		altCode = (uint16(data[2]&0x1F) << 8) | uint16(data[3])
-	} else if df == 17 || df == 18 {
-		// Extended squitter - altitude is in ME field bits 9-20 (AC12 field)
-		// ME starts at byte 4, so bits 9-20 of ME are in bytes 5-6 of the full message
-		// Extract 12-bit AC12 field properly
-		altCode = (uint16(data[5]&0x1F) << 7) | (uint16(data[6]) >> 1)
-	} else {
-		return 0
+	var (
+		feet int
+		ok   bool
+	)
+	switch df := (data[0] >> 3) & 0x1F; df {
+	case 0, 4, 16, 20:
+		feet, ok = adsb.DecodeAC13(uint16(data[2]&0x1F)<<8 | uint16(data[3]))
+	case 17, 18:
+		if hasME(data) {
+			feet, ok = adsb.DecodeAC12(uint16(data[5])<<4 | uint16(data[6])>>4)
+		}
 	}
-
-	if altCode == 0 {
+	if !ok {
 		return 0
 	}
-
-	// Decode altitude using dump1090's AC12 method
-	// Check Q-bit (bit 4 of the 12-bit field)
-	qBit := (altCode & 0x10) != 0
-
-	if qBit {
-		// 25-foot resolution encoding (dump1090's decodeAC12Field)
-		// N is the 11 bit integer resulting from the removal of bit Q at bit 4
-		n := ((altCode & 0x0FE0) >> 1) | (altCode & 0x000F)
-		// The final altitude is the resulting number multiplied by 25, minus 1000
-		return int(n)*25 - 1000
-	} else {
-		// 100-foot resolution (Gillham Mode C encoding)
-		// Make N a 13 bit Gillham coded altitude by inserting M=0 at bit 6
-		n13 := ((altCode & 0x0FC0) << 1) | (altCode & 0x003F)
-
-		if n13 == 0 {
-			return 0
-		}
-
-		// Simplified conversion - convert to 500ft increments first
-		hundreds := int((n13 >> 8) & 0x07)
-		fiveHundreds := int((n13 >> 4) & 0x0F)
-
-		// Combine and convert to feet (each unit = 100ft)
-		altitude := (fiveHundreds*5 + hundreds) * 100
-
-		// Sanity check - reject unrealistic altitudes
-		if altitude < -2000 || altitude > 60000 {
-			return 0
-		}
-
-		return altitude
-	}
+	return feet
 }
 
 // extractSquawk extracts squawk code from surveillance messages
@@ -221,24 +195,20 @@ func (app *Application) extractSquawk(data []byte) int {
 		return 0
 	}
 
-	// Extract 13-bit identity field
+	// Extract 13-bit identity field (bits 20-32)
 	identity := (uint16(data[2]&0x1F) << 8) | uint16(data[3])
 
-	// Convert to 4-digit squawk code
-	squawk := 0
-	squawk += int((identity>>adsb.SquawkA4A2A1Shift)&adsb.SquawkA4A2A1Mask) * adsb.SquawkAMultiplier // A4 A2 A1
-	squawk += int((identity>>adsb.SquawkB4B2B1Shift)&adsb.SquawkB4B2B1Mask) * adsb.SquawkBMultiplier // B4 B2 B1
-	squawk += int((identity>>adsb.SquawkC4C2C1Shift)&adsb.SquawkC4C2C1Mask) * adsb.SquawkCMultiplier // C4 C2 C1
-	squawk += int((identity>>adsb.SquawkD4D2D1Shift)&adsb.SquawkD4D2D1Mask) * adsb.SquawkDMultiplier // D4 D2 D1
-
-	return squawk
+	// Undo the Mode A bit interleaving to get the 4-digit squawk code
+	return adsb.DecodeID13(identity)
 }
 
 // extractVelocity extracts velocity information from airborne velocity messages
 func (app *Application) extractVelocity(data []byte) (int, float64, int) {
-	if len(data) < 11 {
-		if app.verbose {
-			app.logger.Debugf("Velocity extraction failed: data too short (%d bytes)", len(data))
+	icao := app.extractICAO(data)
+
+	if !hasME(data) {
+		if app.tracer.Enabled(icao) {
+			app.tracer.Debugf(icao, "Velocity extraction failed: not a long frame (%d bytes)", len(data))
 		}
 		return 0, 0, 0
 	}
@@ -246,13 +216,13 @@ func (app *Application) extractVelocity(data []byte) (int, float64, int) {
 	// Extract velocity subtype
 	subtype := (data[4] >> 1) & 0x07
 
-	if app.verbose {
-		app.logger.Debugf("Velocity message: subtype=%d, data=%x", subtype, data[:11])
+	if app.tracer.Enabled(icao) {
+		app.tracer.Debugf(icao, "Velocity message: subtype=%d, data=%x", subtype, data[:11])
 	}
 
 	if subtype < 1 || subtype > 4 {
-		if app.verbose {
-			app.logger.Debugf("Velocity extraction failed: unsupported subtype %d (only 1-4 supported)", subtype)
+		if app.tracer.Enabled(icao) {
+			app.tracer.Debugf(icao, "Velocity extraction failed: unsupported subtype %d (only 1-4 supported)", subtype)
 		}
 		return 0, 0, 0 // Only handle groundspeed and airspeed subtypes (1-4)
 	}
@@ -270,8 +240,8 @@ func (app *Application) extractVelocity(data []byte) (int, float64, int) {
 		// Extract north-south velocity (bits 26-35 of ME)
 		nsRaw := app.getBitsUint16(me, 26, 35)
 
-		if app.verbose {
-			app.logger.Debugf("Ground speed components: ewDir=%d, ewVel=%d, nsDir=%d, nsVel=%d",
+		if app.tracer.Enabled(icao) {
+			app.tracer.Debugf(icao, "Ground speed components: ewDir=%d, ewVel=%d, nsDir=%d, nsVel=%d",
 				app.getBits(me, 14, 14), ewRaw, app.getBits(me, 25, 25), nsRaw)
 		}
 
@@ -296,8 +266,8 @@ func (app *Application) extractVelocity(data []byte) (int, float64, int) {
 					track += 360
 				}
 
-				if app.verbose {
-					app.logger.Debugf("Valid ground speed: %d kt, track: %.1f°", groundSpeed, track)
+				if app.tracer.Enabled(icao) {
+					app.tracer.Debugf(icao, "Valid ground speed: %d kt, track: %.1f°", groundSpeed, track)
 				}
 			}
 		}
@@ -320,31 +290,22 @@ func (app *Application) extractVelocity(data []byte) (int, float64, int) {
 			// But we can use airspeed as an approximation
 			groundSpeed = airspeed
 
-			if app.verbose {
-				app.logger.Debugf("Airspeed data: airspeed=%d, heading=%.1f", airspeed, track)
+			if app.tracer.Enabled(icao) {
+				app.tracer.Debugf(icao, "Airspeed data: airspeed=%d, heading=%.1f", airspeed, track)
 				if groundSpeed > 0 {
-					app.logger.Debugf("Using airspeed as ground speed: %d kt", groundSpeed)
+					app.tracer.Debugf(icao, "Using airspeed as ground speed: %d kt", groundSpeed)
 				}
 			}
 		}
 	}
 
-	// Extract vertical rate (common for all subtypes) - dump1090 method
-	me := data[4:]
-	vrRaw := app.getBitsUint16(me, 38, 46) // bits 38-46 of ME
-
-	var verticalRate int
-	if vrRaw != 0 {
-		verticalRate = int(vrRaw-1) * 64
-		if app.getBits(me, 37, 37) != 0 { // sign bit 37
-			verticalRate = -verticalRate
-		}
-	}
+	// Vertical rate is common to all subtypes; 0 when not available
+	verticalRate, _ := app.extractVerticalRate(data)
 
-	if app.verbose {
-		app.logger.Debugf("Velocity result: groundSpeed=%d, track=%.1f, verticalRate=%d", groundSpeed, track, verticalRate)
+	if app.tracer.Enabled(icao) {
+		app.tracer.Debugf(icao, "Velocity result: groundSpeed=%d, track=%.1f, verticalRate=%d", groundSpeed, track, verticalRate)
 		if groundSpeed == 0 && track == 0 && verticalRate == 0 {
-			app.logger.Debugf("All velocity values are zero - check message parsing")
+			app.tracer.Debugf(icao, "All velocity values are zero - check message parsing")
 		}
 	}
 
@@ -360,7 +321,7 @@ func (app *Application) extractVelocity(data []byte) (int, float64, int) {
 
 // extractPosition extracts latitude and longitude from position messages
 func (app *Application) extractPosition(data []byte) (float64, float64) {
-	if len(data) < 11 {
+	if !hasME(data) {
 		return 0, 0
 	}
 
@@ -375,8 +336,8 @@ func (app *Application) extractPosition(data []byte) (float64, float64) {
 	// Extract CPR longitude (17 bits)
 	cprLonRaw := ((uint32(data[8]&0x01) << 16) | (uint32(data[9]) << 8) | uint32(data[10])) & 0x1FFFF
 
-	if app.verbose {
-		app.logger.Debugf("CPR position data: ICAO=%06X, F=%d, lat_cpr=%d (%.6f), lon_cpr=%d (%.6f)",
+	if app.tracer.Enabled(icao) {
+		app.tracer.Debugf(icao, "CPR position data: ICAO=%06X, F=%d, lat_cpr=%d (%.6f), lon_cpr=%d (%.6f)",
 			icao, fFlag, cprLatRaw, float64(cprLatRaw)/adsb.CPR_LAT_MAX, cprLonRaw, float64(cprLonRaw)/adsb.CPR_LON_MAX)
 	}
 
@@ -386,54 +347,340 @@ func (app *Application) extractPosition(data []byte) (float64, float64) {
 
 // extractICAO extracts the ICAO address from the message
 func (app *Application) extractICAO(data []byte) uint32 {
-	if len(data) < 4 {
-		return 0
+	return adsb.FrameICAO(data)
+}
+
+// extractVelocityFlags extracts the intent change (ME bit 9) and IFR capability
+// (ME bit 10) flags from the common header of an airborne velocity message
+func (app *Application) extractVelocityFlags(data []byte) (bool, bool) {
+	if !hasME(data) {
+		return false, false
 	}
-	return (uint32(data[1]) << 16) | (uint32(data[2]) << 8) | uint32(data[3])
+	me := data[4:]
+	return app.getBits(me, 9, 9) != 0, app.getBits(me, 10, 10) != 0
 }
 
-// extractGroundState extracts ground/airborne state with improved accuracy
-func (app *Application) extractGroundState(data []byte) string {
-	if len(data) < 5 {
-		return "0" // Default to airborne
+// Surveillance status of an airborne position message (ME bits 6-7)
+const (
+	surveillanceNoCondition    = 0
+	surveillancePermanentAlert = 1 // Emergency condition
+	surveillanceTemporaryAlert = 2 // Mode A identity changed
+	surveillanceSPI            = 3 // Special position identification
+)
+
+// extractSurveillanceStatus extracts the surveillance status (ME bits 6-7) and
+// single antenna flag (ME bit 8) from an airborne position message. From
+// ADS-B version 2 bit 8 is the NIC supplement-B instead.
+func (app *Application) extractSurveillanceStatus(data []byte) (status uint8, singleAntenna bool) {
+	if !hasME(data) {
+		return surveillanceNoCondition, false
 	}
+	me := data[4:]
+	return app.getBits(me, 6, 7), app.getBits(me, 8, 8) != 0
+}
 
-	df := (data[0] >> 3) & 0x1F
+// extractVerticalRate extracts the vertical rate in ft/min from an airborne
+// velocity message (sign ME bit 37, magnitude ME bits 38-46). A raw magnitude
+// of 0 means no information and gives ok false; 1 is level flight, so a
+// reported 0 ft/min is distinct from an unknown rate.
+func (app *Application) extractVerticalRate(data []byte) (rate int, ok bool) {
+	if !hasME(data) {
+		return 0, false
+	}
+	me := data[4:]
 
-	// For surveillance messages, check VS and FS bits
-	if df == 4 || df == 5 || df == 20 || df == 21 {
-		// VS (Vertical Status) bit - bit 6
-		vs := (data[0] >> 2) & 0x01
-		if vs == 1 {
-			return "1" // On ground
-		}
+	raw := app.getBitsUint16(me, 38, 46)
+	if raw == 0 {
+		return 0, false
+	}
+	rate = int(raw-1) * 64
+	if app.getBits(me, 37, 37) != 0 {
+		rate = -rate
+	}
+	return rate, true
+}
 
-		// Also check FS (Flight Status)
-		fs := (data[0] >> 3) & 0x07
-		if fs == 1 || fs == 3 {
-			return "1" // On ground
-		}
+// extractSurfaceMovement extracts ground speed (ME bits 6-12) and ground track
+// (ME bits 14-20, valid when status bit 13 is set) from a surface position
+// message. ok is false when the movement is not available; heading is -1 when
+// the track is not valid.
+func (app *Application) extractSurfaceMovement(data []byte) (speedKt float64, heading float64, ok bool) {
+	if !hasME(data) {
+		return 0, -1, false
+	}
+	me := data[4:]
+
+	heading = -1
+	if app.getBits(me, 13, 13) != 0 {
+		heading = float64(app.getBits(me, 14, 20)) * 360.0 / 128.0
+	}
+
+	speedKt, ok = surfaceMovementSpeed(app.getBits(me, 6, 12))
+	return speedKt, heading, ok
+}
+
+// surfaceMovementSpeed converts a surface movement code to the lower bound of
+// its speed band in knots. The quantization steps coarsen with speed.
+func surfaceMovementSpeed(movement uint8) (float64, bool) {
+	m := float64(movement)
+	switch {
+	case movement == 0 || movement > 124:
+		return 0, false // Not available or reserved
+	case movement == 1:
+		return 0, true // Stopped
+	case movement <= 8:
+		return (m - 1) * 0.125, true
+	case movement <= 12:
+		return 1 + (m-9)*0.25, true
+	case movement <= 38:
+		return 2 + (m-13)*0.5, true
+	case movement <= 93:
+		return 15 + (m - 39), true
+	case movement <= 108:
+		return 70 + (m-94)*2, true
+	case movement <= 123:
+		return 100 + (m-109)*5, true
+	default:
+		return 175, true // 175 kt or more
+	}
+}
+
+// extractADSBVersion extracts the ADS-B version number (ME bits 41-43) from an
+// airborne or surface operational status message (TC31 subtype 0 or 1)
+func (app *Application) extractADSBVersion(data []byte) (int, bool) {
+	if !hasME(data) {
+		return 0, false
+	}
+	me := data[4:]
+	if app.getBits(me, 1, 5) != 31 || app.getBits(me, 6, 8) > 1 {
+		return 0, false
 	}
+	return int(app.getBits(me, 41, 43)), true
+}
 
-	// For extended squitter messages
-	if df == 17 || df == 18 {
-		typeCode := (data[4] >> 3) & 0x1F
+// extractAircraftSize extracts the length/width code (ME bits 21-24) from a
+// surface operational status message (TC31 subtype 1). Version 0 messages do
+// not carry it.
+func (app *Application) extractAircraftSize(data []byte) (adsb.AircraftSize, bool) {
+	if !hasME(data) {
+		return adsb.AircraftSize{}, false
+	}
+	me := data[4:]
+	if app.getBits(me, 1, 5) != 31 || app.getBits(me, 6, 8) != 1 || app.getBits(me, 41, 43) == 0 {
+		return adsb.AircraftSize{}, false
+	}
+	return adsb.AircraftSizeFromCode(app.getBits(me, 21, 24))
+}
+
+// opStatusEquipage is the equipage reported in the capability class and
+// operational mode of a TC31 operational status message. Fields the message's
+// subtype and version do not define are nil.
+type opStatusEquipage struct {
+	tcas     *bool // TCAS/ACAS operational
+	es1090In *bool // Can receive 1090ES
+	uatIn    *bool // Can receive UAT
+	raActive *bool // A TCAS resolution advisory is active
+}
 
-		// Surface position messages (type codes 5-8)
-		if typeCode >= 5 && typeCode <= 8 {
-			return "1" // On ground
+// extractOpStatusEquipage extracts the capability class (ME bits 9-24, or
+// 9-20 on the surface) and operational mode (ME bits 25-40) flags from an
+// operational status message. Version 0 defines neither. Version 1 carries
+// NOT-ACAS in bit 11 and only version 2 has the receive capabilities; each
+// field is only decoded when its two format bits are 00.
+func (app *Application) extractOpStatusEquipage(data []byte) (opStatusEquipage, bool) {
+	var eq opStatusEquipage
+	if !hasME(data) {
+		return eq, false
+	}
+	me := data[4:]
+	subtype := app.getBits(me, 6, 8)
+	version := app.getBits(me, 41, 43)
+	if app.getBits(me, 1, 5) != 31 || subtype > 1 || version == 0 || version > 2 {
+		return eq, false
+	}
+
+	flag := func(bit int) *bool {
+		v := app.getBits(me, bit, bit) == 1
+		return &v
+	}
+
+	if app.getBits(me, 9, 10) == 0 {
+		switch {
+		case subtype == 0 && version == 1:
+			tcas := app.getBits(me, 11, 11) == 0 // NOT-ACAS
+			eq.tcas = &tcas
+		case subtype == 0:
+			eq.tcas = flag(11)
+			eq.es1090In = flag(12)
+			eq.uatIn = flag(19)
+		case version == 2:
+			eq.es1090In = flag(12)
+			eq.uatIn = flag(16)
 		}
+	}
+	if app.getBits(me, 25, 26) == 0 {
+		eq.raActive = flag(27)
+	}
+	return eq, true
+}
+
+// extractNICBaro extracts the barometric altitude integrity bit (ME bit 53)
+// from an airborne operational status message (TC31 subtype 0). Version 0
+// does not carry it, and in surface status the bit is the track/heading flag.
+func (app *Application) extractNICBaro(data []byte) (bool, bool) {
+	if !hasME(data) {
+		return false, false
+	}
+	me := data[4:]
+	if app.getBits(me, 1, 5) != 31 || app.getBits(me, 6, 8) != 0 || app.getBits(me, 41, 43) == 0 {
+		return false, false
+	}
+	return app.getBits(me, 53, 53) == 1, true
+}
+
+// extractNICSupplements extracts NIC supplement-A (ME bit 44) from an
+// operational status message, and from a version 2 surface one also NIC
+// supplement-C (ME bit 20 of the capability class). Fields the message's
+// subtype and version do not define are nil.
+func (app *Application) extractNICSupplements(data []byte) (suppA, suppC *bool) {
+	if !hasME(data) {
+		return nil, nil
+	}
+	me := data[4:]
+	subtype := app.getBits(me, 6, 8)
+	version := app.getBits(me, 41, 43)
+	if app.getBits(me, 1, 5) != 31 || subtype > 1 || version == 0 || version > 2 {
+		return nil, nil
+	}
+
+	a := app.getBits(me, 44, 44) == 1
+	suppA = &a
+	if subtype == 1 && version == 2 && app.getBits(me, 9, 10) == 0 {
+		c := app.getBits(me, 20, 20) == 1
+		suppC = &c
+	}
+	return suppA, suppC
+}
+
+// Transponder capability (CA) of DF11/DF17 messages
+const (
+	caLevel1     = 0 // Level 1 transponder, air/ground status not reported
+	caOnGround   = 4 // Level 2+ transponder, on the ground
+	caAirborne   = 5 // Level 2+ transponder, airborne
+	caEither     = 6 // Level 2+ transponder, on the ground or airborne
+	caDownlinkRq = 7 // Downlink request or alert/SPI pending, on the ground or airborne
+)
 
-		// Check CA (Capability) field for DF17
-		if df == 17 {
-			ca := data[0] & 0x07
-			if ca == 4 {
-				return "1" // Ground vehicle
-			} else if ca == 5 {
-				return "0" // Airborne
+// Flight status (FS, bits 6-8) of DF4/5/20/21 replies
+const (
+	fsAirborne      = 0
+	fsOnGround      = 1
+	fsAlertAirborne = 2 // Alert: Mode A identity changed
+	fsAlertOnGround = 3
+	fsAlertSPI      = 4 // Alert and SPI, airborne or on the ground
+	fsSPI           = 5 // SPI, airborne or on the ground
+)
+
+// extractFlightStatus extracts the flight status of a surveillance reply
+func extractFlightStatus(data []byte) uint8 {
+	return data[0] & 0x07
+}
+
+// extractGroundState determines whether the sender is on the ground. known
+// is false when the message does not say, so the aircraft's last stated
+// state can apply. Extended squitter content decides first: surface
+// positions are on the ground, airborne positions and velocities airborne.
+// Otherwise the DF11/DF17 capability or the surveillance flight status may
+// state it.
+func (app *Application) extractGroundState(data []byte) (onGround, known bool) {
+	if len(data) < 5 {
+		return false, false
+	}
+
+	df := (data[0] >> 3) & 0x1F
+	switch df {
+	case 4, 5, 20, 21:
+		switch extractFlightStatus(data) {
+		case fsAirborne, fsAlertAirborne:
+			return false, true
+		case fsOnGround, fsAlertOnGround:
+			return true, true
+		}
+		return false, false
+
+	case 17, 18:
+		if hasME(data) {
+			typeCode := (data[4] >> 3) & 0x1F
+			switch {
+			case typeCode >= 5 && typeCode <= 8:
+				return true, true // Surface position
+			case typeCode >= 9 && typeCode <= 22:
+				return false, true // Airborne position or velocity
 			}
 		}
+		if df == 18 {
+			return false, false // No CA field
+		}
+	}
+
+	// The DF11/DF17 capability states the air/ground status outright when it
+	// is 4 or 5; the other values leave it open
+	if df == 11 || df == 17 {
+		switch data[0] & 0x07 {
+		case caOnGround:
+			return true, true
+		case caAirborne:
+			return false, true
+		}
 	}
+	return false, false
+}
+
+// Threat identity data type (TTI) of a resolution advisory
+const (
+	ttiNone     = 0 // No identity data
+	ttiAddress  = 1 // Mode S address of the threat
+	ttiPosition = 2 // Altitude, range and bearing of the threat
+)
 
-	return "0" // Default to airborne
+// extractACASRA extracts the resolution advisory from an aircraft status
+// message (TC28 subtype 2): ARA (ME bits 9-22), RAC (23-26), RA terminated
+// (27), multiple threat encounter (28) and threat identity (29-56)
+func (app *Application) extractACASRA(data []byte) (adsb.ACASRA, bool) {
+	if !hasME(data) {
+		return adsb.ACASRA{}, false
+	}
+	me := data[4:]
+	if app.getBits(me, 1, 5) != 28 || app.getBits(me, 6, 8) != 2 {
+		return adsb.ACASRA{}, false
+	}
+
+	ara := app.getBitsUint16(me, 9, 22)
+	mte := app.getBits(me, 28, 28) != 0
+	ra := adsb.ACASRA{
+		// The first ARA bit is set while an RA is in force against one
+		// threat; with several threats only MTE is guaranteed to be set
+		Active:          ara&0x2000 != 0 || mte,
+		Terminated:      app.getBits(me, 27, 27) != 0,
+		MultipleThreats: mte,
+		ARA:             ara,
+		RAC:             adsb.RACNames(app.getBits(me, 23, 26)),
+	}
+
+	switch app.getBits(me, 29, 30) {
+	case ttiAddress:
+		threat := uint32(app.getBitsUint16(me, 31, 46))<<8 | uint32(app.getBits(me, 47, 54))
+		ra.ThreatHex = fmt.Sprintf("%06X", threat)
+	case ttiPosition:
+		if alt, ok := adsb.DecodeAC13(app.getBitsUint16(me, 31, 43)); ok {
+			ra.ThreatAltitude = &alt
+		}
+		if nm, ok := adsb.ThreatRangeFromCode(app.getBits(me, 44, 50)); ok {
+			ra.ThreatRange = &nm
+		}
+		if bearing, ok := adsb.ThreatBearingFromCode(app.getBits(me, 51, 56)); ok {
+			ra.ThreatBearing = &bearing
+		}
+	}
+	return ra, true
 }
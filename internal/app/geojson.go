@@ -0,0 +1,81 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go1090/internal/adsb"
+)
+
+// geoJSONFeature is a GeoJSON (RFC 7946) Feature with a Point geometry
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   geoJSONPoint      `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+// geoJSONPoint is a Point geometry; coordinates are longitude then latitude
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// geoJSONProperties are the aircraft fields carried by each Feature
+type geoJSONProperties struct {
+	ICAO      string   `json:"icao"`
+	Callsign  string   `json:"callsign,omitempty"`
+	Altitude  *int     `json:"alt,omitempty"` // Barometric, or GNSS height without one
+	Track     *float64 `json:"track,omitempty"`
+	Speed     *int     `json:"gs,omitempty"`
+	OnGround  bool     `json:"on_ground"`
+	Timestamp string   `json:"timestamp"`
+}
+
+// convertToGeoJSON converts a position-bearing message to a single-line
+// GeoJSON Feature. The stream is newline-delimited GeoJSON (one Feature per
+// position, as GeoJSON Text Sequences without the RS separator) rather than a
+// periodic FeatureCollection, so it can be tailed and appended to like the
+// other formats.
+func (app *Application) convertToGeoJSON(decoded *adsb.DecodedMessage) (string, error) {
+	if decoded.Latitude == nil || decoded.Longitude == nil {
+		return "", fmt.Errorf("message from %s has no position", decoded.Hex)
+	}
+
+	// Positions carry no callsign; use the one last heard from the aircraft
+	callsign := decoded.Callsign
+	if callsign == "" {
+		if ac, ok := app.registry.Get(decoded.ICAO); ok {
+			callsign = ac.Callsign
+		}
+	}
+
+	altitude := decoded.Altitude
+	if altitude == nil {
+		altitude = decoded.AltitudeGeom
+	}
+
+	feature := geoJSONFeature{
+		Type: "Feature",
+		Geometry: geoJSONPoint{
+			Type:        "Point",
+			Coordinates: [2]float64{app.roundCoord(*decoded.Longitude), app.roundCoord(*decoded.Latitude)},
+		},
+		Properties: geoJSONProperties{
+			ICAO:      decoded.Hex,
+			Callsign:  strings.TrimSpace(callsign),
+			Altitude:  altitude,
+			Track:     decoded.Track,
+			Speed:     decoded.GroundSpeed,
+			OnGround:  decoded.OnGround,
+			Timestamp: decoded.Timestamp.Format(time.RFC3339Nano),
+		},
+	}
+
+	data, err := json.Marshal(feature)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode GeoJSON feature: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultReadyTimeout is how long the receiver may go without seeing a
+// preamble or message before it reports not ready
+const DefaultReadyTimeout = 60 * time.Second
+
+// receiverHealth tracks SDR state for the readiness probe
+type receiverHealth struct {
+	configured   atomic.Bool
+	lastActivity atomic.Int64 // Unix nanoseconds of the last preamble or message
+}
+
+// MarkConfigured records that the sample source is configured and streaming
+func (h *receiverHealth) MarkConfigured() {
+	h.configured.Store(true)
+}
+
+// MarkActivity records that a preamble or message was seen
+func (h *receiverHealth) MarkActivity(now time.Time) {
+	h.lastActivity.Store(now.UnixNano())
+}
+
+// Ready reports whether the receiver is configured and has seen activity within
+// maxIdle, with a reason when it has not
+func (h *receiverHealth) Ready(now time.Time, maxIdle time.Duration) (bool, string) {
+	if !h.configured.Load() {
+		return false, "SDR not configured"
+	}
+
+	last := h.lastActivity.Load()
+	if last == 0 {
+		return false, "no preambles or messages seen yet"
+	}
+
+	if idle := now.Sub(time.Unix(0, last)); idle > maxIdle {
+		return false, fmt.Sprintf("no preambles or messages for %s", idle.Truncate(time.Second))
+	}
+
+	return true, ""
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"go1090/internal/rtlsdr"
+)
+
+// inputChunkSize matches an RTL-SDR async buffer so the demodulator sees the
+// same block sizes from a pipe as from the device
+const inputChunkSize = 16 * rtlsdr.BufferChunkSize
+
+// inputChunk is one chunk read from the input, with the read's error
+type inputChunk struct {
+	data []byte
+	err  error
+}
+
+// readInput reads raw I/Q bytes in the configured --iq-format from r in
+// fixed-size chunks and feeds them to dataChan until EOF or shutdown. dataChan is closed on return
+// so the processing loop can drain it and finish.
+func (app *Application) readInput(r io.Reader, dataChan chan<- []byte) error {
+	defer close(dataChan)
+
+	// A read blocks regardless of shutdown, e.g. on a pipe that stays open
+	// without data, so reads run on their own goroutine and one still
+	// blocked at shutdown is abandoned
+	chunks := make(chan inputChunk)
+	go func() {
+		for {
+			buf := make([]byte, inputChunkSize)
+			n, err := io.ReadFull(r, buf)
+			select {
+			case chunks <- inputChunk{data: buf[:n], err: err}:
+			case <-app.ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		var chunk inputChunk
+		select {
+		case chunk = <-chunks:
+		case <-app.ctx.Done():
+			return nil
+		}
+		err := chunk.err
+
+		// Keep whole I/Q pairs; a partial one can only occur at EOF
+		n := len(chunk.data) - len(chunk.data)%iqSampleBytes(app.config.IQFormat)
+		if n > 0 {
+			select {
+			case dataChan <- chunk.data[:n]:
+			case <-app.ctx.Done():
+				return nil
+			}
+		}
+
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			app.logger.Info("Input stream ended")
+			return nil
+		default:
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// I/Q sample formats for --iq-format, each interleaved I then Q
+const (
+	IQFormatU8   = "u8"   // Unsigned 8-bit, offset by 127.5 (RTL-SDR native)
+	IQFormatCS16 = "cs16" // Signed 16-bit little-endian (HackRF, Airspy, SoapySDR dumps)
+	IQFormatCF32 = "cf32" // 32-bit little-endian float, full scale ±1
+)
+
+// iqSampleBytes returns the bytes per I/Q pair of a --iq-format
+func iqSampleBytes(format string) int {
+	switch format {
+	case IQFormatCS16:
+		return 4
+	case IQFormatCF32:
+		return 8
+	default:
+		return 2
+	}
+}
+
+// u8FullScale is the unsigned 8-bit sample amplitude about its centre. Other
+// formats are scaled to it, as the demodulator's magnitude scale assumes it.
+const u8FullScale = 127.5
+
+// inputToIQ converts raw input in the configured --iq-format to I/Q samples
+// on the unsigned 8-bit scale. A trailing partial sample is ignored.
+func (app *Application) inputToIQ(data []byte) []complex128 {
+	switch app.config.IQFormat {
+	case IQFormatCS16:
+		return cs16ToIQ(data)
+	case IQFormatCF32:
+		return cf32ToIQ(data)
+	default:
+		return app.bytesToIQ(data)
+	}
+}
+
+// cs16ToIQ converts signed 16-bit little-endian I/Q pairs, scaling full
+// scale (32768) to the unsigned 8-bit amplitude
+func cs16ToIQ(data []byte) []complex128 {
+	const scale = u8FullScale / 32768
+	samples := make([]complex128, len(data)/4)
+	for i := range samples {
+		iSample := int16(binary.LittleEndian.Uint16(data[4*i:]))
+		qSample := int16(binary.LittleEndian.Uint16(data[4*i+2:]))
+		samples[i] = complex(float64(iSample)*scale, float64(qSample)*scale)
+	}
+	return samples
+}
+
+// cf32ToIQ converts 32-bit little-endian float I/Q pairs, scaling full
+// scale (1.0) to the unsigned 8-bit amplitude
+func cf32ToIQ(data []byte) []complex128 {
+	samples := make([]complex128, len(data)/8)
+	for i := range samples {
+		iSample := math.Float32frombits(binary.LittleEndian.Uint32(data[8*i:]))
+		qSample := math.Float32frombits(binary.LittleEndian.Uint32(data[8*i+4:]))
+		samples[i] = complex(float64(iSample)*u8FullScale, float64(qSample)*u8FullScale)
+	}
+	return samples
+}
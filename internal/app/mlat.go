@@ -0,0 +1,42 @@
+package app
+
+import (
+	"math"
+
+	"go1090/internal/adsb"
+	"go1090/internal/beast"
+)
+
+// writeMLAT sends a valid frame to MLAT clients as a Beast frame. Its
+// timestamp counts 12 MHz ticks of the receiver sample clock since capture
+// started, never wall-clock time, so frames keep the spacing at which they
+// were received.
+func (app *Application) writeMLAT(msg *adsb.ADSBMessage) {
+	if app.mlatServer == nil {
+		return
+	}
+
+	messageType := byte(beast.ModeSLong)
+	length := adsb.MessageLength(msg.GetDF())
+	if length == adsb.ShortMessageBytes {
+		messageType = beast.ModeS
+	}
+
+	app.mlatServer.Broadcast(app.mlatEncoder.Encode(&beast.Message{
+		MessageType: messageType,
+		Signal:      beastSignal(msg.Signal),
+		Data:        msg.Data[:length],
+		Counter:     adsb.MLATTicks(msg.SampleClock, app.config.SampleRate),
+		HasCounter:  true,
+	}))
+}
+
+// beastSignal converts a signal level in dBFS to the Beast signal byte, the
+// amplitude scaled to 0-255
+func beastSignal(dbfs float64) byte {
+	if math.IsInf(dbfs, -1) || math.IsNaN(dbfs) {
+		return 0
+	}
+	level := math.Round(255 * math.Pow(10, dbfs/20))
+	return byte(math.Min(math.Max(level, 0), 255))
+}
@@ -0,0 +1,288 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go1090/internal/adsb"
+	"go1090/internal/basestation"
+	"go1090/internal/clock"
+	"go1090/internal/logging"
+	"go1090/internal/output"
+)
+
+// DefaultOutputQueueSize is how many decoded messages may wait for the output
+// writer before new ones are dropped
+const DefaultOutputQueueSize = 4096
+
+// outputFlushTimeout bounds how long shutdown waits for the output writer to
+// drain the queue, so a blocked destination cannot hang it
+var outputFlushTimeout = 5 * time.Second
+
+// startOutputWriter builds the output sinks and starts the goroutine that
+// drains the output queue into them, so a slow disk or blocked stdout never
+// stalls demodulation
+func (app *Application) startOutputWriter() {
+	app.outputStop = make(chan struct{})
+	app.outputDone = make(chan struct{})
+	app.sinks = app.newSinks()
+
+	// Stdout is a single stream, so it gets the CSV header once
+	if app.config.OutputFormat == OutputFormatCSV {
+		fmt.Fprintln(app.stdout, csvHeader())
+	}
+
+	go func() {
+		defer close(app.outputDone)
+		for {
+			select {
+			case decoded := <-app.outputQueue:
+				app.writeQueued(decoded)
+			case <-app.outputStop:
+				// Write what was queued before the stop, then finish
+				for {
+					select {
+					case decoded := <-app.outputQueue:
+						app.writeQueued(decoded)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// writeQueued writes a message taken from the output queue
+func (app *Application) writeQueued(decoded *adsb.DecodedMessage) {
+	if err := app.writeOutput(decoded); err != nil {
+		app.logger.WithError(err).Debug("Failed to write message")
+	}
+}
+
+// RegisterHandler adds a handler called with every decoded message, after
+// the log file, stdout and socket outputs. Output filters such as
+// --positions-only apply only to the built-in outputs. It must be called
+// before Start.
+func (app *Application) RegisterHandler(handler adsb.MessageHandler) {
+	app.handlers = append(app.handlers, handler)
+}
+
+// newSinks creates a sink for every configured destination: the log file,
+// or the per-key log files with --split-by, stdout and, when enabled, socket
+// clients and UDP destinations, then the registered handlers. The built-in outputs use the
+// configured format and, with --positions-only or GeoJSON output, skip
+// messages without a position.
+func (app *Application) newSinks() *output.MultiSink {
+	var logSink output.Sink
+	if keyOf := app.splitKey(); keyOf != nil {
+		app.splitSink = output.NewSplitSink("log", keyOf, app.openSplitLog, app.lineFormatter(), SplitMaxOpenFiles)
+		logSink = app.splitSink
+	} else {
+		logSink = output.NewLineSink("log", app.logRotator, app.lineFormatter())
+	}
+
+	sinks := []output.Sink{
+		logSink,
+		output.NewLineSink("stdout", app.stdout, app.lineFormatter()),
+	}
+	if app.socketServer != nil {
+		sinks = append(sinks, output.NewLineSink("socket", app.socketServer, app.lineFormatter()))
+	}
+	for _, sink := range app.udpSinks {
+		sinks = append(sinks, sink)
+	}
+	for _, accept := range app.outputFilters() {
+		for i, sink := range sinks {
+			sinks[i] = output.NewFilterSink(sink, accept)
+		}
+	}
+	for i, handler := range app.handlers {
+		sinks = append(sinks, output.NewHandlerSink(fmt.Sprintf("handler %d", i+1), handler))
+	}
+	return output.NewMultiSink(sinks...)
+}
+
+// openUDPSinks dials the --sbs-udp and --json-udp destinations, which
+// always get their own format whatever --output-format says
+func (app *Application) openUDPSinks() error {
+	destinations := []struct {
+		name   string
+		addr   string
+		format output.Formatter
+	}{
+		{"SBS UDP", app.config.SBSUDP, app.sbsFormatter()},
+		{"JSON UDP", app.config.JSONUDP, app.convertToJSON},
+	}
+	for _, dest := range destinations {
+		if dest.addr == "" {
+			continue
+		}
+		sink, err := output.NewUDPSink(dest.name, dest.addr, dest.format)
+		if err != nil {
+			return err
+		}
+		app.udpSinks = append(app.udpSinks, sink)
+	}
+	return nil
+}
+
+// splitKey returns the key of each message's log file with --split-by, or
+// nil for one combined log
+func (app *Application) splitKey() output.KeyFunc {
+	switch app.config.SplitBy {
+	case SplitByICAO:
+		return output.ByICAO
+	case SplitByType:
+		return output.ByType
+	default:
+		return nil
+	}
+}
+
+// openSplitLog opens the daily log of one --split-by key, named
+// adsb_<date>_<key>.log, in the combined log's format and compression. The
+// split sink calls it under its lock.
+func (app *Application) openSplitLog(key string) (io.WriteCloser, error) {
+	rotator, err := logging.NewKeyedLogRotator(app.config.LogDir, key, app.config.LogRotateUTC, app.logger, clock.Real{})
+	if err != nil {
+		return nil, err
+	}
+	if app.config.CompressLive {
+		if err := rotator.SetCompressLive(true); err != nil {
+			rotator.Close()
+			return nil, err
+		}
+	}
+	if app.config.OutputFormat == OutputFormatCSV {
+		if err := rotator.SetHeader(csvHeader()); err != nil {
+			rotator.Close()
+			return nil, err
+		}
+	}
+
+	// Files left from earlier days are looked for once a day per key, not
+	// on every reopen as keys cycle through the open files
+	if date := rotator.GetCurrentDate(); date != app.splitCheckedDate {
+		app.splitChecked = make(map[string]bool)
+		app.splitCheckedDate = date
+	}
+	if !app.splitChecked[key] {
+		rotator.CompressEarlier()
+		app.splitChecked[key] = true
+	}
+	return splitLog{rotator}, nil
+}
+
+// splitLog is a per-key log file. It is not started, so each write checks
+// for the date change itself.
+type splitLog struct {
+	*logging.LogRotator
+}
+
+func (l splitLog) Write(p []byte) (int, error) {
+	l.CheckRotation()
+	return l.LogRotator.Write(p)
+}
+
+// lineFormatter returns the formatter of one line sink. In SBS each sink
+// tracks the aircraft it has announced, so every destination gets the AIR
+// record of an aircraft before its first MSG and an ID record for each new
+// callsign, as BaseStation feeds do.
+func (app *Application) lineFormatter() output.Formatter {
+	if app.config.OutputFormat != "" && app.config.OutputFormat != OutputFormatSBS {
+		return app.formatMessage
+	}
+	return app.sbsFormatter()
+}
+
+// sbsFormatter returns an SBS formatter with its own announced aircraft
+func (app *Application) sbsFormatter() output.Formatter {
+	tracker := basestation.NewAircraftTracker(basestation.DefaultAnnounceTTL)
+	return func(decoded *adsb.DecodedMessage) (string, error) {
+		var lines strings.Builder
+		for _, record := range tracker.Announce(decoded.ICAO, strings.TrimSpace(decoded.Callsign), decoded.Timestamp) {
+			lines.WriteString(basestation.FormatCSV(record))
+			lines.WriteString("\n")
+		}
+		// With --emit-raw-comment the frame precedes its record as an AVR line
+		if decoded.Raw != "" {
+			fmt.Fprintf(&lines, "*%s;\n", decoded.Raw)
+		}
+		lines.WriteString(app.convertToSBS(decoded))
+		return lines.String(), nil
+	}
+}
+
+// outputFilters returns the predicates every written message must pass
+func (app *Application) outputFilters() []output.Predicate {
+	var filters []output.Predicate
+	// GeoJSON features need a position
+	if app.config.PositionOnly || app.config.OutputFormat == OutputFormatGeoJSON {
+		filters = append(filters, output.HasPosition)
+	}
+	if app.config.MergeFrames {
+		filters = append(filters, output.PositionResolved)
+	}
+	if app.config.NoMilitary {
+		filters = append(filters, output.NotMilitary)
+	}
+	if app.config.AnonOnly {
+		filters = append(filters, output.Anonymous)
+	}
+	return filters
+}
+
+// stopOutputWriter flushes any queued lines, waiting up to
+// outputFlushTimeout for the writer to finish, then closes the sinks.
+// Messages enqueued later are never written. A writer still blocked on a
+// destination keeps the sinks open, as closing them under it would race.
+func (app *Application) stopOutputWriter() {
+	if app.outputDone == nil {
+		return
+	}
+	select {
+	case <-app.outputStop:
+		return // Already stopped
+	default:
+		close(app.outputStop)
+	}
+
+	select {
+	case <-app.outputDone:
+	case <-time.After(outputFlushTimeout):
+		app.logger.Warn("Output flush timeout, queued messages lost")
+		return
+	}
+
+	if err := app.sinks.Close(); err != nil {
+		app.logger.WithError(err).Warn("Failed to close output sinks")
+	}
+}
+
+// enqueueOutput queues a decoded message without blocking, dropping it when
+// the queue is full
+func (app *Application) enqueueOutput(decoded *adsb.DecodedMessage) {
+	select {
+	case app.outputQueue <- decoded:
+	default:
+		atomic.AddUint64(&app.outputDropped, 1)
+	}
+}
+
+// writeOutput writes a decoded message to every output sink. Except with
+// wall-clock timestamps, no message is stamped earlier than the one before.
+func (app *Application) writeOutput(decoded *adsb.DecodedMessage) error {
+	if app.config.TimestampSource != TimestampWall {
+		app.monotonicTimestamp(decoded)
+	}
+	return app.sinks.Write(decoded)
+}
+
+// GetOutputDropped returns the number of messages dropped because the output queue was full
+func (app *Application) GetOutputDropped() uint64 {
+	return atomic.LoadUint64(&app.outputDropped)
+}
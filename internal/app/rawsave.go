@@ -0,0 +1,149 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// rawCaptureQueueSize is how many input buffers may wait for the capture
+// writer before new ones are dropped, about two seconds of 2.4 MS/s input
+const rawCaptureQueueSize = 32
+
+// rawCapture records the incoming I/Q stream to a file in the format --stdin
+// reads, so a capture can be replayed through the decoder.
+// Recording never holds up decoding: buffers are written to disk by their own
+// goroutine and dropped when it falls behind, and once the size cap is
+// reached or a write fails, the capture stops and decoding carries on.
+type rawCapture struct {
+	mu      sync.Mutex // Guards queue against Write after Close
+	closed  bool
+	queue   chan []byte
+	done    chan struct{}
+	dropped uint64
+
+	path    string
+	file    *os.File
+	w       *bufio.Writer
+	limit   int64 // Bytes; 0 is unlimited
+	written int64
+	stopped bool
+	logger  *logrus.Logger
+}
+
+// newRawCapture creates (or truncates) the capture file at path. limit caps
+// the bytes recorded; 0 records until shutdown.
+func newRawCapture(path string, limit int64, logger *logrus.Logger) (*rawCapture, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create raw capture file: %w", err)
+	}
+	return startRawCapture(path, file, limit, logger), nil
+}
+
+// startRawCapture starts the goroutine that writes queued buffers to file
+func startRawCapture(path string, file *os.File, limit int64, logger *logrus.Logger) *rawCapture {
+	c := &rawCapture{
+		queue:  make(chan []byte, rawCaptureQueueSize),
+		done:   make(chan struct{}),
+		path:   path,
+		file:   file,
+		w:      bufio.NewWriterSize(file, inputChunkSize),
+		limit:  limit,
+		logger: logger,
+	}
+
+	go func() {
+		defer close(c.done)
+		for data := range c.queue {
+			c.write(data)
+		}
+	}()
+
+	return c
+}
+
+// Write queues a copy of a buffer of I/Q bytes without blocking, dropping it
+// when the writer has fallen behind
+func (c *rawCapture) Write(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	select {
+	case c.queue <- append([]byte(nil), data...):
+	default:
+		atomic.AddUint64(&c.dropped, 1)
+	}
+}
+
+// write records a buffer of I/Q bytes, cut to whole I/Q pairs at the cap.
+// It runs on the writer goroutine only.
+func (c *rawCapture) write(data []byte) {
+	if c.stopped {
+		return
+	}
+
+	full := false
+	if c.limit > 0 && c.written+int64(len(data)) >= c.limit {
+		n := c.limit - c.written
+		data = data[:n-n%2]
+		full = true
+	}
+
+	n, err := c.w.Write(data)
+	c.written += int64(n)
+	if err != nil {
+		c.stopped = true
+		c.logger.WithError(err).WithField("path", c.path).Warn("Raw capture stopped: write failed")
+		return
+	}
+	if full {
+		c.stopped = true
+		c.logger.WithFields(logrus.Fields{
+			"path":  c.path,
+			"bytes": c.written,
+		}).Info("Raw capture stopped: size limit reached")
+	}
+}
+
+// Dropped returns the number of buffers dropped because the writer fell behind
+func (c *rawCapture) Dropped() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
+
+// Close writes the queued buffers, then flushes and closes the capture file
+func (c *rawCapture) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.queue)
+	c.mu.Unlock()
+	<-c.done
+
+	if dropped := c.Dropped(); dropped > 0 {
+		c.logger.WithFields(logrus.Fields{
+			"path":    c.path,
+			"dropped": dropped,
+		}).Warn("Raw capture has gaps: writer fell behind")
+	}
+
+	if err := c.w.Flush(); err != nil {
+		c.file.Close()
+		return fmt.Errorf("failed to flush raw capture: %w", err)
+	}
+	if err := c.file.Close(); err != nil {
+		return fmt.Errorf("failed to close raw capture: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go1090/internal/adsb"
+)
+
+// Frequency scan settings for --scan-offset
+const (
+	ScanSpan  = 50000           // Hz tried either side of the configured frequency
+	ScanStep  = 25000           // Hz between tried frequencies
+	ScanDwell = 2 * time.Second // Time spent decoding on each frequency
+)
+
+// scanResult is the decode rate measured on one centre frequency
+type scanResult struct {
+	frequency uint32
+	rate      float64 // Valid messages per second
+}
+
+// scanFrequencies returns the centre frequencies tried around center, from
+// center-span up to center+span in steps
+func scanFrequencies(center uint32, span, step int) []uint32 {
+	var frequencies []uint32
+	for offset := -span; offset <= span; offset += step {
+		frequencies = append(frequencies, uint32(int(center)+offset))
+	}
+	return frequencies
+}
+
+// bestScanFrequency picks the frequency with the highest decode rate. Ties go
+// to the frequency nearest center, so without traffic the configured
+// frequency is kept.
+func bestScanFrequency(results []scanResult, center uint32) (scanResult, bool) {
+	if len(results) == 0 {
+		return scanResult{}, false
+	}
+
+	distance := func(f uint32) int64 {
+		d := int64(f) - int64(center)
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+
+	best := results[0]
+	for _, r := range results[1:] {
+		if r.rate > best.rate || (r.rate == best.rate && distance(r.frequency) < distance(best.frequency)) {
+			best = r
+		}
+	}
+	return best, true
+}
+
+// scanOffset decodes briefly on each frequency around the configured one and
+// tunes the device to the one with the best decode rate
+func (app *Application) scanOffset() error {
+	center := app.config.Frequency
+	var results []scanResult
+	for _, frequency := range scanFrequencies(center, ScanSpan, ScanStep) {
+		rate, err := app.measureDecodeRate(frequency, ScanDwell)
+		if err != nil {
+			return err
+		}
+		app.logger.WithFields(logrus.Fields{
+			"frequency": frequency,
+			"rate":      fmt.Sprintf("%.1f/s", rate),
+		}).Debug("Frequency scan step")
+		results = append(results, scanResult{frequency: frequency, rate: rate})
+	}
+
+	best, _ := bestScanFrequency(results, center)
+	if err := app.rtlsdr.SetFrequency(best.frequency); err != nil {
+		return fmt.Errorf("failed to tune to %d Hz: %w", best.frequency, err)
+	}
+	app.config.Frequency = best.frequency
+
+	app.logger.WithFields(logrus.Fields{
+		"frequency": best.frequency,
+		"offset":    int64(best.frequency) - int64(center),
+		"rate":      fmt.Sprintf("%.1f/s", best.rate),
+	}).Info("Frequency scan complete")
+	return nil
+}
+
+// measureDecodeRate tunes to frequency and returns the valid messages per
+// second decoded over dwell. A processor of its own keeps the scan out of the
+// run's statistics and deduplication state.
+func (app *Application) measureDecodeRate(frequency uint32, dwell time.Duration) (float64, error) {
+	if err := app.rtlsdr.SetFrequency(frequency); err != nil {
+		return 0, fmt.Errorf("failed to tune to %d Hz: %w", frequency, err)
+	}
+
+	processor := adsb.NewADSBProcessor(app.config.SampleRate, app.logger)
+	processor.SetSNRThreshold(app.config.SNRThreshold)
+	processor.SetErrorCorrection(!app.config.StrictCRC)
+	processor.SetSinglePhase(app.config.DemodPhases == DemodPhasesSingle)
+	processor.SetBufferOverlap(!app.config.NoOverlap)
+
+	ctx, cancel := context.WithTimeout(app.ctx, dwell)
+	defer cancel()
+
+	dataChan := make(chan []byte, 100)
+	captureErr := make(chan error, 1)
+	go func() {
+		captureErr <- app.rtlsdr.StartCapture(ctx, dataChan)
+	}()
+
+	for {
+		select {
+		case data := <-dataChan:
+			if len(data) >= 2 {
+				processor.ProcessIQSamples(app.bytesToIQ(data), time.Now())
+			}
+		case err := <-captureErr:
+			if err != nil {
+				return 0, fmt.Errorf("failed to capture on %d Hz: %w", frequency, err)
+			}
+			if err := app.ctx.Err(); err != nil {
+				return 0, err
+			}
+			_, _, valid, _, _, _ := processor.GetStats()
+			return float64(valid) / dwell.Seconds(), nil
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go1090/internal/web"
+)
+
+// statsSnapshotInterval is how often counters are snapshotted for the
+// per-period figures in stats.json
+const statsSnapshotInterval = time.Minute
+
+// statsHistoryLength keeps enough snapshots to cover the 15 minute period
+const statsHistoryLength = 16
+
+// statsCounters are the cumulative receiver counters at one point in time
+type statsCounters struct {
+	samples   uint64
+	preambles uint64
+	bad       uint64
+	valid     uint64
+	corrected uint64
+	messages  uint64
+	noise     float64 // Noise floor in dBFS, a level rather than a count
+}
+
+// sub returns the counter increase from earlier to c
+func (c statsCounters) sub(earlier statsCounters) statsCounters {
+	return statsCounters{
+		samples:   c.samples - earlier.samples,
+		preambles: c.preambles - earlier.preambles,
+		bad:       c.bad - earlier.bad,
+		valid:     c.valid - earlier.valid,
+		corrected: c.corrected - earlier.corrected,
+		messages:  c.messages - earlier.messages,
+		noise:     c.noise,
+	}
+}
+
+// statsSnapshot is the counters as they stood at a time
+type statsSnapshot struct {
+	at       time.Time
+	counters statsCounters
+}
+
+// topMessageTypesCount is how many message types are reported in the
+// statistics log and stats.json
+const topMessageTypesCount = 8
+
+// messageTypeCount is the number of valid messages of one type
+type messageTypeCount struct {
+	key   string
+	count uint64
+}
+
+// topMessageTypes returns the n most frequent message types, most frequent
+// first and ties by key
+func topMessageTypes(breakdown map[string]uint64, n int) []messageTypeCount {
+	types := make([]messageTypeCount, 0, len(breakdown))
+	for key, count := range breakdown {
+		types = append(types, messageTypeCount{key: key, count: count})
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].count != types[j].count {
+			return types[i].count > types[j].count
+		}
+		return types[i].key < types[j].key
+	})
+	if len(types) > n {
+		types = types[:n]
+	}
+	return types
+}
+
+// formatMessageTypes renders message type counts for the statistics log
+func formatMessageTypes(types []messageTypeCount) string {
+	parts := make([]string, len(types))
+	for i, t := range types {
+		parts[i] = fmt.Sprintf("%s=%d", t.key, t.count)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatPhaseStats renders the valid messages per demodulation phase for the
+// statistics log, in phase order
+func formatPhaseStats(stats map[int]uint64) string {
+	phases := make([]int, 0, len(stats))
+	for phase := range stats {
+		phases = append(phases, phase)
+	}
+	sort.Ints(phases)
+
+	parts := make([]string, len(phases))
+	for i, phase := range phases {
+		parts[i] = fmt.Sprintf("%d=%d", phase, stats[phase])
+	}
+	return strings.Join(parts, " ")
+}
+
+// statsTracker keeps the latest counters, published by the processing loop,
+// and minute snapshots so HTTP handlers can report periods without touching
+// the demodulator
+type statsTracker struct {
+	mu      sync.Mutex
+	start   time.Time
+	current statsSnapshot
+	history []statsSnapshot // Oldest first
+}
+
+// newStatsTracker creates a tracker whose counting starts at now
+func newStatsTracker(now time.Time) *statsTracker {
+	initial := statsSnapshot{at: now}
+	return &statsTracker{
+		start:   now,
+		current: initial,
+		history: []statsSnapshot{initial},
+	}
+}
+
+// Update publishes the current cumulative counters
+func (s *statsTracker) Update(counters statsCounters, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current = statsSnapshot{at: now, counters: counters}
+
+	if now.Sub(s.history[len(s.history)-1].at) >= statsSnapshotInterval {
+		s.history = append(s.history, s.current)
+		if len(s.history) > statsHistoryLength {
+			s.history = s.history[1:]
+		}
+	}
+}
+
+// Report builds the stats.json document as of the latest update
+func (s *statsTracker) Report() web.Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := s.history[len(s.history)-1]
+	return web.Stats{
+		Latest:    s.period(latest),
+		Last1Min:  s.period(s.since(time.Minute)),
+		Last5Min:  s.period(s.since(5 * time.Minute)),
+		Last15Min: s.period(s.since(15 * time.Minute)),
+		Total:     s.period(statsSnapshot{at: s.start}),
+	}
+}
+
+// since returns the newest snapshot at least d older than the current
+// counters, or the oldest one kept. The caller must hold mu.
+func (s *statsTracker) since(d time.Duration) statsSnapshot {
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if s.current.at.Sub(s.history[i].at) >= d {
+			return s.history[i]
+		}
+	}
+	return s.history[0]
+}
+
+// period reports the counter increase from a snapshot to the current counters.
+// The caller must hold mu.
+func (s *statsTracker) period(from statsSnapshot) web.StatsPeriod {
+	delta := s.current.counters.sub(from.counters)
+	local := web.LocalStats{
+		SamplesProcessed: delta.samples,
+		ModeS:            delta.preambles,
+		Bad:              delta.bad,
+		Accepted:         []uint64{delta.valid - delta.corrected, delta.corrected},
+	}
+	if !math.IsInf(delta.noise, -1) {
+		local.Noise = delta.noise
+	}
+	return web.StatsPeriod{
+		Start:    unixSeconds(from.at),
+		End:      unixSeconds(s.current.at),
+		Local:    local,
+		Messages: delta.messages,
+	}
+}
+
+// unixSeconds returns t as fractional Unix seconds, as dump1090 reports times
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// publishStats hands the demodulator counters to the stats tracker. It must
+// be called from the processing loop, which owns the processor.
+func (app *Application) publishStats(samples uint64) {
+	_, preambles, valid, corrected, _, _ := app.adsbProcessor.GetStats()
+	app.stats.Update(statsCounters{
+		samples:   samples,
+		preambles: preambles,
+		bad:       app.adsbProcessor.GetBadCount(),
+		valid:     valid,
+		corrected: corrected,
+		messages:  valid,
+		noise:     app.adsbProcessor.GetNoiseFloor(),
+	}, time.Now())
+}
+
+// statsReport builds the stats.json document, adding the most frequent
+// message types to the tracked periods
+func (app *Application) statsReport() web.Stats {
+	report := app.stats.Report()
+	if app.adsbProcessor != nil {
+		types := topMessageTypes(app.adsbProcessor.GetTypeBreakdown(), topMessageTypesCount)
+		if len(types) > 0 {
+			report.MessageTypes = make(map[string]uint64, len(types))
+			for _, t := range types {
+				report.MessageTypes[t.key] = t.count
+			}
+		}
+	}
+	return report
+}
+
+// receiverInfo builds the receiver.json document
+func (app *Application) receiverInfo() web.Receiver {
+	receiver := web.Receiver{
+		Version: Version,
+		Refresh: 1000,
+	}
+	if app.hasReceiverPosition() {
+		lat, lon := app.config.Latitude, app.config.Longitude
+		receiver.Lat = &lat
+		receiver.Lon = &lon
+	}
+	return receiver
+}
+
+// aircraftReport builds the aircraft.json document from the registry
+func (app *Application) aircraftReport() web.AircraftList {
+	now := time.Now()
+	all := app.registry.All()
+
+	list := web.AircraftList{
+		Now:      unixSeconds(now),
+		Aircraft: make([]web.Aircraft, len(all)),
+	}
+	for i, ac := range all {
+		list.Messages += ac.Messages
+		list.Aircraft[i] = web.Aircraft{
+			Hex:      fmt.Sprintf("%06x", ac.ICAO),
+			Flight:   ac.Callsign,
+			AltBaro:  ac.Altitude,
+			GS:       ac.GroundSpeed,
+			Track:    ac.Track,
+			Lat:      ac.Latitude,
+			Lon:      ac.Longitude,
+			Seen:     secondsSince(now, ac.LastSeen),
+			Messages: ac.Messages,
+		}
+		if !ac.PositionTime.IsZero() {
+			seen := secondsSince(now, ac.PositionTime)
+			list.Aircraft[i].SeenPos = &seen
+		}
+	}
+	return list
+}
+
+// secondsSince returns the time from t to now in seconds, to 0.1 s like
+// dump1090, and never negative
+func secondsSince(now, t time.Time) float64 {
+	return math.Max(0, math.Round(now.Sub(t).Seconds()*10)/10)
+}
+
+// trackReport builds the track document of one aircraft
+func (app *Application) trackReport(icao uint32) (web.Track, bool) {
+	points, ok := app.registry.History(icao)
+	if !ok {
+		return web.Track{}, false
+	}
+
+	track := web.Track{
+		Hex:    fmt.Sprintf("%06x", icao),
+		Points: make([]web.TrackPoint, len(points)),
+	}
+	for i, point := range points {
+		track.Points[i] = web.TrackPoint{
+			Time: unixSeconds(point.Time),
+			Lat:  point.Latitude,
+			Lon:  point.Longitude,
+			Alt:  point.Altitude,
+		}
+	}
+	return track, true
+}
+
+// hasReceiverPosition reports whether a receiver position was configured
+func (app *Application) hasReceiverPosition() bool {
+	return app.config.Latitude != 0 || app.config.Longitude != 0
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// statsCSVColumns is the --stats-csv row layout, written as a header when the
+// file is created
+var statsCSVColumns = []string{
+	"start", "duration_s", "total_processed", "preambles", "valid", "corrected",
+	"success_rate", "peak_aircraft", "config",
+}
+
+// notePeakAircraft raises the peak tracked-aircraft count to n if higher
+func (app *Application) notePeakAircraft(n int) {
+	for {
+		peak := atomic.LoadInt64(&app.peakAircraft)
+		if int64(n) <= peak || atomic.CompareAndSwapInt64(&app.peakAircraft, peak, int64(n)) {
+			return
+		}
+	}
+}
+
+// statsCSVRecord builds the run's --stats-csv row as of end. The success
+// rate is a plain percentage and the config is the effective Config as JSON,
+// so runs with different settings can be compared from one file.
+func (app *Application) statsCSVRecord(end time.Time) ([]string, error) {
+	total, preambles, valid, corrected, _, _ := app.adsbProcessor.GetStats()
+
+	rate := 0.0
+	if preambles > 0 {
+		rate = float64(valid) / float64(preambles) * 100
+	}
+
+	config, err := json.Marshal(app.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	start := app.stats.start
+	return []string{
+		start.UTC().Format(time.RFC3339),
+		strconv.FormatFloat(end.Sub(start).Seconds(), 'f', 1, 64),
+		strconv.FormatUint(total, 10),
+		strconv.FormatUint(preambles, 10),
+		strconv.FormatUint(valid, 10),
+		strconv.FormatUint(corrected, 10),
+		strconv.FormatFloat(rate, 'f', 2, 64),
+		strconv.FormatInt(atomic.LoadInt64(&app.peakAircraft), 10),
+		string(config),
+	}, nil
+}
+
+// appendStatsCSV appends the run's statistics row to path, writing the
+// header first when the file is new or empty
+func (app *Application) appendStatsCSV(path string, end time.Time) error {
+	record, err := app.statsCSVRecord(end)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open stats CSV: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat stats CSV: %w", err)
+	}
+
+	w := csv.NewWriter(file)
+	if info.Size() == 0 {
+		if err := w.Write(statsCSVColumns); err != nil {
+			return fmt.Errorf("failed to write stats CSV header: %w", err)
+		}
+	}
+	if err := w.Write(record); err != nil {
+		return fmt.Errorf("failed to write stats CSV row: %w", err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write stats CSV row: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"time"
+
+	"go1090/internal/adsb"
+)
+
+// Output timestamp sources for --timestamp-source
+const (
+	// Reception time from the wall clock when the first buffer arrived plus
+	// the samples received since: never steps with the system clock, and
+	// catches up when dropped samples leave it more than maxClockLag
+	// behind the time elapsed
+	TimestampMonotonic = "monotonic"
+
+	// Reception time from the wall clock as each buffer arrives: follows
+	// NTP corrections, so may step backwards
+	TimestampWall = "wall"
+)
+
+// maxClockLag is how far the sample count may fall behind the monotonic
+// time elapsed since the first buffer before the clock catches up. Buffers
+// dropped before reaching the decoder leave the count behind; a bound above
+// the buffer backlog keeps a slow decoder from moving it. The count is never
+// pulled back, as stdin may deliver a recording faster than real time.
+const maxClockLag = time.Second
+
+// receptionClock stamps each sample buffer with the reception time of its
+// first sample; message timestamps add their offset within the buffer
+type receptionClock struct {
+	wall       bool
+	now        func() time.Time
+	since      func(time.Time) time.Duration // Monotonic time elapsed
+	sampleRate uint32
+
+	start   time.Time     // Arrival of the first buffer
+	samples uint64        // Samples received before the next buffer
+	shift   time.Duration // Time skipped to catch up
+}
+
+// newReceptionClock creates a clock for the given --timestamp-source
+func newReceptionClock(source string, sampleRate uint32) *receptionClock {
+	return &receptionClock{
+		wall:       source == TimestampWall,
+		now:        time.Now,
+		since:      time.Since,
+		sampleRate: sampleRate,
+	}
+}
+
+// bufferTime returns the reception time of the first of n samples that have
+// just arrived. On the wall clock the buffer is taken to have filled up to
+// now; otherwise the time counts on from the first buffer by sample count,
+// moved up to the monotonic time elapsed once it falls more than
+// maxClockLag behind. time.Now's monotonic reading keeps the first-buffer
+// base, and so every timestamp derived from it, immune to system clock steps.
+func (c *receptionClock) bufferTime(n int) time.Time {
+	now := c.now()
+	if c.samples == 0 {
+		c.start = now
+	}
+	counted := adsb.SampleDuration(c.samples, c.sampleRate) + c.shift
+	c.samples += uint64(n)
+
+	if c.wall {
+		return now.Add(-adsb.SampleDuration(uint64(n), c.sampleRate))
+	}
+
+	elapsed := c.since(c.start) - adsb.SampleDuration(uint64(n), c.sampleRate)
+	if lag := elapsed - counted; lag > maxClockLag {
+		c.shift += lag
+		counted = elapsed
+	}
+	return c.start.Add(counted)
+}
+
+// monotonicTimestamp raises decoded's timestamp to the last one written, so
+// output never goes backwards: records such as reassembled Comm-D ELMs
+// carry the time of an earlier frame
+func (app *Application) monotonicTimestamp(decoded *adsb.DecodedMessage) {
+	if decoded.Timestamp.Before(app.lastOutputTime) {
+		decoded.Timestamp = app.lastOutputTime
+	}
+	app.lastOutputTime = decoded.Timestamp
+}
@@ -0,0 +1,91 @@
+package basestation
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultAnnounceTTL is how long an aircraft stays announced after its last
+// message; one heard again after that gets a new AIR record
+const DefaultAnnounceTTL = 5 * time.Minute
+
+// announced is what a stream has been told about one aircraft
+type announced struct {
+	callsign string
+	lastSeen time.Time
+}
+
+// AircraftTracker remembers which aircraft and callsigns a BaseStation stream
+// has announced, so that an AIR record precedes the first MSG of every
+// aircraft and an ID record its first (or a changed) callsign
+type AircraftTracker struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	aircraft  map[uint32]*announced
+	nextPrune time.Time
+}
+
+// NewAircraftTracker creates a tracker that forgets aircraft not heard from
+// within ttl
+func NewAircraftTracker(ttl time.Duration) *AircraftTracker {
+	return &AircraftTracker{
+		ttl:      ttl,
+		aircraft: make(map[uint32]*announced),
+	}
+}
+
+// Announce records a message from icao heard at t, with the callsign it
+// carried if any, and returns the AIR and ID records due before its MSG line
+func (tr *AircraftTracker) Announce(icao uint32, callsign string, t time.Time) []*Message {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	tr.pruneLocked(t)
+
+	var records []*Message
+	ac, ok := tr.aircraft[icao]
+	if !ok || t.Sub(ac.lastSeen) > tr.ttl {
+		ac = &announced{}
+		tr.aircraft[icao] = ac
+		records = append(records, newRecord(AIR, icao, t))
+	}
+	ac.lastSeen = t
+
+	if callsign != "" && callsign != ac.callsign {
+		ac.callsign = callsign
+		record := newRecord(ID, icao, t)
+		record.Callsign = callsign
+		records = append(records, record)
+	}
+	return records
+}
+
+// pruneLocked forgets aircraft past the TTL, at most once per TTL. The caller
+// must hold mu.
+func (tr *AircraftTracker) pruneLocked(now time.Time) {
+	if now.Before(tr.nextPrune) {
+		return
+	}
+	for icao, ac := range tr.aircraft {
+		if now.Sub(ac.lastSeen) > tr.ttl {
+			delete(tr.aircraft, icao)
+		}
+	}
+	tr.nextPrune = now.Add(tr.ttl)
+}
+
+// newRecord creates an AIR or ID record for icao, generated and logged at t
+func newRecord(messageType string, icao uint32, t time.Time) *Message {
+	return &Message{
+		MessageType:   messageType,
+		SessionID:     1,
+		AircraftID:    1,
+		HexIdent:      fmt.Sprintf("%06X", icao),
+		FlightID:      1,
+		DateGenerated: t,
+		TimeGenerated: t,
+		DateLogged:    t,
+		TimeLogged:    t,
+	}
+}
@@ -71,14 +71,20 @@ func TestBaseStationWriter_WriteMessage(t *testing.T) {
 		t.Fatalf("No lines in log file")
 	}
 
-	// Basic validation - should start with MSG
-	if !strings.HasPrefix(lines[0], "MSG,") {
-		t.Errorf("Expected line to start with 'MSG,', got: %s", lines[0])
+	// A new aircraft is announced before its first MSG
+	if len(lines) != 2 {
+		t.Fatalf("Expected an AIR and a MSG line, got: %q", lines)
+	}
+	if lines[0] != "AIR,,1,1,484412,1,2023/01/01,12:00:00.000,2023/01/01,12:00:00.000" {
+		t.Errorf("Expected an AIR record for 484412, got: %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "MSG,") {
+		t.Errorf("Expected line to start with 'MSG,', got: %s", lines[1])
 	}
 
 	// Check ICAO is present (should be 484412 from test data)
-	if !strings.Contains(lines[0], "484412") {
-		t.Errorf("Expected ICAO 484412 in output, got: %s", lines[0])
+	if !strings.Contains(lines[1], "484412") {
+		t.Errorf("Expected ICAO 484412 in output, got: %s", lines[1])
 	}
 }
 
@@ -197,23 +203,28 @@ func TestBaseStationWriter_ConcurrentWrite(t *testing.T) {
 		t.Fatalf("No log files created")
 	}
 
-	// Count total lines written
-	totalLines := 0
+	// Count total lines written by record type
+	counts := make(map[string]int)
 	for _, file := range files {
 		content, err := os.ReadFile(file)
 		if err != nil {
 			t.Fatalf("Failed to read file %s: %v", file, err)
 		}
 
-		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
-		if len(lines) > 0 && lines[0] != "" {
-			totalLines += len(lines)
+		for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			if line != "" {
+				counts[strings.SplitN(line, ",", 2)[0]]++
+			}
 		}
 	}
 
+	// Every goroutine writes as one aircraft, announced once
 	expectedLines := numGoroutines * messagesPerGoroutine
-	if totalLines != expectedLines {
-		t.Errorf("Expected %d lines total, got %d", expectedLines, totalLines)
+	if counts[MSG] != expectedLines {
+		t.Errorf("Expected %d MSG lines total, got %d", expectedLines, counts[MSG])
+	}
+	if counts[AIR] != numGoroutines {
+		t.Errorf("Expected %d AIR lines total, got %d", numGoroutines, counts[AIR])
 	}
 }
 
@@ -315,3 +326,49 @@ func BenchmarkBaseStationWriter_WriteMessage(b *testing.B) {
 		}
 	}
 }
+
+// TestAircraftTracker tests when AIR and ID records are due
+func TestAircraftTracker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewAircraftTracker(DefaultAnnounceTTL)
+
+	types := func(records []*Message) []string {
+		var result []string
+		for _, record := range records {
+			result = append(result, record.MessageType)
+		}
+		return result
+	}
+
+	steps := []struct {
+		name     string
+		icao     uint32
+		callsign string
+		at       time.Duration
+		expected []string
+	}{
+		{name: "New aircraft", icao: 0x4840D6, at: 0, expected: []string{AIR}},
+		{name: "Known aircraft", icao: 0x4840D6, at: time.Second, expected: nil},
+		{name: "First callsign", icao: 0x4840D6, callsign: "KLM1023", at: 2 * time.Second, expected: []string{ID}},
+		{name: "Same callsign", icao: 0x4840D6, callsign: "KLM1023", at: 3 * time.Second, expected: nil},
+		{name: "Changed callsign", icao: 0x4840D6, callsign: "KLM1024", at: 4 * time.Second, expected: []string{ID}},
+		{name: "New aircraft with callsign", icao: 0x40621D, callsign: "BAW123", at: 5 * time.Second, expected: []string{AIR, ID}},
+		{name: "Returning after the TTL", icao: 0x4840D6, callsign: "KLM1024", at: 5*time.Second + DefaultAnnounceTTL, expected: []string{AIR, ID}},
+	}
+
+	for _, step := range steps {
+		records := tracker.Announce(step.icao, step.callsign, start.Add(step.at))
+		if got := types(records); strings.Join(got, ",") != strings.Join(step.expected, ",") {
+			t.Errorf("%s: expected %v, got %v", step.name, step.expected, got)
+		}
+	}
+
+	// Records carry the address, and ID records the callsign
+	records := tracker.Announce(0xABCDEF, "DLH4AB", start)
+	if got := FormatCSV(records[0]); got != "AIR,,1,1,ABCDEF,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000" {
+		t.Errorf("Unexpected AIR record: %s", got)
+	}
+	if got := FormatCSV(records[1]); got != "ID,,1,1,ABCDEF,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,DLH4AB" {
+		t.Errorf("Unexpected ID record: %s", got)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"go1090/internal/adsb"
 	"go1090/internal/beast"
 	"go1090/internal/logging"
 )
@@ -66,6 +67,7 @@ type Writer struct {
 	logger     *logrus.Logger
 	sessionID  int
 	aircraftID int
+	tracker    *AircraftTracker
 }
 
 // NewWriter creates a new BaseStation writer
@@ -75,6 +77,7 @@ func NewWriter(logRotator *logging.LogRotator, logger *logrus.Logger) *Writer {
 		logger:     logger,
 		sessionID:  1,
 		aircraftID: 1,
+		tracker:    NewAircraftTracker(DefaultAnnounceTTL),
 	}
 }
 
@@ -95,8 +98,15 @@ func (w *Writer) WriteMessage(msg *beast.Message) error {
 		return nil
 	}
 
-	// Format as BaseStation CSV
-	csvLine := w.formatCSV(baseMsg)
+	// Format as BaseStation CSV, after the AIR/ID records announcing a new
+	// aircraft or callsign
+	var csvLine string
+	if baseMsg.HexIdent != "" {
+		for _, record := range w.tracker.Announce(msg.GetICAO(), baseMsg.Callsign, msg.Timestamp) {
+			csvLine += w.formatCSV(record) + "\n"
+		}
+	}
+	csvLine += w.formatCSV(baseMsg)
 
 	// Get current writer
 	writer, err := w.logRotator.GetWriter()
@@ -164,7 +174,7 @@ func (w *Writer) convertMessage(msg *beast.Message) *Message {
 
 			// Extract altitude if present
 			if df == 4 || df == 20 {
-				altitude := w.extractAltitude(msg.Data)
+				altitude := w.extractAltitude(msg.Data, df)
 				if altitude != 0 {
 					baseMsg.Altitude = strconv.Itoa(altitude)
 				}
@@ -212,7 +222,7 @@ func (w *Writer) convertMessage(msg *beast.Message) *Message {
 					}
 
 					// Extract altitude
-					altitude := w.extractAltitude(msg.Data)
+					altitude := w.extractAltitude(msg.Data, df)
 					if altitude != 0 {
 						baseMsg.Altitude = strconv.Itoa(altitude)
 					}
@@ -249,9 +259,21 @@ func (w *Writer) convertADSBMessage(data []byte) *Message {
 
 // formatCSV formats a BaseStation message as CSV
 func (w *Writer) formatCSV(msg *Message) string {
+	return FormatCSV(msg)
+}
+
+// FormatCSV formats a BaseStation message as CSV. AIR records end after the
+// logged time and ID records after the callsign; only MSG carries a
+// transmission type and the remaining fields.
+func FormatCSV(msg *Message) string {
+	transmissionType := ""
+	if msg.MessageType == MSG {
+		transmissionType = strconv.Itoa(msg.TransmissionType)
+	}
+
 	fields := []string{
 		msg.MessageType,
-		strconv.Itoa(msg.TransmissionType),
+		transmissionType,
 		strconv.Itoa(msg.SessionID),
 		strconv.Itoa(msg.AircraftID),
 		msg.HexIdent,
@@ -260,6 +282,15 @@ func (w *Writer) formatCSV(msg *Message) string {
 		msg.TimeGenerated.Format("15:04:05.000"),
 		msg.DateLogged.Format("2006/01/02"),
 		msg.TimeLogged.Format("15:04:05.000"),
+	}
+	switch msg.MessageType {
+	case AIR:
+		return strings.Join(fields, ",")
+	case ID:
+		return strings.Join(append(fields, msg.Callsign), ",")
+	}
+
+	fields = append(fields,
 		msg.Callsign,
 		msg.Altitude,
 		msg.GroundSpeed,
@@ -272,26 +303,31 @@ func (w *Writer) formatCSV(msg *Message) string {
 		msg.Emergency,
 		msg.SPI,
 		msg.IsOnGround,
-	}
+	)
 
 	return strings.Join(fields, ",")
 }
 
-// extractAltitude extracts altitude from Mode S message
-func (w *Writer) extractAltitude(data []byte) int {
-	if len(data) < 3 {
+// extractAltitude extracts altitude in feet from a surveillance reply (AC13)
+// or an airborne position squitter (AC12), returning 0 when unavailable
+func (w *Writer) extractAltitude(data []byte, df uint8) int {
+	if len(data) < 7 {
 		return 0
 	}
 
-	// Altitude is in bits 20-32 of the message
-	altitude := (int(data[2]) << 4) | ((int(data[3]) >> 4) & 0x0F)
-
-	if altitude == 0 {
+	var (
+		altitude int
+		ok       bool
+	)
+	if df == 17 || df == 18 || df == 19 {
+		altitude, ok = adsb.DecodeAC12(uint16(data[5])<<4 | uint16(data[6])>>4)
+	} else {
+		altitude, ok = adsb.DecodeAC13(uint16(data[2]&0x1F)<<8 | uint16(data[3]))
+	}
+	if !ok {
 		return 0
 	}
-
-	// Convert to feet
-	return (altitude - 1) * 25
+	return altitude
 }
 
 // extractSquawk extracts squawk code from Mode S message
@@ -366,3 +366,111 @@ func BenchmarkMessage_GetICAO(b *testing.B) {
 		msg.GetICAO()
 	}
 }
+
+// TestEncoder_PreserveTimestamps tests that a Beast frame survives a decode and
+// encode round trip with its original counter
+func TestEncoder_PreserveTimestamps(t *testing.T) {
+	input := []byte{
+		0x1A, 0x33, // Sync + Type
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, // Timestamp
+		0x3C, // Signal level
+		0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71,
+		0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98,
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	messages, err := NewDecoder(logger).Decode(input)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("Decode() = %d messages, %v", len(messages), err)
+	}
+	msg := messages[0]
+	if !msg.HasCounter || msg.Counter != 0x0123456789AB {
+		t.Fatalf("Counter = %012X (set %v), want 0123456789AB", msg.Counter, msg.HasCounter)
+	}
+
+	encoder := NewEncoder()
+	encoder.SetPreserveTimestamps(true)
+	if output := encoder.Encode(msg); string(output) != string(input) {
+		t.Errorf("Encode() = % X, want % X", output, input)
+	}
+
+	// Without preservation the counter is regenerated
+	encoder.SetPreserveTimestamps(false)
+	if output := encoder.Encode(msg); string(output[2:8]) == string(input[2:8]) {
+		t.Errorf("Encode() kept the original counter without preservation")
+	}
+
+	// Sync bytes in the frame body are escaped
+	msg.Counter = 0x00000000001A
+	encoder.SetPreserveTimestamps(true)
+	output := encoder.Encode(msg)
+	want := []byte{0x1A, 0x33, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1A, 0x1A, 0x3C}
+	if string(output[:len(want)]) != string(want) {
+		t.Errorf("Encode() header = % X, want % X", output[:len(want)], want)
+	}
+}
+
+// TestBeastModeDecoder_EscapedHeader tests that 0x1A bytes escaped in the
+// timestamp and signal are unescaped without disturbing the framing of the
+// frame or the one after it, including when input is split mid-escape
+func TestBeastModeDecoder_EscapedHeader(t *testing.T) {
+	input := []byte{
+		0x1A, 0x33, // Sync + Type
+		0x00, 0x00, 0x1A, 0x1A, 0x00, 0x12, 0x1A, 0x1A, // Timestamp 00001A00121A
+		0x1A, 0x1A, // Signal level 0x1A
+		0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71,
+		0xC3, 0x2C, 0xE0, 0x57, 0x60, 0x98,
+		0x1A, 0x32, // Sync + Type
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x01, // Timestamp
+		0x02,                                           // Signal level
+		0x5D, 0x48, 0x40, 0xD6, 0x1A, 0x1A, 0x56, 0x78, // Message data with an escaped byte
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	for _, split := range []int{len(input), 5, 11} {
+		t.Run(fmt.Sprintf("Split at %d", split), func(t *testing.T) {
+			decoder := NewDecoder(logger)
+			first, err := decoder.Decode(input[:split])
+			if err != nil {
+				t.Fatalf("Decode() error: %v", err)
+			}
+			rest, err := decoder.Decode(input[split:])
+			if err != nil {
+				t.Fatalf("Decode() error: %v", err)
+			}
+			messages := append(first, rest...)
+			if len(messages) != 2 {
+				t.Fatalf("Decode() = %d messages, want 2", len(messages))
+			}
+
+			long := messages[0]
+			if long.Counter != 0x00001A00121A {
+				t.Errorf("Counter = %012X, want 00001A00121A", long.Counter)
+			}
+			if long.Signal != 0x1A {
+				t.Errorf("Signal = %02X, want 1A", long.Signal)
+			}
+			if got := fmt.Sprintf("%X", long.Data); got != "8D4840D6202CC371C32CE0576098" {
+				t.Errorf("Data = %s, want 8D4840D6202CC371C32CE0576098", got)
+			}
+
+			short := messages[1]
+			if short.MessageType != ModeS || short.Counter != 1 || short.Signal != 0x02 {
+				t.Errorf("Second frame = type %02X counter %d signal %02X", short.MessageType, short.Counter, short.Signal)
+			}
+			if got := fmt.Sprintf("%X", short.Data); got != "5D4840D61A5678" {
+				t.Errorf("Data = %s, want 5D4840D61A5678", got)
+			}
+		})
+	}
+
+	// A lone sync byte inside a frame starts the next frame
+	truncated := append([]byte{0x1A, 0x33, 0x00, 0x00, 0x00}, input[26:]...)
+	messages, err := NewDecoder(logger).Decode(truncated)
+	if err != nil || len(messages) != 1 || messages[0].MessageType != ModeS {
+		t.Errorf("Decode() after a truncated frame = %d messages, %v", len(messages), err)
+	}
+}
@@ -1,6 +1,7 @@
 package beast
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -78,14 +79,18 @@ func (d *Decoder) Decode(data []byte) ([]*Message, error) {
 			continue
 		}
 
-		// Check if we have a complete message
-		if len(d.buffer) < messageLen {
+		// Unescape the complete frame; escapes can occur anywhere after the
+		// type byte, so the raw length is only known once it is read
+		messageData, consumed, err := readFrame(d.buffer, messageLen)
+		if err == errIncompleteFrame {
 			break
 		}
-
-		// Extract message
-		messageData := make([]byte, messageLen)
-		copy(messageData, d.buffer[:messageLen])
+		if err != nil {
+			// A new frame started inside this one; resume at its sync byte
+			d.logger.WithError(err).Debug("Dropping truncated Beast message")
+			d.buffer = d.buffer[consumed:]
+			continue
+		}
 
 		// Debug: Log message detection
 		d.logger.WithFields(logrus.Fields{
@@ -109,10 +114,11 @@ func (d *Decoder) Decode(data []byte) ([]*Message, error) {
 			"data_length":  len(msg.Data),
 		}).Debug("Successfully decoded Beast message")
 
+		msg.Raw = append([]byte(nil), d.buffer[:consumed]...)
 		messages = append(messages, msg)
 
 		// Remove processed message from buffer
-		d.buffer = d.buffer[messageLen:]
+		d.buffer = d.buffer[consumed:]
 	}
 
 	// Keep buffer size reasonable
@@ -173,31 +179,46 @@ func (d *Decoder) decodeMessage(data []byte) (*Message, error) {
 	messageData := make([]byte, expectedLen-9) // Subtract header length
 	copy(messageData, data[9:expectedLen])
 
-	// Unescape data (Beast protocol escapes 0x1A bytes)
-	messageData = d.unescapeData(messageData)
-
 	return &Message{
 		MessageType: messageType,
 		Timestamp:   timestampTime,
 		Signal:      signal,
 		Data:        messageData,
 		Raw:         data,
+		Counter:     timestamp,
+		HasCounter:  true,
 	}, nil
 }
 
-// unescapeData removes Beast protocol escaping
-func (d *Decoder) unescapeData(data []byte) []byte {
-	result := make([]byte, 0, len(data))
-
-	for i := 0; i < len(data); i++ {
-		if data[i] == 0x1A && i+1 < len(data) {
-			// Escaped byte
-			result = append(result, data[i+1])
-			i++ // Skip the escape byte
-		} else {
-			result = append(result, data[i])
+// errIncompleteFrame reports that the buffer ends before the frame does
+var errIncompleteFrame = errors.New("incomplete frame")
+
+// readFrame reads the frame at the start of buf, which begins with its sync
+// and type bytes, and returns it unescaped to length bytes together with the
+// raw bytes it took up. Every 0x1A after the type byte, in the timestamp and
+// signal as well as the data, is sent doubled; a lone one is the sync byte of
+// the next frame, and consumed is then the offset of that sync byte.
+func readFrame(buf []byte, length int) (frame []byte, consumed int, err error) {
+	frame = make([]byte, 0, length)
+	frame = append(frame, buf[0], buf[1])
+
+	i := 2
+	for len(frame) < length {
+		if i >= len(buf) {
+			return nil, 0, errIncompleteFrame
 		}
+		b := buf[i]
+		if b == SyncByte {
+			if i+1 >= len(buf) {
+				return nil, 0, errIncompleteFrame
+			}
+			if buf[i+1] != SyncByte {
+				return nil, i, fmt.Errorf("frame truncated after %d of %d bytes", len(frame), length)
+			}
+			i++
+		}
+		frame = append(frame, b)
+		i++
 	}
-
-	return result
+	return frame, i, nil
 }
@@ -0,0 +1,60 @@
+package beast
+
+import (
+	"time"
+)
+
+// counterMask keeps the 48 bits of the Beast timestamp counter
+const counterMask = 1<<48 - 1
+
+// Encoder encodes messages as Beast frames
+type Encoder struct {
+	preserveTimestamps bool
+}
+
+// NewEncoder creates a new Beast encoder
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// SetPreserveTimestamps makes the encoder re-emit the original 12 MHz counter
+// of messages that came from Beast input, so a relay keeps MLAT timing
+// coherent. Other messages get a counter derived from their timestamp.
+func (e *Encoder) SetPreserveTimestamps(preserve bool) {
+	e.preserveTimestamps = preserve
+}
+
+// Encode returns the escaped Beast frame for a message
+func (e *Encoder) Encode(msg *Message) []byte {
+	counter := timeCounter(msg.Timestamp)
+	if e.preserveTimestamps && msg.HasCounter {
+		counter = msg.Counter & counterMask
+	}
+
+	frame := make([]byte, 0, 2+2*(6+1+len(msg.Data)))
+	frame = append(frame, SyncByte, msg.MessageType)
+	for shift := 40; shift >= 0; shift -= 8 {
+		frame = appendEscaped(frame, byte(counter>>shift))
+	}
+	frame = appendEscaped(frame, msg.Signal)
+	for _, b := range msg.Data {
+		frame = appendEscaped(frame, b)
+	}
+	return frame
+}
+
+// timeCounter derives a 12 MHz counter from a wall-clock time
+func timeCounter(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano()) / 1000 * 12 & counterMask
+}
+
+// appendEscaped appends b, doubling it if it is the sync byte
+func appendEscaped(frame []byte, b byte) []byte {
+	if b == SyncByte {
+		frame = append(frame, SyncByte)
+	}
+	return append(frame, b)
+}
@@ -20,6 +20,11 @@ type Message struct {
 	Signal      byte
 	Data        []byte
 	Raw         []byte
+
+	// Counter is the 48-bit 12 MHz timestamp as received; HasCounter marks
+	// messages decoded from Beast input, whose counter can be re-emitted
+	Counter    uint64
+	HasCounter bool
 }
 
 // GetICAO extracts ICAO address from Mode S message
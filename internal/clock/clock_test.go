@@ -0,0 +1,29 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMock tests that the mock clock moves only when set or advanced
+func TestMock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 23, 59, 0, 0, time.UTC)
+	mock := NewMock(start)
+	assert.Equal(t, start, mock.Now())
+
+	mock.Advance(2 * time.Minute)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 1, 0, 0, time.UTC), mock.Now())
+
+	mock.Set(start)
+	assert.Equal(t, start, mock.Now())
+}
+
+// TestReal tests that the real clock follows the system time
+func TestReal(t *testing.T) {
+	before := time.Now()
+	now := Real{}.Now()
+	assert.False(t, now.Before(before))
+	assert.False(t, now.After(time.Now()))
+}
@@ -0,0 +1,94 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ICAOFilter decides which aircraft addresses are reported. When the allow
+// list is non-empty only listed addresses pass; denied addresses never pass.
+type ICAOFilter struct {
+	allow map[uint32]struct{}
+	deny  map[uint32]struct{}
+}
+
+// LoadICAOFilter reads an ICAO allow/deny list file
+func LoadICAOFilter(path string) (*ICAOFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ICAO filter file: %w", err)
+	}
+	defer file.Close()
+
+	return ParseICAOFilter(file)
+}
+
+// ParseICAOFilter parses an ICAO allow/deny list: one hex address per line,
+// prefixed with '!' to deny it. Blank lines and '#' comments are ignored.
+func ParseICAOFilter(r io.Reader) (*ICAOFilter, error) {
+	f := &ICAOFilter{
+		allow: make(map[uint32]struct{}),
+		deny:  make(map[uint32]struct{}),
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		target := f.allow
+		if strings.HasPrefix(line, "!") {
+			target = f.deny
+			line = strings.TrimSpace(line[1:])
+		}
+
+		icao, err := strconv.ParseUint(line, 16, 24)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ICAO address %q on line %d", line, lineNum)
+		}
+		target[uint32(icao)] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ICAO filter: %w", err)
+	}
+
+	return f, nil
+}
+
+// Allows reports whether messages from the given address should be reported.
+// A nil filter allows everything.
+func (f *ICAOFilter) Allows(icao uint32) bool {
+	if f == nil {
+		return true
+	}
+	if _, denied := f.deny[icao]; denied {
+		return false
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	_, allowed := f.allow[icao]
+	return allowed
+}
+
+// Len returns the number of allowed and denied addresses
+func (f *ICAOFilter) Len() (int, int) {
+	if f == nil {
+		return 0, 0
+	}
+	return len(f.allow), len(f.deny)
+}
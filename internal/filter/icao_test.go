@@ -0,0 +1,92 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseICAOFilter tests allow/deny list parsing and matching
+func TestParseICAOFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		allowed []uint32
+		blocked []uint32
+		wantErr bool
+	}{
+		{
+			name:    "Empty list allows everything",
+			input:   "",
+			allowed: []uint32{0x4840D6, 0xABCDEF},
+		},
+		{
+			name:    "Allow list",
+			input:   "4840D6\n# comment\nabcdef  # trailing comment\n",
+			allowed: []uint32{0x4840D6, 0xABCDEF},
+			blocked: []uint32{0x123456},
+		},
+		{
+			name:    "Deny list only",
+			input:   "!123456\n",
+			allowed: []uint32{0x4840D6},
+			blocked: []uint32{0x123456},
+		},
+		{
+			name:    "Deny wins over allow",
+			input:   "123456\n!123456\n",
+			blocked: []uint32{0x123456},
+		},
+		{
+			name:    "Invalid address",
+			input:   "XYZ123\n",
+			wantErr: true,
+		},
+		{
+			name:    "Address too wide",
+			input:   "1234567\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseICAOFilter(strings.NewReader(tt.input))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			for _, icao := range tt.allowed {
+				assert.True(t, f.Allows(icao), "%06X should be allowed", icao)
+			}
+			for _, icao := range tt.blocked {
+				assert.False(t, f.Allows(icao), "%06X should be blocked", icao)
+			}
+		})
+	}
+}
+
+// TestLoadICAOFilter tests loading a filter from disk
+func TestLoadICAOFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "icao.txt")
+	require.NoError(t, os.WriteFile(path, []byte("4840D6\n!ABCDEF\n"), 0644))
+
+	f, err := LoadICAOFilter(path)
+	require.NoError(t, err)
+	allow, deny := f.Len()
+	assert.Equal(t, 1, allow)
+	assert.Equal(t, 1, deny)
+
+	_, err = LoadICAOFilter(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+
+	// A nil filter allows everything
+	var none *ICAOFilter
+	assert.True(t, none.Allows(0x123456))
+}
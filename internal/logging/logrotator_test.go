@@ -12,6 +12,8 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"go1090/internal/clock"
 )
 
 // TestLogRotator_NewLogRotator tests the creation of new log rotator
@@ -106,6 +108,192 @@ func TestLogRotator_GetWriter(t *testing.T) {
 	assert.Equal(t, testData, string(content))
 }
 
+// TestLogRotator_Reopen tests that a moved log file is recreated on reopen
+func TestLogRotator_Reopen(t *testing.T) {
+	tempDir := t.TempDir()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	rotator, err := NewLogRotator(tempDir, false, logger)
+	require.NoError(t, err)
+	defer rotator.Close()
+
+	before, err := rotator.GetWriter()
+	require.NoError(t, err)
+
+	// Simulate logrotate moving the file away
+	currentFile := rotator.GetCurrentLogFile()
+	require.NoError(t, os.Rename(currentFile, currentFile+".1"))
+
+	require.NoError(t, rotator.Reopen())
+
+	after, err := rotator.GetWriter()
+	require.NoError(t, err)
+	assert.NotSame(t, before, after)
+	assert.Equal(t, currentFile, rotator.GetCurrentLogFile())
+
+	_, err = after.Write([]byte("after reopen\n"))
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(currentFile)
+	require.NoError(t, err)
+	assert.Equal(t, "after reopen\n", string(content))
+}
+
+// TestLogRotator_SetHeader tests that the header is written exactly once per
+// file, including files created by rotation and reopening
+func TestLogRotator_SetHeader(t *testing.T) {
+	tempDir := t.TempDir()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	rotator, err := NewLogRotator(tempDir, false, logger)
+	require.NoError(t, err)
+	defer rotator.Close()
+
+	require.NoError(t, rotator.SetHeader("a,b"))
+	writer, err := rotator.GetWriter()
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("1,2\n"))
+	require.NoError(t, err)
+
+	// Rotating onto the same, non-empty file does not repeat the header.
+	// The stale date keeps the background compression away from the live file.
+	rotator.currentDate = "2000-01-01"
+	require.NoError(t, rotator.rotateLogFile())
+	currentFile := rotator.GetCurrentLogFile()
+	content, err := os.ReadFile(currentFile)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n1,2\n", string(content))
+
+	// A fresh file after the old one is moved away starts with the header
+	require.NoError(t, os.Rename(currentFile, currentFile+".1"))
+	require.NoError(t, rotator.Reopen())
+	writer, err = rotator.GetWriter()
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("3,4\n"))
+	require.NoError(t, err)
+
+	content, err = os.ReadFile(currentFile)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n3,4\n", string(content))
+}
+
+// TestLogRotator_CompressLive tests reading back live-compressed output across
+// a header, a periodic flush, a reopen and shutdown
+func TestLogRotator_CompressLive(t *testing.T) {
+	tempDir := t.TempDir()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	rotator, err := NewLogRotator(tempDir, false, logger)
+	require.NoError(t, err)
+	plainFile := rotator.GetCurrentLogFile()
+
+	require.NoError(t, rotator.SetCompressLive(true))
+	require.NoError(t, rotator.SetHeader("a,b"))
+	currentFile := rotator.GetCurrentLogFile()
+	assert.Equal(t, plainFile+".gz", currentFile)
+
+	// The unused plain file is removed
+	_, err = os.Stat(plainFile)
+	assert.True(t, os.IsNotExist(err))
+
+	writer, err := rotator.GetWriter()
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("1,2\n"))
+	require.NoError(t, err)
+
+	// Flushed output is readable while the file is still open
+	require.NoError(t, rotator.Flush())
+	assert.Equal(t, "a,b\n1,2\n", readGzipPrefix(t, currentFile))
+
+	// Reopening appends a second gzip member without repeating the header
+	require.NoError(t, rotator.Reopen())
+	writer, err = rotator.GetWriter()
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("3,4\n"))
+	require.NoError(t, err)
+
+	// Shutdown flushes the rest
+	require.NoError(t, rotator.Close())
+	assert.Equal(t, "a,b\n1,2\n3,4\n", readGzip(t, currentFile))
+}
+
+// TestLogRotator_CompressionQueue tests that every rotated file is compressed
+// exactly once by the worker, and that Close waits for pending compressions
+func TestLogRotator_CompressionQueue(t *testing.T) {
+	tempDir := t.TempDir()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	rotator, err := NewLogRotator(tempDir, false, logger)
+	require.NoError(t, err)
+
+	dates := []string{"2000-01-01", "2000-01-02", "2000-01-03", "2000-01-02"}
+	for _, date := range dates {
+		plain := filepath.Join(tempDir, "adsb_"+date+".log")
+		if _, err := os.Stat(plain + ".gz"); os.IsNotExist(err) {
+			require.NoError(t, os.WriteFile(plain, []byte("log for "+date+"\n"), 0644))
+		}
+
+		// Rotating away from the stale date queues its file
+		rotator.mutex.Lock()
+		rotator.currentDate = date
+		require.NoError(t, rotator.rotateLogFile())
+		rotator.mutex.Unlock()
+	}
+
+	require.NoError(t, rotator.Close())
+
+	for _, date := range dates[:3] {
+		plain := filepath.Join(tempDir, "adsb_"+date+".log")
+		_, err := os.Stat(plain)
+		assert.True(t, os.IsNotExist(err), "%s was not removed", plain)
+		assert.Equal(t, "log for "+date+"\n", readGzip(t, plain+".gz"))
+	}
+
+	// Rotating onto the same date leaves the live file alone
+	rotator, err = NewLogRotator(tempDir, false, logger)
+	require.NoError(t, err)
+	current := rotator.GetCurrentLogFile()
+	require.NoError(t, rotator.rotateLogFile())
+	require.NoError(t, rotator.Close())
+	_, err = os.Stat(current)
+	assert.NoError(t, err)
+	_, err = os.Stat(current + ".gz")
+	assert.True(t, os.IsNotExist(err))
+}
+
+// readGzip returns the complete decompressed content of a gzip file
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(content)
+}
+
+// readGzipPrefix returns what can be decompressed from a gzip file that is
+// still being written
+func readGzipPrefix(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	return string(content)
+}
+
 // TestLogRotator_GetLogFiles tests the GetLogFiles method
 func TestLogRotator_GetLogFiles(t *testing.T) {
 	tempDir := t.TempDir()
@@ -152,24 +340,27 @@ func TestLogRotator_CleanupOldLogs(t *testing.T) {
 	logger := logrus.New()
 	logger.SetOutput(io.Discard)
 
-	rotator, err := NewLogRotator(tempDir, false, logger)
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	rotator, err := NewLogRotatorWithClock(tempDir, true, logger, clock.NewMock(now))
 	require.NoError(t, err)
 	defer rotator.Close()
 
 	// Create old log files with different timestamps
-	oldFile := filepath.Join(tempDir, "adsb_2023-01-01.log")
+	oldFile := filepath.Join(tempDir, "adsb_2024-01-05.log")
 	err = os.WriteFile(oldFile, []byte("old content"), 0644)
 	require.NoError(t, err)
 
 	// Set old modification time
-	oldTime := time.Now().AddDate(0, 0, -10) // 10 days ago
+	oldTime := now.AddDate(0, 0, -10) // 10 days ago
 	err = os.Chtimes(oldFile, oldTime, oldTime)
 	require.NoError(t, err)
 
 	// Create recent log file
-	recentFile := filepath.Join(tempDir, "adsb_2023-12-31.log")
+	recentFile := filepath.Join(tempDir, "adsb_2024-01-13.log")
 	err = os.WriteFile(recentFile, []byte("recent content"), 0644)
 	require.NoError(t, err)
+	recentTime := now.AddDate(0, 0, -2)
+	require.NoError(t, os.Chtimes(recentFile, recentTime, recentTime))
 
 	// Test cleanup with maxDays = 5
 	err = rotator.CleanupOldLogs(5)
@@ -237,7 +428,8 @@ func TestLogRotator_CompressLogFile(t *testing.T) {
 	logger := logrus.New()
 	logger.SetOutput(io.Discard)
 
-	rotator, err := NewLogRotator(tempDir, false, logger)
+	now := time.Date(2023, 1, 2, 0, 1, 0, 0, time.UTC)
+	rotator, err := NewLogRotatorWithClock(tempDir, true, logger, clock.NewMock(now))
 	require.NoError(t, err)
 	defer rotator.Close()
 
@@ -248,12 +440,9 @@ func TestLogRotator_CompressLogFile(t *testing.T) {
 	err = os.WriteFile(testFile, []byte(testContent), 0644)
 	require.NoError(t, err)
 
-	// Call compress function
+	// Compression is synchronous outside the worker
 	rotator.compressLogFile(testDate)
 
-	// Wait a bit for compression to complete
-	time.Sleep(100 * time.Millisecond)
-
 	// Original file should be removed
 	assert.NoFileExists(t, testFile)
 
@@ -273,6 +462,7 @@ func TestLogRotator_CompressLogFile(t *testing.T) {
 	decompressed, err := io.ReadAll(gzReader)
 	require.NoError(t, err)
 	assert.Equal(t, testContent, string(decompressed))
+	assert.True(t, now.Equal(gzReader.ModTime), "gzip header time %s", gzReader.ModTime)
 }
 
 // TestLogRotator_DateRotation tests date-based log rotation
@@ -281,33 +471,95 @@ func TestLogRotator_DateRotation(t *testing.T) {
 	logger := logrus.New()
 	logger.SetOutput(io.Discard)
 
-	rotator, err := NewLogRotator(tempDir, false, logger)
+	mock := clock.NewMock(time.Date(2024, 1, 1, 23, 59, 0, 0, time.UTC))
+	rotator, err := NewLogRotatorWithClock(tempDir, true, logger, mock)
 	require.NoError(t, err)
-	defer rotator.Close()
 
 	// Get initial log file
 	initialFile := rotator.GetCurrentLogFile()
-	assert.NotEmpty(t, initialFile)
+	assert.Equal(t, filepath.Join(tempDir, "adsb_2024-01-01.log"), initialFile)
 
 	// Write some data
 	writer, err := rotator.GetWriter()
 	require.NoError(t, err)
-	_, err = writer.Write([]byte("initial content"))
+	_, err = writer.Write([]byte("initial content\n"))
 	require.NoError(t, err)
 
-	// Manually trigger rotation (simulating date change)
-	err = rotator.rotateLogFile()
-	assert.NoError(t, err)
+	// Same date, no rotation
+	mock.Advance(30 * time.Second)
+	rotator.CheckRotation()
+	assert.Equal(t, initialFile, rotator.GetCurrentLogFile())
 
-	// Current file should be the same (since date hasn't actually changed)
-	currentFile := rotator.GetCurrentLogFile()
-	assert.Equal(t, initialFile, currentFile)
+	// Past midnight the next day's file is opened
+	mock.Advance(time.Minute)
+	rotator.CheckRotation()
+	assert.Equal(t, filepath.Join(tempDir, "adsb_2024-01-02.log"), rotator.GetCurrentLogFile())
 
-	// File should still exist and be writable
+	// File should be writable
 	writer, err = rotator.GetWriter()
-	assert.NoError(t, err)
-	_, err = writer.Write([]byte("new content"))
-	assert.NoError(t, err)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("new content\n"))
+	require.NoError(t, err)
+
+	// Closing waits for the previous day's file to be compressed
+	require.NoError(t, rotator.Close())
+	assert.NoFileExists(t, initialFile)
+	assert.Equal(t, "initial content\n", readGzip(t, initialFile+".gz"))
+	content, err := os.ReadFile(filepath.Join(tempDir, "adsb_2024-01-02.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "new content\n", string(content))
+}
+
+// TestLogRotator_KeyedEarlierFiles tests that a keyed file closed before
+// midnight is compressed by CompressEarlier or by a close on a later date,
+// leaving other keys' files alone
+func TestLogRotator_KeyedEarlierFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	mock := clock.NewMock(time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC))
+	write := func(key, content string) {
+		rotator, err := NewKeyedLogRotator(tempDir, key, true, logger, mock)
+		require.NoError(t, err)
+		_, err = rotator.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, rotator.Close())
+	}
+	fileOf := func(date, key string) string {
+		return filepath.Join(tempDir, "adsb_"+date+"_"+key+".log")
+	}
+
+	// Closed on the day it was written, the file stays plain
+	write("484412", "day one\n")
+	write("4840d6", "other\n")
+	assert.FileExists(t, fileOf("2024-01-01", "484412"))
+
+	// Opening a key the next day leaves the earlier file to CompressEarlier
+	mock.Advance(2 * time.Hour)
+	write("4840d6", "other again\n")
+	assert.FileExists(t, fileOf("2024-01-01", "4840d6"))
+
+	rotator, err := NewKeyedLogRotator(tempDir, "484412", true, logger, mock)
+	require.NoError(t, err)
+	rotator.CompressEarlier()
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(fileOf("2024-01-01", "484412") + ".gz")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	_, err = rotator.Write([]byte("day two\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-02", rotator.GetCurrentDate())
+
+	// Closing it a day later compresses the current file too
+	mock.Advance(24 * time.Hour)
+	require.NoError(t, rotator.Close())
+
+	for date, content := range map[string]string{"2024-01-01": "day one\n", "2024-01-02": "day two\n"} {
+		assert.NoFileExists(t, fileOf(date, "484412"))
+		assert.Equal(t, content, readGzip(t, fileOf(date, "484412")+".gz"))
+	}
+	assert.FileExists(t, fileOf("2024-01-01", "4840d6"))
 }
 
 // TestLogRotator_ConcurrentAccess tests concurrent access to log rotator
@@ -380,18 +632,21 @@ func TestLogRotator_UTCTimezone(t *testing.T) {
 	logger := logrus.New()
 	logger.SetOutput(io.Discard)
 
-	rotator, err := NewLogRotator(tempDir, true, logger)
+	// Late evening in UTC-3 is already the next day in UTC
+	mock := clock.NewMock(time.Date(2024, 1, 1, 22, 30, 0, 0, time.FixedZone("BRT", -3*60*60)))
+
+	rotator, err := NewLogRotatorWithClock(tempDir, true, logger, mock)
 	require.NoError(t, err)
 	defer rotator.Close()
 
-	// Current file should exist
 	currentFile := rotator.GetCurrentLogFile()
-	assert.NotEmpty(t, currentFile)
 	assert.FileExists(t, currentFile)
+	assert.Equal(t, filepath.Join(tempDir, "adsb_2024-01-02.log"), currentFile)
 
-	// File name should contain current UTC date
-	expectedDate := time.Now().UTC().Format("2006-01-02")
-	assert.Contains(t, currentFile, expectedDate)
+	local, err := NewLogRotatorWithClock(t.TempDir(), false, logger, mock)
+	require.NoError(t, err)
+	defer local.Close()
+	assert.Contains(t, local.GetCurrentLogFile(), "adsb_2024-01-01.log")
 }
 
 // BenchmarkLogRotator_Write benchmarks writing performance
@@ -7,26 +7,61 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"go1090/internal/clock"
 )
 
+// LiveFlushInterval is how often a live-compressed log is flushed, bounding
+// how much output is lost if the process dies
+const LiveFlushInterval = 10 * time.Second
+
+// CompressQueueSize is how many rotated files may wait for compression before
+// a rotation blocks on the worker
+const CompressQueueSize = 16
+
 // LogRotator handles log rotation with gzip compression
 type LogRotator struct {
 	logDir      string
+	key         string // Appended to the date in file names, if set
 	useUTC      bool
+	clock       clock.Clock
 	logger      *logrus.Logger
 	currentFile *os.File
 	currentDate string
+	header      string // Written at the top of every new log file
 	mutex       sync.RWMutex
 	ctx         context.Context
 	cancel      context.CancelFunc
+
+	// Compress the current file as it is written
+	compressLive bool
+	gzWriter     *gzip.Writer
+
+	// Dates of rotated files, compressed one at a time by a single worker.
+	// The queue is set to nil under mutex once closed.
+	compressQueue chan string
+	compressDone  chan struct{}
 }
 
 // NewLogRotator creates a new log rotator
 func NewLogRotator(logDir string, useUTC bool, logger *logrus.Logger) (*LogRotator, error) {
+	return NewLogRotatorWithClock(logDir, useUTC, logger, clock.Real{})
+}
+
+// NewLogRotatorWithClock creates a log rotator that takes the date from clk.
+// The first log file is opened for clk's current date.
+func NewLogRotatorWithClock(logDir string, useUTC bool, logger *logrus.Logger, clk clock.Clock) (*LogRotator, error) {
+	return NewKeyedLogRotator(logDir, "", useUTC, logger, clk)
+}
+
+// NewKeyedLogRotator creates a log rotator whose files carry key after the
+// date, as adsb_<date>_<key>.log, for output split over several files
+func NewKeyedLogRotator(logDir, key string, useUTC bool, logger *logrus.Logger, clk clock.Clock) (*LogRotator, error) {
 	// Create log directory if it doesn't exist
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create log directory: %w", err)
@@ -35,28 +70,48 @@ func NewLogRotator(logDir string, useUTC bool, logger *logrus.Logger) (*LogRotat
 	ctx, cancel := context.WithCancel(context.Background())
 
 	rotator := &LogRotator{
-		logDir: logDir,
-		useUTC: useUTC,
-		logger: logger,
-		ctx:    ctx,
-		cancel: cancel,
+		logDir:        logDir,
+		key:           key,
+		useUTC:        useUTC,
+		clock:         clk,
+		logger:        logger,
+		ctx:           ctx,
+		cancel:        cancel,
+		compressQueue: make(chan string, CompressQueueSize),
+		compressDone:  make(chan struct{}),
 	}
+	go rotator.compressWorker(rotator.compressQueue)
 
 	// Initialize current log file
 	if err := rotator.rotateLogFile(); err != nil {
 		cancel()
+		close(rotator.compressQueue)
 		return nil, fmt.Errorf("failed to initialize log file: %w", err)
 	}
 
 	return rotator, nil
 }
 
+// CompressEarlier compresses this key's plain files dated before the current
+// one in the background. A keyed file closed before midnight never rotates,
+// so split output calls it the first time each key is opened on a day.
+func (r *LogRotator) CompressEarlier() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.key != "" && r.compressQueue != nil {
+		r.queueEarlierLocked(r.currentDate)
+	}
+}
+
 // Start starts the log rotation scheduler
 func (r *LogRotator) Start(ctx context.Context) {
 	r.logger.Info("Starting log rotator")
 
 	ticker := time.NewTicker(1 * time.Minute) // Check every minute
 	defer ticker.Stop()
+	flushTicker := time.NewTicker(LiveFlushInterval)
+	defer flushTicker.Stop()
 
 	for {
 		select {
@@ -66,21 +121,27 @@ func (r *LogRotator) Start(ctx context.Context) {
 		case <-r.ctx.Done():
 			return
 		case <-ticker.C:
-			r.checkRotation()
+			r.CheckRotation()
+		case <-flushTicker.C:
+			if err := r.Flush(); err != nil {
+				r.logger.WithError(err).Error("Failed to flush log file")
+			}
 		}
 	}
 }
 
-// checkRotation checks if log rotation is needed
-func (r *LogRotator) checkRotation() {
-	var now time.Time
+// now returns the current time in the rotation time zone
+func (r *LogRotator) now() time.Time {
 	if r.useUTC {
-		now = time.Now().UTC()
-	} else {
-		now = time.Now()
+		return r.clock.Now().UTC()
 	}
+	return r.clock.Now()
+}
 
-	currentDate := now.Format("2006-01-02")
+// CheckRotation rotates the log file if the date has changed. Start does so
+// every minute; a rotator that is not started must call it itself.
+func (r *LogRotator) CheckRotation() {
+	currentDate := r.now().Format("2006-01-02")
 
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -99,50 +160,78 @@ func (r *LogRotator) checkRotation() {
 
 // rotateLogFile performs log rotation
 func (r *LogRotator) rotateLogFile() error {
-	var now time.Time
-	if r.useUTC {
-		now = time.Now().UTC()
-	} else {
-		now = time.Now()
-	}
-
-	newDate := now.Format("2006-01-02")
+	newDate := r.now().Format("2006-01-02")
 
 	// Close current file if it exists
 	if r.currentFile != nil {
-		oldFile := r.currentFile
 		oldDate := r.currentDate
 
 		// Close the file
-		if err := oldFile.Close(); err != nil {
+		if err := r.closeCurrentLocked(); err != nil {
 			r.logger.WithError(err).Error("Failed to close old log file")
 		}
 
-		// Compress the old file in a goroutine
-		go r.compressLogFile(oldDate)
+		// Hand the old file to the compression worker, unless the same file
+		// is about to be reopened. A live-compressed file has no plain
+		// counterpart, unless one was left by an earlier run.
+		if oldDate != newDate && r.compressQueue != nil {
+			r.compressQueue <- oldDate
+		}
 	}
 
 	// Create new log file
-	filename := fmt.Sprintf("adsb_%s.log", newDate)
-	filepath := filepath.Join(r.logDir, filename)
-
-	file, err := os.OpenFile(filepath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
+	filepath := r.logFilePath(newDate)
+	if err := r.openCurrentLocked(filepath); err != nil {
 		return fmt.Errorf("failed to create log file %s: %w", filepath, err)
 	}
-
-	r.currentFile = file
 	r.currentDate = newDate
 
-	r.logger.WithField("file", filepath).Info("Created new log file")
+	if err := r.writeHeaderLocked(); err != nil {
+		return err
+	}
+
+	// Split output opens keyed files all the time
+	if r.key != "" {
+		r.logger.WithField("file", filepath).Debug("Created new log file")
+	} else {
+		r.logger.WithField("file", filepath).Info("Created new log file")
+	}
 
 	return nil
 }
 
+// queueEarlierLocked hands the plain files of this key dated before the
+// given date to the compression worker. The caller must hold mutex.
+func (r *LogRotator) queueEarlierLocked(before string) {
+	files, err := filepath.Glob(filepath.Join(r.logDir, r.logFileName("*")))
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list earlier log files")
+		return
+	}
+
+	prefix, suffix := "adsb_", "_"+r.key+".log"
+	for _, file := range files {
+		date := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), prefix), suffix)
+		if _, err := time.Parse("2006-01-02", date); err != nil || date >= before {
+			continue
+		}
+		r.compressQueue <- date
+	}
+}
+
+// compressWorker compresses queued dates in order until the queue is closed,
+// so rotations never run several compressions at once
+func (r *LogRotator) compressWorker(queue <-chan string) {
+	defer close(r.compressDone)
+	for date := range queue {
+		r.compressLogFile(date)
+	}
+}
+
 // compressLogFile compresses a log file with gzip
 func (r *LogRotator) compressLogFile(date string) {
-	logFile := filepath.Join(r.logDir, fmt.Sprintf("adsb_%s.log", date))
-	gzipFile := filepath.Join(r.logDir, fmt.Sprintf("adsb_%s.log.gz", date))
+	logFile := filepath.Join(r.logDir, r.logFileName(date))
+	gzipFile := logFile + ".gz"
 
 	r.logger.WithFields(logrus.Fields{
 		"source": logFile,
@@ -163,8 +252,9 @@ func (r *LogRotator) compressLogFile(date string) {
 	}
 	defer src.Close()
 
-	// Create compressed file
-	dst, err := os.Create(gzipFile)
+	// Create compressed file, appending a new gzip member if a live-compressed
+	// file for the date already exists
+	dst, err := os.OpenFile(gzipFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		r.logger.WithError(err).WithField("file", gzipFile).Error("Failed to create compressed file")
 		return
@@ -177,7 +267,7 @@ func (r *LogRotator) compressLogFile(date string) {
 
 	// Set file header
 	gzWriter.Name = filepath.Base(logFile)
-	gzWriter.ModTime = time.Now()
+	gzWriter.ModTime = r.clock.Now()
 
 	// Copy data
 	if _, err := io.Copy(gzWriter, src); err != nil {
@@ -214,30 +304,247 @@ func (r *LogRotator) GetWriter() (io.Writer, error) {
 	if r.currentFile == nil {
 		return nil, fmt.Errorf("no current log file")
 	}
+	if r.compressLive {
+		return liveWriter{r}, nil
+	}
 
 	return r.currentFile, nil
 }
 
-// Close closes the log rotator
-func (r *LogRotator) Close() error {
-	r.logger.Info("Closing log rotator")
+// Write writes p to the current log file, so the rotator can be used as an
+// io.Writer that follows rotation
+func (r *LogRotator) Write(p []byte) (int, error) {
+	w, err := r.GetWriter()
+	if err != nil {
+		return 0, err
+	}
+	return w.Write(p)
+}
 
-	r.cancel()
+// liveWriter writes to the rotator's gzip stream, taking the lock on every
+// write so flushes and rotation never interleave with it
+type liveWriter struct {
+	r *LogRotator
+}
+
+func (w liveWriter) Write(p []byte) (int, error) {
+	w.r.mutex.Lock()
+	defer w.r.mutex.Unlock()
+
+	if w.r.gzWriter == nil {
+		return 0, fmt.Errorf("no current log file")
+	}
+	return w.r.gzWriter.Write(p)
+}
+
+// SetCompressLive switches between plain log files, compressed on rotation,
+// and gzip-compressed files written as adsb_<date>.log.gz directly. The
+// current file is reopened in the new mode; if it was still empty it is
+// removed.
+func (r *LogRotator) SetCompressLive(enabled bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if enabled == r.compressLive {
+		return nil
+	}
+
+	if r.currentFile != nil {
+		oldPath := r.logFilePath(r.currentDate)
+		empty := false
+		if info, err := r.currentFile.Stat(); err == nil {
+			empty = info.Size() == 0
+		}
+		if err := r.closeCurrentLocked(); err != nil {
+			r.logger.WithError(err).Error("Failed to close current log file")
+		}
+		if empty {
+			os.Remove(oldPath)
+		}
+	}
+
+	r.compressLive = enabled
+
+	filepath := r.logFilePath(r.currentDate)
+	if err := r.openCurrentLocked(filepath); err != nil {
+		return fmt.Errorf("failed to open log file %s: %w", filepath, err)
+	}
+	return r.writeHeaderLocked()
+}
+
+// Flush writes buffered compressed output to the current file
+func (r *LogRotator) Flush() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.gzWriter == nil {
+		return nil
+	}
+	if err := r.gzWriter.Flush(); err != nil {
+		return fmt.Errorf("failed to flush compressed log: %w", err)
+	}
+	return nil
+}
+
+// logFileName returns the name of the plain log file for a date
+func (r *LogRotator) logFileName(date string) string {
+	if r.key != "" {
+		return fmt.Sprintf("adsb_%s_%s.log", date, r.key)
+	}
+	return fmt.Sprintf("adsb_%s.log", date)
+}
+
+// logFilePath returns the log file path for a date in the current mode
+func (r *LogRotator) logFilePath(date string) string {
+	name := r.logFileName(date)
+	if r.compressLive {
+		name += ".gz"
+	}
+	return filepath.Join(r.logDir, name)
+}
+
+// openCurrentLocked opens path for appending as the current file. The caller
+// must hold mutex.
+func (r *LogRotator) openCurrentLocked(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	r.currentFile = file
+	if r.compressLive {
+		// Appending to an existing file starts a new gzip member, which
+		// gzip readers concatenate
+		r.gzWriter = gzip.NewWriter(file)
+		r.gzWriter.Name = filepath.Base(strings.TrimSuffix(path, ".gz"))
+		r.gzWriter.ModTime = r.clock.Now()
+	}
+	return nil
+}
+
+// closeCurrentLocked flushes and closes the current file. The caller must
+// hold mutex.
+func (r *LogRotator) closeCurrentLocked() error {
+	var gzErr error
+	if r.gzWriter != nil {
+		gzErr = r.gzWriter.Close()
+		r.gzWriter = nil
+	}
 
+	err := r.currentFile.Close()
+	r.currentFile = nil
+	if gzErr != nil {
+		return fmt.Errorf("failed to finish compressed log: %w", gzErr)
+	}
+	return err
+}
+
+// Reopen closes and reopens the current log file without rotating, so a file
+// moved away by external tooling (e.g. logrotate) is recreated
+func (r *LogRotator) Reopen() error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
 	if r.currentFile != nil {
-		if err := r.currentFile.Close(); err != nil {
+		if err := r.closeCurrentLocked(); err != nil {
 			r.logger.WithError(err).Error("Failed to close current log file")
-			return err
 		}
-		r.currentFile = nil
 	}
 
+	filepath := r.logFilePath(r.currentDate)
+	if err := r.openCurrentLocked(filepath); err != nil {
+		return fmt.Errorf("failed to reopen log file %s: %w", filepath, err)
+	}
+
+	r.logger.WithField("file", filepath).Info("Reopened log file")
+
+	if err := r.writeHeaderLocked(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetHeader sets a line written at the top of every new log file, e.g. a CSV
+// header. It is written to the current file immediately if that is empty.
+func (r *LogRotator) SetHeader(header string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.header = header
+	return r.writeHeaderLocked()
+}
+
+// writeHeaderLocked writes the header to the current file if the file is
+// empty, so appending to an existing file never repeats it. The caller must
+// hold mutex.
+func (r *LogRotator) writeHeaderLocked() error {
+	if r.header == "" || r.currentFile == nil {
+		return nil
+	}
+
+	info, err := r.currentFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	if info.Size() > 0 {
+		return nil
+	}
+
+	var w io.Writer = r.currentFile
+	if r.gzWriter != nil {
+		w = r.gzWriter
+	}
+	if _, err := io.WriteString(w, r.header+"\n"); err != nil {
+		return fmt.Errorf("failed to write log header: %w", err)
+	}
 	return nil
 }
 
+// Close closes the log rotator, waiting for queued compressions to complete.
+// A keyed rotator closed after the date has changed compresses its files of
+// earlier dates, the current one included.
+func (r *LogRotator) Close() error {
+	// Split output opens and closes keyed rotators all the time
+	if r.key != "" {
+		r.logger.WithField("key", r.key).Debug("Closing log rotator")
+	} else {
+		r.logger.Info("Closing log rotator")
+	}
+
+	r.cancel()
+
+	r.mutex.Lock()
+	var err error
+	if r.currentFile != nil {
+		if err = r.closeCurrentLocked(); err != nil {
+			r.logger.WithError(err).Error("Failed to close current log file")
+		}
+	}
+	if r.key != "" && r.compressQueue != nil {
+		if today := r.now().Format("2006-01-02"); r.currentDate != today {
+			r.queueEarlierLocked(today)
+		}
+	}
+	if r.compressQueue != nil {
+		close(r.compressQueue)
+		r.compressQueue = nil
+	}
+	r.mutex.Unlock()
+
+	// Let pending compressions finish
+	<-r.compressDone
+
+	return err
+}
+
+// GetCurrentDate returns the date of the current log file
+func (r *LogRotator) GetCurrentDate() string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.currentDate
+}
+
 // GetCurrentLogFile returns the current log file path
 func (r *LogRotator) GetCurrentLogFile() string {
 	r.mutex.RLock()
@@ -247,7 +554,7 @@ func (r *LogRotator) GetCurrentLogFile() string {
 		return ""
 	}
 
-	return filepath.Join(r.logDir, fmt.Sprintf("adsb_%s.log", r.currentDate))
+	return r.logFilePath(r.currentDate)
 }
 
 // GetLogFiles returns a list of all log files (including compressed ones)
@@ -271,12 +578,7 @@ func (r *LogRotator) CleanupOldLogs(maxDays int) error {
 		return fmt.Errorf("failed to get log files: %w", err)
 	}
 
-	var cutoff time.Time
-	if r.useUTC {
-		cutoff = time.Now().UTC().AddDate(0, 0, -maxDays)
-	} else {
-		cutoff = time.Now().AddDate(0, 0, -maxDays)
-	}
+	cutoff := r.now().AddDate(0, 0, -maxDays)
 
 	removed := 0
 	for _, file := range files {
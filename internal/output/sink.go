@@ -0,0 +1,166 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"go1090/internal/adsb"
+)
+
+// Sink is a destination for decoded messages
+type Sink interface {
+	Write(msg *adsb.DecodedMessage) error
+	Close() error
+}
+
+// Formatter renders a decoded message as a single line, without the newline
+type Formatter func(msg *adsb.DecodedMessage) (string, error)
+
+// LineSink formats each message as a line and writes it to an io.Writer
+type LineSink struct {
+	name   string
+	w      io.Writer
+	format Formatter
+}
+
+// NewLineSink creates a sink writing lines rendered by format to w. The name
+// identifies the sink in errors. w is owned by the caller and not closed.
+func NewLineSink(name string, w io.Writer, format Formatter) *LineSink {
+	return &LineSink{name: name, w: w, format: format}
+}
+
+// Write formats msg and writes it as one line
+func (s *LineSink) Write(msg *adsb.DecodedMessage) error {
+	line, err := s.format(msg)
+	if err != nil {
+		return fmt.Errorf("failed to format message for %s: %w", s.name, err)
+	}
+	// A fresh buffer per line, as writers such as the socket server keep it
+	if _, err := s.w.Write([]byte(line + "\n")); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", s.name, err)
+	}
+	return nil
+}
+
+// Close does nothing; the writer belongs to the caller
+func (s *LineSink) Close() error {
+	return nil
+}
+
+// Predicate decides whether a message is passed on by a FilterSink
+type Predicate func(msg *adsb.DecodedMessage) bool
+
+// HasPosition accepts messages that carry a latitude and longitude
+func HasPosition(msg *adsb.DecodedMessage) bool {
+	return msg.Latitude != nil && msg.Longitude != nil
+}
+
+// PositionResolved accepts every message except a position whose CPR
+// position could not be resolved yet, such as the first frame of a pair
+func PositionResolved(msg *adsb.DecodedMessage) bool {
+	return !msg.IsPosition() || HasPosition(msg)
+}
+
+// NotMilitary accepts messages whose address is outside the military blocks
+func NotMilitary(msg *adsb.DecodedMessage) bool {
+	return adsb.ClassifyICAO(msg.ICAO) != adsb.AddressMilitary
+}
+
+// Anonymous accepts messages whose address is allocated to no state
+func Anonymous(msg *adsb.DecodedMessage) bool {
+	return adsb.ClassifyICAO(msg.ICAO) == adsb.AddressAnonymous
+}
+
+// FilterSink passes only the messages accepted by a predicate to another sink
+type FilterSink struct {
+	sink   Sink
+	accept Predicate
+}
+
+// NewFilterSink creates a sink writing the messages accepted by accept to sink
+func NewFilterSink(sink Sink, accept Predicate) *FilterSink {
+	return &FilterSink{sink: sink, accept: accept}
+}
+
+// Write passes msg on when it is accepted and drops it silently otherwise
+func (s *FilterSink) Write(msg *adsb.DecodedMessage) error {
+	if !s.accept(msg) {
+		return nil
+	}
+	return s.sink.Write(msg)
+}
+
+// Close closes the wrapped sink
+func (s *FilterSink) Close() error {
+	return s.sink.Close()
+}
+
+// HandlerSink passes every message to a MessageHandler
+type HandlerSink struct {
+	name    string
+	handler adsb.MessageHandler
+}
+
+// NewHandlerSink creates a sink calling handler for each message. The name
+// identifies the sink in errors.
+func NewHandlerSink(name string, handler adsb.MessageHandler) *HandlerSink {
+	return &HandlerSink{name: name, handler: handler}
+}
+
+// Write hands msg to the handler
+func (s *HandlerSink) Write(msg *adsb.DecodedMessage) error {
+	if err := s.handler.HandleMessage(msg); err != nil {
+		return fmt.Errorf("%s failed: %w", s.name, err)
+	}
+	return nil
+}
+
+// Close does nothing; the handler belongs to the caller
+func (s *HandlerSink) Close() error {
+	return nil
+}
+
+// MultiSink fans every message out to several sinks. A failing sink does not
+// stop the others from receiving the message.
+type MultiSink struct {
+	sinks []Sink
+}
+
+// NewMultiSink creates a sink writing to each of sinks in order
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+// Add appends a sink. It must not be called concurrently with Write.
+func (m *MultiSink) Add(sink Sink) {
+	m.sinks = append(m.sinks, sink)
+}
+
+// Len returns the number of sinks
+func (m *MultiSink) Len() int {
+	return len(m.sinks)
+}
+
+// Write writes msg to every sink, returning the joined errors of those that
+// failed
+func (m *MultiSink) Write(msg *adsb.DecodedMessage) error {
+	var errs []error
+	for _, sink := range m.sinks {
+		if err := sink.Write(msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every sink, returning the joined errors of those that failed
+func (m *MultiSink) Close() error {
+	var errs []error
+	for _, sink := range m.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
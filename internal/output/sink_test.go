@@ -0,0 +1,170 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go1090/internal/adsb"
+)
+
+// recordingSink remembers the messages written to it
+type recordingSink struct {
+	messages []*adsb.DecodedMessage
+	err      error
+	closed   bool
+}
+
+func (s *recordingSink) Write(msg *adsb.DecodedMessage) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = true
+	return s.err
+}
+
+// failingWriter rejects every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func hexFormat(msg *adsb.DecodedMessage) (string, error) {
+	return strings.ToUpper(msg.Hex), nil
+}
+
+// TestLineSink tests that messages are formatted one per line
+func TestLineSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewLineSink("buffer", &buf, hexFormat)
+
+	require.NoError(t, sink.Write(&adsb.DecodedMessage{Hex: "4840d6"}))
+	require.NoError(t, sink.Write(&adsb.DecodedMessage{Hex: "40621d"}))
+	assert.Equal(t, "4840D6\n40621D\n", buf.String())
+	assert.NoError(t, sink.Close())
+
+	failing := NewLineSink("file", failingWriter{}, hexFormat)
+	err := failing.Write(&adsb.DecodedMessage{Hex: "4840d6"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to write to file")
+
+	badFormat := NewLineSink("buffer", &buf, func(*adsb.DecodedMessage) (string, error) {
+		return "", errors.New("unsupported")
+	})
+	assert.Error(t, badFormat.Write(&adsb.DecodedMessage{}))
+}
+
+// TestMultiSink tests that every sink receives every message
+func TestMultiSink(t *testing.T) {
+	first, second := &recordingSink{}, &recordingSink{}
+	sinks := NewMultiSink(first)
+	sinks.Add(second)
+	assert.Equal(t, 2, sinks.Len())
+
+	msg := &adsb.DecodedMessage{Hex: "4840d6"}
+	require.NoError(t, sinks.Write(msg))
+	assert.Equal(t, []*adsb.DecodedMessage{msg}, first.messages)
+	assert.Equal(t, []*adsb.DecodedMessage{msg}, second.messages)
+
+	require.NoError(t, sinks.Close())
+	assert.True(t, first.closed)
+	assert.True(t, second.closed)
+}
+
+// TestMultiSink_ErrorIsolation tests that a failing sink neither stops the
+// others nor hides their errors
+func TestMultiSink_ErrorIsolation(t *testing.T) {
+	errFull := errors.New("disk full")
+	errGone := errors.New("connection reset")
+	failing, healthy, alsoFailing := &recordingSink{err: errFull}, &recordingSink{}, &recordingSink{err: errGone}
+	sinks := NewMultiSink(failing, healthy, alsoFailing)
+
+	msg := &adsb.DecodedMessage{Hex: "4840d6"}
+	err := sinks.Write(msg)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errFull)
+	assert.ErrorIs(t, err, errGone)
+	assert.Equal(t, []*adsb.DecodedMessage{msg}, healthy.messages)
+
+	// Every sink is closed even when some fail
+	err = sinks.Close()
+	assert.ErrorIs(t, err, errFull)
+	assert.True(t, failing.closed)
+	assert.True(t, healthy.closed)
+	assert.True(t, alsoFailing.closed)
+}
+
+// TestFilterSink tests that only accepted messages reach the wrapped sink
+func TestFilterSink(t *testing.T) {
+	lat, lon := 52.2657, 3.9389
+	positioned := &adsb.DecodedMessage{Hex: "40621d", Latitude: &lat, Longitude: &lon}
+	latOnly := &adsb.DecodedMessage{Hex: "40621d", Latitude: &lat}
+	identification := &adsb.DecodedMessage{Hex: "4840d6", Callsign: "KLM1023 "}
+
+	recorder := &recordingSink{}
+	sink := NewFilterSink(recorder, HasPosition)
+	for _, msg := range []*adsb.DecodedMessage{identification, positioned, latOnly} {
+		require.NoError(t, sink.Write(msg))
+	}
+	assert.Equal(t, []*adsb.DecodedMessage{positioned}, recorder.messages)
+
+	require.NoError(t, sink.Close())
+	assert.True(t, recorder.closed)
+}
+
+// TestPositionResolved tests that only position messages without a resolved
+// position are dropped
+func TestPositionResolved(t *testing.T) {
+	lat, lon := 52.2657, 3.9389
+	alt := 38000
+	resolved := &adsb.DecodedMessage{Hex: "40621d", DF: 17, TypeCode: 11, Altitude: &alt, Latitude: &lat, Longitude: &lon}
+	unresolved := &adsb.DecodedMessage{Hex: "40621d", DF: 17, TypeCode: 11, Altitude: &alt}
+	surface := &adsb.DecodedMessage{Hex: "40621d", DF: 18, TypeCode: 7}
+	identification := &adsb.DecodedMessage{Hex: "4840d6", DF: 17, TypeCode: 4, Callsign: "KLM1023 "}
+	surveillance := &adsb.DecodedMessage{Hex: "40621d", DF: 4, Altitude: &alt}
+
+	recorder := &recordingSink{}
+	sink := NewFilterSink(recorder, PositionResolved)
+	for _, msg := range []*adsb.DecodedMessage{unresolved, resolved, surface, identification, surveillance} {
+		require.NoError(t, sink.Write(msg))
+	}
+	assert.Equal(t, []*adsb.DecodedMessage{resolved, identification, surveillance}, recorder.messages)
+}
+
+// TestAddressPredicates tests filtering by military and anonymous address blocks
+func TestAddressPredicates(t *testing.T) {
+	civil := &adsb.DecodedMessage{ICAO: 0x4840D6, Hex: "4840d6"}
+	military := &adsb.DecodedMessage{ICAO: 0xAE07A5, Hex: "ae07a5"}
+	anonymous := &adsb.DecodedMessage{ICAO: 0xF00001, Hex: "f00001"}
+	messages := []*adsb.DecodedMessage{civil, military, anonymous}
+
+	tests := []struct {
+		name     string
+		accept   Predicate
+		expected []*adsb.DecodedMessage
+	}{
+		{name: "Exclude military", accept: NotMilitary, expected: []*adsb.DecodedMessage{civil, anonymous}},
+		{name: "Anonymous only", accept: Anonymous, expected: []*adsb.DecodedMessage{anonymous}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &recordingSink{}
+			sink := NewFilterSink(recorder, tt.accept)
+			for _, msg := range messages {
+				require.NoError(t, sink.Write(msg))
+			}
+			assert.Equal(t, tt.expected, recorder.messages)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package output
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"go1090/internal/adsb"
+)
+
+// KeyFunc picks the file a SplitSink writes a message to
+type KeyFunc func(msg *adsb.DecodedMessage) string
+
+// ByICAO keys messages by aircraft address, e.g. "484412"
+func ByICAO(msg *adsb.DecodedMessage) string {
+	return msg.Hex
+}
+
+// ByType keys messages by downlink format, e.g. "df17"
+func ByType(msg *adsb.DecodedMessage) string {
+	return fmt.Sprintf("df%d", msg.DF)
+}
+
+// SplitSink formats each message as a line and writes it to a file chosen by
+// its key. At most maxOpen files are open at once: the least recently written
+// is closed to make room, and opened again when next written.
+type SplitSink struct {
+	name    string
+	keyOf   KeyFunc
+	open    func(key string) (io.WriteCloser, error)
+	format  Formatter
+	maxOpen int
+
+	mu    sync.Mutex
+	files map[string]*list.Element
+	lru   *list.List // Of *splitFile, most recently written first
+}
+
+// splitFile is an open file of a SplitSink
+type splitFile struct {
+	key string
+	w   io.WriteCloser
+}
+
+// NewSplitSink creates a sink writing lines rendered by format to the file
+// open returns for each message's key, keeping at most maxOpen (at least 1)
+// files open. The name identifies the sink in errors.
+func NewSplitSink(name string, keyOf KeyFunc, open func(key string) (io.WriteCloser, error), format Formatter, maxOpen int) *SplitSink {
+	if maxOpen < 1 {
+		maxOpen = 1
+	}
+	return &SplitSink{
+		name:    name,
+		keyOf:   keyOf,
+		open:    open,
+		format:  format,
+		maxOpen: maxOpen,
+		files:   make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Write formats msg and writes it as one line to the file of its key
+func (s *SplitSink) Write(msg *adsb.DecodedMessage) error {
+	line, err := s.format(msg)
+	if err != nil {
+		return fmt.Errorf("failed to format message for %s: %w", s.name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.keyOf(msg)
+	w, err := s.fileLocked(key)
+	if w == nil {
+		return err
+	}
+	if _, werr := w.Write([]byte(line + "\n")); werr != nil {
+		return errors.Join(err, fmt.Errorf("failed to write to %s file %s: %w", s.name, key, werr))
+	}
+	return err
+}
+
+// fileLocked returns the open file of key, opening it and closing the least
+// recently written one if needed. A failure to close that one is returned
+// along with the file. The caller must hold mu.
+func (s *SplitSink) fileLocked(key string) (io.Writer, error) {
+	if elem, ok := s.files[key]; ok {
+		s.lru.MoveToFront(elem)
+		return elem.Value.(*splitFile).w, nil
+	}
+
+	var closeErr error
+	if s.lru.Len() >= s.maxOpen {
+		closeErr = s.closeLocked(s.lru.Back())
+	}
+
+	w, err := s.open(key)
+	if err != nil {
+		return nil, errors.Join(closeErr, fmt.Errorf("failed to open %s file %s: %w", s.name, key, err))
+	}
+	s.files[key] = s.lru.PushFront(&splitFile{key: key, w: w})
+	return w, closeErr
+}
+
+// closeLocked closes the file of elem and forgets it. The caller must hold mu.
+func (s *SplitSink) closeLocked(elem *list.Element) error {
+	file := s.lru.Remove(elem).(*splitFile)
+	delete(s.files, file.key)
+	if err := file.w.Close(); err != nil {
+		return fmt.Errorf("failed to close %s file %s: %w", s.name, file.key, err)
+	}
+	return nil
+}
+
+// OpenFiles returns how many files are open
+func (s *SplitSink) OpenFiles() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// Close closes every open file, returning the joined errors of those that
+// failed. Files are opened again by later writes, so a SIGHUP reload can use
+// it to let external tooling move them away.
+func (s *SplitSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for s.lru.Len() > 0 {
+		if err := s.closeLocked(s.lru.Front()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
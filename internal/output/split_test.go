@@ -0,0 +1,68 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go1090/internal/adsb"
+)
+
+// keyedFile collects the lines written to one SplitSink file
+type keyedFile struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (f *keyedFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+// TestSplitSink tests that messages go to the file of their key and that
+// the least recently written file is closed to stay within the open limit
+func TestSplitSink(t *testing.T) {
+	var opened []*keyedFile
+	var openedKeys []string
+	open := func(key string) (io.WriteCloser, error) {
+		if key == "bad" {
+			return nil, errors.New("permission denied")
+		}
+		f := &keyedFile{}
+		opened = append(opened, f)
+		openedKeys = append(openedKeys, key)
+		return f, nil
+	}
+	sink := NewSplitSink("log", ByICAO, open, hexFormat, 2)
+
+	for _, hex := range []string{"4840d6", "40621d", "4840d6", "3c6586", "4840d6", "40621d"} {
+		require.NoError(t, sink.Write(&adsb.DecodedMessage{Hex: hex}))
+	}
+	assert.Equal(t, []string{"4840d6", "40621d", "3c6586", "40621d"}, openedKeys)
+	assert.Equal(t, "4840D6\n4840D6\n4840D6\n", opened[0].String())
+	assert.Equal(t, "40621D\n", opened[1].String())
+	assert.True(t, opened[1].closed, "least recently written file closed for 3c6586")
+	assert.True(t, opened[2].closed, "3c6586 closed for 40621d")
+	assert.False(t, opened[0].closed)
+	assert.Equal(t, 2, sink.OpenFiles())
+
+	err := sink.Write(&adsb.DecodedMessage{Hex: "bad"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to open log file bad")
+
+	require.NoError(t, sink.Close())
+	assert.True(t, opened[0].closed)
+	assert.True(t, opened[3].closed)
+	assert.Zero(t, sink.OpenFiles())
+}
+
+// TestSplitKeys tests the file keys of the split dimensions
+func TestSplitKeys(t *testing.T) {
+	msg := &adsb.DecodedMessage{Hex: "484412", DF: 17}
+	assert.Equal(t, "484412", ByICAO(msg))
+	assert.Equal(t, "df17", ByType(msg))
+}
@@ -0,0 +1,89 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+
+	"go1090/internal/adsb"
+)
+
+// DatagramSize is the payload a UDPSink packs lines into: an Ethernet frame
+// less the IPv4 and UDP headers, so datagrams are not fragmented
+const DatagramSize = 1472
+
+// maxUDPPayload is the largest payload a single IPv4 UDP datagram can carry
+const maxUDPPayload = 65507
+
+// UDPSink formats each message and sends it as UDP datagrams without
+// waiting for, or knowing of, any receiver. A datagram holds whole lines, as
+// many as fit in DatagramSize; a longer line is sent alone, fragmented by IP.
+// Lines beyond the UDP payload limit are dropped, as a truncated record would
+// not parse.
+type UDPSink struct {
+	name   string
+	conn   *net.UDPConn
+	format Formatter
+}
+
+// NewUDPSink creates a sink sending lines rendered by format to addr, a
+// host:port that may be a multicast group. The name identifies the sink in
+// errors.
+func NewUDPSink(name, addr string, format Formatter) (*UDPSink, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s address %q: %w", name, addr, err)
+	}
+	conn, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s to %s: %w", name, addr, err)
+	}
+	return &UDPSink{name: name, conn: conn, format: format}, nil
+}
+
+// Write formats msg and sends its lines. Nobody listening is not an error.
+func (s *UDPSink) Write(msg *adsb.DecodedMessage) error {
+	text, err := s.format(msg)
+	if err != nil {
+		return fmt.Errorf("failed to format message for %s: %w", s.name, err)
+	}
+
+	var errs []error
+	var datagram []byte
+	send := func() {
+		if len(datagram) == 0 {
+			return
+		}
+		// A connected socket reports an earlier datagram's ICMP port
+		// unreachable on a later send
+		if _, err := s.conn.Write(datagram); err != nil && !errors.Is(err, syscall.ECONNREFUSED) {
+			errs = append(errs, fmt.Errorf("failed to send to %s: %w", s.name, err))
+		}
+		datagram = datagram[:0]
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			continue
+		}
+		if len(line)+1 > maxUDPPayload {
+			errs = append(errs, fmt.Errorf("dropped %d-byte line too long for %s", len(line), s.name))
+			continue
+		}
+		if len(datagram)+len(line)+1 > DatagramSize {
+			send()
+		}
+		datagram = append(datagram, line...)
+		datagram = append(datagram, '\n')
+	}
+	send()
+
+	return errors.Join(errs...)
+}
+
+// Close closes the socket
+func (s *UDPSink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,77 @@
+package output
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go1090/internal/adsb"
+)
+
+// TestUDPSink tests that each record reaches a local listener in datagrams
+// of whole lines
+func TestUDPSink(t *testing.T) {
+	listener, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// The message's Hex carries the formatted record
+	sink, err := NewUDPSink("JSON UDP", listener.LocalAddr().String(), func(msg *adsb.DecodedMessage) (string, error) {
+		return msg.Hex, nil
+	})
+	require.NoError(t, err)
+	defer sink.Close()
+
+	receive := func() string {
+		buf := make([]byte, 65536)
+		require.NoError(t, listener.SetReadDeadline(time.Now().Add(time.Second)))
+		n, err := listener.Read(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+
+	// One record, one datagram
+	record := `{"hex":"4840d6","df":17}`
+	require.NoError(t, sink.Write(&adsb.DecodedMessage{Hex: record}))
+	assert.Equal(t, record+"\n", receive())
+
+	// Lines that together overflow a datagram are split between lines
+	line := strings.Repeat("a", 999)
+	require.NoError(t, sink.Write(&adsb.DecodedMessage{Hex: line + "\n" + line + "\n"}))
+	assert.Equal(t, line+"\n", receive())
+	assert.Equal(t, line+"\n", receive())
+
+	// A line over the datagram size goes alone rather than truncated
+	long := strings.Repeat("b", 3*DatagramSize)
+	require.NoError(t, sink.Write(&adsb.DecodedMessage{Hex: long}))
+	assert.Equal(t, long+"\n", receive())
+
+	// A line no datagram can carry is dropped; the rest still go
+	err = sink.Write(&adsb.DecodedMessage{Hex: strings.Repeat("c", maxUDPPayload) + "\n" + record})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "too long for JSON UDP")
+	assert.Equal(t, record+"\n", receive())
+}
+
+// TestUDPSink_NoListener tests that sending with nobody listening succeeds
+func TestUDPSink_NoListener(t *testing.T) {
+	listener, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	addr := listener.LocalAddr().String()
+	listener.Close()
+
+	sink, err := NewUDPSink("SBS UDP", addr, hexFormat)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, sink.Write(&adsb.DecodedMessage{Hex: "4840d6"}))
+	}
+
+	_, err = NewUDPSink("SBS UDP", "no-port", hexFormat)
+	assert.Error(t, err)
+}
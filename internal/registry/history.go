@@ -0,0 +1,80 @@
+package registry
+
+import (
+	"time"
+
+	"go1090/internal/adsb"
+)
+
+// DefaultTrackHistory is how many positions are kept per aircraft
+const DefaultTrackHistory = 200
+
+// TrackPoint is one position in an aircraft's history
+type TrackPoint struct {
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+	Altitude  *int // Feet; nil when the position came without one
+}
+
+// trackHistory is a ring buffer of the most recent positions of one aircraft
+type trackHistory struct {
+	points []TrackPoint
+	next   int // Slot overwritten by the next point once the buffer is full
+}
+
+// add stores a point, replacing the oldest once limit points are held
+func (h *trackHistory) add(point TrackPoint, limit int) {
+	if len(h.points) < limit {
+		h.points = append(h.points, point)
+		return
+	}
+	h.points[h.next] = point
+	h.next = (h.next + 1) % len(h.points)
+}
+
+// snapshot returns a copy of the points, oldest first
+func (h *trackHistory) snapshot() []TrackPoint {
+	points := make([]TrackPoint, 0, len(h.points))
+	points = append(points, h.points[h.next:]...)
+	return append(points, h.points[:h.next]...)
+}
+
+// SetTrackHistory sets how many positions are kept per aircraft; 0 disables
+// the history. It must be called before the first Update.
+func (r *Registry) SetTrackHistory(limit int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.historyLimit = limit
+}
+
+// History returns the recorded positions of one aircraft, oldest first
+func (r *Registry) History(icao uint32) ([]TrackPoint, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ac, ok := r.aircraft[icao]
+	if !ok {
+		return nil, false
+	}
+	return ac.history.snapshot(), true
+}
+
+// recordPositionLocked appends the position of msg, if it has one, to the
+// aircraft's history. The caller must hold mu.
+func (r *Registry) recordPositionLocked(ac *Aircraft, msg *adsb.DecodedMessage) {
+	if r.historyLimit <= 0 || msg.Latitude == nil || msg.Longitude == nil {
+		return
+	}
+
+	point := TrackPoint{
+		Time:      msg.Timestamp,
+		Latitude:  *msg.Latitude,
+		Longitude: *msg.Longitude,
+	}
+	if msg.Altitude != nil {
+		altitude := *msg.Altitude
+		point.Altitude = &altitude
+	}
+	ac.history.add(point, r.historyLimit)
+}
@@ -0,0 +1,277 @@
+package registry
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go1090/internal/adsb"
+	"go1090/internal/clock"
+)
+
+// DefaultAircraftTTL is how long an aircraft is kept after its last message
+const DefaultAircraftTTL = 5 * time.Minute
+
+// MaxFusedTrackAge is how old a velocity track may be and still be attached to
+// a position
+const MaxFusedTrackAge = 10 * time.Second
+
+// Aircraft is the accumulated state of one aircraft
+type Aircraft struct {
+	ICAO      uint32
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Messages  uint64
+
+	// Callsign from the latest identification message (TC1-4)
+	Callsign string
+
+	// ADS-B version from operational status (TC31); nil until one is heard.
+	// Decoding of NIC/NACp and similar fields depends on it.
+	ADSBVersion *int
+
+	// Air/ground state from the latest message stating it; nil until one
+	// is heard
+	OnGround *bool
+
+	// NIC supplements A and C from operational status; nil until heard
+	NICSuppA *bool
+	NICSuppC *bool
+
+	// Track from the latest airborne velocity message (TC19)
+	Track     *float64
+	TrackTime time.Time
+
+	// A TCAS resolution advisory is in force, since RAStart
+	RAActive bool
+	RAStart  time.Time
+
+	// Mode A code from the latest reply or squitter carrying one
+	Squawk string
+
+	// Latest barometric altitude, ground speed and position reported
+	Altitude     *int
+	GroundSpeed  *int
+	Latitude     *float64
+	Longitude    *float64
+	PositionTime time.Time
+
+	// Recent positions, read through Registry.History
+	history trackHistory
+}
+
+// Registry tracks every aircraft currently heard, keyed by ICAO address
+type Registry struct {
+	mu       sync.RWMutex
+	aircraft map[uint32]*Aircraft
+	ttl      time.Duration
+	clock    clock.Clock
+
+	// Positions kept per aircraft; 0 disables the history
+	historyLimit int
+}
+
+// New creates an empty registry
+func New() *Registry {
+	return &Registry{
+		aircraft:     make(map[uint32]*Aircraft),
+		ttl:          DefaultAircraftTTL,
+		clock:        clock.Real{},
+		historyLimit: DefaultTrackHistory,
+	}
+}
+
+// Update records a decoded message against its aircraft, and fills in fields
+// of msg that the aircraft reported earlier (e.g. its ADS-B version, or its
+// air/ground state when msg does not state one)
+func (r *Registry) Update(msg *adsb.DecodedMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ac, ok := r.aircraft[msg.ICAO]
+	if !ok {
+		ac = &Aircraft{ICAO: msg.ICAO, FirstSeen: msg.Timestamp}
+		r.aircraft[msg.ICAO] = ac
+	}
+	ac.LastSeen = msg.Timestamp
+	ac.Messages++
+	if msg.Callsign != "" {
+		ac.Callsign = msg.Callsign
+	}
+
+	if msg.ADSBVersion != nil {
+		version := *msg.ADSBVersion
+		ac.ADSBVersion = &version
+	} else if ac.ADSBVersion != nil {
+		version := *ac.ADSBVersion
+		msg.ADSBVersion = &version
+	}
+
+	if msg.NICSuppA != nil {
+		a := *msg.NICSuppA
+		ac.NICSuppA = &a
+	}
+	if msg.NICSuppC != nil {
+		c := *msg.NICSuppC
+		ac.NICSuppC = &c
+	}
+
+	if msg.GroundKnown {
+		onGround := msg.OnGround
+		ac.OnGround = &onGround
+	} else if ac.OnGround != nil {
+		msg.OnGround = *ac.OnGround
+	}
+
+	r.fuseTrackLocked(ac, msg)
+	r.integrityLocked(ac, msg)
+	r.advisoryLocked(ac, msg)
+	r.recordStateLocked(ac, msg)
+	r.recordPositionLocked(ac, msg)
+}
+
+// recordStateLocked keeps the latest altitude, speed and position of msg. The
+// caller must hold mu.
+func (r *Registry) recordStateLocked(ac *Aircraft, msg *adsb.DecodedMessage) {
+	if msg.Squawk != "" {
+		ac.Squawk = msg.Squawk
+	}
+	if msg.Altitude != nil {
+		altitude := *msg.Altitude
+		ac.Altitude = &altitude
+	}
+	if msg.GroundSpeed != nil {
+		speed := *msg.GroundSpeed
+		ac.GroundSpeed = &speed
+	}
+	if msg.Latitude != nil && msg.Longitude != nil {
+		lat, lon := *msg.Latitude, *msg.Longitude
+		ac.Latitude, ac.Longitude = &lat, &lon
+		ac.PositionTime = msg.Timestamp
+	}
+}
+
+// fuseTrackLocked remembers the track of a velocity message and attaches it to
+// later airborne positions, which carry none, while it is recent. The caller
+// must hold mu.
+func (r *Registry) fuseTrackLocked(ac *Aircraft, msg *adsb.DecodedMessage) {
+	if msg.TypeCode == 19 && msg.Track != nil {
+		track := *msg.Track
+		ac.Track = &track
+		ac.TrackTime = msg.Timestamp
+		return
+	}
+
+	airbornePosition := msg.TypeCode >= 9 && msg.TypeCode <= 22 && msg.Latitude != nil && msg.Longitude != nil
+	if airbornePosition && msg.Track == nil && ac.Track != nil && msg.Timestamp.Sub(ac.TrackTime) <= MaxFusedTrackAge {
+		track := *ac.Track
+		msg.Track = &track
+	}
+}
+
+// integrityLocked sets the NIC and containment radius of a position message.
+// They depend on the aircraft's ADS-B version, taken as 0 until operational
+// status is heard, and its NIC supplements: A and C from operational status,
+// B from the position itself. The caller must hold mu.
+func (r *Registry) integrityLocked(ac *Aircraft, msg *adsb.DecodedMessage) {
+	if !msg.IsPosition() {
+		return
+	}
+
+	version := 0
+	if ac.ADSBVersion != nil {
+		version = *ac.ADSBVersion
+	}
+	var supp adsb.NICSupplements
+	if ac.NICSuppA != nil {
+		supp.A = *ac.NICSuppA
+	}
+	if ac.NICSuppC != nil {
+		supp.C = *ac.NICSuppC
+	}
+	if version >= 2 && msg.SingleAntenna != nil {
+		supp.B = *msg.SingleAntenna
+	}
+
+	nic, rc, ok := adsb.PositionIntegrity(msg.TypeCode, version, supp)
+	if !ok {
+		return
+	}
+	msg.Rc = &rc
+	if nic >= 0 {
+		msg.NIC = &nic
+	}
+}
+
+// advisoryLocked tracks the aircraft's resolution advisory and marks the
+// advisory that starts or ends it. Aircraft keep broadcasting an RA for 18 s
+// after it terminates, with the terminated bit set, so only the first of
+// those ends it. The caller must hold mu.
+func (r *Registry) advisoryLocked(ac *Aircraft, msg *adsb.DecodedMessage) {
+	if msg.ACASRA == nil {
+		return
+	}
+
+	active := msg.ACASRA.Active && !msg.ACASRA.Terminated
+	switch {
+	case active && !ac.RAActive:
+		ac.RAActive = true
+		ac.RAStart = msg.Timestamp
+		msg.RAEvent = adsb.RAEventStart
+	case !active && ac.RAActive:
+		ac.RAActive = false
+		msg.RAEvent = adsb.RAEventEnd
+	}
+}
+
+// Get returns a copy of the state of one aircraft
+func (r *Registry) Get(icao uint32) (Aircraft, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ac, ok := r.aircraft[icao]
+	if !ok {
+		return Aircraft{}, false
+	}
+	return *ac, true
+}
+
+// All returns a copy of the state of every aircraft, ordered by ICAO address
+func (r *Registry) All() []Aircraft {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]Aircraft, 0, len(r.aircraft))
+	for _, ac := range r.aircraft {
+		all = append(all, *ac)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ICAO < all[j].ICAO })
+	return all
+}
+
+// Len returns the number of aircraft tracked
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.aircraft)
+}
+
+// SetClock sets the clock aircraft are expired by
+func (r *Registry) SetClock(clk clock.Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock = clk
+}
+
+// Prune removes aircraft not heard from within the TTL
+func (r *Registry) Prune() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	for icao, ac := range r.aircraft {
+		if now.Sub(ac.LastSeen) > r.ttl {
+			delete(r.aircraft, icao)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package registry
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go1090/internal/adsb"
+	"go1090/internal/clock"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// TestRegistry_ADSBVersion tests that the version from operational status is
+// stored per aircraft and attached to its later messages
+func TestRegistry_ADSBVersion(t *testing.T) {
+	reg := New()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Before any operational status the version is unknown
+	position := &adsb.DecodedMessage{Timestamp: now, ICAO: 0x4840D6}
+	reg.Update(position)
+	assert.Nil(t, position.ADSBVersion)
+
+	reg.Update(&adsb.DecodedMessage{Timestamp: now.Add(time.Second), ICAO: 0x4840D6, ADSBVersion: intPtr(2)})
+
+	later := &adsb.DecodedMessage{Timestamp: now.Add(2 * time.Second), ICAO: 0x4840D6}
+	reg.Update(later)
+	require.NotNil(t, later.ADSBVersion)
+	assert.Equal(t, 2, *later.ADSBVersion)
+
+	// Other aircraft are unaffected
+	other := &adsb.DecodedMessage{Timestamp: now, ICAO: 0xABCDEF}
+	reg.Update(other)
+	assert.Nil(t, other.ADSBVersion)
+
+	ac, ok := reg.Get(0x4840D6)
+	require.True(t, ok)
+	assert.Equal(t, uint64(3), ac.Messages)
+	assert.Equal(t, now, ac.FirstSeen)
+	assert.Equal(t, now.Add(2*time.Second), ac.LastSeen)
+	assert.Equal(t, 2, reg.Len())
+}
+
+// TestRegistry_TrackFusion tests that the track of a velocity message is
+// attached to later airborne positions of the same aircraft while recent
+func TestRegistry_TrackFusion(t *testing.T) {
+	reg := New()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	lat, lon := 52.2657, 3.9389
+	position := func(icao uint32, at time.Time) *adsb.DecodedMessage {
+		return &adsb.DecodedMessage{Timestamp: at, ICAO: icao, TypeCode: 11, Latitude: &lat, Longitude: &lon}
+	}
+
+	track := 182.88
+	reg.Update(&adsb.DecodedMessage{Timestamp: now, ICAO: 0x4840D6, TypeCode: 19, Track: &track})
+
+	fused := position(0x4840D6, now.Add(time.Second))
+	reg.Update(fused)
+	require.NotNil(t, fused.Track)
+	assert.Equal(t, track, *fused.Track)
+
+	data, err := json.Marshal(fused)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"track":182.88`)
+
+	// Other aircraft get no track
+	other := position(0xABCDEF, now.Add(time.Second))
+	reg.Update(other)
+	assert.Nil(t, other.Track)
+
+	// A stale track is not attached
+	stale := position(0x4840D6, now.Add(MaxFusedTrackAge+time.Second))
+	reg.Update(stale)
+	assert.Nil(t, stale.Track)
+}
+
+// TestRegistry_TrackHistory tests that only the most recent positions are kept,
+// oldest first, and that the history goes with the aircraft
+func TestRegistry_TrackHistory(t *testing.T) {
+	reg := New()
+	reg.SetTrackHistory(5)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 12; i++ {
+		lat, lon := 52.0+float64(i)/100, 4.0
+		reg.Update(&adsb.DecodedMessage{
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			ICAO:      0x4840D6,
+			Latitude:  &lat,
+			Longitude: &lon,
+			Altitude:  intPtr(38000 + i*100),
+		})
+		// Messages without a position are not recorded
+		reg.Update(&adsb.DecodedMessage{Timestamp: now.Add(time.Duration(i) * time.Second), ICAO: 0x4840D6})
+	}
+
+	points, ok := reg.History(0x4840D6)
+	require.True(t, ok)
+	require.Len(t, points, 5)
+	for i, point := range points {
+		assert.Equal(t, now.Add(time.Duration(7+i)*time.Second), point.Time)
+		assert.InDelta(t, 52.0+float64(7+i)/100, point.Latitude, 1e-9)
+		require.NotNil(t, point.Altitude)
+		assert.Equal(t, 38000+(7+i)*100, *point.Altitude)
+	}
+
+	reg.SetClock(clock.NewMock(now.Add(time.Hour)))
+	reg.Prune()
+	_, ok = reg.History(0x4840D6)
+	assert.False(t, ok)
+}
+
+// TestRegistry_Prune tests that aircraft expire after the TTL
+func TestRegistry_Prune(t *testing.T) {
+	reg := New()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	mock := clock.NewMock(now)
+	reg.SetClock(mock)
+
+	reg.Update(&adsb.DecodedMessage{Timestamp: now, ICAO: 0x4840D6})
+	reg.Update(&adsb.DecodedMessage{Timestamp: now.Add(DefaultAircraftTTL), ICAO: 0xABCDEF})
+
+	// Nothing is due yet
+	reg.Prune()
+	assert.Equal(t, 2, reg.Len())
+
+	mock.Advance(DefaultAircraftTTL + time.Second)
+	reg.Prune()
+
+	_, ok := reg.Get(0x4840D6)
+	assert.False(t, ok)
+	_, ok = reg.Get(0xABCDEF)
+	assert.True(t, ok)
+}
+
+// TestRegistry_State tests that the latest altitude, speed and position are
+// kept across messages that carry only some of them
+func TestRegistry_State(t *testing.T) {
+	reg := New()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	lat, lon := 52.2657, 3.9389
+
+	reg.Update(&adsb.DecodedMessage{Timestamp: now, ICAO: 0x40621D, Altitude: intPtr(38000), Latitude: &lat, Longitude: &lon})
+	reg.Update(&adsb.DecodedMessage{Timestamp: now.Add(time.Second), ICAO: 0x40621D, GroundSpeed: intPtr(450), Squawk: "7700"})
+	reg.Update(&adsb.DecodedMessage{Timestamp: now.Add(2 * time.Second), ICAO: 0x40621D, Altitude: intPtr(38025)})
+	reg.Update(&adsb.DecodedMessage{Timestamp: now, ICAO: 0x4840D6, Callsign: "KLM1023 "})
+
+	all := reg.All()
+	require.Len(t, all, 2)
+	ac := all[0]
+	assert.Equal(t, uint32(0x40621D), ac.ICAO)
+	assert.Equal(t, uint64(3), ac.Messages)
+	assert.Equal(t, "7700", ac.Squawk)
+	require.NotNil(t, ac.Altitude)
+	assert.Equal(t, 38025, *ac.Altitude)
+	require.NotNil(t, ac.GroundSpeed)
+	assert.Equal(t, 450, *ac.GroundSpeed)
+	require.NotNil(t, ac.Latitude)
+	assert.Equal(t, lat, *ac.Latitude)
+	assert.Equal(t, lon, *ac.Longitude)
+	assert.Equal(t, now, ac.PositionTime)
+
+	ac = all[1]
+	assert.Equal(t, uint32(0x4840D6), ac.ICAO)
+	assert.Equal(t, "KLM1023 ", ac.Callsign)
+	assert.Nil(t, ac.Altitude)
+	assert.Nil(t, ac.Latitude)
+	assert.True(t, ac.PositionTime.IsZero())
+}
+
+// TestRegistry_PositionIntegrity tests that positions get a containment radius
+// for the aircraft's ADS-B version and NIC supplements once they are known
+func TestRegistry_PositionIntegrity(t *testing.T) {
+	reg := New()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	boolPtr := func(v bool) *bool { return &v }
+
+	// Until operational status is heard the aircraft is taken as version 0
+	position := &adsb.DecodedMessage{Timestamp: now, ICAO: 0x4840D6, DF: 17, TypeCode: 11, SingleAntenna: boolPtr(true)}
+	reg.Update(position)
+	assert.Nil(t, position.NIC)
+	require.NotNil(t, position.Rc)
+	assert.Equal(t, 185.2, *position.Rc)
+
+	reg.Update(&adsb.DecodedMessage{Timestamp: now, ICAO: 0x4840D6, DF: 17, TypeCode: 31, ADSBVersion: intPtr(2), NICSuppA: boolPtr(true)})
+
+	// Version 2 with supplements A and B narrows TC11 to 75 m
+	position = &adsb.DecodedMessage{Timestamp: now, ICAO: 0x4840D6, DF: 17, TypeCode: 11, SingleAntenna: boolPtr(true)}
+	reg.Update(position)
+	require.NotNil(t, position.NIC)
+	assert.Equal(t, 9, *position.NIC)
+	assert.Equal(t, 75.0, *position.Rc)
+
+	position = &adsb.DecodedMessage{Timestamp: now, ICAO: 0x4840D6, DF: 17, TypeCode: 11, SingleAntenna: boolPtr(false)}
+	reg.Update(position)
+	assert.Equal(t, 8, *position.NIC)
+	assert.Equal(t, 185.2, *position.Rc)
+
+	// Other messages get no integrity
+	velocity := &adsb.DecodedMessage{Timestamp: now, ICAO: 0x4840D6, DF: 17, TypeCode: 19}
+	reg.Update(velocity)
+	assert.Nil(t, velocity.NIC)
+	assert.Nil(t, velocity.Rc)
+}
+
+// TestRegistry_AdvisoryEvents tests that a resolution advisory broadcast
+// repeatedly while active and then while terminated starts and ends once
+func TestRegistry_AdvisoryEvents(t *testing.T) {
+	reg := New()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	active := adsb.ACASRA{Active: true, ARA: 0x2000}
+	terminated := adsb.ACASRA{Active: true, Terminated: true, ARA: 0x2000}
+	sequence := []adsb.ACASRA{active, active, active, terminated, terminated, {}, active}
+	expected := []string{adsb.RAEventStart, "", "", adsb.RAEventEnd, "", "", adsb.RAEventStart}
+
+	var events []string
+	for i, ra := range sequence {
+		ra := ra
+		msg := &adsb.DecodedMessage{Timestamp: start.Add(time.Duration(i) * time.Second), ICAO: 0x4840D6, DF: 17, TypeCode: 28, ACASRA: &ra}
+		reg.Update(msg)
+		events = append(events, msg.RAEvent)
+
+		// Another aircraft's messages leave the advisory alone
+		other := &adsb.DecodedMessage{Timestamp: msg.Timestamp, ICAO: 0x40621D, DF: 17, TypeCode: 19}
+		reg.Update(other)
+		assert.Empty(t, other.RAEvent)
+
+		if i == 2 {
+			ac, ok := reg.Get(0x4840D6)
+			require.True(t, ok)
+			assert.True(t, ac.RAActive)
+			assert.Equal(t, start, ac.RAStart)
+		}
+	}
+	assert.Equal(t, expected, events)
+
+	// An advisory first heard terminated ends nothing
+	late := &adsb.DecodedMessage{Timestamp: start, ICAO: 0x3C6586, DF: 17, TypeCode: 28, ACASRA: &terminated}
+	reg.Update(late)
+	assert.Empty(t, late.RAEvent)
+}
@@ -11,6 +11,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	rtlsdr "github.com/jpoirier/gortlsdr"
 	"github.com/sirupsen/logrus"
@@ -21,13 +24,68 @@ const (
 	BufferChunkSize = 16384 // 16KB chunk size for RTL-SDR buffer
 )
 
+// Reopen constants for recovering from transient USB errors
+const (
+	DefaultMaxReopenAttempts = 5           // Reopen attempts before giving up
+	DefaultReopenBackoff     = time.Second // Backoff step between reopen attempts
+)
+
+// cancelRetryInterval is how often StartCapture cancels the async read again
+// while waiting for it to return
+const cancelRetryInterval = 100 * time.Millisecond
+
+// sdrContext is the subset of the librtlsdr context used by RTLSDRDevice,
+// so the hardware can be replaced by a mock in tests
+type sdrContext interface {
+	SetCenterFreq(freqHz int) error
+	SetSampleRate(rateHz int) error
+	SetTunerGainMode(manualMode bool) error
+	SetTunerGain(gainTenthsDb int) error
+	SetFreqCorrection(ppm int) error
+	SetBiasTee(enable bool) error
+	SetOffsetTuning(enable bool) error
+	ResetBuffer() error
+	ReadAsync(f rtlsdr.ReadAsyncCbT, userCtx *rtlsdr.UserCtx, bufNum, bufLen int) error
+	CancelAsync() error
+	Close() error
+}
+
+// TuningOptions are optional tuner settings, left at the librtlsdr defaults
+// when zero
+type TuningOptions struct {
+	PPM          int  // Frequency correction in parts per million
+	BiasTee      bool // Power an external LNA over the antenna input
+	OffsetTuning bool // Tune off-centre to avoid the DC spike (E4000 tuners)
+}
+
+// openDevice opens the librtlsdr device at the given index
+func openDevice(index int) (sdrContext, error) {
+	dev, err := rtlsdr.Open(index)
+	if err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
 // RTLSDRDevice represents an RTL-SDR device
 type RTLSDRDevice struct {
-	device   *rtlsdr.Context
+	device   sdrContext
+	openFn   func(index int) (sdrContext, error)
 	logger   *logrus.Logger
 	index    int
 	isOpen   bool
 	cancelFn context.CancelFunc
+	mu       sync.Mutex
+
+	// Last Configure parameters, re-applied after a reopen
+	frequency  uint32
+	sampleRate uint32
+	gain       int
+	tuning     TuningOptions
+
+	maxReopenAttempts int
+	reopenBackoff     time.Duration
+	reopenCount       uint64
 }
 
 // NewRTLSDRDevice creates a new RTL-SDR device
@@ -37,75 +95,206 @@ func NewRTLSDRDevice(index int) (*RTLSDRDevice, error) {
 	// Check if device exists
 	count := rtlsdr.GetDeviceCount()
 	if count == 0 {
-		return nil, errors.New("no RTL-SDR devices found")
+		return nil, ErrDeviceNotFound
 	}
 
 	if index >= count {
-		return nil, fmt.Errorf("device index %d out of range (0-%d)", index, count-1)
+		return nil, fmt.Errorf("device index %d out of range (0-%d): %w", index, count-1, ErrDeviceNotFound)
 	}
 
 	return &RTLSDRDevice{
-		logger: logger,
-		index:  index,
-		isOpen: false,
+		logger:            logger,
+		index:             index,
+		isOpen:            false,
+		openFn:            openDevice,
+		maxReopenAttempts: DefaultMaxReopenAttempts,
+		reopenBackoff:     DefaultReopenBackoff,
 	}, nil
 }
 
+// SetTuningOptions sets the optional tuner settings applied by Configure and
+// after every reopen. It must be called before Configure.
+func (r *RTLSDRDevice) SetTuningOptions(opts TuningOptions) {
+	r.tuning = opts
+}
+
 // Configure configures the RTL-SDR device
 func (r *RTLSDRDevice) Configure(frequency, sampleRate uint32, gain int) error {
-	var err error
+	r.frequency = frequency
+	r.sampleRate = sampleRate
+	r.gain = gain
 
 	// Open device
-	r.device, err = rtlsdr.Open(r.index)
+	device, err := r.openFn(r.index)
 	if err != nil {
-		return fmt.Errorf("failed to open device: %w", err)
+		return newConfigureError("open device", err)
 	}
+	r.device = device
 	r.isOpen = true
 
+	if err := r.applySettings(); err != nil {
+		return err
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"device_index": r.index,
+		"frequency":    frequency,
+		"sample_rate":  sampleRate,
+		"gain":         gain,
+		"ppm":          r.tuning.PPM,
+		"bias_tee":     r.tuning.BiasTee,
+		"offset_tune":  r.tuning.OffsetTuning,
+	}).Info("RTL-SDR device configured successfully")
+
+	return nil
+}
+
+// SetFrequency retunes the open device. The new frequency is kept and
+// re-applied after a reopen.
+func (r *RTLSDRDevice) SetFrequency(frequency uint32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.isOpen {
+		return errors.New("device not open")
+	}
+	if err := r.device.SetCenterFreq(int(frequency)); err != nil {
+		return newConfigureError("set frequency", err)
+	}
+	if err := r.device.ResetBuffer(); err != nil {
+		return newConfigureError("reset buffer", err)
+	}
+	r.frequency = frequency
+	return nil
+}
+
+// applySettings applies the stored frequency, sample rate and gain to the open device
+func (r *RTLSDRDevice) applySettings() error {
+	frequency, sampleRate, gain := r.frequency, r.sampleRate, r.gain
+
 	// Set frequency
 	if err := r.device.SetCenterFreq(int(frequency)); err != nil {
-		return fmt.Errorf("failed to set frequency: %w", err)
+		return newConfigureError("set frequency", err)
 	}
 
 	// Set sample rate
 	if err := r.device.SetSampleRate(int(sampleRate)); err != nil {
-		return fmt.Errorf("failed to set sample rate: %w", err)
+		return newConfigureError("set sample rate", err)
 	}
 
 	// Set gain
 	if gain == 0 {
 		// Auto gain
 		if err := r.device.SetTunerGainMode(false); err != nil {
-			return fmt.Errorf("failed to set auto gain: %w", err)
+			return newConfigureError("set auto gain", err)
 		}
 	} else {
 		// Manual gain
 		if err := r.device.SetTunerGainMode(true); err != nil {
-			return fmt.Errorf("failed to set manual gain mode: %w", err)
+			return newConfigureError("set manual gain mode", err)
 		}
 
 		// Convert gain to tenths of dB
 		gainTenths := gain * 10
 		if err := r.device.SetTunerGain(gainTenths); err != nil {
-			return fmt.Errorf("failed to set gain: %w", err)
+			return newConfigureError("set gain", err)
 		}
 	}
 
+	if err := r.applyTuningOptions(); err != nil {
+		return err
+	}
+
 	// Reset buffer
 	if err := r.device.ResetBuffer(); err != nil {
-		return fmt.Errorf("failed to reset buffer: %w", err)
+		return newConfigureError("reset buffer", err)
 	}
 
-	r.logger.WithFields(logrus.Fields{
-		"device_index": r.index,
-		"frequency":    frequency,
-		"sample_rate":  sampleRate,
-		"gain":         gain,
-	}).Info("RTL-SDR device configured successfully")
+	return nil
+}
+
+// applyTuningOptions applies the optional tuner settings that are enabled.
+// Bias-tee and offset tuning depend on the dongle, tuner and librtlsdr
+// build, so when they are rejected a warning is logged and reception goes on.
+func (r *RTLSDRDevice) applyTuningOptions() error {
+	opts := r.tuning
+
+	if opts.PPM != 0 {
+		if err := r.device.SetFreqCorrection(opts.PPM); err != nil {
+			return newConfigureError("set frequency correction", err)
+		}
+	}
+
+	if opts.BiasTee {
+		if err := r.device.SetBiasTee(true); err != nil {
+			r.logger.WithError(err).Warn("Bias-tee not supported by this dongle or librtlsdr build")
+		}
+	}
+
+	if opts.OffsetTuning {
+		if err := r.device.SetOffsetTuning(true); err != nil {
+			r.logger.WithError(err).Warn("Offset tuning not supported by this tuner")
+		}
+	}
 
 	return nil
 }
 
+// reopen closes the device and opens it again with the stored settings,
+// retrying with a linear backoff up to maxReopenAttempts times
+func (r *RTLSDRDevice) reopen(ctx context.Context) error {
+	var lastErr error
+
+	for attempt := 1; attempt <= r.maxReopenAttempts; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * r.reopenBackoff):
+		}
+
+		r.mu.Lock()
+		// Forget the handle once closed so a failed attempt cannot leave a
+		// freed device behind for CancelAsync or Close
+		if r.device != nil {
+			r.device.Close()
+			r.device = nil
+		}
+		device, err := r.openFn(r.index)
+		if err != nil {
+			err = newConfigureError("open device", err)
+		} else {
+			r.device = device
+			if err = r.applySettings(); err != nil {
+				device.Close()
+				r.device = nil
+			}
+		}
+		r.isOpen = err == nil
+		r.mu.Unlock()
+
+		if err == nil {
+			atomic.AddUint64(&r.reopenCount, 1)
+			r.logger.WithField("attempt", attempt).Info("RTL-SDR device reopened")
+			return nil
+		}
+
+		lastErr = err
+		r.logger.WithError(err).WithField("attempt", attempt).Warn("Failed to reopen RTL-SDR device")
+
+		// Permissions will not change by retrying
+		if errors.Is(err, ErrAccessDenied) {
+			return fmt.Errorf("failed to reopen device: %w", err)
+		}
+	}
+
+	return fmt.Errorf("failed to reopen device after %d attempts: %w", r.maxReopenAttempts, lastErr)
+}
+
+// GetReopenCount returns how many times the device was reopened after a read error
+func (r *RTLSDRDevice) GetReopenCount() uint64 {
+	return atomic.LoadUint64(&r.reopenCount)
+}
+
 // StartCapture starts capturing data from the RTL-SDR device
 func (r *RTLSDRDevice) StartCapture(ctx context.Context, dataChan chan<- []byte) error {
 	if !r.isOpen {
@@ -133,29 +322,72 @@ func (r *RTLSDRDevice) StartCapture(ctx context.Context, dataChan chan<- []byte)
 
 	r.logger.Info("Starting RTL-SDR capture")
 
-	// Start async reading in a goroutine
+	// Start async reading in a goroutine, reopening the device on read errors
+	readErr := make(chan error, 1)
+	readDone := make(chan struct{})
 	go func() {
+		defer close(readDone)
 		defer func() {
 			if panicData := recover(); panicData != nil {
 				r.logger.WithField("panic", panicData).Error("RTL-SDR capture panic")
 			}
 		}()
 
-		// This will block until canceled
-		if err := r.device.ReadAsync(callback, nil, 0, bufLen); err != nil {
-			r.logger.WithError(err).Error("RTL-SDR read async failed")
+		for {
+			r.mu.Lock()
+			device := r.device
+			r.mu.Unlock()
+
+			// This will block until canceled or the device fails
+			err := device.ReadAsync(callback, nil, 0, bufLen)
+			if captureCtx.Err() != nil {
+				return
+			}
+			if err == nil {
+				err = errors.New("async read ended unexpectedly")
+			}
+			r.logger.WithError(err).Error("RTL-SDR read async failed, reopening device")
+
+			if err := r.reopen(captureCtx); err != nil {
+				if captureCtx.Err() == nil {
+					readErr <- err
+				}
+				return
+			}
 		}
 	}()
 
-	// Wait for context cancellation
-	<-captureCtx.Done()
+	// Wait for context cancellation or an unrecoverable device error
+	var captureErr error
+	select {
+	case <-captureCtx.Done():
+	case captureErr = <-readErr:
+		cancel()
+	}
 
-	// Cancel async reading
-	if err := r.device.CancelAsync(); err != nil {
-		r.logger.WithError(err).Error("Failed to cancel async reading")
+	// Cancel async reading and wait for the reader to return, so a capture
+	// started next does not find the last async read still winding down.
+	// A read started just after a cancel is canceled again.
+	for {
+		r.cancelAsync()
+		select {
+		case <-readDone:
+			return captureErr
+		case <-time.After(cancelRetryInterval):
+		}
 	}
+}
 
-	return nil
+// cancelAsync cancels the async read of the open device, if any
+func (r *RTLSDRDevice) cancelAsync() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.device != nil {
+		if err := r.device.CancelAsync(); err != nil {
+			r.logger.WithError(err).Error("Failed to cancel async reading")
+		}
+	}
 }
 
 // Close closes the RTL-SDR device
@@ -164,11 +396,17 @@ func (r *RTLSDRDevice) Close() error {
 		r.cancelFn()
 	}
 
-	if r.device != nil && r.isOpen {
-		if err := r.device.Close(); err != nil {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The handle is dropped even if closing fails, so it is never freed twice
+	if r.device != nil {
+		device := r.device
+		r.device = nil
+		r.isOpen = false
+		if err := device.Close(); err != nil {
 			return fmt.Errorf("failed to close device: %w", err)
 		}
-		r.isOpen = false
 		r.logger.Info("RTL-SDR device closed")
 	}
 
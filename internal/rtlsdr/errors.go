@@ -0,0 +1,102 @@
+package rtlsdr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error classes for RTL-SDR failures, matched with errors.Is
+var (
+	ErrDeviceNotFound = errors.New("no RTL-SDR devices found")
+	ErrDeviceBusy     = errors.New("RTL-SDR device busy")
+	ErrAccessDenied   = errors.New("RTL-SDR device access denied")
+)
+
+// librtlsdr passes libusb return codes through; gortlsdr turns them into
+// errors carrying only these messages, so the code is recovered by message
+var libErrorCodes = map[string]int{
+	"input/output error":                              -1,
+	"invalid parameter(s)":                            -2,
+	"access denied (insufficient permissions)":        -3,
+	"no such device (it may have been disconnected)":  -4,
+	"entity not found":                                -5,
+	"resource busy":                                   -6,
+	"operation timed out":                             -7,
+	"overflow":                                        -8,
+	"pipe error":                                      -9,
+	"system call interrupted (perhaps due to signal)": -10,
+	"insufficient memory":                             -11,
+	"operation not supported or unimplemented on this platform": -12,
+}
+
+// libErrorMessages describes the common return codes for the user
+var libErrorMessages = map[int]string{
+	-1:  "I/O error",
+	-2:  "invalid parameter",
+	-3:  "access denied, check the udev rules or run with sufficient permissions",
+	-4:  "no such device, it may have been disconnected",
+	-5:  "device not found",
+	-6:  "device busy, another program or the DVB kernel driver may be using it",
+	-7:  "operation timed out",
+	-11: "insufficient memory",
+	-12: "operation not supported",
+}
+
+// ErrConfigure reports a failed librtlsdr operation with its return code
+type ErrConfigure struct {
+	Op   string // Operation, e.g. "set frequency"
+	Code int    // librtlsdr return code, 0 when unknown
+	Err  error
+}
+
+// newConfigureError wraps err from the named operation
+func newConfigureError(op string, err error) *ErrConfigure {
+	return &ErrConfigure{Op: op, Code: errorCode(err), Err: err}
+}
+
+func (e *ErrConfigure) Error() string {
+	msg := e.Err.Error()
+	if described, ok := libErrorMessages[e.Code]; ok {
+		msg = described
+	}
+	if e.Code != 0 {
+		return fmt.Sprintf("failed to %s: %s (code %d)", e.Op, msg, e.Code)
+	}
+	return fmt.Sprintf("failed to %s: %s", e.Op, msg)
+}
+
+func (e *ErrConfigure) Unwrap() error {
+	return e.Err
+}
+
+// Is classifies the error by its return code
+func (e *ErrConfigure) Is(target error) bool {
+	class := classifyError(e.Op, e.Code)
+	return class != nil && target == class
+}
+
+// errorCode recovers the librtlsdr return code from a gortlsdr error, or 0
+func errorCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return libErrorCodes[err.Error()]
+}
+
+// classifyError maps a return code to an error class, or nil when the code
+// has none. librtlsdr's open returns -1 when there is no device at the index.
+func classifyError(op string, code int) error {
+	switch code {
+	case -4, -5:
+		return ErrDeviceNotFound
+	case -6:
+		return ErrDeviceBusy
+	case -3:
+		return ErrAccessDenied
+	case -1:
+		if op == "open device" {
+			return ErrDeviceNotFound
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package rtlsdr
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	rtlsdr "github.com/jpoirier/gortlsdr"
+)
+
+// DeviceInfo describes an attached RTL-SDR dongle
+type DeviceInfo struct {
+	Index        int
+	Name         string
+	Manufacturer string
+	Product      string
+	Serial       string
+}
+
+// ListDevices returns every RTL-SDR dongle librtlsdr can see. The USB strings
+// are left empty when they cannot be read, e.g. while another program has
+// the device open.
+func ListDevices() []DeviceInfo {
+	count := rtlsdr.GetDeviceCount()
+	devices := make([]DeviceInfo, 0, count)
+	for i := 0; i < count; i++ {
+		info := DeviceInfo{Index: i, Name: rtlsdr.GetDeviceName(i)}
+		if manufacturer, product, serial, err := rtlsdr.GetDeviceUsbStrings(i); err == nil {
+			info.Manufacturer, info.Product, info.Serial = manufacturer, product, serial
+		}
+		devices = append(devices, info)
+	}
+	return devices
+}
+
+// WriteDeviceTable writes devices to w as a table of index, name and serial,
+// or a short explanation when there are none
+func WriteDeviceTable(w io.Writer, devices []DeviceInfo) error {
+	if len(devices) == 0 {
+		_, err := fmt.Fprintln(w, "No RTL-SDR devices found. Check that the dongle is plugged in and not claimed by the DVB kernel driver.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tNAME\tMANUFACTURER\tPRODUCT\tSERIAL")
+	for _, d := range devices {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", d.Index, orDash(d.Name), orDash(d.Manufacturer), orDash(d.Product), orDash(d.Serial))
+	}
+	return tw.Flush()
+}
+
+// orDash stands in for an unknown table value
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
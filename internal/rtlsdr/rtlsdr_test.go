@@ -2,13 +2,160 @@ package rtlsdr
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	rtlsdr "github.com/jpoirier/gortlsdr"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// mockSDRState is shared between all mock contexts opened for one device
+type mockSDRState struct {
+	mu        sync.Mutex
+	failReads int
+	reads     int
+	opens     int
+	closes    int
+	failOpens bool
+	openErr   error // Returned by failing opens, "no such device" when nil
+
+	// Optional tuner settings applied, and the error they return
+	tuningCalls []string
+	tuningErr   error
+
+	frequency int // Last centre frequency set
+
+	gainCalls []string // Gain mode and gain settings, in call order
+
+	// Async reads in progress, and how long one takes to wind down once
+	// canceled. librtlsdr rejects a read started while one is in progress.
+	activeReads int
+	readExit    time.Duration
+}
+
+// mockSDRContext is a fake librtlsdr context whose first failReads reads fail
+type mockSDRContext struct {
+	state      *mockSDRState
+	cancelOnce sync.Once
+	cancel     chan struct{}
+}
+
+func newMockOpenFn(state *mockSDRState) func(int) (sdrContext, error) {
+	return func(index int) (sdrContext, error) {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		state.opens++
+		if state.failOpens && state.opens > 1 {
+			if state.openErr != nil {
+				return nil, state.openErr
+			}
+			return nil, errors.New("no such device")
+		}
+		return &mockSDRContext{state: state, cancel: make(chan struct{})}, nil
+	}
+}
+
+func (m *mockSDRContext) SetSampleRate(rateHz int) error { return nil }
+func (m *mockSDRContext) ResetBuffer() error             { return nil }
+func (m *mockSDRContext) Close() error {
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	m.state.closes++
+	return nil
+}
+
+func (m *mockSDRContext) SetTunerGainMode(manualMode bool) error {
+	return m.recordGain(fmt.Sprintf("manual=%t", manualMode))
+}
+
+func (m *mockSDRContext) SetTunerGain(gainTenthsDb int) error {
+	return m.recordGain(fmt.Sprintf("gain=%d", gainTenthsDb))
+}
+
+func (m *mockSDRContext) recordGain(call string) error {
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	m.state.gainCalls = append(m.state.gainCalls, call)
+	return nil
+}
+
+func (m *mockSDRContext) SetCenterFreq(freqHz int) error {
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	m.state.frequency = freqHz
+	return nil
+}
+
+func (m *mockSDRContext) SetFreqCorrection(ppm int) error {
+	return m.recordTuning(fmt.Sprintf("ppm=%d", ppm))
+}
+
+func (m *mockSDRContext) SetBiasTee(enable bool) error {
+	return m.recordTuning(fmt.Sprintf("bias-tee=%t", enable))
+}
+
+func (m *mockSDRContext) SetOffsetTuning(enable bool) error {
+	return m.recordTuning(fmt.Sprintf("offset-tuning=%t", enable))
+}
+
+func (m *mockSDRContext) recordTuning(call string) error {
+	m.state.mu.Lock()
+	defer m.state.mu.Unlock()
+	m.state.tuningCalls = append(m.state.tuningCalls, call)
+	return m.state.tuningErr
+}
+
+func (m *mockSDRContext) ReadAsync(f rtlsdr.ReadAsyncCbT, _ *rtlsdr.UserCtx, bufNum, bufLen int) error {
+	m.state.mu.Lock()
+	m.state.reads++
+	fail := m.state.reads <= m.state.failReads
+	busy := m.state.activeReads > 0
+	if !busy && !fail {
+		m.state.activeReads++
+	}
+	m.state.mu.Unlock()
+
+	if busy {
+		return errors.New("async read already in progress")
+	}
+	if fail {
+		return errors.New("input/output error")
+	}
+
+	f([]byte{127, 127, 130, 125})
+	<-m.cancel
+	time.Sleep(m.state.readExit)
+
+	m.state.mu.Lock()
+	m.state.activeReads--
+	m.state.mu.Unlock()
+	return nil
+}
+
+func (m *mockSDRContext) CancelAsync() error {
+	m.cancelOnce.Do(func() { close(m.cancel) })
+	return nil
+}
+
+func newMockDevice(state *mockSDRState) *RTLSDRDevice {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	return &RTLSDRDevice{
+		logger:            logger,
+		openFn:            newMockOpenFn(state),
+		maxReopenAttempts: 3,
+		reopenBackoff:     time.Millisecond,
+	}
+}
+
 // TestNewRTLSDRDevice tests the NewRTLSDRDevice function
 func TestNewRTLSDRDevice(t *testing.T) {
 	// Since we can't control the actual RTL-SDR count, we'll test the basic structure
@@ -175,6 +322,274 @@ func TestRTLSDRDevice_ContextCancellation(t *testing.T) {
 	assert.True(t, true)
 }
 
+// TestRTLSDRDevice_ReopenOnReadError tests that capture resumes after transient read errors
+func TestRTLSDRDevice_ReopenOnReadError(t *testing.T) {
+	state := &mockSDRState{failReads: 2}
+	device := newMockDevice(state)
+	require.NoError(t, device.Configure(1090000000, 2400000, 40))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dataChan := make(chan []byte, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- device.StartCapture(ctx, dataChan)
+	}()
+
+	select {
+	case data := <-dataChan:
+		assert.Len(t, data, 4)
+	case <-time.After(time.Second):
+		t.Fatal("no data received after reopen")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("StartCapture did not return after cancellation")
+	}
+
+	assert.Equal(t, uint64(2), device.GetReopenCount())
+	assert.Equal(t, 3, state.opens)
+}
+
+// TestRTLSDRDevice_BackToBackCapture tests that StartCapture returns only
+// once its async read has ended, so the next capture, as a scan starts on
+// each frequency, does not collide with it and reopen the device
+func TestRTLSDRDevice_BackToBackCapture(t *testing.T) {
+	state := &mockSDRState{readExit: 50 * time.Millisecond}
+	device := newMockDevice(state)
+	require.NoError(t, device.Configure(1090000000, 2400000, 40))
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := device.StartCapture(ctx, make(chan []byte, 10))
+		cancel()
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, uint64(0), device.GetReopenCount())
+	assert.Equal(t, 3, state.reads)
+}
+
+// TestRTLSDRDevice_ReopenGivesUp tests that capture fails once reopen attempts are exhausted
+func TestRTLSDRDevice_ReopenGivesUp(t *testing.T) {
+	state := &mockSDRState{failReads: 1, failOpens: true}
+	device := newMockDevice(state)
+	require.NoError(t, device.Configure(1090000000, 2400000, 40))
+
+	dataChan := make(chan []byte, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- device.StartCapture(context.Background(), dataChan)
+	}()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to reopen device after 3 attempts")
+	case <-time.After(time.Second):
+		t.Fatal("StartCapture did not give up")
+	}
+
+	assert.Equal(t, uint64(0), device.GetReopenCount())
+
+	// The handle closed before reopening is not closed again, and the device
+	// reports closed
+	require.NoError(t, device.Close())
+	assert.Equal(t, 1, state.closes)
+	err := device.StartCapture(context.Background(), dataChan)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "device not open")
+}
+
+// TestRTLSDRDevice_ReopenAccessDenied tests that reopening stops at a permission error
+func TestRTLSDRDevice_ReopenAccessDenied(t *testing.T) {
+	state := &mockSDRState{
+		failReads: 1,
+		failOpens: true,
+		openErr:   errors.New("access denied (insufficient permissions)"),
+	}
+	device := newMockDevice(state)
+	require.NoError(t, device.Configure(1090000000, 2400000, 40))
+
+	err := device.StartCapture(context.Background(), make(chan []byte, 10))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrAccessDenied)
+	assert.Equal(t, 2, state.opens)
+}
+
+// TestClassifyError tests mapping librtlsdr errors to error classes and messages
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      string
+		err     error
+		class   error
+		code    int
+		message string
+	}{
+		{
+			name:    "Busy",
+			op:      "open device",
+			err:     errors.New("resource busy"),
+			class:   ErrDeviceBusy,
+			code:    -6,
+			message: "failed to open device: device busy, another program or the DVB kernel driver may be using it (code -6)",
+		},
+		{
+			name:    "Disconnected",
+			op:      "set frequency",
+			err:     errors.New("no such device (it may have been disconnected)"),
+			class:   ErrDeviceNotFound,
+			code:    -4,
+			message: "failed to set frequency: no such device, it may have been disconnected (code -4)",
+		},
+		{
+			name:    "No device at index",
+			op:      "open device",
+			err:     errors.New("input/output error"),
+			class:   ErrDeviceNotFound,
+			code:    -1,
+			message: "failed to open device: I/O error (code -1)",
+		},
+		{
+			name:    "I/O error while configuring",
+			op:      "set sample rate",
+			err:     errors.New("input/output error"),
+			code:    -1,
+			message: "failed to set sample rate: I/O error (code -1)",
+		},
+		{
+			name:    "Permissions",
+			op:      "open device",
+			err:     errors.New("access denied (insufficient permissions)"),
+			class:   ErrAccessDenied,
+			code:    -3,
+			message: "failed to open device: access denied, check the udev rules or run with sufficient permissions (code -3)",
+		},
+		{
+			name:    "Unrecognised",
+			op:      "reset buffer",
+			err:     errors.New("something else"),
+			message: "failed to reset buffer: something else",
+		},
+	}
+
+	classes := []error{ErrDeviceNotFound, ErrDeviceBusy, ErrAccessDenied}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := error(newConfigureError(tt.op, tt.err))
+
+			var configureErr *ErrConfigure
+			require.ErrorAs(t, err, &configureErr)
+			assert.Equal(t, tt.op, configureErr.Op)
+			assert.Equal(t, tt.code, configureErr.Code)
+			assert.Equal(t, tt.message, err.Error())
+			assert.ErrorIs(t, err, tt.err)
+
+			for _, class := range classes {
+				assert.Equal(t, class == tt.class, errors.Is(err, class), "class %v", class)
+			}
+		})
+	}
+}
+
+// TestRTLSDRDevice_TuningOptions tests that Configure applies only the
+// optional tuner settings that are enabled, and again after a reopen
+func TestRTLSDRDevice_TuningOptions(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		state := &mockSDRState{}
+		device := newMockDevice(state)
+		require.NoError(t, device.Configure(1090000000, 2400000, 40))
+		assert.Empty(t, state.tuningCalls)
+	})
+
+	t.Run("All enabled", func(t *testing.T) {
+		state := &mockSDRState{}
+		device := newMockDevice(state)
+		device.SetTuningOptions(TuningOptions{PPM: -12, BiasTee: true, OffsetTuning: true})
+		require.NoError(t, device.Configure(1090000000, 2400000, 40))
+		assert.Equal(t, []string{"ppm=-12", "bias-tee=true", "offset-tuning=true"}, state.tuningCalls)
+
+		// A reopened device gets the same settings
+		require.NoError(t, device.applySettings())
+		assert.Len(t, state.tuningCalls, 6)
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		// Bias-tee and offset tuning are best effort, a rejected PPM is not
+		state := &mockSDRState{tuningErr: errors.New("invalid parameter(s)")}
+		device := newMockDevice(state)
+		device.SetTuningOptions(TuningOptions{BiasTee: true, OffsetTuning: true})
+		require.NoError(t, device.Configure(1090000000, 2400000, 40))
+
+		device = newMockDevice(state)
+		device.SetTuningOptions(TuningOptions{PPM: 30})
+		err := device.Configure(1090000000, 2400000, 40)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "set frequency correction")
+	})
+}
+
+// TestRTLSDRDevice_GainMode tests that manual gain switches the tuner out of
+// its AGC before the gain is set, and that gain 0 selects the AGC
+func TestRTLSDRDevice_GainMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		gain     int
+		expected []string
+	}{
+		{name: "Manual", gain: 40, expected: []string{"manual=true", "gain=400"}},
+		{name: "Auto", gain: 0, expected: []string{"manual=false"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &mockSDRState{}
+			device := newMockDevice(state)
+			require.NoError(t, device.Configure(1090000000, 2400000, tt.gain))
+			assert.Equal(t, tt.expected, state.gainCalls)
+
+			// A reopened device gets the same order
+			require.NoError(t, device.applySettings())
+			assert.Equal(t, append(tt.expected, tt.expected...), state.gainCalls)
+		})
+	}
+}
+
+// TestRTLSDRDevice_SetFrequency tests retuning an open device
+func TestRTLSDRDevice_SetFrequency(t *testing.T) {
+	state := &mockSDRState{}
+	device := newMockDevice(state)
+	assert.Error(t, device.SetFrequency(1090025000))
+
+	require.NoError(t, device.Configure(1090000000, 2400000, 40))
+	require.NoError(t, device.SetFrequency(1090025000))
+	assert.Equal(t, 1090025000, state.frequency)
+
+	// A reopened device stays on the new frequency
+	state.frequency = 0
+	require.NoError(t, device.applySettings())
+	assert.Equal(t, 1090025000, state.frequency)
+}
+
+// TestRTLSDRDevice_ConfigureBusy tests that a busy device is reported as such
+func TestRTLSDRDevice_ConfigureBusy(t *testing.T) {
+	device := newMockDevice(&mockSDRState{})
+	device.openFn = func(int) (sdrContext, error) {
+		return nil, errors.New("resource busy")
+	}
+
+	err := device.Configure(1090000000, 2400000, 40)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrDeviceBusy)
+	assert.NotErrorIs(t, err, ErrDeviceNotFound)
+}
+
 // TestRTLSDRDevice_ParameterValidation tests parameter validation
 func TestRTLSDRDevice_ParameterValidation(t *testing.T) {
 	tests := []struct {
@@ -263,6 +678,25 @@ func TestRTLSDRDevice_StateTransitions(t *testing.T) {
 	assert.False(t, device.isOpen)
 }
 
+// TestWriteDeviceTable tests the device table for a synthetic device list
+func TestWriteDeviceTable(t *testing.T) {
+	devices := []DeviceInfo{
+		{Index: 0, Name: "Generic RTL2832U OEM", Manufacturer: "Realtek", Product: "RTL2838UHIDIR", Serial: "00000001"},
+		{Index: 1, Name: "Generic RTL2832U OEM"},
+	}
+
+	var out strings.Builder
+	require.NoError(t, WriteDeviceTable(&out, devices))
+	assert.Equal(t, ""+
+		"INDEX  NAME                  MANUFACTURER  PRODUCT        SERIAL\n"+
+		"0      Generic RTL2832U OEM  Realtek       RTL2838UHIDIR  00000001\n"+
+		"1      Generic RTL2832U OEM  -             -              -\n", out.String())
+
+	out.Reset()
+	require.NoError(t, WriteDeviceTable(&out, nil))
+	assert.Contains(t, out.String(), "No RTL-SDR devices found")
+}
+
 // Benchmark tests for performance
 func BenchmarkNewRTLSDRDevice(b *testing.B) {
 	b.ResetTimer()
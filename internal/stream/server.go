@@ -0,0 +1,258 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultClientQueueSize is how many lines may wait for a slow client before
+// new lines to it are dropped
+const DefaultClientQueueSize = 1024
+
+// client is a connected reader of the broadcast stream
+type client struct {
+	conn  net.Conn
+	lines chan []byte
+}
+
+// Server broadcasts output lines to every client connected to its listener
+type Server struct {
+	network string
+	addr    string
+	logger  *logrus.Logger
+
+	listener net.Listener
+	mu       sync.Mutex
+	clients  map[*client]struct{}
+	closed   bool
+	dropped  uint64
+
+	// Most recent lines, replayed to each new client; oldest at replayNext
+	// once the ring is full
+	replay     [][]byte
+	replaySize int
+	replayNext int
+}
+
+// NewServer creates a broadcast server for a TCP address (host:port)
+func NewServer(addr string, logger *logrus.Logger) *Server {
+	return newServer("tcp", addr, logger)
+}
+
+// NewUnixServer creates a broadcast server on a Unix domain socket path
+func NewUnixServer(path string, logger *logrus.Logger) *Server {
+	return newServer("unix", path, logger)
+}
+
+func newServer(network, addr string, logger *logrus.Logger) *Server {
+	return &Server{
+		network: network,
+		addr:    addr,
+		logger:  logger,
+		clients: make(map[*client]struct{}),
+	}
+}
+
+// SetReplay keeps the last n broadcast lines and sends them to each client
+// as it connects, so it starts with recent context; 0 disables the replay.
+// It must be called before the server starts.
+func (s *Server) SetReplay(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replaySize = n
+	s.replay = nil
+	s.replayNext = 0
+}
+
+// Listen binds the server's address so bind errors surface before serving.
+// A stale Unix socket left by an earlier run is removed first.
+func (s *Server) Listen() error {
+	if s.network == "unix" {
+		if err := removeStaleSocket(s.addr); err != nil {
+			return err
+		}
+	}
+
+	listener, err := net.Listen(s.network, s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	s.listener = listener
+	return nil
+}
+
+// Start accepts clients until ctx is cancelled, binding first if Listen was
+// not called. The Unix socket file is removed on return.
+func (s *Server) Start(ctx context.Context) error {
+	if s.listener == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.listener.Close()
+	}()
+	defer s.closeClients()
+
+	s.logger.WithFields(logrus.Fields{
+		"network": s.network,
+		"addr":    s.listener.Addr().String(),
+	}).Info("Stream server listening")
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		s.addClient(conn)
+	}
+}
+
+// Broadcast queues a line for every connected client without blocking; a
+// client whose queue is full misses the line
+func (s *Server) Broadcast(line []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remember(line)
+	for c := range s.clients {
+		select {
+		case c.lines <- line:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// remember adds a line to the replay ring. The caller must hold mu.
+func (s *Server) remember(line []byte) {
+	if s.replaySize <= 0 {
+		return
+	}
+	if len(s.replay) < s.replaySize {
+		s.replay = append(s.replay, line)
+		return
+	}
+	s.replay[s.replayNext] = line
+	s.replayNext = (s.replayNext + 1) % s.replaySize
+}
+
+// Write broadcasts p as one line, so the server can be used as an io.Writer.
+// It never fails; slow clients miss the line instead.
+func (s *Server) Write(p []byte) (int, error) {
+	s.Broadcast(p)
+	return len(p), nil
+}
+
+// ClientCount returns the number of connected clients
+func (s *Server) ClientCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// GetDropped returns the number of lines dropped for slow clients
+func (s *Server) GetDropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// addClient registers a connection, queues the replayed lines for it, oldest
+// first, and starts writing queued lines to it
+func (s *Server) addClient(conn net.Conn) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	c := &client{
+		conn:  conn,
+		lines: make(chan []byte, DefaultClientQueueSize+len(s.replay)),
+	}
+	for i := range s.replay {
+		c.lines <- s.replay[(s.replayNext+i)%len(s.replay)]
+	}
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+
+	s.logger.WithField("remote", conn.RemoteAddr().String()).Debug("Stream client connected")
+
+	go s.writeClient(c)
+}
+
+// writeClient sends queued lines to one client until it disconnects or the
+// server closes its queue
+func (s *Server) writeClient(c *client) {
+	defer c.conn.Close()
+
+	for line := range c.lines {
+		if _, err := c.conn.Write(line); err != nil {
+			s.removeClient(c)
+			s.logger.WithError(err).Debug("Stream client disconnected")
+			return
+		}
+	}
+}
+
+// removeClient unregisters a client and closes its queue
+func (s *Server) removeClient(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		close(c.lines)
+	}
+}
+
+// closeClients disconnects every client and removes the Unix socket file
+func (s *Server) closeClients() {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.clients {
+		delete(s.clients, c)
+		close(c.lines)
+	}
+	s.mu.Unlock()
+
+	if s.network == "unix" {
+		os.Remove(s.addr)
+	}
+}
+
+// removeStaleSocket deletes a socket file nothing is listening on. Any other
+// file at the path is left alone and reported.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat socket %s: %w", path, err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("refusing to replace %s: not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s is in use by another process", path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+	}
+	return nil
+}
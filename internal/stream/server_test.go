@@ -0,0 +1,177 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLogger returns a logger that discards output
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// startServer starts s in the background and returns a function that stops it
+func startServer(t *testing.T, s *Server) func() {
+	t.Helper()
+	require.NoError(t, s.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Start(ctx) }()
+
+	return func() {
+		cancel()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("server did not stop")
+		}
+	}
+}
+
+// waitForClients waits until n clients are registered
+func waitForClients(t *testing.T, s *Server, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return s.ClientCount() == n }, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestUnixServer tests reading a broadcast line over a Unix domain socket
+func TestUnixServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go1090.sock")
+	server := NewUnixServer(path, newTestLogger())
+	stop := startServer(t, server)
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitForClients(t, server, 1)
+
+	line := "MSG,3,1,1,4840D6,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,,35000,,,52.25720,3.91937,,,0,0,0,0\n"
+	server.Broadcast([]byte(line))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	received, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, line, received)
+
+	// The socket file is removed on shutdown
+	stop()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// TestUnixServer_StaleSocket tests that a leftover socket file is replaced
+// but a live socket or a regular file is not
+func TestUnixServer_StaleSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	// Leave a socket file behind without anything listening
+	stale := filepath.Join(dir, "stale.sock")
+	listener, err := net.Listen("unix", stale)
+	require.NoError(t, err)
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+
+	server := NewUnixServer(stale, newTestLogger())
+	require.NoError(t, server.Listen())
+	defer server.listener.Close()
+
+	// A second server must not steal the live socket
+	err = NewUnixServer(stale, newTestLogger()).Listen()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "in use")
+
+	// A regular file is never removed
+	regular := filepath.Join(dir, "regular")
+	require.NoError(t, os.WriteFile(regular, []byte("data"), 0o644))
+	err = NewUnixServer(regular, newTestLogger()).Listen()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a socket")
+}
+
+// TestServer_Broadcast tests TCP clients and removal of disconnected clients
+func TestServer_Broadcast(t *testing.T) {
+	server := NewServer("127.0.0.1:0", newTestLogger())
+	stop := startServer(t, server)
+	defer stop()
+
+	first, err := net.Dial("tcp", server.listener.Addr().String())
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := net.Dial("tcp", server.listener.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+	waitForClients(t, server, 2)
+
+	server.Broadcast([]byte("hello\n"))
+	for _, conn := range []net.Conn{first, second} {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		received, err := bufio.NewReader(conn).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "hello\n", received)
+	}
+
+	// A client that disconnects is dropped on the next write
+	first.Close()
+	assert.Eventually(t, func() bool {
+		server.Broadcast([]byte("ping\n"))
+		return server.ClientCount() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestServer_Replay tests that a client connecting after some traffic first
+// receives the most recent lines, oldest first, and then the live stream
+func TestServer_Replay(t *testing.T) {
+	server := NewServer("127.0.0.1:0", newTestLogger())
+	server.SetReplay(3)
+	stop := startServer(t, server)
+	defer stop()
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		server.Broadcast([]byte(line))
+	}
+
+	conn, err := net.Dial("tcp", server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	waitForClients(t, server, 1)
+	server.Broadcast([]byte("six\n"))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	reader := bufio.NewReader(conn)
+	for _, want := range []string{"three\n", "four\n", "five\n", "six\n"} {
+		received, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, want, received)
+	}
+
+	// Without a replay buffer a new client gets only live lines
+	live := NewServer("127.0.0.1:0", newTestLogger())
+	stopLive := startServer(t, live)
+	defer stopLive()
+	live.Broadcast([]byte("missed\n"))
+
+	conn, err = net.Dial("tcp", live.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	waitForClients(t, live, 1)
+	live.Broadcast([]byte("live\n"))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	received, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "live\n", received)
+}
@@ -0,0 +1,127 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Receiver is the dump1090 receiver.json document
+type Receiver struct {
+	Version string   `json:"version"`
+	Refresh int      `json:"refresh"` // Suggested polling interval in milliseconds
+	History int      `json:"history"`
+	Lat     *float64 `json:"lat,omitempty"`
+	Lon     *float64 `json:"lon,omitempty"`
+}
+
+// LocalStats holds the receiver counters for one stats period, following
+// dump1090's "local" block
+type LocalStats struct {
+	SamplesProcessed uint64   `json:"samples_processed"`
+	SamplesDropped   uint64   `json:"samples_dropped"`
+	ModeAC           uint64   `json:"modeac"`
+	ModeS            uint64   `json:"modes"`
+	Bad              uint64   `json:"bad"`
+	UnknownICAO      uint64   `json:"unknown_icao"`
+	Accepted         []uint64 `json:"accepted"`        // Indexed by number of corrected bits
+	Noise            float64  `json:"noise,omitempty"` // Channel noise floor in dBFS at the end of the period
+}
+
+// StatsPeriod is one period of the dump1090 stats.json document
+type StatsPeriod struct {
+	Start    float64    `json:"start"` // Unix seconds
+	End      float64    `json:"end"`
+	Local    LocalStats `json:"local"`
+	Messages uint64     `json:"messages"`
+}
+
+// Stats is the dump1090 stats.json document
+type Stats struct {
+	Latest    StatsPeriod `json:"latest"`
+	Last1Min  StatsPeriod `json:"last1min"`
+	Last5Min  StatsPeriod `json:"last5min"`
+	Last15Min StatsPeriod `json:"last15min"`
+	Total     StatsPeriod `json:"total"`
+
+	// Most frequent message types since start, e.g. "DF17/TC11". Not part
+	// of the dump1090 document.
+	MessageTypes map[string]uint64 `json:"message_types,omitempty"`
+}
+
+// JSONHandler serves the document returned by get as uncached JSON
+func JSONHandler[T any](get func() T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.Marshal(get())
+		if err != nil {
+			http.Error(w, "failed to encode JSON", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+	})
+}
+
+// Aircraft is one entry of the dump1090 aircraft.json document
+type Aircraft struct {
+	Hex      string   `json:"hex"`
+	Flight   string   `json:"flight,omitempty"`
+	AltBaro  *int     `json:"alt_baro,omitempty"` // Feet
+	GS       *int     `json:"gs,omitempty"`       // Knots
+	Track    *float64 `json:"track,omitempty"`
+	Lat      *float64 `json:"lat,omitempty"`
+	Lon      *float64 `json:"lon,omitempty"`
+	SeenPos  *float64 `json:"seen_pos,omitempty"` // Seconds since the last position
+	Seen     float64  `json:"seen"`               // Seconds since the last message
+	Messages uint64   `json:"messages"`
+}
+
+// AircraftList is the dump1090 aircraft.json document
+type AircraftList struct {
+	Now      float64    `json:"now"` // Unix seconds
+	Messages uint64     `json:"messages"`
+	Aircraft []Aircraft `json:"aircraft"`
+}
+
+// TrackPoint is one position of a track document
+type TrackPoint struct {
+	Time float64 `json:"time"` // Unix seconds
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	Alt  *int    `json:"alt,omitempty"` // Feet
+}
+
+// Track is the position history of one aircraft, served as
+// /data/track/<icao>.json. Not part of the dump1090 documents.
+type Track struct {
+	Hex    string       `json:"hex"`
+	Points []TrackPoint `json:"points"`
+}
+
+// TrackHandler serves the track of the aircraft named by the last path
+// element, "<icao>.json" with a hex address, as uncached JSON. Unknown
+// aircraft and malformed names are not found.
+func TrackHandler(get func(icao uint32) (Track, bool)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := strings.CutSuffix(path.Base(r.URL.Path), ".json")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		icao, err := strconv.ParseUint(name, 16, 24)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		track, ok := get(uint32(icao))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		JSONHandler(func() Track { return track }).ServeHTTP(w, r)
+	})
+}
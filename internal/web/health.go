@@ -0,0 +1,32 @@
+package web
+
+import (
+	"net/http"
+)
+
+// ReadinessFunc reports whether the receiver is ready to serve, with a reason when it is not
+type ReadinessFunc func() (bool, string)
+
+// HealthzHandler reports that the process is alive
+func HealthzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadyzHandler reports readiness, answering 503 with the reason when not ready
+func ReadyzHandler(ready ReadinessFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		ok, reason := ready()
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(reason + "\n"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ready\n"))
+	})
+}
@@ -0,0 +1,156 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHealthzHandler tests the liveness endpoint
+func TestHealthzHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HealthzHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok\n", rec.Body.String())
+}
+
+// TestReadyzHandler tests the readiness endpoint in healthy and stalled states
+func TestReadyzHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		ready        bool
+		reason       string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Healthy",
+			ready:        true,
+			expectedCode: http.StatusOK,
+			expectedBody: "ready\n",
+		},
+		{
+			name:         "Stalled",
+			ready:        false,
+			reason:       "no preambles or messages for 1m0s",
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: "no preambles or messages for 1m0s\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ReadyzHandler(func() (bool, string) { return tt.ready, tt.reason })
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+// TestTrackHandler tests addressing a track by the hex ICAO in the path
+func TestTrackHandler(t *testing.T) {
+	handler := TrackHandler(func(icao uint32) (Track, bool) {
+		if icao != 0x4840D6 {
+			return Track{}, false
+		}
+		return Track{Hex: "4840d6", Points: []TrackPoint{{Time: 1704110400, Lat: 52.25, Lon: 3.92}}}, true
+	})
+
+	tests := []struct {
+		path         string
+		expectedCode int
+	}{
+		{path: "/data/track/4840d6.json", expectedCode: http.StatusOK},
+		{path: "/data/track/4840D6.json", expectedCode: http.StatusOK},
+		{path: "/data/track/abcdef.json", expectedCode: http.StatusNotFound},
+		{path: "/data/track/4840d6", expectedCode: http.StatusNotFound},
+		{path: "/data/track/klm1023.json", expectedCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode == http.StatusOK {
+				assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+				assert.JSONEq(t, `{"hex":"4840d6","points":[{"time":1704110400,"lat":52.25,"lon":3.92}]}`, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestUIHandler tests that the status page is served as HTML at the root only
+func TestUIHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	UIHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "<!DOCTYPE html>")
+	assert.Contains(t, rec.Body.String(), "data/aircraft.json")
+
+	rec = httptest.NewRecorder()
+	UIHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// TestServer_Start tests serving registered handlers until the context is cancelled
+func TestServer_Start(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	server := NewServer("127.0.0.1:0", logger)
+	server.Handle("/healthz", HealthzHandler())
+
+	// Exercise the mux directly; the listener address is chosen by the OS
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Start(ctx) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+// TestServer_Listen tests binding to localhost and to an address not on this host
+func TestServer_Listen(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	local := NewServer("127.0.0.1:0", logger)
+	require.NoError(t, local.Listen())
+	assert.Equal(t, "127.0.0.1", local.listener.Addr().(*net.TCPAddr).IP.String())
+	local.listener.Close()
+
+	// 192.0.2.1 is TEST-NET-1 and never assigned to a local interface
+	remote := NewServer("192.0.2.1:0", logger)
+	err := remote.Listen()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to listen on 192.0.2.1:0")
+}
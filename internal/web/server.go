@@ -0,0 +1,84 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take once the server stops
+const shutdownTimeout = 5 * time.Second
+
+// Server serves health and data endpoints over HTTP
+type Server struct {
+	addr     string
+	logger   *logrus.Logger
+	mux      *http.ServeMux
+	listener net.Listener
+}
+
+// NewServer creates an HTTP server listening on addr (host:port)
+func NewServer(addr string, logger *logrus.Logger) *Server {
+	return &Server{
+		addr:   addr,
+		logger: logger,
+		mux:    http.NewServeMux(),
+	}
+}
+
+// Handle registers a handler for the given pattern
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Handler returns the server's request multiplexer
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Listen binds the server's address so bind errors surface before serving
+func (s *Server) Listen() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	s.listener = listener
+	return nil
+}
+
+// Start serves requests until ctx is cancelled, binding first if Listen was
+// not called
+func (s *Server) Start(ctx context.Context) error {
+	if s.listener == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+	listener := s.listener
+
+	server := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			s.logger.WithError(err).Warn("HTTP server shutdown failed")
+		}
+	}()
+
+	s.logger.WithField("addr", listener.Addr().String()).Info("HTTP server listening")
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("HTTP server failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package web
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// indexHTML is the status page: a table of current aircraft, polled from
+// /data/aircraft.json by a script in the page itself
+//
+//go:embed ui/index.html
+var indexHTML []byte
+
+// UIHandler serves the status page at the root path. Every other path that
+// reaches it is not found, since it is registered for "/".
+func UIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(indexHTML)
+	})
+}